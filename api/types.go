@@ -5,7 +5,10 @@ import (
 	"blockbook/common"
 	"blockbook/db"
 	"encoding/json"
+	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -129,11 +132,17 @@ type TokenTransfer struct {
 
 // EthereumSpecific contains ethereum specific transaction data
 type EthereumSpecific struct {
-	Status   int      `json:"status"` // 1 OK, 0 Fail, -1 pending
-	Nonce    uint64   `json:"nonce"`
-	GasLimit *big.Int `json:"gaslimit"`
-	GasUsed  *big.Int `json:"gasused"`
-	GasPrice *Amount  `json:"gasprice"`
+	Status               int                             `json:"status"` // 1 OK, 0 Fail, -1 pending
+	Nonce                uint64                          `json:"nonce"`
+	GasLimit             *big.Int                        `json:"gaslimit"`
+	GasUsed              *big.Int                        `json:"gasused"`
+	GasPrice             *Amount                         `json:"gasprice"`
+	Type                 int                             `json:"type,omitempty"` // 0 legacy, 1 EIP-2930, 2 EIP-1559
+	MaxPriorityFeePerGas *Amount                         `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         *Amount                         `json:"maxFeePerGas,omitempty"`
+	BaseFeePerGas        *Amount                         `json:"baseFeePerGas,omitempty"`
+	EffectiveGasPrice    *Amount                         `json:"effectiveGasPrice,omitempty"`
+	ParsedData           *bchain.EthereumParsedInputData `json:"parsedData,omitempty"`
 }
 
 // Tx holds information about a transaction
@@ -181,6 +190,55 @@ type AddressFilter struct {
 	Contract   string
 	FromHeight uint32
 	ToHeight   uint32
+	// Cursor replaces the paging of the transactions, if set
+	Cursor *TxCursor
+}
+
+func (f *AddressFilter) matches(vout int32, isOutput bool) bool {
+	return f.Vout == AddressFilterVoutOff ||
+		(f.Vout == AddressFilterVoutInputs && !isOutput) ||
+		(f.Vout == AddressFilterVoutOutputs && isOutput) ||
+		(vout == int32(f.Vout))
+}
+
+// TxCursorStart is the cursor of the beginning of the transaction history, including the mempool transactions
+const TxCursorStart = "tip"
+
+// TxCursor is a stable position in the transaction history of an address, which is not shifted by new transactions
+type TxCursor struct {
+	// Height of the block with the next transaction
+	Height uint32
+	// Index is the number of transactions of the address in the block at Height already returned
+	Index int
+}
+
+// ParseTxCursor parses the cursor in the format height:index or TxCursorStart
+func ParseTxCursor(s string) (*TxCursor, error) {
+	if s == TxCursorStart {
+		return &TxCursor{Height: ^uint32(0)}, nil
+	}
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, NewAPIError("Invalid cursor", true)
+	}
+	height, err := strconv.ParseUint(s[:i], 10, 32)
+	if err != nil {
+		return nil, NewAPIError("Invalid cursor", true)
+	}
+	index, err := strconv.Atoi(s[i+1:])
+	if err != nil || index < 0 {
+		return nil, NewAPIError("Invalid cursor", true)
+	}
+	return &TxCursor{Height: uint32(height), Index: index}, nil
+}
+
+// IsStart returns true if the cursor is at the beginning of the transaction history
+func (c *TxCursor) IsStart() bool {
+	return c.Height == ^uint32(0) && c.Index == 0
+}
+
+func (c *TxCursor) String() string {
+	return fmt.Sprintf("%d:%d", c.Height, c.Index)
 }
 
 // Address holds information about address and its transactions
@@ -198,9 +256,44 @@ type Address struct {
 	Nonce                   string                `json:"nonce,omitempty"`
 	Erc20Contract           *bchain.Erc20Contract `json:"erc20contract,omitempty"`
 	Erc20Tokens             []Erc20Token          `json:"erc20tokens,omitempty"`
+	NextCursor              string                `json:"nextCursor,omitempty"`
+	HistoryTruncated        bool                  `json:"historyTruncated,omitempty"`
 	Filter                  string                `json:"-"`
 }
 
+// TokenHolder contains an address holding an ERC20 token and its balance of the token
+type TokenHolder struct {
+	Address    string  `json:"address"`
+	BalanceSat *Amount `json:"balance"`
+}
+
+// ContractHolders contains holders of an ERC20 contract sorted by balance
+type ContractHolders struct {
+	Paging
+	Erc20Contract *bchain.Erc20Contract `json:"erc20contract"`
+	TotalHolders  int                   `json:"totalHolders"`
+	Holders       []TokenHolder         `json:"holders"`
+}
+
+// LogEntry contains a receipt log found in the log index
+type LogEntry struct {
+	Txid     string   `json:"txid"`
+	LogIndex int      `json:"logIndex"`
+	Height   uint32   `json:"blockHeight"`
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics,omitempty"`
+	Data     string   `json:"data,omitempty"`
+}
+
+// Logs contains receipt logs of an address ordered by height, tx position and log index,
+// TotalPages is -1 if there are more logs after the page,
+// HistoryTruncated is set if the logs start at the height from which the log index is complete
+type Logs struct {
+	Paging
+	Logs             []LogEntry `json:"logs"`
+	HistoryTruncated bool       `json:"historyTruncated,omitempty"`
+}
+
 // AddressUtxo holds information about address and its transactions
 type AddressUtxo struct {
 	Txid          string  `json:"txid"`
@@ -243,9 +336,39 @@ type BlockbookInfo struct {
 	DbSize            int64                        `json:"dbSize"`
 	DbSizeFromColumns int64                        `json:"dbSizeFromColumns,omitempty"`
 	DbColumns         []common.InternalStateColumn `json:"dbColumns,omitempty"`
+	PruneDepth        uint32                       `json:"pruneDepth,omitempty"`
+	PrunedHeight      uint32                       `json:"prunedHeight,omitempty"`
+	DbTuning          string                       `json:"dbTuning,omitempty"`
 	About             string                       `json:"about"`
 }
 
+// AddrDescTx is a transaction of an address descriptor, unconfirmed transaction has Height 0
+type AddrDescTx struct {
+	Txid   string
+	Height uint32
+}
+
+// AddrDescHistory contains transactions and balances of an address descriptor
+type AddrDescHistory struct {
+	Confirmed             []AddrDescTx
+	Unconfirmed           []AddrDescTx
+	BalanceSat            big.Int
+	UnconfirmedBalanceSat big.Int
+}
+
+// AddressExportTx is a transaction in the export of the address history
+type AddressExportTx struct {
+	Txid        string  `json:"txid"`
+	Blockhash   string  `json:"blockHash"`
+	Blockheight int     `json:"blockHeight"`
+	Blocktime   int64   `json:"blockTime"`
+	ReceivedSat *Amount `json:"received"`
+	SentSat     *Amount `json:"sent"`
+	FeesSat     *Amount `json:"fees"`
+	NetValueSat *Amount `json:"netValue"`
+	BalanceSat  *Amount `json:"balance"`
+}
+
 // SystemInfo contains information about the running blockbook and backend instance
 type SystemInfo struct {
 	Blockbook *BlockbookInfo    `json:"blockbook"`
@@ -49,3 +49,53 @@ func TestAmount_MarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTxCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    *TxCursor
+		wantErr bool
+	}{
+		{
+			name: "start",
+			s:    "tip",
+			want: &TxCursor{Height: 4294967295},
+		},
+		{
+			name: "height and index",
+			s:    "225494:3",
+			want: &TxCursor{Height: 225494, Index: 3},
+		},
+		{
+			name:    "missing index",
+			s:       "225494",
+			wantErr: true,
+		},
+		{
+			name:    "negative index",
+			s:       "225494:-1",
+			wantErr: true,
+		},
+		{
+			name:    "height out of range",
+			s:       "4294967296:0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTxCursor(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTxCursor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTxCursor() = %+v, want %+v", got, tt.want)
+			}
+			if got != nil && tt.s != TxCursorStart && got.String() != tt.s {
+				t.Errorf("TxCursor.String() = %v, want %v", got.String(), tt.s)
+			}
+		})
+	}
+}
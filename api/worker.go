@@ -6,10 +6,14 @@ import (
 	"blockbook/common"
 	"blockbook/db"
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -18,16 +22,24 @@ import (
 
 // Worker is handle to api worker
 type Worker struct {
-	db          *db.RocksDB
+	db          db.IndexStore
 	txCache     *db.TxCache
 	chain       bchain.BlockChain
 	chainParser bchain.BlockChainParser
 	chainType   bchain.ChainType
 	is          *common.InternalState
+	holdersMux  sync.Mutex
+	holders     map[string]*contractHoldersCache
+}
+
+// contractHoldersCache holds sorted holders of a contract computed at the given block height
+type contractHoldersCache struct {
+	height  uint32
+	holders []TokenHolder
 }
 
 // NewWorker creates new api worker
-func NewWorker(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, is *common.InternalState) (*Worker, error) {
+func NewWorker(db db.IndexStore, chain bchain.BlockChain, txCache *db.TxCache, is *common.InternalState) (*Worker, error) {
 	w := &Worker{
 		db:          db,
 		txCache:     txCache,
@@ -35,6 +47,7 @@ func NewWorker(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, is
 		chainParser: chain.GetChainParser(),
 		chainType:   chain.GetChainParser().GetChainType(),
 		is:          is,
+		holders:     make(map[string]*contractHoldersCache),
 	}
 	return w, nil
 }
@@ -95,6 +108,9 @@ func (w *Worker) GetSpendingTxid(txid string, n int) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if ph := w.is.GetPrunedHeight(); ph > 0 && tx.Vout[n].Spent && tx.Vout[n].SpentTxID == "" {
+		return "", NewAPIError(fmt.Sprintf("Spending transaction not found, the index is pruned up to block %v", ph), true)
+	}
 	glog.Info("GetSpendingTxid ", txid, " ", n, " finished in ", time.Since(start))
 	return tx.Vout[n].SpentTxID, nil
 }
@@ -147,8 +163,8 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 					return nil, errors.Annotatef(err, "GetTxAddresses %v", bchainVin.Txid)
 				}
 				if tas == nil {
-					// mempool transactions are not in TxAddresses but confirmed should be there, log a problem
-					if bchainTx.Confirmations > 0 {
+					// mempool transactions are not in TxAddresses but confirmed should be there unless pruned, log a problem
+					if bchainTx.Confirmations > 0 && w.is.GetPrunedHeight() == 0 {
 						glog.Warning("DB inconsistency:  tx ", bchainVin.Txid, ": not found in txAddresses")
 					}
 					// try to load from backend
@@ -208,6 +224,9 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 					glog.Errorf("setSpendingTxToVout error %v, %v, output %v", err, vout.AddrDesc, vout.N)
 				}
 			}
+		} else if w.chainType == bchain.ChainBitcoinType && bchainTx.Confirmations > 0 && height <= w.is.GetPrunedHeight() {
+			// only fully spent transactions are pruned from the index
+			vout.Spent = len(vout.AddrDesc) > 0
 		}
 	}
 	if w.chainType == bchain.ChainBitcoinType {
@@ -251,17 +270,28 @@ func (w *Worker) GetTransactionFromBchainTx(bchainTx *bchain.Tx, height uint32,
 		ethTxData := eth.GetEthereumTxData(bchainTx)
 		// mempool txs do not have fees yet
 		if ethTxData.GasUsed != nil {
-			feesSat.Mul(ethTxData.GasPrice, ethTxData.GasUsed)
+			// EIP-1559 transactions pay the effective gas price, which can be lower than the max fee
+			if ethTxData.EffectiveGasPrice != nil {
+				feesSat.Mul(ethTxData.EffectiveGasPrice, ethTxData.GasUsed)
+			} else {
+				feesSat.Mul(ethTxData.GasPrice, ethTxData.GasUsed)
+			}
 		}
 		if len(bchainTx.Vout) > 0 {
 			valOutSat = bchainTx.Vout[0].ValueSat
 		}
 		ethSpecific = &EthereumSpecific{
-			GasLimit: ethTxData.GasLimit,
-			GasPrice: (*Amount)(ethTxData.GasPrice),
-			GasUsed:  ethTxData.GasUsed,
-			Nonce:    ethTxData.Nonce,
-			Status:   ethTxData.Status,
+			GasLimit:             ethTxData.GasLimit,
+			GasPrice:             (*Amount)(ethTxData.GasPrice),
+			GasUsed:              ethTxData.GasUsed,
+			Nonce:                ethTxData.Nonce,
+			Status:               ethTxData.Status,
+			Type:                 ethTxData.Type,
+			MaxPriorityFeePerGas: (*Amount)(ethTxData.MaxPriorityFeePerGas),
+			MaxFeePerGas:         (*Amount)(ethTxData.MaxFeePerGas),
+			BaseFeePerGas:        (*Amount)(ethTxData.BaseFeePerGas),
+			EffectiveGasPrice:    (*Amount)(ethTxData.EffectiveGasPrice),
+			ParsedData:           eth.ParseInputData(ethTxData.Data),
 		}
 	}
 	// for now do not return size, we would have to compute vsize of segwit transactions
@@ -300,10 +330,7 @@ func (w *Worker) getAddressTxids(addrDesc bchain.AddressDescriptor, mempool bool
 	var err error
 	txids := make([]string, 0, 4)
 	addFilteredTxid := func(txid string, vout int32, isOutput bool) error {
-		if filter.Vout == AddressFilterVoutOff ||
-			(filter.Vout == AddressFilterVoutInputs && !isOutput) ||
-			(filter.Vout == AddressFilterVoutOutputs && isOutput) ||
-			(vout == int32(filter.Vout)) {
+		if filter.matches(vout, isOutput) {
 			txids = append(txids, txid)
 		}
 		return nil
@@ -335,6 +362,51 @@ func (w *Worker) getAddressTxids(addrDesc bchain.AddressDescriptor, mempool bool
 	return txids, nil
 }
 
+// getAddressTxidsFromCursor returns up to count unique txids of the confirmed transactions of the address starting at filter.Cursor,
+// the newest first, and the cursor of the following transactions or nil if there are no more transactions
+func (w *Worker) getAddressTxidsFromCursor(addrDesc bchain.AddressDescriptor, filter *AddressFilter, count int) ([]string, *TxCursor, error) {
+	if count <= 0 {
+		return nil, nil, NewAPIError("Invalid page size", true)
+	}
+	cursor := filter.Cursor
+	higher := cursor.Height
+	if filter.ToHeight > 0 && filter.ToHeight < higher {
+		higher = filter.ToHeight
+	}
+	txids := make([]string, 0, count)
+	var next *TxCursor
+	// the same transaction can be passed multiple times only within one block
+	var blockHeight uint32
+	var blockTxids map[string]struct{}
+	err := w.db.GetAddrDescTransactionsReverse(addrDesc, filter.FromHeight, higher, func(txid string, height uint32, vout int32, isOutput bool) error {
+		if !filter.matches(vout, isOutput) {
+			return nil
+		}
+		if blockTxids == nil || height != blockHeight {
+			blockHeight = height
+			blockTxids = make(map[string]struct{})
+		}
+		if _, found := blockTxids[txid]; found {
+			return nil
+		}
+		index := len(blockTxids)
+		blockTxids[txid] = struct{}{}
+		if height == cursor.Height && index < cursor.Index {
+			return nil
+		}
+		if len(txids) == count {
+			next = &TxCursor{Height: height, Index: index}
+			return &db.StopIteration{}
+		}
+		txids = append(txids, txid)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return txids, next, nil
+}
+
 func (t *Tx) getAddrVoutValue(addrDesc bchain.AddressDescriptor) *big.Int {
 	var val big.Int
 	for _, vout := range t.Vout {
@@ -499,10 +571,9 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 			}
 			// do not read contract balances etc in case of Basic option
 			if option != Basic {
-				b, err = w.chain.EthereumTypeGetErc20ContractBalance(addrDesc, c.Contract)
+				b, err = w.getErc20ContractBalance(addrDesc, c.Contract)
 				if err != nil {
-					// return nil, nil, nil, errors.Annotatef(err, "EthereumTypeGetErc20ContractBalance %v %v", addrDesc, c.Contract)
-					glog.Warningf("EthereumTypeGetErc20ContractBalance addr %v, contract %v, %v", addrDesc, c.Contract, err)
+					return nil, nil, nil, 0, err
 				}
 			} else {
 				b = nil
@@ -527,6 +598,195 @@ func (w *Worker) getEthereumTypeAddressBalances(addrDesc bchain.AddressDescripto
 	return ba, erc20t, ci, n, nil
 }
 
+// getErc20ContractBalance returns the token balance from the index,
+// the backend is asked only if the balance is not tracked by the index
+func (w *Worker) getErc20ContractBalance(addrDesc, contract bchain.AddressDescriptor) (*big.Int, error) {
+	b, err := w.db.GetAddrDescTokenBalance(addrDesc, contract)
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetAddrDescTokenBalance %v %v", addrDesc, contract)
+	}
+	if b == nil {
+		b, err = w.chain.EthereumTypeGetErc20ContractBalance(addrDesc, contract)
+		if err != nil {
+			glog.Warningf("EthereumTypeGetErc20ContractBalance addr %v, contract %v, %v", addrDesc, contract, err)
+			return nil, nil
+		}
+	}
+	return b, nil
+}
+
+// getContractHolders returns holders of the contract with nonzero balance, sorted by balance descending
+// the result is cached and recomputed only when a new block is connected
+func (w *Worker) getContractHolders(contract bchain.AddressDescriptor) ([]TokenHolder, error) {
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBestBlock")
+	}
+	key := string(contract)
+	w.holdersMux.Lock()
+	c, found := w.holders[key]
+	w.holdersMux.Unlock()
+	if found && c.height == bestheight {
+		return c.holders, nil
+	}
+	holders := make([]TokenHolder, 0)
+	err = w.db.GetContractHolders(contract, func(addrDesc bchain.AddressDescriptor) error {
+		b, err := w.getErc20ContractBalance(addrDesc, contract)
+		if err != nil {
+			return err
+		}
+		if b == nil || b.Sign() == 0 {
+			return nil
+		}
+		var address string
+		addresses, _, _ := w.chainParser.GetAddressesFromAddrDesc(addrDesc)
+		if len(addresses) > 0 {
+			address = addresses[0]
+		}
+		holders = append(holders, TokenHolder{
+			Address:    address,
+			BalanceSat: (*Amount)(b),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetContractHolders %v", contract)
+	}
+	sort.SliceStable(holders, func(i, j int) bool {
+		return (*big.Int)(holders[i].BalanceSat).Cmp((*big.Int)(holders[j].BalanceSat)) > 0
+	})
+	w.holdersMux.Lock()
+	// drop the holders computed for older blocks
+	for k, v := range w.holders {
+		if v.height != bestheight {
+			delete(w.holders, k)
+		}
+	}
+	w.holders[key] = &contractHoldersCache{height: bestheight, holders: holders}
+	w.holdersMux.Unlock()
+	return holders, nil
+}
+
+// GetContractHolders returns holders of an ERC20 contract sorted by balance
+func (w *Worker) GetContractHolders(contract string, page int, holdersOnPage int) (*ContractHolders, error) {
+	start := time.Now()
+	if w.chainType != bchain.ChainEthereumType {
+		return nil, NewAPIError("Contract holders are supported only for EthereumType coins", true)
+	}
+	page--
+	if page < 0 {
+		page = 0
+	}
+	contractDesc, err := w.chainParser.GetAddrDescFromAddress(contract)
+	if err != nil {
+		return nil, NewAPIError(fmt.Sprintf("Invalid contract, %v", err), true)
+	}
+	ci, err := w.chain.EthereumTypeGetErc20ContractInfo(contractDesc)
+	if err != nil {
+		return nil, errors.Annotatef(err, "EthereumTypeGetErc20ContractInfo %v", contract)
+	}
+	if ci == nil {
+		return nil, NewAPIError(fmt.Sprintf("Contract %v is not an ERC20 contract", contract), true)
+	}
+	holders, err := w.getContractHolders(contractDesc)
+	if err != nil {
+		return nil, err
+	}
+	pg, from, to, _ := computePaging(len(holders), page, holdersOnPage)
+	r := &ContractHolders{
+		Paging:        pg,
+		Erc20Contract: ci,
+		TotalHolders:  len(holders),
+		Holders:       holders[from:to],
+	}
+	glog.Info("GetContractHolders ", contract, " finished in ", time.Since(start))
+	return r, nil
+}
+
+// GetLogs returns receipt logs emitted by the address, optionally filtered by topic0 and block range
+func (w *Worker) GetLogs(address string, topic0 string, from uint32, to uint32, page int, logsOnPage int) (*Logs, error) {
+	start := time.Now()
+	if w.chainType != bchain.ChainEthereumType {
+		return nil, NewAPIError("Logs are supported only for EthereumType coins", true)
+	}
+	if !w.db.LogIndexEnabled() {
+		return nil, NewAPIError("Log index is not enabled", true)
+	}
+	page--
+	if page < 0 {
+		page = 0
+	}
+	addrDesc, err := w.chainParser.GetAddrDescFromAddress(address)
+	if err != nil {
+		return nil, NewAPIError(fmt.Sprintf("Invalid address, %v", err), true)
+	}
+	var topic []byte
+	if topic0 != "" {
+		topic, err = hex.DecodeString(strings.TrimPrefix(topic0, "0x"))
+		if err != nil || len(topic) != 32 {
+			return nil, NewAPIError(fmt.Sprintf("Invalid topic0 %v", topic0), true)
+		}
+	}
+	// the index contains only the logs of the blocks connected since the index was enabled
+	var historyTruncated bool
+	if ih := w.is.GetLogIndexFromHeight(); from < ih {
+		if from > 0 {
+			return nil, NewAPIError(fmt.Sprintf("Logs are indexed from block %v, request logs from block %v", ih, ih), true)
+		}
+		from = ih
+		historyTruncated = true
+	}
+	if to == 0 {
+		to = ^uint32(0)
+	}
+	// the logs are read in the index order and the iteration stops after the requested page,
+	// therefore the total number of pages is known only on the last page
+	logs := make([]LogEntry, 0, logsOnPage)
+	skip := page * logsOnPage
+	more := false
+	if err = w.db.GetLogs(addrDesc, topic, from, to, func(txid string, logIndex int, height uint32) error {
+		if skip > 0 {
+			skip--
+			return nil
+		}
+		if len(logs) == logsOnPage {
+			more = true
+			return &db.StopIteration{}
+		}
+		logs = append(logs, LogEntry{Txid: txid, LogIndex: logIndex, Height: height})
+		return nil
+	}); err != nil {
+		return nil, errors.Annotatef(err, "GetLogs %v", address)
+	}
+	pg := Paging{
+		Page:        page + 1,
+		ItemsOnPage: logsOnPage,
+	}
+	if more {
+		pg.TotalPages = -1
+	} else if len(logs) > 0 || page == 0 {
+		pg.TotalPages = page + 1
+	}
+	for i := range logs {
+		l := &logs[i]
+		tx, _, err := w.txCache.GetTransaction(l.Txid)
+		if err != nil {
+			return nil, errors.Annotatef(err, "txCache.GetTransaction %v", l.Txid)
+		}
+		txLogs, err := w.chainParser.EthereumTypeGetLogsFromTx(tx)
+		if err != nil {
+			return nil, errors.Annotatef(err, "EthereumTypeGetLogsFromTx %v", l.Txid)
+		}
+		if l.LogIndex < len(txLogs) {
+			l.Address = txLogs[l.LogIndex].Address
+			l.Topics = txLogs[l.LogIndex].Topics
+			l.Data = txLogs[l.LogIndex].Data
+		}
+	}
+	glog.Info("GetLogs ", address, " finished in ", time.Since(start))
+	return &Logs{Paging: pg, Logs: logs, HistoryTruncated: historyTruncated}, nil
+}
+
 // GetAddress computes address value and gets transactions for given address
 func (w *Worker) GetAddress(address string, page int, txsOnPage int, option GetAddressOption, filter *AddressFilter) (*Address, error) {
 	start := time.Now()
@@ -549,6 +809,7 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option GetA
 		uBalSat                  big.Int
 		totalReceived, totalSent *big.Int
 		nonce                    string
+		nextCursor               string
 	)
 	if w.chainType == bchain.ChainEthereumType {
 		var n uint64
@@ -564,6 +825,19 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option GetA
 			return nil, NewAPIError(fmt.Sprintf("Address not found, %v", err), true)
 		}
 	}
+	// pruned index returns the history above the pruned height, unless the pruned blocks are requested explicitly
+	var historyTruncated bool
+	if option >= TxidHistory {
+		if ph := w.is.GetPrunedHeight(); ph > 0 && filter.FromHeight <= ph {
+			if filter.FromHeight > 0 {
+				return nil, NewAPIError(fmt.Sprintf("Transaction history up to block %v is pruned, request history from block %v", ph, ph+1), true)
+			}
+			f := *filter
+			f.FromHeight = ph + 1
+			filter = &f
+			historyTruncated = true
+		}
+	}
 	// get tx history if requested by option or check mempool if there are some transactions for a new address
 	if option >= TxidHistory || ba == nil {
 		// convert the address to the format defined by the parser
@@ -585,17 +859,36 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option GetA
 			page = 0
 		}
 		if option >= TxidHistory {
-			txc, err := w.getAddressTxids(addrDesc, false, filter)
-			if err != nil {
-				return nil, errors.Annotatef(err, "getAddressTxids %v false", addrDesc)
+			var txc []string
+			var from, to int
+			if filter.Cursor != nil {
+				var next *TxCursor
+				txc, next, err = w.getAddressTxidsFromCursor(addrDesc, filter, txsOnPage)
+				if err != nil {
+					return nil, errors.Annotatef(err, "getAddressTxidsFromCursor %v", addrDesc)
+				}
+				if next != nil {
+					nextCursor = next.String()
+				}
+				// mempool transactions are returned only at the start of the history
+				if filter.Cursor.IsStart() {
+					page = 0
+				} else {
+					page = 1
+				}
+				to = len(txc)
+			} else {
+				txc, err = w.getAddressTxids(addrDesc, false, filter)
+				if err != nil {
+					return nil, errors.Annotatef(err, "getAddressTxids %v false", addrDesc)
+				}
+				txc = UniqueTxidsInReverse(txc)
+				pg, from, to, page = computePaging(len(txc), page, txsOnPage)
 			}
-			txc = UniqueTxidsInReverse(txc)
 			bestheight, _, err := w.db.GetBestBlock()
 			if err != nil {
 				return nil, errors.Annotatef(err, "GetBestBlock")
 			}
-			var from, to int
-			pg, from, to, page = computePaging(len(txc), page, txsOnPage)
 			if option == TxidHistory {
 				txids = make([]string, len(txm)+to-from)
 			} else {
@@ -680,6 +973,8 @@ func (w *Worker) GetAddress(address string, page int, txsOnPage int, option GetA
 		Erc20Contract:           erc20c,
 		Erc20Tokens:             erc20t,
 		Nonce:                   nonce,
+		NextCursor:              nextCursor,
+		HistoryTruncated:        historyTruncated,
 	}
 	glog.Info("GetAddress ", address, " finished in ", time.Since(start))
 	return r, nil
@@ -692,13 +987,23 @@ func (w *Worker) GetAddressUtxo(address string, onlyConfirmed bool) ([]AddressUt
 	if err != nil {
 		return nil, NewAPIError(fmt.Sprintf("Invalid address, %v", err), true)
 	}
+	r, err := w.GetAddrDescUtxo(addrDesc, onlyConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	glog.Info("GetAddressUtxo ", address, ", ", len(r), " utxos, finished in ", time.Since(start))
+	return r, nil
+}
+
+// GetAddrDescUtxo returns unspent outputs for given address descriptor
+func (w *Worker) GetAddrDescUtxo(addrDesc bchain.AddressDescriptor, onlyConfirmed bool) ([]AddressUtxo, error) {
 	spentInMempool := make(map[string]struct{})
 	r := make([]AddressUtxo, 0, 8)
 	if !onlyConfirmed {
 		// get utxo from mempool
 		txm, err := w.getAddressTxids(addrDesc, true, &AddressFilter{Vout: AddressFilterVoutOff})
 		if err != nil {
-			return nil, errors.Annotatef(err, "getAddressTxids %v true", address)
+			return nil, errors.Annotatef(err, "getAddressTxids %v true", addrDesc)
 		}
 		txm = UniqueTxidsInReverse(txm)
 		mc := make([]*bchain.Tx, len(txm))
@@ -798,12 +1103,208 @@ func (w *Worker) GetAddressUtxo(address string, onlyConfirmed bool) ([]AddressUt
 		}
 	}
 	if checksum.Uint64() != 0 {
-		glog.Warning("DB inconsistency:  ", address, ": checksum is not zero")
+		glog.Warning("DB inconsistency:  ", addrDesc, ": checksum is not zero")
 	}
-	glog.Info("GetAddressUtxo ", address, ", ", len(r), " utxos, finished in ", time.Since(start))
 	return r, nil
 }
 
+// GetAddressTxids returns unique ids of the confirmed or the mempool transactions of the address, newest first
+func (w *Worker) GetAddressTxids(address string, mempool bool) ([]string, error) {
+	addrDesc, err := w.chainParser.GetAddrDescFromAddress(address)
+	if err != nil {
+		return nil, NewAPIError(fmt.Sprintf("Invalid address, %v", err), true)
+	}
+	txids, err := w.getAddressTxids(addrDesc, mempool, &AddressFilter{Vout: AddressFilterVoutOff})
+	if err != nil {
+		return nil, errors.Annotatef(err, "getAddressTxids %v %v", address, mempool)
+	}
+	return UniqueTxidsInReverse(txids), nil
+}
+
+// GetAddrDescHistory returns the balance and the confirmed transactions of BitcoinType address descriptor ordered by height
+// and its unconfirmed transactions, the unconfirmed balance is computed only if unconfirmedBalance is set
+func (w *Worker) GetAddrDescHistory(addrDesc bchain.AddressDescriptor, unconfirmedBalance bool) (*AddrDescHistory, error) {
+	if w.chainType != bchain.ChainBitcoinType {
+		return nil, NewAPIError("History of address descriptor is supported only for BitcoinType coins", true)
+	}
+	r := &AddrDescHistory{}
+	ba, err := w.db.GetAddrDescBalance(addrDesc)
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetAddrDescBalance %v", addrDesc)
+	}
+	// ba can be nil if the address is only in mempool!
+	if ba != nil {
+		r.BalanceSat = ba.BalanceSat
+		txc, err := w.getAddressTxids(addrDesc, false, &AddressFilter{Vout: AddressFilterVoutOff})
+		if err != nil {
+			return nil, errors.Annotatef(err, "getAddressTxids %v false", addrDesc)
+		}
+		// the index returns the transactions ordered by height, possibly repeated
+		seen := make(map[string]struct{}, len(txc))
+		for _, txid := range txc {
+			if _, e := seen[txid]; e {
+				continue
+			}
+			seen[txid] = struct{}{}
+			ta, err := w.db.GetTxAddresses(txid)
+			if err != nil {
+				return nil, errors.Annotatef(err, "GetTxAddresses %v", txid)
+			}
+			if ta == nil {
+				glog.Warning("DB inconsistency:  tx ", txid, ": not found in txAddresses")
+				continue
+			}
+			r.Confirmed = append(r.Confirmed, AddrDescTx{Txid: txid, Height: ta.Height})
+		}
+	}
+	txm, err := w.getAddressTxids(addrDesc, true, &AddressFilter{Vout: AddressFilterVoutOff})
+	if err != nil {
+		return nil, errors.Annotatef(err, "getAddressTxids %v true", addrDesc)
+	}
+	txm = UniqueTxidsInReverse(txm)
+	for i := len(txm) - 1; i >= 0; i-- {
+		txid := txm[i]
+		if unconfirmedBalance {
+			tx, err := w.GetTransaction(txid, false, false)
+			// mempool transaction may fail
+			if err != nil {
+				glog.Error("GetTransaction in mempool ", txid, ": ", err)
+				continue
+			}
+			r.UnconfirmedBalanceSat.Add(&r.UnconfirmedBalanceSat, tx.getAddrVoutValue(addrDesc))
+			r.UnconfirmedBalanceSat.Sub(&r.UnconfirmedBalanceSat, tx.getAddrVinValue(addrDesc))
+		}
+		r.Unconfirmed = append(r.Unconfirmed, AddrDescTx{Txid: txid})
+	}
+	return r, nil
+}
+
+// forEachAddrDescTxAddresses passes the unique confirmed transactions of the address descriptor in blocks lower-higher
+// with their addresses to fn, the oldest first
+func (w *Worker) forEachAddrDescTxAddresses(addrDesc bchain.AddressDescriptor, lower, higher uint32, fn func(txid string, ta *db.TxAddresses) error) error {
+	// the same transaction can be passed multiple times only within one block
+	var blockHeight uint32
+	var blockTxids map[string]struct{}
+	return w.db.GetAddrDescTransactions(addrDesc, lower, higher, func(txid string, vout int32, isOutput bool) error {
+		if _, found := blockTxids[txid]; found {
+			return nil
+		}
+		ta, err := w.db.GetTxAddresses(txid)
+		if err != nil {
+			return errors.Annotatef(err, "GetTxAddresses %v", txid)
+		}
+		if ta == nil {
+			glog.Warning("DB inconsistency:  tx ", txid, ": not found in txAddresses")
+			return nil
+		}
+		if blockTxids == nil || ta.Height != blockHeight {
+			blockHeight = ta.Height
+			blockTxids = make(map[string]struct{})
+		}
+		blockTxids[txid] = struct{}{}
+		return fn(txid, ta)
+	})
+}
+
+// addrDescTxAddressesValues returns the values received and sent by the address descriptor in the transaction
+func addrDescTxAddressesValues(addrDesc bchain.AddressDescriptor, ta *db.TxAddresses) (*big.Int, *big.Int) {
+	var received, sent big.Int
+	for i := range ta.Outputs {
+		if bytes.Equal(ta.Outputs[i].AddrDesc, addrDesc) {
+			received.Add(&received, &ta.Outputs[i].ValueSat)
+		}
+	}
+	for i := range ta.Inputs {
+		if bytes.Equal(ta.Inputs[i].AddrDesc, addrDesc) {
+			sent.Add(&sent, &ta.Inputs[i].ValueSat)
+		}
+	}
+	return &received, &sent
+}
+
+// ExportAddressHistory passes the confirmed transactions of the address in blocks filter.FromHeight-filter.ToHeight to fn,
+// the oldest first, with the values for the address and the running balance of the address
+func (w *Worker) ExportAddressHistory(address string, filter *AddressFilter, fn func(tx *AddressExportTx) error) error {
+	start := time.Now()
+	if w.chainType != bchain.ChainBitcoinType {
+		return NewAPIError("Export of address history is supported only for BitcoinType coins", true)
+	}
+	addrDesc, err := w.chainParser.GetAddrDescFromAddress(address)
+	if err != nil {
+		return NewAPIError(fmt.Sprintf("Invalid address, %v", err), true)
+	}
+	if ph := w.is.GetPrunedHeight(); ph > 0 && filter.FromHeight <= ph {
+		return NewAPIError(fmt.Sprintf("Transaction history up to block %v is pruned, request history from block %v", ph, ph+1), true)
+	}
+	ba, err := w.db.GetAddrDescBalance(addrDesc)
+	if err != nil {
+		return errors.Annotatef(err, "GetAddrDescBalance %v", addrDesc)
+	}
+	// the address has no confirmed transactions
+	if ba == nil {
+		return nil
+	}
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return errors.Annotatef(err, "GetBestBlock")
+	}
+	to := filter.ToHeight
+	if to == 0 {
+		to = bestheight
+	}
+	// the balance before the first exported block is the current balance without the later transactions
+	var balance big.Int
+	if filter.FromHeight > 0 {
+		balance.Set(&ba.BalanceSat)
+		err = w.forEachAddrDescTxAddresses(addrDesc, filter.FromHeight, bestheight, func(txid string, ta *db.TxAddresses) error {
+			received, sent := addrDescTxAddressesValues(addrDesc, ta)
+			balance.Sub(&balance, received)
+			balance.Add(&balance, sent)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var bi *db.BlockInfo
+	count := 0
+	err = w.forEachAddrDescTxAddresses(addrDesc, filter.FromHeight, to, func(txid string, ta *db.TxAddresses) error {
+		if bi == nil || bi.Height != ta.Height {
+			var e error
+			if bi, e = w.db.GetBlockInfo(ta.Height); e != nil {
+				return errors.Annotatef(e, "GetBlockInfo %v", ta.Height)
+			}
+			if bi == nil {
+				glog.Warning("DB inconsistency:  block height ", ta.Height, ": not found in db")
+				return nil
+			}
+		}
+		tx := w.txFromTxAddress(txid, ta, bi, bestheight)
+		received, sent := addrDescTxAddressesValues(addrDesc, ta)
+		var net big.Int
+		net.Sub(received, sent)
+		balance.Add(&balance, &net)
+		b := new(big.Int).Set(&balance)
+		count++
+		return fn(&AddressExportTx{
+			Txid:        txid,
+			Blockhash:   bi.Hash,
+			Blockheight: int(ta.Height),
+			Blocktime:   bi.Time,
+			ReceivedSat: (*Amount)(received),
+			SentSat:     (*Amount)(sent),
+			FeesSat:     tx.FeesSat,
+			NetValueSat: (*Amount)(&net),
+			BalanceSat:  (*Amount)(b),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	glog.Info("ExportAddressHistory ", address, ", ", count, " txs, finished in ", time.Since(start))
+	return nil
+}
+
 // GetBlocks returns BlockInfo for blocks on given page
 func (w *Worker) GetBlocks(page int, blocksOnPage int) (*Blocks, error) {
 	start := time.Now()
@@ -860,6 +1361,9 @@ func (w *Worker) GetBlock(bid string, page int, txsOnPage int) (*Block, error) {
 		}
 		return nil, NewAPIError(fmt.Sprintf("Block not found, %v", err), true)
 	}
+	if ph := w.is.GetPrunedHeight(); ph > 0 && bi.Height <= ph && w.chainType == bchain.ChainBitcoinType {
+		return nil, NewAPIError(fmt.Sprintf("Block %v is pruned, the index is pruned up to block %v", bi.Height, ph), true)
+	}
 	dbi := &db.BlockInfo{
 		Hash:   bi.Hash,
 		Height: bi.Height,
@@ -944,6 +1448,9 @@ func (w *Worker) GetSystemInfo(internal bool) (*SystemInfo, error) {
 		DbSize:            w.db.DatabaseSizeOnDisk(),
 		DbSizeFromColumns: dbs,
 		DbColumns:         dbc,
+		PruneDepth:        w.is.PruneDepth,
+		PrunedHeight:      w.is.GetPrunedHeight(),
+		DbTuning:          w.is.DbTuning,
 		About:             Text.BlockbookAbout,
 	}
 	glog.Info("GetSystemInfo finished in ", time.Since(start))
@@ -260,3 +260,8 @@ func (p *BaseParser) UnpackTx(buf []byte) (*Tx, uint32, error) {
 func (p *BaseParser) EthereumTypeGetErc20FromTx(tx *Tx) ([]Erc20Transfer, error) {
 	return nil, errors.New("Not supported")
 }
+
+// EthereumTypeGetLogsFromTx is unsupported
+func (p *BaseParser) EthereumTypeGetLogsFromTx(tx *Tx) ([]EthereumLog, error) {
+	return nil, errors.New("Not supported")
+}
@@ -81,6 +81,23 @@ func GetCoinNameFromConfig(configfile string) (string, string, string, error) {
 	return cn.CoinName, cn.CoinShortcut, cn.CoinLabel, nil
 }
 
+// GetDBTuningFromConfig returns the name of the built in db tuning profile or the path to the profile file from the config file
+// empty string means the default profile
+func GetDBTuningFromConfig(configfile string) (string, error) {
+	data, err := ioutil.ReadFile(configfile)
+	if err != nil {
+		return "", errors.Annotatef(err, "Error reading file %v", configfile)
+	}
+	var c struct {
+		DBTuning string `json:"db_tuning"`
+	}
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return "", errors.Annotatef(err, "Error parsing file %v", configfile)
+	}
+	return c.DBTuning, nil
+}
+
 // NewBlockChain creates bchain.BlockChain of type defined by parameter coin
 func NewBlockChain(coin string, configfile string, pushHandler func(bchain.NotificationType), metrics *common.Metrics) (bchain.BlockChain, error) {
 	data, err := ioutil.ReadFile(configfile)
@@ -266,3 +283,8 @@ func (c *blockChainWithMetrics) EthereumTypeGetErc20ContractBalance(addrDesc, co
 	defer func(s time.Time) { c.observeRPCLatency("EthereumTypeGetErc20ContractInfo", s, err) }(time.Now())
 	return c.b.EthereumTypeGetErc20ContractBalance(addrDesc, contractDesc)
 }
+
+func (c *blockChainWithMetrics) EthereumTypeGetEip1559Fees() (v *bchain.Eip1559Fees, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("EthereumTypeGetEip1559Fees", s, err) }(time.Now())
+	return c.b.EthereumTypeGetEip1559Fees()
+}
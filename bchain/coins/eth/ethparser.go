@@ -38,6 +38,8 @@ type rpcHeader struct {
 	Time       string `json:"timestamp"`
 	Size       string `json:"size"`
 	Nonce      string `json:"nonce"`
+	// BaseFeePerGas is set only in blocks after the London hard fork (EIP-1559)
+	BaseFeePerGas string `json:"baseFeePerGas,omitempty"`
 }
 
 type rpcTransaction struct {
@@ -52,6 +54,10 @@ type rpcTransaction struct {
 	BlockHash        string `json:"blockHash,omitempty"`
 	From             string `json:"from"`
 	TransactionIndex string `json:"transactionIndex"`
+	// typed transactions (EIP-2718), type 0x1 (EIP-2930) and 0x2 (EIP-1559)
+	Type                 string `json:"type,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
 	// Signature values - ignored
 	// V string `json:"v"`
 	// R string `json:"r"`
@@ -70,9 +76,10 @@ type rpcLogWithTxHash struct {
 }
 
 type rpcReceipt struct {
-	GasUsed string    `json:"gasUsed"`
-	Status  string    `json:"status"`
-	Logs    []*rpcLog `json:"logs"`
+	GasUsed           string    `json:"gasUsed"`
+	Status            string    `json:"status"`
+	Logs              []*rpcLog `json:"logs"`
+	EffectiveGasPrice string    `json:"effectiveGasPrice,omitempty"`
 }
 
 type rpcEtcReceipt struct {
@@ -82,8 +89,9 @@ type rpcEtcReceipt struct {
 }
 
 type completeTransaction struct {
-	Tx      *rpcTransaction `json:"tx"`
-	Receipt *rpcReceipt     `json:"receipt,omitempty"`
+	Tx            *rpcTransaction `json:"tx"`
+	Receipt       *rpcReceipt     `json:"receipt,omitempty"`
+	BaseFeePerGas string          `json:"baseFeePerGas,omitempty"`
 }
 
 type rpcBlockTransactions struct {
@@ -101,7 +109,32 @@ func ethNumber(n string) (int64, error) {
 	return 0, errors.Errorf("Not a number: '%v'", n)
 }
 
-func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, receipt *rpcReceipt, blocktime int64, confirmations uint32) (*bchain.Tx, error) {
+// effectiveGasPrice returns the price per gas paid by the transaction,
+// for EIP-1559 transactions it is min(maxFeePerGas, baseFeePerGas+maxPriorityFeePerGas)
+func effectiveGasPrice(tx *rpcTransaction, baseFee string) string {
+	if tx.MaxFeePerGas == "" || baseFee == "" {
+		return tx.GasPrice
+	}
+	maxFee, err := hexutil.DecodeBig(tx.MaxFeePerGas)
+	if err != nil {
+		return ""
+	}
+	bf, err := hexutil.DecodeBig(baseFee)
+	if err != nil {
+		return ""
+	}
+	priority, err := hexutil.DecodeBig(tx.MaxPriorityFeePerGas)
+	if err != nil {
+		return ""
+	}
+	bf.Add(bf, priority)
+	if bf.Cmp(maxFee) > 0 {
+		return hexutil.EncodeBig(maxFee)
+	}
+	return hexutil.EncodeBig(bf)
+}
+
+func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, receipt *rpcReceipt, baseFee string, blocktime int64, confirmations uint32) (*bchain.Tx, error) {
 	txid := tx.Hash
 	var (
 		fa, ta []string
@@ -114,8 +147,13 @@ func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, receipt *rpcReceipt, bloc
 		ta = []string{tx.To}
 	}
 	ct := completeTransaction{
-		Tx:      tx,
-		Receipt: receipt,
+		Tx:            tx,
+		Receipt:       receipt,
+		BaseFeePerGas: baseFee,
+	}
+	// receipts of transactions in a block are constructed only from logs, compute the effective gas price
+	if receipt != nil && receipt.EffectiveGasPrice == "" && baseFee != "" {
+		receipt.EffectiveGasPrice = effectiveGasPrice(tx, baseFee)
 	}
 	vs, err := hexutil.DecodeBig(tx.Value)
 	if err != nil {
@@ -261,6 +299,27 @@ func (p *EthereumParser) PackTx(tx *bchain.Tx, height uint32, blockTime int64) (
 	if pt.Tx.Value, err = hexDecodeBig(r.Tx.Value); err != nil {
 		return nil, errors.Annotatef(err, "Value %v", r.Tx.Value)
 	}
+	if r.Tx.Type != "" {
+		if n, err = hexutil.DecodeUint64(r.Tx.Type); err != nil {
+			return nil, errors.Annotatef(err, "Type %v", r.Tx.Type)
+		}
+		pt.Tx.Type = uint32(n)
+	}
+	if r.Tx.MaxPriorityFeePerGas != "" {
+		if pt.Tx.MaxPriorityFeePerGas, err = hexDecodeBig(r.Tx.MaxPriorityFeePerGas); err != nil {
+			return nil, errors.Annotatef(err, "MaxPriorityFeePerGas %v", r.Tx.MaxPriorityFeePerGas)
+		}
+	}
+	if r.Tx.MaxFeePerGas != "" {
+		if pt.Tx.MaxFeePerGas, err = hexDecodeBig(r.Tx.MaxFeePerGas); err != nil {
+			return nil, errors.Annotatef(err, "MaxFeePerGas %v", r.Tx.MaxFeePerGas)
+		}
+	}
+	if r.BaseFeePerGas != "" {
+		if pt.BaseFeePerGas, err = hexDecodeBig(r.BaseFeePerGas); err != nil {
+			return nil, errors.Annotatef(err, "BaseFeePerGas %v", r.BaseFeePerGas)
+		}
+	}
 	if r.Receipt != nil {
 		pt.Receipt = &ProtoCompleteTransaction_ReceiptType{}
 		if pt.Receipt.GasUsed, err = hexDecodeBig(r.Receipt.GasUsed); err != nil {
@@ -269,6 +328,11 @@ func (p *EthereumParser) PackTx(tx *bchain.Tx, height uint32, blockTime int64) (
 		if pt.Receipt.Status, err = hexDecodeBig(r.Receipt.Status); err != nil {
 			return nil, errors.Annotatef(err, "Status %v", r.Receipt.Status)
 		}
+		if r.Receipt.EffectiveGasPrice != "" {
+			if pt.Receipt.EffectiveGasPrice, err = hexDecodeBig(r.Receipt.EffectiveGasPrice); err != nil {
+				return nil, errors.Annotatef(err, "EffectiveGasPrice %v", r.Receipt.EffectiveGasPrice)
+			}
+		}
 		ptLogs := make([]*ProtoCompleteTransaction_ReceiptType_LogType, len(r.Receipt.Logs))
 		for i, l := range r.Receipt.Logs {
 			a, err := hexutil.Decode(l.Address)
@@ -320,6 +384,19 @@ func (p *EthereumParser) UnpackTx(buf []byte) (*bchain.Tx, uint32, error) {
 		TransactionIndex: hexutil.EncodeUint64(uint64(pt.Tx.TransactionIndex)),
 		Value:            hexEncodeBig(pt.Tx.Value),
 	}
+	if pt.Tx.Type != 0 {
+		rt.Type = hexutil.EncodeUint64(uint64(pt.Tx.Type))
+	}
+	if len(pt.Tx.MaxPriorityFeePerGas) > 0 {
+		rt.MaxPriorityFeePerGas = hexEncodeBig(pt.Tx.MaxPriorityFeePerGas)
+	}
+	if len(pt.Tx.MaxFeePerGas) > 0 {
+		rt.MaxFeePerGas = hexEncodeBig(pt.Tx.MaxFeePerGas)
+	}
+	var baseFee string
+	if len(pt.BaseFeePerGas) > 0 {
+		baseFee = hexEncodeBig(pt.BaseFeePerGas)
+	}
 	var rr *rpcReceipt
 	if pt.Receipt != nil {
 		logs := make([]*rpcLog, len(pt.Receipt.Log))
@@ -339,8 +416,11 @@ func (p *EthereumParser) UnpackTx(buf []byte) (*bchain.Tx, uint32, error) {
 			Status:  hexEncodeBig(pt.Receipt.Status),
 			Logs:    logs,
 		}
+		if len(pt.Receipt.EffectiveGasPrice) > 0 {
+			rr.EffectiveGasPrice = hexEncodeBig(pt.Receipt.EffectiveGasPrice)
+		}
 	}
-	tx, err := p.ethTxToTx(&rt, rr, int64(pt.BlockTime), 0)
+	tx, err := p.ethTxToTx(&rt, rr, baseFee, int64(pt.BlockTime), 0)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -416,6 +496,23 @@ func (p *EthereumParser) EthereumTypeGetErc20FromTx(tx *bchain.Tx) ([]bchain.Erc
 	return r, nil
 }
 
+// EthereumTypeGetLogsFromTx returns logs from the receipt of bchain.Tx
+func (p *EthereumParser) EthereumTypeGetLogsFromTx(tx *bchain.Tx) ([]bchain.EthereumLog, error) {
+	csd, ok := tx.CoinSpecificData.(completeTransaction)
+	if !ok || csd.Receipt == nil {
+		return nil, nil
+	}
+	r := make([]bchain.EthereumLog, len(csd.Receipt.Logs))
+	for i, l := range csd.Receipt.Logs {
+		r[i] = bchain.EthereumLog{
+			Address: l.Address,
+			Topics:  l.Topics,
+			Data:    l.Data,
+		}
+	}
+	return r, nil
+}
+
 const (
 	txStatusUnknown = iota - 2
 	txStatusPending
@@ -425,11 +522,17 @@ const (
 
 // EthereumTxData contains ethereum specific transaction data
 type EthereumTxData struct {
-	Status   int      `json:"status"` // 1 OK, 0 Fail, -1 pending, -2 unknown
-	Nonce    uint64   `json:"nonce"`
-	GasLimit *big.Int `json:"gaslimit"`
-	GasUsed  *big.Int `json:"gasused"`
-	GasPrice *big.Int `json:"gasprice"`
+	Status               int      `json:"status"` // 1 OK, 0 Fail, -1 pending, -2 unknown
+	Nonce                uint64   `json:"nonce"`
+	GasLimit             *big.Int `json:"gaslimit"`
+	GasUsed              *big.Int `json:"gasused"`
+	GasPrice             *big.Int `json:"gasprice"`
+	Data                 string   `json:"data"`
+	Type                 int      `json:"type"` // 0 legacy, 1 EIP-2930, 2 EIP-1559
+	MaxPriorityFeePerGas *big.Int `json:"maxpriorityfeepergas,omitempty"`
+	MaxFeePerGas         *big.Int `json:"maxfeepergas,omitempty"`
+	BaseFeePerGas        *big.Int `json:"basefeepergas,omitempty"`
+	EffectiveGasPrice    *big.Int `json:"effectivegasprice,omitempty"`
 }
 
 // GetEthereumTxData returns EthereumTxData from bchain.Tx
@@ -441,6 +544,20 @@ func GetEthereumTxData(tx *bchain.Tx) *EthereumTxData {
 			etd.Nonce, _ = hexutil.DecodeUint64(csd.Tx.AccountNonce)
 			etd.GasLimit, _ = hexutil.DecodeBig(csd.Tx.GasLimit)
 			etd.GasPrice, _ = hexutil.DecodeBig(csd.Tx.GasPrice)
+			etd.Data = csd.Tx.Payload
+			if csd.Tx.Type != "" {
+				t, _ := hexutil.DecodeUint64(csd.Tx.Type)
+				etd.Type = int(t)
+			}
+			if csd.Tx.MaxPriorityFeePerGas != "" {
+				etd.MaxPriorityFeePerGas, _ = hexutil.DecodeBig(csd.Tx.MaxPriorityFeePerGas)
+			}
+			if csd.Tx.MaxFeePerGas != "" {
+				etd.MaxFeePerGas, _ = hexutil.DecodeBig(csd.Tx.MaxFeePerGas)
+			}
+		}
+		if csd.BaseFeePerGas != "" {
+			etd.BaseFeePerGas, _ = hexutil.DecodeBig(csd.BaseFeePerGas)
 		}
 		if csd.Receipt != nil {
 			switch csd.Receipt.Status {
@@ -452,6 +569,9 @@ func GetEthereumTxData(tx *bchain.Tx) *EthereumTxData {
 				etd.Status = txStatusFailure
 			}
 			etd.GasUsed, _ = hexutil.DecodeBig(csd.Receipt.GasUsed)
+			if csd.Receipt.EffectiveGasPrice != "" {
+				etd.EffectiveGasPrice, _ = hexutil.DecodeBig(csd.Receipt.EffectiveGasPrice)
+			}
 		}
 	}
 	return &etd
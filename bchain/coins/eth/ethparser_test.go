@@ -265,3 +265,102 @@ func TestEthereumParser_UnpackTx(t *testing.T) {
 		})
 	}
 }
+
+func TestEthereumParser_Eip1559Tx(t *testing.T) {
+	p := NewEthereumParser(1)
+	tx := &rpcTransaction{
+		AccountNonce:         "0x2",
+		GasPrice:             "0x4a817c800",
+		GasLimit:             "0x5208",
+		To:                   "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f",
+		Value:                "0x1",
+		Payload:              "0x",
+		Hash:                 "0xcd647151552b5132b2aef7c9be00dc6f73afc5901dde157aab131335baaa853b",
+		BlockNumber:          "0x41eee8",
+		From:                 "0x3e3a3d69dc66ba10737f531ed088954a9ec89d97",
+		TransactionIndex:     "0x0",
+		Type:                 "0x2",
+		MaxPriorityFeePerGas: "0x77359400",
+		MaxFeePerGas:         "0x6fc23ac00",
+	}
+	// base fee 15 Gwei, effective gas price is base fee + priority fee (2 Gwei), which is less than max fee
+	btx, err := p.ethTxToTx(tx, &rpcReceipt{GasUsed: "0x5208", Status: "0x1", Logs: []*rpcLog{}}, "0x37e11d600", 1534858022, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.PackTx(btx, 4321000, 1534858022)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, height, err := p.UnpackTx(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 4321000 {
+		t.Errorf("UnpackTx() height = %v, want 4321000", height)
+	}
+	gs := got.CoinSpecificData.(completeTransaction)
+	ws := btx.CoinSpecificData.(completeTransaction)
+	if !reflect.DeepEqual(gs.Tx, ws.Tx) {
+		t.Errorf("UnpackTx() Tx = %+v, want %+v", gs.Tx, ws.Tx)
+	}
+	if !reflect.DeepEqual(gs.Receipt, ws.Receipt) {
+		t.Errorf("UnpackTx() Receipt = %+v, want %+v", gs.Receipt, ws.Receipt)
+	}
+	if gs.BaseFeePerGas != "0x37e11d600" {
+		t.Errorf("UnpackTx() BaseFeePerGas = %v, want 0x37e11d600", gs.BaseFeePerGas)
+	}
+	etd := GetEthereumTxData(got)
+	if etd.Type != 2 {
+		t.Errorf("GetEthereumTxData() Type = %v, want 2", etd.Type)
+	}
+	wantValues := []struct {
+		name string
+		got  *big.Int
+		want int64
+	}{
+		{"MaxPriorityFeePerGas", etd.MaxPriorityFeePerGas, 2000000000},
+		{"MaxFeePerGas", etd.MaxFeePerGas, 30000000000},
+		{"BaseFeePerGas", etd.BaseFeePerGas, 15000000000},
+		{"EffectiveGasPrice", etd.EffectiveGasPrice, 17000000000},
+	}
+	for _, v := range wantValues {
+		if v.got == nil || v.got.Int64() != v.want {
+			t.Errorf("GetEthereumTxData() %v = %v, want %v", v.name, v.got, v.want)
+		}
+	}
+}
+
+func Test_effectiveGasPrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		tx      rpcTransaction
+		baseFee string
+		want    string
+	}{
+		{
+			name: "legacy tx",
+			tx:   rpcTransaction{GasPrice: "0x4a817c800"},
+			want: "0x4a817c800",
+		},
+		{
+			name:    "base fee plus priority fee",
+			tx:      rpcTransaction{GasPrice: "0x6fc23ac00", MaxFeePerGas: "0x6fc23ac00", MaxPriorityFeePerGas: "0x77359400"},
+			baseFee: "0x37e11d600",
+			want:    "0x3f5476a00",
+		},
+		{
+			name:    "limited by max fee",
+			tx:      rpcTransaction{GasPrice: "0x4a817c800", MaxFeePerGas: "0x4a817c800", MaxPriorityFeePerGas: "0x77359400"},
+			baseFee: "0x4a817c800",
+			want:    "0x4a817c800",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveGasPrice(&tt.tx, tt.baseFee); got != tt.want {
+				t.Errorf("effectiveGasPrice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
 	ethereum "github.com/ethereum/go-ethereum"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -36,6 +38,7 @@ type Configuration struct {
 	RPCURL               string `json:"rpc_url"`
 	RPCTimeout           int    `json:"rpc_timeout"`
 	BlockAddressesToKeep int    `json:"block_addresses_to_keep"`
+	SignaturesFile       string `json:"signatures_file"`
 }
 
 // EthereumRPC is an interface to JSON-RPC eth service.
@@ -89,6 +92,15 @@ func NewEthereumRPC(config json.RawMessage, pushHandler func(bchain.Notification
 	s.Parser = NewEthereumParser(c.BlockAddressesToKeep)
 	s.timeout = time.Duration(c.RPCTimeout) * time.Second
 
+	// load additional function signatures used to decode transaction input data
+	if c.SignaturesFile != "" {
+		n, err := LoadFunctionSignatures(c.SignaturesFile)
+		if err != nil {
+			return nil, errors.Annotatef(err, "LoadFunctionSignatures %v", c.SignaturesFile)
+		}
+		glog.Info("rpc: loaded ", n, " function signatures from ", c.SignaturesFile)
+	}
+
 	// detect ethereum classic
 	s.isETC = s.ChainConfig.CoinName == "Ethereum Classic"
 
@@ -476,7 +488,7 @@ func (b *EthereumRPC) GetBlock(hash string, height uint32) (*bchain.Block, error
 	btxs := make([]bchain.Tx, len(body.Transactions))
 	for i := range body.Transactions {
 		tx := &body.Transactions[i]
-		btx, err := b.Parser.ethTxToTx(tx, &rpcReceipt{Logs: logs[tx.Hash]}, bbh.Time, uint32(bbh.Confirmations))
+		btx, err := b.Parser.ethTxToTx(tx, &rpcReceipt{Logs: logs[tx.Hash]}, head.BaseFeePerGas, bbh.Time, uint32(bbh.Confirmations))
 		if err != nil {
 			return nil, errors.Annotatef(err, "hash %v, height %v, txid %v", hash, height, tx.Hash)
 		}
@@ -543,7 +555,7 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 	var btx *bchain.Tx
 	if tx.BlockNumber == "" {
 		// mempool tx
-		btx, err = b.Parser.ethTxToTx(tx, nil, 0, 0)
+		btx, err = b.Parser.ethTxToTx(tx, nil, "", 0, 0)
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
@@ -554,7 +566,8 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 			return nil, err
 		}
 		var ht struct {
-			Time string `json:"timestamp"`
+			Time          string `json:"timestamp"`
+			BaseFeePerGas string `json:"baseFeePerGas,omitempty"`
 		}
 		if err := json.Unmarshal(raw, &ht); err != nil {
 			return nil, errors.Annotatef(err, "hash %v", hash)
@@ -600,7 +613,7 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
-		btx, err = b.Parser.ethTxToTx(tx, &receipt, time, confirmations)
+		btx, err = b.Parser.ethTxToTx(tx, &receipt, ht.BaseFeePerGas, time, confirmations)
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
@@ -669,6 +682,70 @@ func (b *EthereumRPC) EstimateSmartFee(blocks int, conservative bool) (big.Int,
 	return r, err
 }
 
+// number of blocks and percentiles of priority fees used in EIP-1559 fee estimation
+const feeHistoryBlocks = 20
+
+var feeHistoryPercentiles = []int{10, 50, 90}
+
+type rpcFeeHistory struct {
+	BaseFeePerGas []string   `json:"baseFeePerGas"`
+	Reward        [][]string `json:"reward"`
+}
+
+// EthereumTypeGetEip1559Fees returns the base fee of the next block and low, medium and high priority fee tiers,
+// the tiers are medians of the 10th, 50th and 90th percentile of priority fees paid in the last blocks
+func (b *EthereumRPC) EthereumTypeGetEip1559Fees() (*bchain.Eip1559Fees, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	var h rpcFeeHistory
+	err := b.rpc.CallContext(ctx, &h, "eth_feeHistory", hexutil.EncodeUint64(feeHistoryBlocks), "latest", feeHistoryPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.BaseFeePerGas) == 0 {
+		return nil, errors.New("Fee history not available")
+	}
+	// the last base fee is the base fee of the next block
+	baseFee, err := hexutil.DecodeBig(h.BaseFeePerGas[len(h.BaseFeePerGas)-1])
+	if err != nil {
+		return nil, errors.Annotatef(err, "baseFeePerGas %v", h.BaseFeePerGas)
+	}
+	if baseFee.Sign() == 0 {
+		return nil, errors.New("EIP-1559 is not active")
+	}
+	tiers := make([]*bchain.Eip1559Fee, len(feeHistoryPercentiles))
+	for i := range tiers {
+		rewards := make([]*big.Int, 0, len(h.Reward))
+		for _, r := range h.Reward {
+			if i < len(r) {
+				v, err := hexutil.DecodeBig(r[i])
+				if err != nil {
+					return nil, errors.Annotatef(err, "reward %v", r)
+				}
+				rewards = append(rewards, v)
+			}
+		}
+		priorityFee := new(big.Int)
+		if len(rewards) > 0 {
+			sort.Slice(rewards, func(k, l int) bool { return rewards[k].Cmp(rewards[l]) < 0 })
+			priorityFee = rewards[len(rewards)/2]
+		}
+		// the max fee allows the base fee to double before the transaction cannot be included
+		maxFee := new(big.Int).Mul(baseFee, big.NewInt(2))
+		maxFee.Add(maxFee, priorityFee)
+		tiers[i] = &bchain.Eip1559Fee{
+			MaxFeePerGas:         maxFee,
+			MaxPriorityFeePerGas: priorityFee,
+		}
+	}
+	return &bchain.Eip1559Fees{
+		BaseFeePerGas: baseFee,
+		Low:           tiers[0],
+		Medium:        tiers[1],
+		High:          tiers[2],
+	}, nil
+}
+
 func getStringFromMap(p string, params map[string]interface{}) (string, bool) {
 	v, ok := params[p]
 	if ok {
@@ -0,0 +1,299 @@
+package eth
+
+import (
+	"blockbook/bchain"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// defaultFunctionSignatures are the 4-byte function signatures known without loading the signatures file
+var defaultFunctionSignatures = map[string]string{
+	erc20TransferMethodSignature: "transfer(address,uint256)",
+	"0x23b872dd":                 "transferFrom(address,address,uint256)",
+	"0x095ea7b3":                 "approve(address,uint256)",
+	"0xcae9ca51":                 "approveAndCall(address,uint256,bytes)",
+	"0xd0e30db0":                 "deposit()",
+	"0x2e1a7d4d":                 "withdraw(uint256)",
+}
+
+var functionSignatures = copyFunctionSignatures(defaultFunctionSignatures)
+var functionSignaturesMux sync.Mutex
+
+func copyFunctionSignatures(m map[string]string) map[string]string {
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// LoadFunctionSignatures loads 4-byte function signatures from a json file
+// in the form {"0xa9059cbb": "transfer(address,uint256)", ...}
+// the loaded signatures are added to the default signatures, the number of loaded signatures is returned
+func LoadFunctionSignatures(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var m map[string]string
+	if err = json.Unmarshal(data, &m); err != nil {
+		return 0, errors.Annotatef(err, "Invalid signatures file")
+	}
+	s := copyFunctionSignatures(defaultFunctionSignatures)
+	for k, v := range m {
+		k = strings.ToLower(k)
+		if !has0xPrefix(k) {
+			k = "0x" + k
+		}
+		if len(k) != 10 {
+			return 0, errors.Errorf("Invalid function signature id '%v'", k)
+		}
+		if _, err = hex.DecodeString(k[2:]); err != nil {
+			return 0, errors.Errorf("Invalid function signature id '%v'", k)
+		}
+		s[k] = v
+	}
+	functionSignaturesMux.Lock()
+	functionSignatures = s
+	functionSignaturesMux.Unlock()
+	return len(m), nil
+}
+
+func getFunctionSignature(methodID string) (string, bool) {
+	functionSignaturesMux.Lock()
+	defer functionSignaturesMux.Unlock()
+	s, found := functionSignatures[methodID]
+	return s, found
+}
+
+// parseFunctionSignature splits signature in the form name(type1,type2) to name and types
+func parseFunctionSignature(signature string) (string, []string, error) {
+	i := strings.IndexByte(signature, '(')
+	if i <= 0 || signature[len(signature)-1] != ')' {
+		return "", nil, errors.Errorf("Invalid function signature '%v'", signature)
+	}
+	name := signature[:i]
+	params := signature[i+1 : len(signature)-1]
+	if len(params) == 0 {
+		return name, nil, nil
+	}
+	// tuples are not supported
+	if strings.ContainsAny(params, "()") {
+		return name, nil, errors.Errorf("Unsupported function signature '%v'", signature)
+	}
+	return name, strings.Split(params, ","), nil
+}
+
+// ParseInputData decodes transaction input data using the known function signatures
+// nil is returned if the data do not contain a method call
+func ParseInputData(data string) *bchain.EthereumParsedInputData {
+	if has0xPrefix(data) {
+		data = data[2:]
+	}
+	if len(data) < 8 {
+		return nil
+	}
+	methodID := "0x" + strings.ToLower(data[:8])
+	pd := &bchain.EthereumParsedInputData{MethodId: methodID}
+	signature, found := getFunctionSignature(methodID)
+	if !found {
+		return pd
+	}
+	pd.Function = signature
+	name, types, err := parseFunctionSignature(signature)
+	pd.Name = name
+	if err != nil {
+		glog.V(1).Info("ParseInputData ", methodID, ": ", err)
+		return pd
+	}
+	params, err := decodeInputParams(types, data[8:])
+	if err != nil {
+		glog.V(1).Info("ParseInputData ", methodID, ": ", err)
+		return pd
+	}
+	pd.Params = params
+	return pd
+}
+
+const abiWordLen = 32
+
+// splitArrayType returns element type and number of elements (-1 for dynamic array) of array type,
+// for a type which is not array it returns the type and 0
+func splitArrayType(t string) (string, int, error) {
+	if len(t) == 0 {
+		return "", 0, errors.New("Empty type")
+	}
+	if t[len(t)-1] != ']' {
+		return t, 0, nil
+	}
+	i := strings.LastIndexByte(t, '[')
+	if i <= 0 {
+		return "", 0, errors.Errorf("Invalid type '%v'", t)
+	}
+	elem := t[:i]
+	if strings.ContainsAny(elem, "[]") {
+		return "", 0, errors.Errorf("Unsupported type '%v'", t)
+	}
+	if i == len(t)-2 {
+		return elem, -1, nil
+	}
+	n, err := strconv.Atoi(t[i+1 : len(t)-1])
+	if err != nil || n <= 0 {
+		return "", 0, errors.Errorf("Invalid type '%v'", t)
+	}
+	return elem, n, nil
+}
+
+func decodeInputParams(types []string, data string) ([]bchain.EthereumParsedInputParam, error) {
+	b, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]bchain.EthereumParsedInputParam, len(types))
+	head := 0
+	for i, t := range types {
+		elem, n, err := splitArrayType(t)
+		if err != nil {
+			return nil, err
+		}
+		var values []string
+		if n == 0 && elem != "bytes" && elem != "string" {
+			// static type
+			word, err := getWord(b, head)
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeStaticValue(elem, word)
+			if err != nil {
+				return nil, err
+			}
+			values = []string{v}
+			head += abiWordLen
+		} else if n > 0 {
+			// fixed array of static types is stored inline
+			values = make([]string, n)
+			for j := range values {
+				word, err := getWord(b, head)
+				if err != nil {
+					return nil, err
+				}
+				values[j], err = decodeStaticValue(elem, word)
+				if err != nil {
+					return nil, err
+				}
+				head += abiWordLen
+			}
+		} else {
+			// dynamic type, the head contains offset of the data
+			word, err := getWord(b, head)
+			if err != nil {
+				return nil, err
+			}
+			offset, err := wordToInt(word, len(b))
+			if err != nil {
+				return nil, err
+			}
+			values, err = decodeDynamicValue(elem, n, b, offset)
+			if err != nil {
+				return nil, err
+			}
+			head += abiWordLen
+		}
+		params[i] = bchain.EthereumParsedInputParam{Type: t, Values: values}
+	}
+	return params, nil
+}
+
+func getWord(b []byte, offset int) ([]byte, error) {
+	if offset < 0 || offset+abiWordLen > len(b) {
+		return nil, errors.New("Data too short")
+	}
+	return b[offset : offset+abiWordLen], nil
+}
+
+func wordToInt(word []byte, max int) (int, error) {
+	var v big.Int
+	v.SetBytes(word)
+	if !v.IsInt64() || v.Int64() > int64(max) {
+		return 0, errors.New("Invalid offset or length")
+	}
+	return int(v.Int64()), nil
+}
+
+func decodeStaticValue(t string, word []byte) (string, error) {
+	switch {
+	case t == "address":
+		return hexutil.Encode(word[abiWordLen-EthereumTypeAddressDescriptorLen:]), nil
+	case t == "bool":
+		if word[abiWordLen-1] != 0 {
+			return "true", nil
+		}
+		return "false", nil
+	case strings.HasPrefix(t, "uint"):
+		var v big.Int
+		v.SetBytes(word)
+		return v.String(), nil
+	case strings.HasPrefix(t, "int"):
+		var v big.Int
+		v.SetBytes(word)
+		// two's complement of 256 bit number
+		if word[0]&0x80 != 0 {
+			v.Sub(&v, new(big.Int).Lsh(big.NewInt(1), 8*abiWordLen))
+		}
+		return v.String(), nil
+	case strings.HasPrefix(t, "bytes"):
+		n, err := strconv.Atoi(t[5:])
+		if err != nil || n <= 0 || n > abiWordLen {
+			return "", errors.Errorf("Invalid type '%v'", t)
+		}
+		return hexutil.Encode(word[:n]), nil
+	}
+	return "", errors.Errorf("Unsupported type '%v'", t)
+}
+
+func decodeDynamicValue(t string, n int, b []byte, offset int) ([]string, error) {
+	word, err := getWord(b, offset)
+	if err != nil {
+		return nil, err
+	}
+	l, err := wordToInt(word, len(b))
+	if err != nil {
+		return nil, err
+	}
+	offset += abiWordLen
+	// dynamic array of static types
+	if n < 0 {
+		if t == "bytes" || t == "string" {
+			return nil, errors.Errorf("Unsupported type '%v[]'", t)
+		}
+		values := make([]string, l)
+		for i := range values {
+			word, err = getWord(b, offset)
+			if err != nil {
+				return nil, err
+			}
+			values[i], err = decodeStaticValue(t, word)
+			if err != nil {
+				return nil, err
+			}
+			offset += abiWordLen
+		}
+		return values, nil
+	}
+	if offset+l > len(b) {
+		return nil, errors.New("Data too short")
+	}
+	if t == "string" {
+		return []string{string(b[offset : offset+l])}, nil
+	}
+	return []string{hexutil.Encode(b[offset : offset+l])}, nil
+}
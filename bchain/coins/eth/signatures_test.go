@@ -0,0 +1,144 @@
+// +build unittest
+
+package eth
+
+import (
+	"blockbook/bchain"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseInputData(t *testing.T) {
+	f, err := ioutil.TempFile("", "signatures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(`{"0x12345678": "test(int8,bool,bytes2,string,uint32[],address[2])", "87654321": "tuple((uint256,uint256))", "0x11223344": "empty(uint256,)"}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	n, err := LoadFunctionSignatures(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("LoadFunctionSignatures() = %v, want 3", n)
+	}
+	tests := []struct {
+		name string
+		data string
+		want *bchain.EthereumParsedInputData
+	}{
+		{
+			name: "no data",
+			data: "0x",
+			want: nil,
+		},
+		{
+			name: "unknown method",
+			data: "0xaabbccdd0000000000000000000000000000000000000000000000000000000000000001",
+			want: &bchain.EthereumParsedInputData{MethodId: "0xaabbccdd"},
+		},
+		{
+			name: "transfer",
+			data: "0xa9059cbb000000000000000000000000555ee11fbddc0e49a9bab358a8941ad95ffdb48f00000000000000000000000000000000000000000000021e19e0c9bab2400000",
+			want: &bchain.EthereumParsedInputData{
+				MethodId: "0xa9059cbb",
+				Name:     "transfer",
+				Function: "transfer(address,uint256)",
+				Params: []bchain.EthereumParsedInputParam{
+					{Type: "address", Values: []string{"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f"}},
+					{Type: "uint256", Values: []string{"10000000000000000000000"}},
+				},
+			},
+		},
+		{
+			name: "approveAndCall with bytes",
+			data: "0xcae9ca51" +
+				"000000000000000000000000555ee11fbddc0e49a9bab358a8941ad95ffdb48f" +
+				"0000000000000000000000000000000000000000000000000000000000000005" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0102030000000000000000000000000000000000000000000000000000000000",
+			want: &bchain.EthereumParsedInputData{
+				MethodId: "0xcae9ca51",
+				Name:     "approveAndCall",
+				Function: "approveAndCall(address,uint256,bytes)",
+				Params: []bchain.EthereumParsedInputParam{
+					{Type: "address", Values: []string{"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f"}},
+					{Type: "uint256", Values: []string{"5"}},
+					{Type: "bytes", Values: []string{"0x010203"}},
+				},
+			},
+		},
+		{
+			name: "all supported types from loaded signatures",
+			data: "0x12345678" +
+				"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"abcd000000000000000000000000000000000000000000000000000000000000" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000120" +
+				"000000000000000000000000555ee11fbddc0e49a9bab358a8941ad95ffdb48f" +
+				"0000000000000000000000004bda106325c335df99eab7fe363cac8a0ba2a24d" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"4869000000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000007" +
+				"0000000000000000000000000000000000000000000000000000000000000008",
+			want: &bchain.EthereumParsedInputData{
+				MethodId: "0x12345678",
+				Name:     "test",
+				Function: "test(int8,bool,bytes2,string,uint32[],address[2])",
+				Params: []bchain.EthereumParsedInputParam{
+					{Type: "int8", Values: []string{"-2"}},
+					{Type: "bool", Values: []string{"true"}},
+					{Type: "bytes2", Values: []string{"0xabcd"}},
+					{Type: "string", Values: []string{"Hi"}},
+					{Type: "uint32[]", Values: []string{"7", "8"}},
+					{Type: "address[2]", Values: []string{"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "0x4bda106325c335df99eab7fe363cac8a0ba2a24d"}},
+				},
+			},
+		},
+		{
+			name: "unsupported tuple",
+			data: "0x87654321",
+			want: &bchain.EthereumParsedInputData{
+				MethodId: "0x87654321",
+				Name:     "tuple",
+				Function: "tuple((uint256,uint256))",
+			},
+		},
+		{
+			name: "empty type",
+			data: "0x11223344" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002",
+			want: &bchain.EthereumParsedInputData{
+				MethodId: "0x11223344",
+				Name:     "empty",
+				Function: "empty(uint256,)",
+			},
+		},
+		{
+			name: "data too short",
+			data: "0xa9059cbb000000000000000000000000555ee11fbddc0e49a9bab358a8941ad95ffdb48f",
+			want: &bchain.EthereumParsedInputData{
+				MethodId: "0xa9059cbb",
+				Name:     "transfer",
+				Function: "transfer(address,uint256)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseInputData(tt.data)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseInputData() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -28,10 +28,11 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type ProtoCompleteTransaction struct {
-	BlockNumber uint32                                `protobuf:"varint,1,opt,name=BlockNumber" json:"BlockNumber,omitempty"`
-	BlockTime   uint64                                `protobuf:"varint,2,opt,name=BlockTime" json:"BlockTime,omitempty"`
-	Tx          *ProtoCompleteTransaction_TxType      `protobuf:"bytes,3,opt,name=Tx" json:"Tx,omitempty"`
-	Receipt     *ProtoCompleteTransaction_ReceiptType `protobuf:"bytes,4,opt,name=Receipt" json:"Receipt,omitempty"`
+	BlockNumber   uint32                                `protobuf:"varint,1,opt,name=BlockNumber" json:"BlockNumber,omitempty"`
+	BlockTime     uint64                                `protobuf:"varint,2,opt,name=BlockTime" json:"BlockTime,omitempty"`
+	Tx            *ProtoCompleteTransaction_TxType      `protobuf:"bytes,3,opt,name=Tx" json:"Tx,omitempty"`
+	Receipt       *ProtoCompleteTransaction_ReceiptType `protobuf:"bytes,4,opt,name=Receipt" json:"Receipt,omitempty"`
+	BaseFeePerGas []byte                                `protobuf:"bytes,5,opt,name=BaseFeePerGas,proto3" json:"BaseFeePerGas,omitempty"`
 }
 
 func (m *ProtoCompleteTransaction) Reset()                    { *m = ProtoCompleteTransaction{} }
@@ -67,16 +68,26 @@ func (m *ProtoCompleteTransaction) GetReceipt() *ProtoCompleteTransaction_Receip
 	return nil
 }
 
+func (m *ProtoCompleteTransaction) GetBaseFeePerGas() []byte {
+	if m != nil {
+		return m.BaseFeePerGas
+	}
+	return nil
+}
+
 type ProtoCompleteTransaction_TxType struct {
-	AccountNonce     uint64 `protobuf:"varint,1,opt,name=AccountNonce" json:"AccountNonce,omitempty"`
-	GasPrice         []byte `protobuf:"bytes,2,opt,name=GasPrice,proto3" json:"GasPrice,omitempty"`
-	GasLimit         uint64 `protobuf:"varint,3,opt,name=GasLimit" json:"GasLimit,omitempty"`
-	Value            []byte `protobuf:"bytes,4,opt,name=Value,proto3" json:"Value,omitempty"`
-	Payload          []byte `protobuf:"bytes,5,opt,name=Payload,proto3" json:"Payload,omitempty"`
-	Hash             []byte `protobuf:"bytes,6,opt,name=Hash,proto3" json:"Hash,omitempty"`
-	To               []byte `protobuf:"bytes,7,opt,name=To,proto3" json:"To,omitempty"`
-	From             []byte `protobuf:"bytes,8,opt,name=From,proto3" json:"From,omitempty"`
-	TransactionIndex uint32 `protobuf:"varint,9,opt,name=TransactionIndex" json:"TransactionIndex,omitempty"`
+	AccountNonce         uint64 `protobuf:"varint,1,opt,name=AccountNonce" json:"AccountNonce,omitempty"`
+	GasPrice             []byte `protobuf:"bytes,2,opt,name=GasPrice,proto3" json:"GasPrice,omitempty"`
+	GasLimit             uint64 `protobuf:"varint,3,opt,name=GasLimit" json:"GasLimit,omitempty"`
+	Value                []byte `protobuf:"bytes,4,opt,name=Value,proto3" json:"Value,omitempty"`
+	Payload              []byte `protobuf:"bytes,5,opt,name=Payload,proto3" json:"Payload,omitempty"`
+	Hash                 []byte `protobuf:"bytes,6,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	To                   []byte `protobuf:"bytes,7,opt,name=To,proto3" json:"To,omitempty"`
+	From                 []byte `protobuf:"bytes,8,opt,name=From,proto3" json:"From,omitempty"`
+	TransactionIndex     uint32 `protobuf:"varint,9,opt,name=TransactionIndex" json:"TransactionIndex,omitempty"`
+	Type                 uint32 `protobuf:"varint,10,opt,name=Type" json:"Type,omitempty"`
+	MaxPriorityFeePerGas []byte `protobuf:"bytes,11,opt,name=MaxPriorityFeePerGas,proto3" json:"MaxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         []byte `protobuf:"bytes,12,opt,name=MaxFeePerGas,proto3" json:"MaxFeePerGas,omitempty"`
 }
 
 func (m *ProtoCompleteTransaction_TxType) Reset()         { *m = ProtoCompleteTransaction_TxType{} }
@@ -149,10 +160,32 @@ func (m *ProtoCompleteTransaction_TxType) GetTransactionIndex() uint32 {
 	return 0
 }
 
+func (m *ProtoCompleteTransaction_TxType) GetType() uint32 {
+	if m != nil {
+		return m.Type
+	}
+	return 0
+}
+
+func (m *ProtoCompleteTransaction_TxType) GetMaxPriorityFeePerGas() []byte {
+	if m != nil {
+		return m.MaxPriorityFeePerGas
+	}
+	return nil
+}
+
+func (m *ProtoCompleteTransaction_TxType) GetMaxFeePerGas() []byte {
+	if m != nil {
+		return m.MaxFeePerGas
+	}
+	return nil
+}
+
 type ProtoCompleteTransaction_ReceiptType struct {
-	GasUsed []byte                                          `protobuf:"bytes,1,opt,name=GasUsed,proto3" json:"GasUsed,omitempty"`
-	Status  []byte                                          `protobuf:"bytes,2,opt,name=Status,proto3" json:"Status,omitempty"`
-	Log     []*ProtoCompleteTransaction_ReceiptType_LogType `protobuf:"bytes,3,rep,name=Log" json:"Log,omitempty"`
+	GasUsed           []byte                                          `protobuf:"bytes,1,opt,name=GasUsed,proto3" json:"GasUsed,omitempty"`
+	Status            []byte                                          `protobuf:"bytes,2,opt,name=Status,proto3" json:"Status,omitempty"`
+	Log               []*ProtoCompleteTransaction_ReceiptType_LogType `protobuf:"bytes,3,rep,name=Log" json:"Log,omitempty"`
+	EffectiveGasPrice []byte                                          `protobuf:"bytes,4,opt,name=EffectiveGasPrice,proto3" json:"EffectiveGasPrice,omitempty"`
 }
 
 func (m *ProtoCompleteTransaction_ReceiptType) Reset()         { *m = ProtoCompleteTransaction_ReceiptType{} }
@@ -183,6 +216,13 @@ func (m *ProtoCompleteTransaction_ReceiptType) GetLog() []*ProtoCompleteTransact
 	return nil
 }
 
+func (m *ProtoCompleteTransaction_ReceiptType) GetEffectiveGasPrice() []byte {
+	if m != nil {
+		return m.EffectiveGasPrice
+	}
+	return nil
+}
+
 type ProtoCompleteTransaction_ReceiptType_LogType struct {
 	Address []byte   `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
 	Data    []byte   `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
@@ -231,30 +271,34 @@ func init() {
 func init() { proto.RegisterFile("tx.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 393 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x92, 0xdf, 0x8a, 0xd4, 0x30,
-	0x14, 0xc6, 0xe9, 0x9f, 0xf9, 0xb3, 0xa7, 0x55, 0x24, 0x88, 0x84, 0xe2, 0x45, 0x59, 0xbc, 0xa8,
-	0x5e, 0x14, 0x5c, 0x7d, 0x81, 0x75, 0xc4, 0x55, 0x18, 0xd6, 0x21, 0x46, 0xef, 0xb3, 0x69, 0xd8,
-	0x29, 0xb6, 0x4d, 0x69, 0x52, 0xe8, 0xbe, 0x91, 0x2f, 0xe4, 0xbb, 0x78, 0x29, 0x39, 0x4d, 0xd7,
-	0x11, 0x51, 0xbc, 0x3b, 0xbf, 0x6f, 0xce, 0x37, 0xf9, 0xbe, 0xa4, 0xb0, 0xb5, 0x53, 0xd9, 0x0f,
-	0xda, 0x6a, 0x12, 0x29, 0x7b, 0x3c, 0xff, 0xb6, 0x02, 0x7a, 0x70, 0xb8, 0xd3, 0x6d, 0xdf, 0x28,
-	0xab, 0xf8, 0x20, 0x3a, 0x23, 0xa4, 0xad, 0x75, 0x47, 0x72, 0x48, 0xde, 0x34, 0x5a, 0x7e, 0xbd,
-	0x1e, 0xdb, 0x1b, 0x35, 0xd0, 0x20, 0x0f, 0x8a, 0x07, 0xec, 0x54, 0x22, 0x4f, 0xe1, 0x0c, 0x91,
-	0xd7, 0xad, 0xa2, 0x61, 0x1e, 0x14, 0x31, 0xfb, 0x25, 0x90, 0xd7, 0x10, 0xf2, 0x89, 0x46, 0x79,
-	0x50, 0x24, 0x17, 0xcf, 0x4a, 0x65, 0x8f, 0xe5, 0xdf, 0x8e, 0x2a, 0xf9, 0xc4, 0xef, 0x7a, 0xc5,
-	0x42, 0x3e, 0x91, 0x1d, 0x6c, 0x98, 0x92, 0xaa, 0xee, 0x2d, 0x8d, 0xd1, 0xfa, 0xfc, 0xdf, 0x56,
-	0xbf, 0x8c, 0xfe, 0xc5, 0x99, 0xfd, 0x08, 0x60, 0x3d, 0xff, 0x27, 0x39, 0x87, 0xf4, 0x52, 0x4a,
-	0x3d, 0x76, 0xf6, 0x5a, 0x77, 0x52, 0x61, 0x8d, 0x98, 0xfd, 0xa6, 0x91, 0x0c, 0xb6, 0x57, 0xc2,
-	0x1c, 0x86, 0x5a, 0xce, 0x35, 0x52, 0x76, 0xcf, 0xfe, 0xb7, 0x7d, 0xdd, 0xd6, 0x16, 0xbb, 0xc4,
-	0xec, 0x9e, 0xc9, 0x63, 0x58, 0x7d, 0x11, 0xcd, 0xa8, 0x30, 0x69, 0xca, 0x66, 0x20, 0x14, 0x36,
-	0x07, 0x71, 0xd7, 0x68, 0x51, 0xd1, 0x15, 0xea, 0x0b, 0x12, 0x02, 0xf1, 0x7b, 0x61, 0x8e, 0x74,
-	0x8d, 0x32, 0xce, 0xe4, 0x21, 0x84, 0x5c, 0xd3, 0x0d, 0x2a, 0x21, 0xd7, 0x6e, 0xe7, 0xdd, 0xa0,
-	0x5b, 0xba, 0x9d, 0x77, 0xdc, 0x4c, 0x5e, 0xc0, 0xa3, 0x93, 0xca, 0x1f, 0xba, 0x4a, 0x4d, 0xf4,
-	0x0c, 0x9f, 0xe3, 0x0f, 0x3d, 0xfb, 0x1e, 0x40, 0x72, 0x72, 0x27, 0x2e, 0xcd, 0x95, 0x30, 0x9f,
-	0x8d, 0xaa, 0xb0, 0x7a, 0xca, 0x16, 0x24, 0x4f, 0x60, 0xfd, 0xc9, 0x0a, 0x3b, 0x1a, 0xdf, 0xd9,
-	0x13, 0xd9, 0x41, 0xb4, 0xd7, 0xb7, 0x34, 0xca, 0xa3, 0x22, 0xb9, 0x78, 0xf9, 0xdf, 0xb7, 0x5f,
-	0xee, 0xf5, 0x2d, 0xbe, 0x82, 0x73, 0x67, 0x1f, 0x61, 0xe3, 0xd9, 0x25, 0xb8, 0xac, 0xaa, 0x41,
-	0x19, 0xb3, 0x24, 0xf0, 0xe8, 0xba, 0xbe, 0x15, 0x56, 0xf8, 0xf3, 0x71, 0x76, 0xa9, 0xb8, 0xee,
-	0x6b, 0x69, 0x30, 0x40, 0xca, 0x3c, 0xdd, 0xac, 0xf1, 0xb3, 0x7d, 0xf5, 0x33, 0x00, 0x00, 0xff,
-	0xff, 0xde, 0xd5, 0x28, 0xa3, 0xc2, 0x02, 0x00, 0x00,
+	// 457 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8d, 0x53, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0x55, 0x13, 0xd7, 0x49, 0x27, 0x2e, 0x82, 0x55, 0x55, 0xad, 0xa2, 0x1e, 0xaa, 0xaa, 0x07,
+	0x40, 0xc8, 0x12, 0x2d, 0x7f, 0xa0, 0x2d, 0x2d, 0x20, 0x95, 0x12, 0x2d, 0x86, 0xfb, 0xd6, 0x9e,
+	0x52, 0x8b, 0xd8, 0x6b, 0x79, 0x37, 0xc8, 0x39, 0xf3, 0x57, 0x39, 0xf0, 0x33, 0x98, 0x1d, 0x3b,
+	0x5f, 0x2a, 0x45, 0xdc, 0xe6, 0xbd, 0x99, 0xe7, 0x99, 0x37, 0xb3, 0x86, 0xa1, 0x6b, 0xe2, 0xaa,
+	0x36, 0xce, 0x88, 0x3e, 0xba, 0xfb, 0xa3, 0xdf, 0x21, 0xc8, 0x89, 0x87, 0x17, 0xa6, 0xa8, 0xa6,
+	0xe8, 0x30, 0xa9, 0x75, 0x69, 0x75, 0xea, 0x72, 0x53, 0x8a, 0x43, 0x18, 0x9d, 0x4f, 0x4d, 0xfa,
+	0xfd, 0x66, 0x56, 0xdc, 0x62, 0x2d, 0xb7, 0x0e, 0xb7, 0x9e, 0xef, 0xaa, 0x75, 0x4a, 0x1c, 0xc0,
+	0x0e, 0xc3, 0x24, 0x2f, 0x50, 0xf6, 0x28, 0x1f, 0xa8, 0x15, 0x21, 0xde, 0x40, 0x2f, 0x69, 0x64,
+	0x9f, 0xe8, 0xd1, 0xc9, 0x71, 0x4c, 0xed, 0xe2, 0xc7, 0x5a, 0xc5, 0x49, 0x93, 0xcc, 0x2b, 0x54,
+	0x54, 0x2f, 0x2e, 0x60, 0xa0, 0x30, 0xc5, 0xbc, 0x72, 0x32, 0x60, 0xe9, 0x8b, 0x7f, 0x4b, 0xbb,
+	0x62, 0xd6, 0x2f, 0x94, 0xe2, 0x18, 0x76, 0xcf, 0xb5, 0xc5, 0x2b, 0xc4, 0x09, 0xd6, 0xef, 0xb4,
+	0x95, 0xdb, 0xf4, 0xa9, 0x48, 0x6d, 0x92, 0xe3, 0x5f, 0x3d, 0x08, 0xdb, 0xce, 0xe2, 0x08, 0xa2,
+	0xb3, 0x34, 0x35, 0xb3, 0xd2, 0xdd, 0x98, 0x32, 0x45, 0x36, 0x1b, 0xa8, 0x0d, 0x4e, 0x8c, 0x61,
+	0x48, 0xaa, 0x49, 0x9d, 0xa7, 0xad, 0xd9, 0x48, 0x2d, 0x71, 0x97, 0xbb, 0xce, 0x8b, 0xdc, 0xb1,
+	0xe3, 0x40, 0x2d, 0xb1, 0xd8, 0x83, 0xed, 0xaf, 0x7a, 0x3a, 0x43, 0xf6, 0x13, 0xa9, 0x16, 0x08,
+	0x09, 0x83, 0x89, 0x9e, 0x4f, 0x8d, 0xce, 0xba, 0xe1, 0x16, 0x50, 0x08, 0x08, 0xde, 0x6b, 0x7b,
+	0x2f, 0x43, 0xa6, 0x39, 0x16, 0x4f, 0x68, 0x97, 0x46, 0x0e, 0x98, 0xa1, 0xc8, 0xd7, 0x5c, 0xd5,
+	0xa6, 0x90, 0xc3, 0xb6, 0xc6, 0xc7, 0xe2, 0x25, 0x3c, 0x5d, 0x5b, 0xcc, 0x87, 0x32, 0xc3, 0x46,
+	0xee, 0xf0, 0xd1, 0x1e, 0xf0, 0x5e, 0xef, 0x7d, 0x4b, 0xe0, 0x3c, 0xc7, 0xe2, 0x04, 0xf6, 0x3e,
+	0xea, 0x86, 0xfc, 0x98, 0x3a, 0x77, 0xf3, 0xd5, 0xee, 0x46, 0xdc, 0xe3, 0xaf, 0x39, 0xbf, 0x37,
+	0xe2, 0x57, 0xb5, 0x11, 0xd7, 0x6e, 0x70, 0xe3, 0x9f, 0x3d, 0x18, 0xad, 0x5d, 0xc9, 0x3b, 0x27,
+	0xfa, 0x8b, 0xc5, 0x8c, 0xd7, 0x4c, 0xce, 0x3b, 0x28, 0xf6, 0x21, 0xfc, 0xec, 0xb4, 0x9b, 0xd9,
+	0x6e, 0xbf, 0x1d, 0xa2, 0x37, 0xd1, 0xbf, 0x36, 0xdf, 0x68, 0xb1, 0x7d, 0x7a, 0x0f, 0xaf, 0xff,
+	0xfb, 0x3d, 0xc4, 0x24, 0xe2, 0x77, 0xe1, 0xd5, 0xe2, 0x15, 0x3c, 0xbb, 0xbc, 0xbb, 0x43, 0xaa,
+	0xfa, 0x81, 0xcb, 0x3b, 0xb6, 0x27, 0x79, 0x98, 0x18, 0x7f, 0x82, 0x41, 0xa7, 0xf6, 0xf3, 0x9e,
+	0x65, 0x59, 0x8d, 0xd6, 0x2e, 0xe6, 0xed, 0xa0, 0xdf, 0xe2, 0x5b, 0xed, 0x74, 0x37, 0x2d, 0xc7,
+	0xde, 0x43, 0x62, 0xaa, 0x3c, 0xb5, 0x3c, 0x2e, 0x79, 0x68, 0xd1, 0x6d, 0xc8, 0xbf, 0xdd, 0xe9,
+	0x1f, 0x63, 0x0c, 0x4b, 0x89, 0x82, 0x03, 0x00, 0x00,
 }
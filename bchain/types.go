@@ -175,6 +175,20 @@ type Erc20Contract struct {
 	Decimals int    `json:"decimals"`
 }
 
+// Eip1559Fee contains a priority fee tier of EIP-1559 fee estimation
+type Eip1559Fee struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// Eip1559Fees contains the base fee of the next block and priority fee tiers
+type Eip1559Fees struct {
+	BaseFeePerGas *big.Int
+	Low           *Eip1559Fee
+	Medium        *Eip1559Fee
+	High          *Eip1559Fee
+}
+
 // Erc20Transfer contains a single ERC20 token transfer
 type Erc20Transfer struct {
 	Contract string
@@ -183,6 +197,27 @@ type Erc20Transfer struct {
 	Tokens   big.Int
 }
 
+// EthereumLog contains a log emitted by a transaction, taken from the transaction receipt
+type EthereumLog struct {
+	Address string
+	Topics  []string
+	Data    string
+}
+
+// EthereumParsedInputParam contains a decoded parameter of a contract method call
+type EthereumParsedInputParam struct {
+	Type   string   `json:"type"`
+	Values []string `json:"values,omitempty"`
+}
+
+// EthereumParsedInputData contains the contract method called by a transaction and its decoded parameters
+type EthereumParsedInputData struct {
+	MethodId string                     `json:"methodId"`
+	Name     string                     `json:"name,omitempty"`
+	Function string                     `json:"function,omitempty"`
+	Params   []EthereumParsedInputParam `json:"params,omitempty"`
+}
+
 // OnNewBlockFunc is used to send notification about a new block
 type OnNewBlockFunc func(hash string, height uint32)
 
@@ -227,6 +262,7 @@ type BlockChain interface {
 	EthereumTypeEstimateGas(params map[string]interface{}) (uint64, error)
 	EthereumTypeGetErc20ContractInfo(contractDesc AddressDescriptor) (*Erc20Contract, error)
 	EthereumTypeGetErc20ContractBalance(addrDesc, contractDesc AddressDescriptor) (*big.Int, error)
+	EthereumTypeGetEip1559Fees() (*Eip1559Fees, error)
 }
 
 // BlockChainParser defines common interface to parsing and conversions of block chain data
@@ -262,4 +298,5 @@ type BlockChainParser interface {
 	ParseBlock(b []byte) (*Block, error)
 	// EthereumType specific
 	EthereumTypeGetErc20FromTx(tx *Tx) ([]Erc20Transfer, error)
+	EthereumTypeGetLogsFromTx(tx *Tx) ([]EthereumLog, error)
 }
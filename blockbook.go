@@ -8,6 +8,7 @@ import (
 	"blockbook/db"
 	"blockbook/server"
 	"context"
+	"encoding/json"
 	"flag"
 	"log"
 	"math/rand"
@@ -53,6 +54,7 @@ var (
 	syncChunk   = flag.Int("chunk", 100, "block chunk size for processing in bulk mode")
 	syncWorkers = flag.Int("workers", 8, "number of workers to process blocks in bulk mode")
 	dryRun      = flag.Bool("dryrun", false, "do not index blocks, only download")
+	blocksDir   = flag.String("blocksdir", "", "path to the blocks directory of the backend, initial sync imports the blocks from its blk*.dat files (BitcoinType coins only, default import using RPC)")
 
 	debugMode = flag.Bool("debug", false, "debug mode, return more verbose errors, reload templates on each request")
 
@@ -60,6 +62,16 @@ var (
 
 	publicBinding = flag.String("public", "", "public http server binding [address]:port[/path] (default no public server)")
 
+	esploraPath = flag.String("esplora", "", "path of the Esplora compatible REST API on the public server, e.g. esplora (BitcoinType coins only, default no Esplora API)")
+
+	rateLimitConfig = flag.String("ratelimitcfg", "", "path to the json file with the per client rate limits and API keys of the public server (default no rate limits)")
+
+	electrumBinding = flag.String("electrum", "", "electrum protocol server binding [address]:port, uses -certfile for SSL, requires and builds the script hash index (BitcoinType coins only, default no electrum server)")
+
+	grpcBinding = flag.String("grpc", "", "gRPC server binding [address]:port, uses -certfile for TLS (default no gRPC server)")
+
+	enableWebhooks = flag.Bool("webhooks", false, "send events of addresses and blocks to the webhooks registered using the internal server")
+
 	certFiles = flag.String("certfile", "", "to enable SSL specify path to certificate files without extension, expecting <certfile>.crt and <certfile>.key (default no SSL)")
 
 	explorerURL = flag.String("explorer", "", "address of blockchain explorer")
@@ -68,6 +80,21 @@ var (
 
 	computeColumnStats = flag.Bool("computedbstats", false, "compute column stats and exit")
 
+	checkDB       = flag.Bool("checkdb", false, "check that address balances match the indexed transactions and exit (BitcoinType coins only)")
+	checkDBRepair = flag.Bool("checkdbrepair", false, "with -checkdb, store the recomputed balances of the inconsistent addresses")
+	checkDBReport = flag.String("checkdbreport", "", "with -checkdb, write the json report to the given file (default stdout)")
+
+	migrate = flag.Bool("migrate", false, "migrate the database to the current data version and exit, an interrupted migration is resumed")
+
+	backup    = flag.String("backup", "", "create a consistent backup of the database to the given directory and exit")
+	backupDir = flag.String("backupdir", "", "directory for backups created by the internal server endpoint backup (default backups disabled)")
+	restore   = flag.String("restore", "", "validate the backup in the given directory and restore it to the empty datadir before start")
+
+	pruneDepth = flag.Int("prune", 0, "keep transaction history only for the given number of last blocks, older fully spent transactions and inputs are pruned from the index (BitcoinType coins only, default 0 - full index)")
+
+	logIndex        = flag.Bool("logindex", false, "index receipt logs by address and topic0 (EthereumType coins only)")
+	reconcileTokens = flag.Bool("reconciletokens", false, "synchronize index, reconcile indexed ERC20 token balances with the backend and exit (EthereumType coins only)")
+
 	// resync index at least each resyncIndexPeriodMs (could be more often if invoked by message from ZeroMQ)
 	resyncIndexPeriodMs = flag.Int("resyncindexperiod", 935093, "resync index period in milliseconds")
 
@@ -167,12 +194,33 @@ func main() {
 		glog.Fatal("rpc: ", err)
 	}
 
-	index, err = db.NewRocksDB(*dbPath, *dbCache, *dbMaxOpenFiles, chain.GetChainParser(), metrics)
+	if *restore != "" {
+		if err = db.RestoreBackup(*restore, *dbPath, coin); err != nil {
+			glog.Fatal("restore: ", err)
+		}
+	}
+
+	dbTuningProfile, err := coins.GetDBTuningFromConfig(*blockchain)
+	if err != nil {
+		glog.Fatal("config: ", err)
+	}
+	dbTuning, err := db.LoadDBTuning(dbTuningProfile)
+	if err != nil {
+		glog.Fatal("rocksDB: ", err)
+	}
+	index, err = db.NewRocksDB(*dbPath, *dbCache, *dbMaxOpenFiles, chain.GetChainParser(), metrics, dbTuning)
 	if err != nil {
 		glog.Fatal("rocksDB: ", err)
 	}
 	defer index.Close()
 
+	if *migrate {
+		if err = index.Migrate(coin, chanOsSignal); err != nil {
+			glog.Error("migrate: ", err)
+		}
+		return
+	}
+
 	internalState, err = newInternalState(coin, coinShortcut, coinLabel, index)
 	if err != nil {
 		glog.Error("internalState: ", err)
@@ -181,10 +229,29 @@ func main() {
 	index.SetInternalState(internalState)
 	if internalState.DbState != common.DbStateClosed {
 		if internalState.DbState == common.DbStateInconsistent {
-			glog.Error("internalState: database is in inconsistent state and cannot be used")
-			return
+			// the interrupted bulk import can continue from its last checkpoint
+			height, err := index.ResumeBulkConnect(chain.GetBlockHash, chain.GetBlock)
+			if err != nil {
+				glog.Error("internalState: ", err)
+				glog.Error("internalState: database is in inconsistent state and cannot be used")
+				return
+			}
+			glog.Warning("internalState: database was left in inconsistent state, continuing the import from checkpoint at height ", height)
+		} else {
+			glog.Warning("internalState: database was left in open state, possibly previous ungraceful shutdown")
 		}
-		glog.Warning("internalState: database was left in open state, possibly previous ungraceful shutdown")
+	}
+	if err = index.SetPruneDepth(uint32(*pruneDepth)); err != nil {
+		glog.Error("prune: ", err)
+		return
+	}
+	if err = index.SetScriptHashIndex(*electrumBinding != ""); err != nil {
+		glog.Error("electrum: ", err)
+		return
+	}
+	if err = index.SetLogIndex(*logIndex); err != nil {
+		glog.Error("logindex: ", err)
+		return
 	}
 
 	if *computeColumnStats {
@@ -197,10 +264,38 @@ func main() {
 		return
 	}
 
+	if *checkDB {
+		if *checkDBRepair {
+			internalState.DbState = common.DbStateOpen
+		}
+		r, err := index.CheckDB(*checkDBRepair, chanOsSignal)
+		if err != nil {
+			glog.Error("checkdb: ", err)
+			return
+		}
+		if err = writeCheckDBReport(r, *checkDBReport); err != nil {
+			glog.Error("checkdb: ", err)
+		}
+		return
+	}
+
+	if *backup != "" {
+		if err = index.Backup(*backup); err != nil {
+			glog.Error("backup: ", err)
+		}
+		return
+	}
+
 	syncWorker, err = db.NewSyncWorker(index, chain, *syncWorkers, *syncChunk, *blockFrom, *dryRun, chanOsSignal, metrics, internalState)
 	if err != nil {
 		glog.Fatalf("NewSyncWorker %v", err)
 	}
+	if *blocksDir != "" {
+		if err = syncWorker.SetBlocksDir(*blocksDir); err != nil {
+			glog.Error("blocksdir: ", err)
+			return
+		}
+	}
 
 	// set the DbState to open at this moment, after all important workers are initialized
 	internalState.DbState = common.DbStateOpen
@@ -236,6 +331,13 @@ func main() {
 		return
 	}
 
+	if *reconcileTokens {
+		if err = syncWorker.ReconcileTokenBalances(); err != nil {
+			glog.Error("reconcileTokens: ", err)
+		}
+		return
+	}
+
 	if txCache, err = db.NewTxCache(index, chain, metrics, internalState, !*noTxCache); err != nil {
 		glog.Error("txCache ", err)
 		return
@@ -248,7 +350,7 @@ func main() {
 
 	var internalServer *server.InternalServer
 	if *internalBinding != "" {
-		internalServer, err = server.NewInternalServer(*internalBinding, *certFiles, index, chain, txCache, internalState)
+		internalServer, err = server.NewInternalServer(*internalBinding, *certFiles, *backupDir, index, chain, txCache, internalState)
 		if err != nil {
 			glog.Error("https: ", err)
 			return
@@ -274,6 +376,19 @@ func main() {
 			glog.Error("socketio: ", err)
 			return
 		}
+		if *rateLimitConfig != "" {
+			rlc, err := server.LoadRateLimitConfig(*rateLimitConfig)
+			if err != nil {
+				glog.Error("rateLimiter: ", err)
+				return
+			}
+			rl, err := server.NewRateLimiter(rlc, metrics)
+			if err != nil {
+				glog.Error("rateLimiter: ", err)
+				return
+			}
+			publicServer.SetRateLimiter(rl)
+		}
 		go func() {
 			err = publicServer.Run()
 			if err != nil {
@@ -289,6 +404,61 @@ func main() {
 		callbacksOnNewTxAddr = append(callbacksOnNewTxAddr, publicServer.OnNewTxAddr)
 	}
 
+	var electrumServer *server.ElectrumServer
+	if *electrumBinding != "" {
+		electrumServer, err = server.NewElectrumServer(*electrumBinding, *certFiles, index, chain, txCache, metrics, internalState)
+		if err != nil {
+			glog.Error("electrum: ", err)
+			return
+		}
+		go func() {
+			err = electrumServer.Run()
+			if err != nil {
+				if err == server.ErrElectrumServerClosed {
+					glog.Info("electrum server: closed")
+				} else {
+					glog.Error(err)
+					return
+				}
+			}
+		}()
+		callbacksOnNewBlock = append(callbacksOnNewBlock, electrumServer.OnNewBlock)
+		callbacksOnNewTxAddr = append(callbacksOnNewTxAddr, electrumServer.OnNewTxAddr)
+	}
+
+	var grpcServer *server.GrpcServer
+	if *grpcBinding != "" {
+		grpcServer, err = server.NewGrpcServer(*grpcBinding, *certFiles, index, chain, txCache, metrics, internalState)
+		if err != nil {
+			glog.Error("grpc: ", err)
+			return
+		}
+		go func() {
+			if err := grpcServer.Run(); err != nil {
+				glog.Error("grpc server: ", err)
+			}
+		}()
+		callbacksOnNewBlock = append(callbacksOnNewBlock, grpcServer.OnNewBlock)
+		callbacksOnNewTxAddr = append(callbacksOnNewTxAddr, grpcServer.OnNewTxAddr)
+	}
+
+	var webhookDispatcher *server.WebhookDispatcher
+	if *enableWebhooks {
+		webhookDispatcher, err = server.NewWebhookDispatcher(index, chain, txCache, metrics, internalState)
+		if err != nil {
+			glog.Error("webhooks: ", err)
+			return
+		}
+		if internalServer != nil {
+			internalServer.SetWebhookDispatcher(webhookDispatcher)
+		} else {
+			glog.Warning("webhooks: the webhooks cannot be managed without the internal server")
+		}
+		go webhookDispatcher.Run()
+		callbacksOnNewBlock = append(callbacksOnNewBlock, webhookDispatcher.OnNewBlock)
+		callbacksOnNewTxAddr = append(callbacksOnNewTxAddr, webhookDispatcher.OnNewTxAddr)
+	}
+
 	if *synchronize {
 		internalState.SyncMode = true
 		internalState.InitialSync = true
@@ -310,7 +480,7 @@ func main() {
 
 	if *publicBinding != "" {
 		// start full public interface
-		publicServer.ConnectFullPublicInterface()
+		publicServer.ConnectFullPublicInterface(*esploraPath)
 	}
 
 	if *blockFrom >= 0 {
@@ -335,7 +505,7 @@ func main() {
 	}
 
 	if internalServer != nil || publicServer != nil || chain != nil {
-		waitForSignalAndShutdown(internalServer, publicServer, chain, 10*time.Second)
+		waitForSignalAndShutdown(internalServer, publicServer, electrumServer, grpcServer, webhookDispatcher, chain, 10*time.Second)
 	}
 
 	if *synchronize {
@@ -368,6 +538,22 @@ func blockbookAppInfoMetric(db *db.RocksDB, chain bchain.BlockChain, txCache *db
 	return nil
 }
 
+// writeCheckDBReport writes the report of the db check in json format to the file or to stdout if path is empty
+func writeCheckDBReport(r *db.CheckDBReport, path string) error {
+	w := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
 func newInternalState(coin, coinShortcut, coinLabel string, d *db.RocksDB) (*common.InternalState, error) {
 	is, err := d.LoadInternalState(coin)
 	if err != nil {
@@ -518,7 +704,7 @@ func pushSynchronizationHandler(nt bchain.NotificationType) {
 	}
 }
 
-func waitForSignalAndShutdown(internal *server.InternalServer, public *server.PublicServer, chain bchain.BlockChain, timeout time.Duration) {
+func waitForSignalAndShutdown(internal *server.InternalServer, public *server.PublicServer, electrum *server.ElectrumServer, grpc *server.GrpcServer, webhooks *server.WebhookDispatcher, chain bchain.BlockChain, timeout time.Duration) {
 	sig := <-chanOsSignal
 	atomic.StoreInt32(&inShutdown, 1)
 	glog.Infof("shutdown: %v", sig)
@@ -538,6 +724,24 @@ func waitForSignalAndShutdown(internal *server.InternalServer, public *server.Pu
 		}
 	}
 
+	if electrum != nil {
+		if err := electrum.Shutdown(ctx); err != nil {
+			glog.Error("electrum server: shutdown error: ", err)
+		}
+	}
+
+	if grpc != nil {
+		if err := grpc.Shutdown(ctx); err != nil {
+			glog.Error("grpc server: shutdown error: ", err)
+		}
+	}
+
+	if webhooks != nil {
+		if err := webhooks.Shutdown(ctx); err != nil {
+			glog.Error("webhooks: shutdown error: ", err)
+		}
+	}
+
 	if chain != nil {
 		if err := chain.Shutdown(ctx); err != nil {
 			glog.Error("rpc: shutdown error: ", err)
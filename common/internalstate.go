@@ -25,6 +25,17 @@ type InternalStateColumn struct {
 	Updated    time.Time `json:"updated"`
 }
 
+// MigrationState contains the progress of a running db migration
+type MigrationState struct {
+	FromVersion uint32    `json:"fromVersion"`
+	ToVersion   uint32    `json:"toVersion"`
+	Started     time.Time `json:"started"`
+	DoneColumns []string  `json:"doneColumns,omitempty"`
+	Column      string    `json:"column,omitempty"`
+	LastKey     []byte    `json:"lastKey,omitempty"`
+	Rows        int64     `json:"rows,omitempty"`
+}
+
 // InternalState contains the data of the internal state
 type InternalState struct {
 	mux sync.Mutex
@@ -51,6 +62,28 @@ type InternalState struct {
 	LastMempoolSync       time.Time `json:"lastMempoolSync"`
 
 	DbColumns []InternalStateColumn `json:"dbColumns"`
+
+	Migration *MigrationState `json:"migration,omitempty"`
+
+	// pruned index keeps the history only for last PruneDepth blocks, blocks up to PrunedHeight are pruned
+	// PruningFromHeight is the first block of an unfinished prune, the blocks PruningFromHeight-PrunedHeight are pruned again
+	PruneDepth        uint32 `json:"pruneDepth,omitempty"`
+	PrunedHeight      uint32 `json:"prunedHeight,omitempty"`
+	PruningFromHeight uint32 `json:"pruningFromHeight,omitempty"`
+
+	// the script hash index contains all addresses, it is kept complete only while it is enabled
+	ScriptHashIndex bool `json:"scriptHashIndex,omitempty"`
+
+	// the log index contains the logs of the blocks from LogIndexFromHeight, it is kept complete only while it is enabled
+	LogIndex           bool   `json:"logIndex,omitempty"`
+	LogIndexFromHeight uint32 `json:"logIndexFromHeight,omitempty"`
+
+	// name of the tuning profile of the db options
+	DbTuning string `json:"dbTuning,omitempty"`
+
+	// the last block fully stored by the interrupted bulk connect, the bulk connect can continue from it
+	BulkCheckpointHeight uint32 `json:"bulkCheckpointHeight,omitempty"`
+	BulkCheckpointHash   string `json:"bulkCheckpointHash,omitempty"`
 }
 
 // StartedSync signals start of synchronization
@@ -114,6 +147,58 @@ func (is *InternalState) GetMempoolSyncState() (bool, time.Time, int) {
 	return is.IsMempoolSynchronized, is.LastMempoolSync, is.MempoolSize
 }
 
+// SetPruneState sets the height of the last pruned block and the first block of an unfinished prune, 0 if there is none
+func (is *InternalState) SetPruneState(prunedHeight, pruningFromHeight uint32) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	is.PrunedHeight = prunedHeight
+	is.PruningFromHeight = pruningFromHeight
+}
+
+// GetPruneState gets the height of the last pruned block and the first block of an unfinished prune
+func (is *InternalState) GetPruneState() (uint32, uint32) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	return is.PrunedHeight, is.PruningFromHeight
+}
+
+// GetPrunedHeight gets the height of the last pruned block, 0 if the index is not pruned
+func (is *InternalState) GetPrunedHeight() uint32 {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	return is.PrunedHeight
+}
+
+// SetLogIndex sets if the log index is enabled and the height of the first block with indexed logs
+func (is *InternalState) SetLogIndex(enabled bool, fromHeight uint32) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	is.LogIndex = enabled
+	is.LogIndexFromHeight = fromHeight
+}
+
+// GetLogIndexFromHeight gets the height of the first block with indexed logs
+func (is *InternalState) GetLogIndexFromHeight() uint32 {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	return is.LogIndexFromHeight
+}
+
+// SetBulkCheckpoint sets the last block fully stored by the bulk connect, empty hash means no checkpoint
+func (is *InternalState) SetBulkCheckpoint(height uint32, hash string) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	is.BulkCheckpointHeight = height
+	is.BulkCheckpointHash = hash
+}
+
+// GetBulkCheckpoint gets the last block fully stored by the bulk connect, empty hash means no checkpoint
+func (is *InternalState) GetBulkCheckpoint() (uint32, string) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+	return is.BulkCheckpointHeight, is.BulkCheckpointHash
+}
+
 // AddDBColumnStats adds differences in column statistics to column stats
 func (is *InternalState) AddDBColumnStats(c int, rowsDiff int64, keyBytesDiff int64, valueBytesDiff int64) {
 	is.mux.Lock()
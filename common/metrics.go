@@ -15,6 +15,12 @@ type Metrics struct {
 	WebsocketSubscribes   *prometheus.CounterVec
 	WebsocketClients      prometheus.Gauge
 	WebsocketReqDuration  *prometheus.HistogramVec
+	ElectrumRequests      *prometheus.CounterVec
+	ElectrumClients       prometheus.Gauge
+	GrpcRequests          *prometheus.CounterVec
+	GrpcSubscriptions     prometheus.Gauge
+	WebhookDeliveries     *prometheus.CounterVec
+	RateLimitRequests     *prometheus.CounterVec
 	IndexResyncDuration   prometheus.Histogram
 	MempoolResyncDuration prometheus.Histogram
 	TxCacheEfficiency     *prometheus.CounterVec
@@ -97,6 +103,52 @@ func GetMetrics(coin string) (*Metrics, error) {
 		},
 		[]string{"method"},
 	)
+	metrics.ElectrumRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "blockbook_electrum_requests",
+			Help:        "Total number of electrum requests by method and status",
+			ConstLabels: Labels{"coin": coin},
+		},
+		[]string{"method", "status"},
+	)
+	metrics.ElectrumClients = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "blockbook_electrum_clients",
+			Help:        "Number of currently connected electrum clients",
+			ConstLabels: Labels{"coin": coin},
+		},
+	)
+	metrics.GrpcRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "blockbook_grpc_requests",
+			Help:        "Total number of grpc calls by method and status",
+			ConstLabels: Labels{"coin": coin},
+		},
+		[]string{"method", "status"},
+	)
+	metrics.GrpcSubscriptions = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "blockbook_grpc_subscriptions",
+			Help:        "Number of currently running grpc subscriptions",
+			ConstLabels: Labels{"coin": coin},
+		},
+	)
+	metrics.WebhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "blockbook_webhook_deliveries",
+			Help:        "Total number of webhook delivery attempts and dropped events by event and status",
+			ConstLabels: Labels{"coin": coin},
+		},
+		[]string{"event", "status"},
+	)
+	metrics.RateLimitRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "blockbook_ratelimit_requests",
+			Help:        "Total number of requests checked by the rate limiter by interface, tier and status",
+			ConstLabels: Labels{"coin": coin},
+		},
+		[]string{"interface", "tier", "status"},
+	)
 	metrics.IndexResyncDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:        "blockbook_index_resync_duration",
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"blockbook/common"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// only one backup can run at a time
+var backupMux sync.Mutex
+
+// Backup creates a consistent copy of the db in the directory dir, which must not exist
+// it uses RocksDB checkpoint, the db can be written during the backup
+// on the same filesystem the table files of the backup are hard linked to the db files, otherwise the files are copied
+func (d *RocksDB) Backup(dir string) error {
+	backupMux.Lock()
+	defer backupMux.Unlock()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return errors.Errorf("Backup directory %v already exists", dir)
+	}
+	if d.is != nil {
+		if d.is.InitialSync {
+			return errors.New("Backup is not possible during initial synchronization")
+		}
+		// store the current internal state so that it is part of the backup
+		if err := d.StoreInternalState(d.is); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	glog.Info("backup: creating backup in ", dir)
+	cp, err := d.db.NewCheckpoint()
+	if err != nil {
+		return err
+	}
+	defer cp.Destroy()
+	// log size 0 forces flush of memtables before the checkpoint is created
+	if err = cp.CreateCheckpoint(dir, 0); err != nil {
+		return errors.Annotatef(err, "CreateCheckpoint %v", dir)
+	}
+	// the db is open while the checkpoint is created, mark the backup as closed so that it is not reported as ungracefully closed
+	if err = updateBackupInternalState(dir, func(is *common.InternalState) {
+		if is.DbState == common.DbStateOpen {
+			is.DbState = common.DbStateClosed
+		}
+	}); err != nil {
+		return err
+	}
+	glog.Info("backup: finished in ", time.Since(start))
+	return nil
+}
+
+// openBackupDB opens the db in the path with all column families it contains, independently of the columns of the open index
+// and returns the handle of the default column family
+func openBackupDB(path string, readOnly bool) (*gorocksdb.DB, []*gorocksdb.ColumnFamilyHandle, *gorocksdb.ColumnFamilyHandle, error) {
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	names, err := gorocksdb.ListColumnFamilies(opts, path)
+	if err != nil {
+		return nil, nil, nil, errors.Annotatef(err, "ListColumnFamilies %v", path)
+	}
+	cfOptions := make([]*gorocksdb.Options, len(names))
+	for i := range cfOptions {
+		cfOptions[i] = opts
+	}
+	var db *gorocksdb.DB
+	var cfh []*gorocksdb.ColumnFamilyHandle
+	if readOnly {
+		db, cfh, err = gorocksdb.OpenDbForReadOnlyColumnFamilies(opts, path, names, cfOptions, false)
+	} else {
+		db, cfh, err = gorocksdb.OpenDbColumnFamilies(opts, path, names, cfOptions)
+	}
+	if err != nil {
+		return nil, nil, nil, errors.Annotatef(err, "Open %v", path)
+	}
+	for i, n := range names {
+		if n == cfNames[cfDefault] {
+			return db, cfh, cfh[i], nil
+		}
+	}
+	closeBackupDB(db, cfh)
+	return nil, nil, nil, errors.Errorf("Database in %v does not contain default column", path)
+}
+
+func closeBackupDB(db *gorocksdb.DB, cfh []*gorocksdb.ColumnFamilyHandle) {
+	for _, h := range cfh {
+		h.Destroy()
+	}
+	db.Close()
+}
+
+func getBackupInternalState(db *gorocksdb.DB, cf *gorocksdb.ColumnFamilyHandle, path string) (*common.InternalState, error) {
+	ro := gorocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	val, err := db.GetCF(ro, cf, []byte(internalStateKey))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	data := val.Data()
+	if len(data) == 0 {
+		return nil, errors.Errorf("Database in %v does not contain internal state", path)
+	}
+	return common.UnpackInternalState(data)
+}
+
+// ReadBackupInternalState returns the internal state stored in the backup, the backup is opened read only
+func ReadBackupInternalState(path string) (*common.InternalState, error) {
+	db, cfh, cf, err := openBackupDB(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBackupDB(db, cfh)
+	return getBackupInternalState(db, cf, path)
+}
+
+// updateBackupInternalState calls fn with the internal state stored in the backup and stores the changed state
+func updateBackupInternalState(path string, fn func(is *common.InternalState)) error {
+	db, cfh, cf, err := openBackupDB(path, false)
+	if err != nil {
+		return err
+	}
+	defer closeBackupDB(db, cfh)
+	is, err := getBackupInternalState(db, cf, path)
+	if err != nil {
+		return err
+	}
+	fn(is)
+	buf, err := is.Pack()
+	if err != nil {
+		return err
+	}
+	wo := gorocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	return db.PutCF(wo, cf, []byte(internalStateKey), buf)
+}
+
+// validateBackupState checks that the backup can be used by this version of blockbook for the coin
+func validateBackupState(is *common.InternalState, rpcCoin string) error {
+	if is.Coin != rpcCoin {
+		return errors.Errorf("Coins do not match. Backup coin %v, RPC coin %v", is.Coin, rpcCoin)
+	}
+	if is.DbState == common.DbStateInconsistent {
+		return errors.New("Backup is in inconsistent state and cannot be used")
+	}
+	if is.Migration != nil {
+		return errors.Errorf("Backup was created during unfinished migration from version %v to %v", is.Migration.FromVersion, is.Migration.ToVersion)
+	}
+	for _, c := range is.DbColumns {
+		if c.Version > dbVersion {
+			return errors.Errorf("Backup version %v of column '%v' is newer than the required version %v", c.Version, c.Name, dbVersion)
+		}
+		if c.Version < dbVersion {
+			for v := c.Version; v < dbVersion; v++ {
+				if findMigration(v) == nil {
+					return errors.Errorf("Backup version %v of column '%v' does not match the required version %v. Backup is not compatible.", c.Version, c.Name, dbVersion)
+				}
+			}
+			glog.Warningf("restore: backup version %v of column '%v' must be migrated to version %v using -migrate", c.Version, c.Name, dbVersion)
+		}
+	}
+	return nil
+}
+
+// RestoreBackup validates the backup in backupDir created by Backup and restores it to the db directory path,
+// which must not exist or must be empty
+func RestoreBackup(backupDir, path, rpcCoin string) error {
+	if files, err := ioutil.ReadDir(path); err == nil && len(files) > 0 {
+		return errors.Errorf("Database directory %v is not empty", path)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	start := time.Now()
+	glog.Info("restore: validating backup ", backupDir)
+	is, err := ReadBackupInternalState(backupDir)
+	if err != nil {
+		return err
+	}
+	if err = validateBackupState(is, rpcCoin); err != nil {
+		return err
+	}
+	glog.Info("restore: restoring backup of height ", is.BestHeight, " to ", path)
+	if err = os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(backupDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		src, dst := filepath.Join(backupDir, f.Name()), filepath.Join(path, f.Name())
+		// only the table files are immutable and can be shared by the backup and the db
+		if filepath.Ext(f.Name()) == ".sst" {
+			err = linkOrCopyFile(src, dst)
+		} else {
+			err = copyFile(src, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	glog.Info("restore: finished in ", time.Since(start))
+	return nil
+}
+
+// linkOrCopyFile creates hard link of the file, if it is not possible, the file is copied
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyFile(src, dst)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
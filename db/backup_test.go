@@ -0,0 +1,73 @@
+// build unittest
+
+package db
+
+import (
+	"blockbook/common"
+	"blockbook/tests/dbtestdata"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRocksDB_BackupRestore(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	d.is.DbState = common.DbStateOpen
+
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "testbackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	backupDir := filepath.Join(tmp, "backup")
+	if err := d.Backup(backupDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Backup(backupDir); err == nil {
+		t.Error("Backup() expected error for existing directory")
+	}
+
+	// changes after the backup must not be part of it
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+
+	is, err := ReadBackupInternalState(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is.Coin != "coin-unittest" || is.DbState != common.DbStateClosed {
+		t.Errorf("backup internal state coin %v, dbState %v, want coin-unittest, %v", is.Coin, is.DbState, common.DbStateClosed)
+	}
+
+	if err := RestoreBackup(backupDir, filepath.Join(tmp, "othercoin"), "other-coin"); err == nil {
+		t.Error("RestoreBackup() expected error for different coin")
+	}
+	if err := RestoreBackup(backupDir, d.path, "coin-unittest"); err == nil {
+		t.Error("RestoreBackup() expected error for not empty directory")
+	}
+
+	restored := filepath.Join(tmp, "restored")
+	if err := RestoreBackup(backupDir, restored, "coin-unittest"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRocksDB(restored, 100000, -1, d.chainParser, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeAndDestroyRocksDB(t, r)
+	ris, err := r.LoadInternalState("coin-unittest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetInternalState(ris)
+	verifyAfterBitcoinTypeBlock1(t, r, false)
+}
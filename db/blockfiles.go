@@ -0,0 +1,308 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// blocksDirTipDistance is the number of the newest blocks, which are not imported from the block files
+// they may not be flushed to the files yet and are synchronized using the backend RPC
+const blocksDirTipDistance = 100
+
+const (
+	// each block in the block file is preceded by the network magic and the size of the block
+	blockRecordHeaderLen = 8
+	blockHeaderLen       = 80
+	blockFilesXorKeyLen  = 8
+)
+
+// blockFiles are the blk*.dat files of the bitcoin-type backend
+type blockFiles struct {
+	files  []string
+	xorKey []byte
+}
+
+// blockFileLocation is the position of the block in the block files
+type blockFileLocation struct {
+	file   int
+	offset int64
+	size   uint32
+}
+
+func openBlockFiles(dir string) (*blockFiles, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "blk*.dat"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("No block files found in %s", dir)
+	}
+	// the files are numbered with fixed width, the string sort keeps their order
+	sort.Strings(files)
+	bf := &blockFiles{files: files}
+	// newer versions of the backend obfuscate the block files using the key stored in xor.dat
+	key, err := ioutil.ReadFile(filepath.Join(dir, "xor.dat"))
+	if err == nil {
+		if len(key) != blockFilesXorKeyLen {
+			return nil, errors.Errorf("Invalid xor key in %s", dir)
+		}
+		for _, k := range key {
+			if k != 0 {
+				bf.xorKey = key
+				break
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return bf, nil
+}
+
+// readAt reads len(buf) bytes of the file from the offset and removes the obfuscation
+func (bf *blockFiles) readAt(f *os.File, buf []byte, offset int64) error {
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return err
+	}
+	if bf.xorKey != nil {
+		for i := range buf {
+			buf[i] ^= bf.xorKey[(offset+int64(i))%blockFilesXorKeyLen]
+		}
+	}
+	return nil
+}
+
+// blockHeaderHash returns double sha256 of the block header, i.e. the block hash in the internal byte order
+func blockHeaderHash(header []byte) []byte {
+	h := sha256.Sum256(header[:blockHeaderLen])
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// scan finds the blocks in the block files
+// hashes maps block hashes in the internal byte order to indexes of the returned locations, not found blocks have zero size
+func (bf *blockFiles) scan(hashes map[string]int, stop chan os.Signal) ([]blockFileLocation, error) {
+	locations := make([]blockFileLocation, len(hashes))
+	buf := make([]byte, blockRecordHeaderLen+blockHeaderLen)
+	var magic uint32
+	var found int
+	for i, name := range bf.files {
+		select {
+		case <-stop:
+			return nil, errors.Errorf("Scan of block files interrupted at %s", name)
+		default:
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var offset int64
+		for offset+int64(len(buf)) <= fi.Size() {
+			if err = bf.readAt(f, buf, offset); err != nil {
+				f.Close()
+				return nil, errors.Annotatef(err, "%s offset %d", name, offset)
+			}
+			m := binary.LittleEndian.Uint32(buf)
+			if magic == 0 {
+				magic = m
+			}
+			// the rest of the file is preallocated space without blocks
+			if m == 0 || m != magic {
+				break
+			}
+			size := binary.LittleEndian.Uint32(buf[4:])
+			if size < blockHeaderLen || offset+blockRecordHeaderLen+int64(size) > fi.Size() {
+				glog.Warning("blockfiles: invalid block record in ", name, " at offset ", offset)
+				break
+			}
+			if j, ok := hashes[string(blockHeaderHash(buf[blockRecordHeaderLen:]))]; ok {
+				locations[j] = blockFileLocation{file: i, offset: offset + blockRecordHeaderLen, size: size}
+				found++
+			}
+			offset += blockRecordHeaderLen + int64(size)
+		}
+		f.Close()
+		if i > 0 && i%100 == 0 {
+			glog.Info("blockfiles: scanned ", i, " of ", len(bf.files), " files, found ", found, " blocks")
+		}
+	}
+	glog.Info("blockfiles: scanned ", len(bf.files), " files, found ", found, " of ", len(hashes), " blocks")
+	return locations, nil
+}
+
+// blockFileReader reads blocks from the block files, keeping the last used file open
+type blockFileReader struct {
+	bf   *blockFiles
+	f    *os.File
+	file int
+}
+
+func (r *blockFileReader) read(loc *blockFileLocation) ([]byte, error) {
+	if r.f == nil || r.file != loc.file {
+		r.close()
+		f, err := os.Open(r.bf.files[loc.file])
+		if err != nil {
+			return nil, err
+		}
+		r.f = f
+		r.file = loc.file
+	}
+	buf := make([]byte, loc.size)
+	if err := r.bf.readAt(r.f, buf, loc.offset); err != nil {
+		return nil, errors.Annotatef(err, "%s offset %d", r.bf.files[loc.file], loc.offset)
+	}
+	return buf, nil
+}
+
+func (r *blockFileReader) close() {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+}
+
+// SetBlocksDir sets the directory with blk*.dat files of the backend, from which the initial sync imports the blocks
+func (w *SyncWorker) SetBlocksDir(dir string) error {
+	if w.chain.GetChainParser().GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("Import from block files is supported only for BitcoinType coins")
+	}
+	if _, err := openBlockFiles(dir); err != nil {
+		return err
+	}
+	w.blocksDir = dir
+	return nil
+}
+
+// ConnectBlocksFromFiles imports blocks lower-higher from the blk*.dat files of the backend in the directory dir
+// the blocks are ordered by the block hashes of the backend, the import stops at the first block not found in the files
+// tip is the best height of the backend, the data for rollback are stored for the blocks near the tip
+func (w *SyncWorker) ConnectBlocksFromFiles(dir string, lower, higher, tip uint32) error {
+	bf, err := openBlockFiles(dir)
+	if err != nil {
+		return err
+	}
+	hashes := make(map[string]int, higher-lower+1)
+	for h := lower; h <= higher; h++ {
+		select {
+		case <-w.chanOsSignal:
+			return errors.Errorf("ConnectBlocksFromFiles interrupted at height %d", h)
+		default:
+		}
+		hash, err := w.chain.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		b, err := hex.DecodeString(hash)
+		if err != nil || len(b) != sha256.Size {
+			return errors.Errorf("Invalid hash %v of block %d", hash, h)
+		}
+		reverseBytes(b)
+		hashes[string(b)] = int(h - lower)
+		if h > 0 && h%100000 == 0 {
+			glog.Info("blockfiles: loaded hashes of blocks up to ", h)
+		}
+	}
+	locations, err := bf.scan(hashes, w.chanOsSignal)
+	if err != nil {
+		return err
+	}
+	// import blocks up to the first missing block, the rest is synchronized using the backend
+	var count int
+	for count < len(locations) && locations[count].size > 0 {
+		count++
+	}
+	if count == 0 {
+		glog.Warning("blockfiles: block ", lower, " not found in block files, nothing imported")
+		return nil
+	}
+	higher = lower + uint32(count) - 1
+	workers := w.syncWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	glog.Infof("blockfiles: import of blocks %d-%d, using %d workers", lower, higher, workers)
+	parser := w.chain.GetChainParser()
+	var wg sync.WaitGroup
+	bch := make([]chan *bchain.Block, workers)
+	for i := range bch {
+		bch[i] = make(chan *bchain.Block)
+	}
+	writeBlockDone := make(chan struct{})
+	terminating := make(chan struct{})
+	// the worker i parses blocks with height h, where h%workers == i, the writer expects them in the channel bch[i]
+	parseBlockWorker := func(i int) {
+		defer wg.Done()
+		defer close(bch[i])
+		r := blockFileReader{bf: bf}
+		defer r.close()
+		n := uint32(workers)
+		start := time.Now()
+		for h := lower + (uint32(i)+n-lower%n)%n; h <= higher; h += n {
+			data, err := r.read(&locations[h-lower])
+			var block *bchain.Block
+			if err == nil {
+				block, err = parser.ParseBlock(data)
+			}
+			if err != nil {
+				// the writer stops before this block, the rest is synchronized using the backend
+				glog.Error("parseBlockWorker ", i, " block ", h, " error ", err, ". Exiting...")
+				return
+			}
+			hash := blockHeaderHash(data)
+			reverseBytes(hash)
+			block.Hash = hex.EncodeToString(hash)
+			block.Height = h
+			if h > 0 && h%1000 == 0 {
+				glog.Info("importing block ", h, " ", block.Hash, ", elapsed ", time.Since(start), " ", w.db.GetAndResetConnectBlockStats())
+				start = time.Now()
+			}
+			if w.dryRun {
+				continue
+			}
+			select {
+			case bch[i] <- block:
+			case <-terminating:
+				return
+			}
+		}
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go parseBlockWorker(i)
+	}
+	go w.writeBlocksInOrder(bch, lower, tip, terminating, writeBlockDone)
+	select {
+	case <-writeBlockDone:
+	case <-w.chanOsSignal:
+		err = errors.Errorf("ConnectBlocksFromFiles interrupted")
+		close(terminating)
+		<-writeBlockDone
+	}
+	if err == nil {
+		// stop the workers, which are waiting to pass blocks after the failed block
+		close(terminating)
+	}
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,113 @@
+// build unittest
+
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testBlockData returns fake block data, only the header is used to compute the block hash
+func testBlockData(seed byte, size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = seed + byte(i)
+	}
+	return b
+}
+
+func writeTestBlockFile(t *testing.T, name string, xorKey []byte, blocks ...[]byte) {
+	var buf bytes.Buffer
+	for _, b := range blocks {
+		h := make([]byte, blockRecordHeaderLen)
+		binary.LittleEndian.PutUint32(h, 0x0709110b)
+		binary.LittleEndian.PutUint32(h[4:], uint32(len(b)))
+		buf.Write(h)
+		buf.Write(b)
+	}
+	// obfuscate the data including the preallocated space
+	buf.Write(make([]byte, 64))
+	data := buf.Bytes()
+	if xorKey != nil {
+		for i := range data {
+			data[i] ^= xorKey[i%len(xorKey)]
+		}
+	}
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_blockFiles(t *testing.T) {
+	blockA := testBlockData(1, 100)
+	blockB := testBlockData(2, 250)
+	blockC := testBlockData(3, 80)
+	blockD := testBlockData(4, 120)
+	tests := []struct {
+		name   string
+		xorKey []byte
+	}{
+		{name: "plain"},
+		{name: "zero xor key", xorKey: make([]byte, blockFilesXorKeyLen)},
+		{name: "obfuscated", xorKey: []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "testblocks")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if _, err := openBlockFiles(dir); err == nil {
+				t.Fatal("openBlockFiles() expected error for directory without block files")
+			}
+			if tt.xorKey != nil {
+				if err := ioutil.WriteFile(filepath.Join(dir, "xor.dat"), tt.xorKey, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// blocks are stored out of order
+			writeTestBlockFile(t, filepath.Join(dir, "blk00001.dat"), tt.xorKey, blockC)
+			writeTestBlockFile(t, filepath.Join(dir, "blk00000.dat"), tt.xorKey, blockB, blockA)
+			bf, err := openBlockFiles(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashes := map[string]int{
+				string(blockHeaderHash(blockA)): 0,
+				string(blockHeaderHash(blockB)): 1,
+				string(blockHeaderHash(blockC)): 2,
+				string(blockHeaderHash(blockD)): 3,
+			}
+			got, err := bf.scan(hashes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []blockFileLocation{
+				{file: 0, offset: 2*blockRecordHeaderLen + 250, size: 100},
+				{file: 0, offset: blockRecordHeaderLen, size: 250},
+				{file: 1, offset: blockRecordHeaderLen, size: 80},
+				{},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("scan() = %+v, want %+v", got, want)
+			}
+			r := blockFileReader{bf: bf}
+			defer r.close()
+			for i, b := range [][]byte{blockA, blockB, blockC} {
+				data, err := r.read(&got[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, b) {
+					t.Errorf("read(%+v) = %x, want %x", got[i], data, b)
+				}
+			}
+		})
+	}
+}
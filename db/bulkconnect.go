@@ -2,21 +2,29 @@ package db
 
 import (
 	"blockbook/bchain"
+	"math/big"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/juju/errors"
 	"github.com/tecbot/gorocksdb"
 )
 
 // bulk connect
 // in bulk mode the data are cached and stored to db in batches
 // it speeds up the import in two ways:
-// 1) balances and txAddresses are modified several times during the import, there is a chance that the modifications are done before write to DB
+// 1) balances and txAddresses (addressContracts and tokenBalances for EthereumType) are modified several times during the import,
+//    there is a chance that the modifications are done before write to DB
 // 2) rocksdb seems to handle better fewer larger batches than continuous stream of smaller batches
+// all cached data are stored periodically and after that the last connected block is recorded as a checkpoint
+// if the bulk connect is interrupted, the db is in inconsistent state, however it can be resumed from the last checkpoint
+// the checkpoint is removed before the first partial store of balances or txAddresses (addressContracts and tokenBalances)
+// after it, the resumed import would apply the partially stored data of the blocks above the checkpoint twice
 
 type bulkAddresses struct {
 	bi        BlockInfo
 	addresses map[string][]outpoint
+	logs      map[string][]logOutpoint
 }
 
 // BulkConnect is used to connect blocks in bulk, faster but if interrupted inconsistent way
@@ -27,24 +35,41 @@ type BulkConnect struct {
 	bulkAddressesCount int
 	txAddressesMap     map[string]*TxAddresses
 	balances           map[string]*AddrBalance
+	addressContracts   map[string]*AddrContracts
+	contractHolders    map[string]bool
+	tokenBalances      map[string]*big.Int
 	height             uint32
+	hash               string
+	lastCheckpoint     time.Time
+	checkpointStored   bool
 }
 
 const (
-	maxBulkAddresses      = 200000
-	maxBulkTxAddresses    = 500000
-	partialStoreAddresses = maxBulkTxAddresses / 10
-	maxBulkBalances       = 800000
-	partialStoreBalances  = maxBulkBalances / 10
+	maxBulkAddresses          = 200000
+	maxBulkTxAddresses        = 500000
+	partialStoreAddresses     = maxBulkTxAddresses / 10
+	maxBulkBalances           = 800000
+	partialStoreBalances      = maxBulkBalances / 10
+	maxBulkAddrContracts      = 800000
+	partialStoreAddrContracts = maxBulkAddrContracts / 10
+	maxBulkTokenBalances      = 800000
+	partialStoreTokenBalances = maxBulkTokenBalances / 10
 )
 
+// bulkCheckpointInterval is the minimum time between two checkpoints of the bulk connect
+var bulkCheckpointInterval = 10 * time.Minute
+
 // InitBulkConnect initializes bulk connect and switches DB to inconsistent state
 func (d *RocksDB) InitBulkConnect() (*BulkConnect, error) {
 	bc := &BulkConnect{
-		d:              d,
-		chainType:      d.chainParser.GetChainType(),
-		txAddressesMap: make(map[string]*TxAddresses),
-		balances:       make(map[string]*AddrBalance),
+		d:                d,
+		chainType:        d.chainParser.GetChainType(),
+		txAddressesMap:   make(map[string]*TxAddresses),
+		balances:         make(map[string]*AddrBalance),
+		addressContracts: make(map[string]*AddrContracts),
+		contractHolders:  make(map[string]bool),
+		tokenBalances:    make(map[string]*big.Int),
+		lastCheckpoint:   time.Now(),
 	}
 	if err := d.SetInconsistentState(true); err != nil {
 		return nil, err
@@ -151,27 +176,242 @@ func (b *BulkConnect) parallelStoreBalances(c chan error, all bool) {
 	c <- nil
 }
 
+func (b *BulkConnect) storeAddressContracts(wb *gorocksdb.WriteBatch, all bool) (int, error) {
+	var acm map[string]*AddrContracts
+	if all {
+		acm = b.addressContracts
+		b.addressContracts = make(map[string]*AddrContracts)
+	} else {
+		acm = make(map[string]*AddrContracts)
+		// store some random address contracts
+		for k, a := range b.addressContracts {
+			acm[k] = a
+			delete(b.addressContracts, k)
+			if len(acm) >= partialStoreAddrContracts {
+				break
+			}
+		}
+	}
+	if err := b.d.storeAddressContracts(wb, acm); err != nil {
+		return 0, err
+	}
+	return len(acm), nil
+}
+
+func (b *BulkConnect) parallelStoreAddressContracts(c chan error, all bool) {
+	defer close(c)
+	start := time.Now()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	count, err := b.storeAddressContracts(wb, all)
+	if err != nil {
+		c <- err
+		return
+	}
+	if err := b.d.db.Write(b.d.wo, wb); err != nil {
+		c <- err
+		return
+	}
+	glog.Info("rocksdb: height ", b.height, ", stored ", count, " addressContracts, ", len(b.addressContracts), " remaining, done in ", time.Since(start))
+	c <- nil
+}
+
+func (b *BulkConnect) storeTokenBalances(wb *gorocksdb.WriteBatch, all bool) (int, error) {
+	var tbm map[string]*big.Int
+	if all {
+		tbm = b.tokenBalances
+		b.tokenBalances = make(map[string]*big.Int)
+	} else {
+		tbm = make(map[string]*big.Int)
+		// store some random token balances
+		for k, a := range b.tokenBalances {
+			tbm[k] = a
+			delete(b.tokenBalances, k)
+			if len(tbm) >= partialStoreTokenBalances {
+				break
+			}
+		}
+	}
+	if err := b.d.storeTokenBalances(wb, tbm); err != nil {
+		return 0, err
+	}
+	return len(tbm), nil
+}
+
+func (b *BulkConnect) parallelStoreTokenBalances(c chan error, all bool) {
+	defer close(c)
+	start := time.Now()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	count, err := b.storeTokenBalances(wb, all)
+	if err != nil {
+		c <- err
+		return
+	}
+	if err := b.d.db.Write(b.d.wo, wb); err != nil {
+		c <- err
+		return
+	}
+	glog.Info("rocksdb: height ", b.height, ", stored ", count, " token balances, ", len(b.tokenBalances), " remaining, done in ", time.Since(start))
+	c <- nil
+}
+
 func (b *BulkConnect) storeBulkAddresses(wb *gorocksdb.WriteBatch) error {
 	for _, ba := range b.bulkAddresses {
 		if err := b.d.storeAddresses(wb, ba.bi.Height, ba.addresses); err != nil {
 			return err
 		}
+		if ba.logs != nil {
+			b.d.storeLogs(wb, ba.bi.Height, ba.logs)
+		}
 		if err := b.d.writeHeight(wb, ba.bi.Height, &ba.bi, opInsert); err != nil {
 			return err
 		}
 	}
+	// contract holders are only added during the connect, they can be stored together with the addresses
+	if len(b.contractHolders) > 0 {
+		if err := b.d.storeContractHolders(wb, b.contractHolders); err != nil {
+			return err
+		}
+		b.contractHolders = make(map[string]bool)
+	}
 	b.bulkAddressesCount = 0
 	b.bulkAddresses = b.bulkAddresses[:0]
 	return nil
 }
 
+func newBulkBlockInfo(block *bchain.Block) BlockInfo {
+	return BlockInfo{
+		Hash:   block.Hash,
+		Time:   block.Time,
+		Txs:    uint32(len(block.Txs)),
+		Size:   uint32(block.Size),
+		Height: block.Height,
+	}
+}
+
+// storeBulkAddressesAndBlockTxs writes the cached addresses if there are too many of them or if forced
+// and the data for rollback of the block if storeBlockTxs is set
+func (b *BulkConnect) storeBulkAddressesAndBlockTxs(force bool, storeBlockTxs func(wb *gorocksdb.WriteBatch) error) error {
+	if !force && b.bulkAddressesCount <= maxBulkAddresses && storeBlockTxs == nil {
+		return nil
+	}
+	// open WriteBatch only if going to write
+	start := time.Now()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	bac := b.bulkAddressesCount
+	if force || b.bulkAddressesCount > maxBulkAddresses {
+		if err := b.storeBulkAddresses(wb); err != nil {
+			return err
+		}
+	}
+	if storeBlockTxs != nil {
+		if err := storeBlockTxs(wb); err != nil {
+			return err
+		}
+	}
+	if err := b.d.db.Write(b.d.wo, wb); err != nil {
+		return err
+	}
+	if bac > b.bulkAddressesCount {
+		glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
+	}
+	return nil
+}
+
+func waitForParallelStore(c ...chan error) error {
+	var err error
+	for _, ch := range c {
+		if ch != nil {
+			if e := <-ch; e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// storeAll writes all cached data, the balances and txAddresses (addressContracts and tokenBalances) in parallel
+func (b *BulkConnect) storeAll() error {
+	start := time.Now()
+	var storeAddressesChan, storeBalancesChan chan error
+	if b.chainType == bchain.ChainEthereumType {
+		storeAddressesChan = make(chan error)
+		go b.parallelStoreAddressContracts(storeAddressesChan, true)
+		storeBalancesChan = make(chan error)
+		go b.parallelStoreTokenBalances(storeBalancesChan, true)
+	} else {
+		storeAddressesChan = make(chan error)
+		go b.parallelStoreTxAddresses(storeAddressesChan, true)
+		storeBalancesChan = make(chan error)
+		go b.parallelStoreBalances(storeBalancesChan, true)
+	}
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	bac := b.bulkAddressesCount
+	err := b.storeBulkAddresses(wb)
+	if err == nil {
+		err = b.d.db.Write(b.d.wo, wb)
+	}
+	if e := waitForParallelStore(storeAddressesChan, storeBalancesChan); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
+	return nil
+}
+
+// storeCheckpoint stores all cached data and after that records the last connected block as the checkpoint
+// it is done only if the checkpoint interval elapsed, the cache is then filled again from scratch
+func (b *BulkConnect) storeCheckpoint() error {
+	if time.Since(b.lastCheckpoint) <= bulkCheckpointInterval {
+		return nil
+	}
+	if err := b.storeAll(); err != nil {
+		return err
+	}
+	b.d.is.SetBulkCheckpoint(b.height, b.hash)
+	if err := b.d.storeState(b.d.is); err != nil {
+		return err
+	}
+	b.checkpointStored = true
+	b.lastCheckpoint = time.Now()
+	glog.Info("rocksdb: height ", b.height, ", stored bulk connect checkpoint")
+	return nil
+}
+
+// removeCheckpoint removes the stored checkpoint, it must be done before a partial store of the cached data
+func (b *BulkConnect) removeCheckpoint() error {
+	if !b.checkpointStored {
+		return nil
+	}
+	b.d.is.SetBulkCheckpoint(0, "")
+	if err := b.d.storeState(b.d.is); err != nil {
+		return err
+	}
+	b.checkpointStored = false
+	glog.Info("rocksdb: height ", b.height, ", removed bulk connect checkpoint")
+	return nil
+}
+
 // ConnectBlock connects block in bulk mode
 func (b *BulkConnect) ConnectBlock(block *bchain.Block, storeBlockTxs bool) error {
 	b.height = block.Height
-	// for non bitcoin types connect blocks in non bulk mode
-	if b.chainType != bchain.ChainBitcoinType {
-		return b.d.ConnectBlock(block)
+	b.hash = block.Hash
+	switch b.chainType {
+	case bchain.ChainBitcoinType:
+		return b.connectBlockBitcoinType(block, storeBlockTxs)
+	case bchain.ChainEthereumType:
+		return b.connectBlockEthereumType(block, storeBlockTxs)
 	}
+	// for other types connect blocks in non bulk mode
+	return b.d.ConnectBlock(block)
+}
+
+func (b *BulkConnect) connectBlockBitcoinType(block *bchain.Block, storeBlockTxs bool) error {
 	addresses := make(map[string][]outpoint)
 	if err := b.d.processAddressesBitcoinType(block, addresses, b.txAddressesMap, b.balances); err != nil {
 		return err
@@ -180,6 +420,9 @@ func (b *BulkConnect) ConnectBlock(block *bchain.Block, storeBlockTxs bool) erro
 	var sa bool
 	if len(b.txAddressesMap) > maxBulkTxAddresses || len(b.balances) > maxBulkBalances {
 		sa = true
+		if err := b.removeCheckpoint(); err != nil {
+			return err
+		}
 		if len(b.txAddressesMap)+partialStoreAddresses > maxBulkTxAddresses {
 			storeAddressesChan = make(chan error)
 			go b.parallelStoreTxAddresses(storeAddressesChan, false)
@@ -190,77 +433,97 @@ func (b *BulkConnect) ConnectBlock(block *bchain.Block, storeBlockTxs bool) erro
 		}
 	}
 	b.bulkAddresses = append(b.bulkAddresses, bulkAddresses{
-		bi: BlockInfo{
-			Hash:   block.Hash,
-			Time:   block.Time,
-			Txs:    uint32(len(block.Txs)),
-			Size:   uint32(block.Size),
-			Height: block.Height,
-		},
+		bi:        newBulkBlockInfo(block),
 		addresses: addresses,
 	})
 	b.bulkAddressesCount += len(addresses)
-	// open WriteBatch only if going to write
-	if sa || b.bulkAddressesCount > maxBulkAddresses || storeBlockTxs {
-		start := time.Now()
-		wb := gorocksdb.NewWriteBatch()
-		defer wb.Destroy()
-		bac := b.bulkAddressesCount
-		if sa || b.bulkAddressesCount > maxBulkAddresses {
-			if err := b.storeBulkAddresses(wb); err != nil {
-				return err
-			}
+	var sbt func(wb *gorocksdb.WriteBatch) error
+	if storeBlockTxs {
+		sbt = func(wb *gorocksdb.WriteBatch) error {
+			return b.d.storeAndCleanupBlockTxs(wb, block)
 		}
-		if storeBlockTxs {
-			if err := b.d.storeAndCleanupBlockTxs(wb, block); err != nil {
-				return err
-			}
-		}
-		if err := b.d.db.Write(b.d.wo, wb); err != nil {
+	}
+	err := b.storeBulkAddressesAndBlockTxs(sa, sbt)
+	if e := waitForParallelStore(storeAddressesChan, storeBalancesChan); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return b.storeCheckpoint()
+}
+
+func (b *BulkConnect) connectBlockEthereumType(block *bchain.Block, storeBlockTxs bool) error {
+	addresses := make(map[string][]outpoint)
+	blockTxs, transfers, err := b.d.processAddressesEthereumType(block, addresses, b.addressContracts, b.contractHolders, b.tokenBalances)
+	if err != nil {
+		return err
+	}
+	var logs map[string][]logOutpoint
+	if b.d.logIndex {
+		logs = make(map[string][]logOutpoint)
+		if err := b.d.processLogsEthereumType(block, logs); err != nil {
 			return err
 		}
-		if bac > b.bulkAddressesCount {
-			glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
-		}
 	}
-	if storeAddressesChan != nil {
-		if err := <-storeAddressesChan; err != nil {
+	var storeAddrContractsChan, storeTokenBalancesChan chan error
+	var sa bool
+	if len(b.addressContracts) > maxBulkAddrContracts || len(b.tokenBalances) > maxBulkTokenBalances {
+		sa = true
+		if err := b.removeCheckpoint(); err != nil {
 			return err
 		}
+		if len(b.addressContracts)+partialStoreAddrContracts > maxBulkAddrContracts {
+			storeAddrContractsChan = make(chan error)
+			go b.parallelStoreAddressContracts(storeAddrContractsChan, false)
+		}
+		if len(b.tokenBalances)+partialStoreTokenBalances > maxBulkTokenBalances {
+			storeTokenBalancesChan = make(chan error)
+			go b.parallelStoreTokenBalances(storeTokenBalancesChan, false)
+		}
 	}
-	if storeBalancesChan != nil {
-		if err := <-storeBalancesChan; err != nil {
-			return err
+	ba := bulkAddresses{
+		bi:        newBulkBlockInfo(block),
+		addresses: addresses,
+	}
+	var sbt func(wb *gorocksdb.WriteBatch) error
+	if storeBlockTxs {
+		// the rollback data of the block are stored immediately, the logs are stored together with their keys in blockLogs
+		sbt = func(wb *gorocksdb.WriteBatch) error {
+			if err := b.d.storeAndCleanupBlockTxsEthereumType(wb, block, blockTxs); err != nil {
+				return err
+			}
+			if err := b.d.storeAndCleanupBlockTokenTransfers(wb, block, transfers); err != nil {
+				return err
+			}
+			if logs != nil {
+				return b.d.storeAndCleanupLogs(wb, block, logs)
+			}
+			return nil
 		}
+	} else {
+		ba.logs = logs
 	}
-	return nil
+	b.bulkAddresses = append(b.bulkAddresses, ba)
+	b.bulkAddressesCount += len(addresses) + len(ba.logs)
+	err = b.storeBulkAddressesAndBlockTxs(sa, sbt)
+	if e := waitForParallelStore(storeAddrContractsChan, storeTokenBalancesChan); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return b.storeCheckpoint()
 }
 
 // Close flushes the cached data and switches DB from inconsistent state open
 // after Close, the BulkConnect cannot be used
 func (b *BulkConnect) Close() error {
 	glog.Info("rocksdb: bulk connect closing")
-	start := time.Now()
-	storeAddressesChan := make(chan error)
-	go b.parallelStoreTxAddresses(storeAddressesChan, true)
-	storeBalancesChan := make(chan error)
-	go b.parallelStoreBalances(storeBalancesChan, true)
-	wb := gorocksdb.NewWriteBatch()
-	defer wb.Destroy()
-	bac := b.bulkAddressesCount
-	if err := b.storeBulkAddresses(wb); err != nil {
-		return err
-	}
-	if err := b.d.db.Write(b.d.wo, wb); err != nil {
-		return err
-	}
-	glog.Info("rocksdb: height ", b.height, ", stored ", bac, " addresses, done in ", time.Since(start))
-	if err := <-storeAddressesChan; err != nil {
-		return err
-	}
-	if err := <-storeBalancesChan; err != nil {
+	if err := b.storeAll(); err != nil {
 		return err
 	}
+	b.d.is.SetBulkCheckpoint(0, "")
 	if err := b.d.SetInconsistentState(false); err != nil {
 		return err
 	}
@@ -268,3 +531,123 @@ func (b *BulkConnect) Close() error {
 	b.d = nil
 	return nil
 }
+
+// deleteRowsAboveHeight removes rows of the column keyed by block height, which are above the height
+func (d *RocksDB) deleteRowsAboveHeight(wb *gorocksdb.WriteBatch, col int, height uint32) {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[col])
+	defer it.Close()
+	for it.Seek(packUint(height + 1)); it.Valid(); it.Next() {
+		wb.DeleteCF(d.cfh[col], append([]byte(nil), it.Key().Data()...))
+	}
+}
+
+// deleteAddressesOfBlock removes the addresses and txAddresses rows, which were stored by the bulk connect for the block
+// txAddressesMap is shared by the consecutive blocks so that the inputs spending the outputs of the previous blocks are found
+func (d *RocksDB) deleteAddressesOfBlock(wb *gorocksdb.WriteBatch, block *bchain.Block, txAddressesMap map[string]*TxAddresses) error {
+	addresses := make(map[string][]outpoint)
+	if d.chainParser.GetChainType() == bchain.ChainEthereumType {
+		if _, _, err := d.processAddressesEthereumType(block, addresses, make(map[string]*AddrContracts), make(map[string]bool), make(map[string]*big.Int)); err != nil {
+			return err
+		}
+	} else {
+		if err := d.processAddressesBitcoinType(block, addresses, txAddressesMap, make(map[string]*AddrBalance)); err != nil {
+			return err
+		}
+		for i := range block.Txs {
+			btxID, err := d.chainParser.PackTxid(block.Txs[i].Txid)
+			if err != nil {
+				return err
+			}
+			wb.DeleteCF(d.cfh[cfTxAddresses], btxID)
+		}
+	}
+	for a := range addresses {
+		wb.DeleteCF(d.cfh[cfAddresses], packAddressKey([]byte(a), block.Height))
+	}
+	return nil
+}
+
+// ResumeBulkConnect makes the db left in inconsistent state by an interrupted bulk connect usable again
+// the db must contain a checkpoint, whose block is verified against the backend using getBlockHash
+// the addresses and heights of blocks above the checkpoint may be already stored, these blocks must be still in the chain,
+// they are retrieved using getBlock from the backend and their addresses, txAddresses, heights and rollback data are removed
+func (d *RocksDB) ResumeBulkConnect(getBlockHash func(height uint32) (string, error), getBlock BlockGetter) (uint32, error) {
+	if d.is == nil {
+		return 0, errors.New("Internal state not set")
+	}
+	height, hash := d.is.GetBulkCheckpoint()
+	if hash == "" {
+		return 0, errors.New("Bulk connect checkpoint not found")
+	}
+	localHash, err := d.GetBlockHash(height)
+	if err != nil {
+		return 0, err
+	}
+	if localHash != hash {
+		return 0, errors.Errorf("Block %v %v does not match the bulk connect checkpoint %v %v", height, localHash, height, hash)
+	}
+	bestHeight, bestHash, err := d.GetBestBlock()
+	if err != nil {
+		return 0, err
+	}
+	// if the best block is in the chain, all the blocks between the checkpoint and the best block are in the chain too
+	for _, b := range []struct {
+		height uint32
+		hash   string
+	}{{height, hash}, {bestHeight, bestHash}} {
+		remoteHash, err := getBlockHash(b.height)
+		if err != nil {
+			return 0, errors.Annotatef(err, "GetBlockHash %v", b.height)
+		}
+		if remoteHash != b.hash {
+			return 0, errors.Errorf("Bulk connect block %v %v is not in the chain, backend has block %v", b.height, b.hash, remoteHash)
+		}
+	}
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	txAddressesMap := make(map[string]*TxAddresses)
+	for h := height + 1; h <= bestHeight; h++ {
+		bi, err := d.GetBlockInfo(h)
+		if err != nil {
+			return 0, err
+		}
+		if bi == nil {
+			continue
+		}
+		block, err := getBlock(bi.Hash, h)
+		if err != nil {
+			return 0, errors.Annotatef(err, "GetBlock %v %v", h, bi.Hash)
+		}
+		if block.Hash != bi.Hash {
+			return 0, errors.Errorf("Backend returned block %v instead of indexed block %v at height %v", block.Hash, bi.Hash, h)
+		}
+		if err := d.deleteAddressesOfBlock(wb, block, txAddressesMap); err != nil {
+			return 0, errors.Annotatef(err, "Block %v", h)
+		}
+	}
+	if d.chainParser.GetChainType() == bchain.ChainEthereumType {
+		var heights []uint32
+		it := d.db.NewIteratorCF(d.ro, d.cfh[cfBlockLogs])
+		for it.Seek(packUint(height + 1)); it.Valid(); it.Next() {
+			heights = append(heights, unpackUint(it.Key().Data()))
+		}
+		it.Close()
+		for _, h := range heights {
+			if err := d.disconnectBlockLogs(wb, h); err != nil {
+				return 0, err
+			}
+		}
+		d.deleteRowsAboveHeight(wb, cfBlockTokenTransfers, height)
+	}
+	d.deleteRowsAboveHeight(wb, cfBlockTxs, height)
+	d.deleteRowsAboveHeight(wb, cfHeight, height)
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return 0, err
+	}
+	d.is.SetBulkCheckpoint(0, "")
+	if err := d.SetInconsistentState(false); err != nil {
+		return 0, err
+	}
+	glog.Infof("rocksdb: bulk connect resumed from checkpoint %d %s", height, hash)
+	return height, nil
+}
@@ -0,0 +1,355 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// CheckDBBalance is the balance of an address as stored in or computed from the db
+type CheckDBBalance struct {
+	Txs        uint32 `json:"txs"`
+	SentSat    string `json:"sent"`
+	BalanceSat string `json:"balance"`
+}
+
+// CheckDBMismatch describes an address whose stored balance does not match the balance computed from the addresses and txAddresses columns
+type CheckDBMismatch struct {
+	AddrDesc  string          `json:"addrDesc"`
+	Addresses []string        `json:"addresses,omitempty"`
+	Stored    *CheckDBBalance `json:"stored,omitempty"`
+	Computed  *CheckDBBalance `json:"computed,omitempty"`
+	Errors    []string        `json:"errors,omitempty"`
+	Repaired  bool            `json:"repaired"`
+}
+
+// CheckDBReport is the result of CheckDB
+type CheckDBReport struct {
+	Coin         string            `json:"coin"`
+	BestHeight   uint32            `json:"bestHeight"`
+	Started      time.Time         `json:"started"`
+	Finished     time.Time         `json:"finished"`
+	Repair       bool              `json:"repair"`
+	Addresses    int64             `json:"checkedAddresses"`
+	Balances     int64             `json:"checkedBalances"`
+	Repaired     int               `json:"repaired"`
+	Unrepairable int               `json:"unrepairable"`
+	Mismatches   []CheckDBMismatch `json:"mismatches"`
+}
+
+// number of repaired balances written in one batch
+const checkDBRepairBatch = 1000
+
+type checkDBRun struct {
+	d      *RocksDB
+	report *CheckDBReport
+	repair bool
+	wb     *gorocksdb.WriteBatch
+	// iterator of the addresses column, it is periodically refreshed
+	ro      *gorocksdb.ReadOptions
+	it      *gorocksdb.Iterator
+	itCount int
+}
+
+func newCheckDBBalance(ab *AddrBalance) *CheckDBBalance {
+	if ab == nil {
+		return nil
+	}
+	return &CheckDBBalance{
+		Txs:        ab.Txs,
+		SentSat:    ab.SentSat.String(),
+		BalanceSat: ab.BalanceSat.String(),
+	}
+}
+
+func balancesEqual(a, b *AddrBalance) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Txs == b.Txs && a.SentSat.Cmp(&b.SentSat) == 0 && a.BalanceSat.Cmp(&b.BalanceSat) == 0
+}
+
+func (c *checkDBRun) flush() error {
+	if c.wb.Count() == 0 {
+		return nil
+	}
+	if err := c.d.db.Write(c.d.wo, c.wb); err != nil {
+		return err
+	}
+	c.wb.Clear()
+	return nil
+}
+
+// checkAddress compares the stored balance of the address with the computed one, computed nil means no transactions
+// the balance is repaired only if it was computed without errors
+func (c *checkDBRun) checkAddress(addrDesc bchain.AddressDescriptor, computed *AddrBalance, errs []string) error {
+	stored, err := c.d.GetAddrDescBalance(addrDesc)
+	if err != nil {
+		return err
+	}
+	if len(errs) == 0 && balancesEqual(stored, computed) {
+		return nil
+	}
+	m := CheckDBMismatch{
+		AddrDesc: hex.EncodeToString(addrDesc),
+		Stored:   newCheckDBBalance(stored),
+		Computed: newCheckDBBalance(computed),
+		Errors:   errs,
+	}
+	m.Addresses, _, _ = c.d.chainParser.GetAddressesFromAddrDesc(addrDesc)
+	if len(errs) > 0 {
+		c.report.Unrepairable++
+	} else if c.repair {
+		// balance with 0 transactions is removed by storeBalances
+		if computed == nil {
+			computed = &AddrBalance{}
+		}
+		c.d.storeBalances(c.wb, map[string]*AddrBalance{string(addrDesc): computed})
+		if c.wb.Count() >= checkDBRepairBatch {
+			if err := c.flush(); err != nil {
+				return err
+			}
+		}
+		m.Repaired = true
+		c.report.Repaired++
+	}
+	glog.Warningf("checkdb: address %v (%v): stored balance %+v, computed balance %+v, errors %v", m.Addresses, m.AddrDesc, m.Stored, m.Computed, errs)
+	c.report.Mismatches = append(c.report.Mismatches, m)
+	return nil
+}
+
+// walkColumn calls fn for all rows of the column, the iterator is periodically refreshed
+func (d *RocksDB) walkColumn(col int, stop chan os.Signal, fn func(key, value []byte) error) error {
+	var seekKey []byte
+	// do not use cache
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetFillCache(false)
+	defer ro.Destroy()
+	for {
+		var key []byte
+		it := d.db.NewIteratorCF(ro, d.cfh[col])
+		if seekKey == nil {
+			it.SeekToFirst()
+		} else {
+			it.Seek(seekKey)
+			it.Next()
+		}
+		for count := 0; it.Valid() && count < refreshIterator; it.Next() {
+			select {
+			case <-stop:
+				it.Close()
+				return errors.New("Interrupted")
+			default:
+			}
+			key = append([]byte(nil), it.Key().Data()...)
+			if err := fn(key, append([]byte(nil), it.Value().Data()...)); err != nil {
+				it.Close()
+				return err
+			}
+			count++
+		}
+		valid := it.Valid()
+		it.Close()
+		if !valid {
+			return nil
+		}
+		seekKey = key
+	}
+}
+
+// computeBalance adds the outpoints of one row of the addresses column to the computed balance of the address
+// amounts are taken from the txAddresses column, txs is the set of already counted transactions of the address
+func (c *checkDBRun) computeBalance(addrDesc bchain.AddressDescriptor, outpoints []outpoint, ab *AddrBalance, txs map[string]struct{}) ([]string, error) {
+	var errs []string
+	for _, o := range outpoints {
+		s := string(o.btxID)
+		_, counted := txs[s]
+		if !counted {
+			txs[s] = struct{}{}
+			ab.Txs++
+		}
+		ta, err := c.d.getTxAddresses(o.btxID)
+		if err != nil {
+			return nil, err
+		}
+		txid, _ := c.d.chainParser.UnpackTxid(o.btxID)
+		if ta == nil {
+			if !counted {
+				errs = append(errs, "tx "+txid+" not found in txAddresses")
+			}
+			continue
+		}
+		if o.index < 0 {
+			vin := int(^o.index)
+			if vin >= len(ta.Inputs) {
+				errs = append(errs, "tx "+txid+" does not have enough inputs in txAddresses")
+			} else if !bytes.Equal(ta.Inputs[vin].AddrDesc, addrDesc) {
+				errs = append(errs, "tx "+txid+" has different input address in txAddresses")
+			} else {
+				ab.SentSat.Add(&ab.SentSat, &ta.Inputs[vin].ValueSat)
+				ab.BalanceSat.Sub(&ab.BalanceSat, &ta.Inputs[vin].ValueSat)
+			}
+		} else {
+			vout := int(o.index)
+			if vout >= len(ta.Outputs) {
+				errs = append(errs, "tx "+txid+" does not have enough outputs in txAddresses")
+			} else if !bytes.Equal(ta.Outputs[vout].AddrDesc, addrDesc) {
+				errs = append(errs, "tx "+txid+" has different output address in txAddresses")
+			} else {
+				ab.BalanceSat.Add(&ab.BalanceSat, &ta.Outputs[vout].ValueSat)
+			}
+		}
+	}
+	return errs, nil
+}
+
+// computeAddress computes the balance of the address from its rows in the addresses column, nil if there are no rows
+// the address descriptors have variable length, the rows of the longer descriptors starting with addrDesc are skipped
+func (c *checkDBRun) computeAddress(addrDesc bchain.AddressDescriptor) (*AddrBalance, []string, error) {
+	if c.it == nil || c.itCount >= refreshIterator {
+		if c.it != nil {
+			c.it.Close()
+		}
+		c.it = c.d.db.NewIteratorCF(c.ro, c.d.cfh[cfAddresses])
+		c.itCount = 0
+	}
+	var ab *AddrBalance
+	var errs []string
+	txs := make(map[string]struct{})
+	for c.it.Seek(packAddressKey(addrDesc, 0)); c.it.Valid(); c.it.Next() {
+		c.itCount++
+		key := c.it.Key().Data()
+		if !bytes.HasPrefix(key, addrDesc) {
+			break
+		}
+		if len(key) != len(addrDesc)+packedHeightBytes {
+			continue
+		}
+		if ab == nil {
+			ab = &AddrBalance{}
+		}
+		outpoints, err := c.d.unpackOutpoints(append([]byte(nil), c.it.Value().Data()...))
+		if err != nil {
+			errs = append(errs, "unparsable addresses row "+hex.EncodeToString(key))
+			continue
+		}
+		e, err := c.computeBalance(addrDesc, outpoints, ab, txs)
+		if err != nil {
+			return nil, nil, err
+		}
+		errs = append(errs, e...)
+	}
+	if ab != nil {
+		c.report.Addresses++
+		if c.report.Addresses%1000000 == 0 {
+			glog.Info("checkdb: checked ", c.report.Addresses, " addresses, found ", len(c.report.Mismatches), " mismatches")
+		}
+	}
+	return ab, errs, nil
+}
+
+// CheckDB recomputes number of transactions, sent amount and balance of all addresses from the addresses and txAddresses columns
+// and compares them with the data in the addressBalance column, it reports the differences and if repair is set, stores the computed balances
+// balances which cannot be computed due to inconsistent data are reported but not repaired
+// works only for BitcoinType coins, can be very slow operation
+func (d *RocksDB) CheckDB(repair bool, stop chan os.Signal) (*CheckDBReport, error) {
+	if d.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return nil, errors.New("CheckDB is supported only for BitcoinType coins")
+	}
+	if d.is != nil && d.is.GetPrunedHeight() > 0 {
+		return nil, errors.New("CheckDB is not supported for pruned index")
+	}
+	report := &CheckDBReport{
+		Started:    time.Now(),
+		Repair:     repair,
+		Mismatches: []CheckDBMismatch{},
+	}
+	if d.is != nil {
+		report.Coin = d.is.Coin
+	}
+	var err error
+	if report.BestHeight, _, err = d.GetBestBlock(); err != nil {
+		return nil, err
+	}
+	c := checkDBRun{
+		d:      d,
+		report: report,
+		repair: repair,
+		wb:     gorocksdb.NewWriteBatch(),
+		ro:     gorocksdb.NewDefaultReadOptions(),
+	}
+	defer c.wb.Destroy()
+	// do not use cache
+	c.ro.SetFillCache(false)
+	defer c.ro.Destroy()
+	defer func() {
+		if c.it != nil {
+			c.it.Close()
+		}
+	}()
+
+	glog.Info("checkdb: checking balances, repair ", repair)
+	// the rows of an address are found by seek, the rows of different addresses can interleave in the addresses column
+	// if one address descriptor is a prefix of another one
+	err = d.walkColumn(cfAddressBalance, stop, func(key, value []byte) error {
+		report.Balances++
+		ab, errs, err := c.computeAddress(key)
+		if err != nil {
+			return err
+		}
+		return c.checkAddress(key, ab, errs)
+	})
+	if err == nil {
+		err = c.flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	glog.Info("checkdb: checking addresses without balance")
+	var lastAddrDesc bchain.AddressDescriptor
+	// the addresses without balance are checked only once, even if their rows are not stored together
+	missing := make(map[string]struct{})
+	err = d.walkColumn(cfAddresses, stop, func(key, value []byte) error {
+		ad, _, err := unpackAddressKey(key)
+		if err != nil {
+			return errors.Annotatef(err, "addresses key %v", hex.EncodeToString(key))
+		}
+		if bytes.Equal(ad, lastAddrDesc) {
+			return nil
+		}
+		lastAddrDesc = ad
+		if _, found := missing[string(ad)]; found {
+			return nil
+		}
+		val, err := d.db.GetCF(d.ro, d.cfh[cfAddressBalance], ad)
+		if err != nil {
+			return err
+		}
+		defer val.Free()
+		if val.Size() > 0 {
+			return nil
+		}
+		missing[string(ad)] = struct{}{}
+		ab, errs, err := c.computeAddress(ad)
+		if err != nil {
+			return err
+		}
+		return c.checkAddress(ad, ab, errs)
+	})
+	if err == nil && repair {
+		err = c.flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Finished = time.Now()
+	glog.Info("checkdb: checked ", report.Addresses, " addresses and ", report.Balances, " balances, found ", len(report.Mismatches), " mismatches, repaired ", report.Repaired, ", unrepairable ", report.Unrepairable, ", finished in ", report.Finished.Sub(report.Started))
+	return report, nil
+}
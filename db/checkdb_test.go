@@ -0,0 +1,199 @@
+// build unittest
+
+package db
+
+import (
+	"blockbook/tests/dbtestdata"
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/tecbot/gorocksdb"
+)
+
+func TestRocksDB_CheckDB(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := d.CheckDB(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Addresses == 0 || r.BestHeight != 225494 || len(r.Mismatches) != 0 {
+		t.Fatalf("CheckDB() of consistent db = %+v, want checked addresses and no mismatches", r)
+	}
+
+	// damage the balances - wrong balance, missing balance and balance of address without transactions
+	addr5, _ := hex.DecodeString(dbtestdata.AddressToPubKeyHex(dbtestdata.Addr5, d.chainParser))
+	addr2, _ := hex.DecodeString(dbtestdata.AddressToPubKeyHex(dbtestdata.Addr2, d.chainParser))
+	orphan, _ := hex.DecodeString("76a914000000000000000000000000000000000000000088ac")
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	ab, err := d.GetAddrDescBalance(addr5)
+	if err != nil || ab == nil {
+		t.Fatal("GetAddrDescBalance", err)
+	}
+	ab.Txs += 7
+	d.storeBalances(wb, map[string]*AddrBalance{
+		string(addr5):  ab,
+		string(orphan): &AddrBalance{Txs: 1},
+	})
+	wb.DeleteCF(d.cfh[cfAddressBalance], addr2)
+	if err := d.db.Write(d.wo, wb); err != nil {
+		t.Fatal(err)
+	}
+
+	checkMismatches := func(r *CheckDBReport, repaired bool) {
+		want := map[string]bool{
+			hex.EncodeToString(addr5):  true,
+			hex.EncodeToString(addr2):  true,
+			hex.EncodeToString(orphan): true,
+		}
+		if len(r.Mismatches) != len(want) {
+			t.Fatalf("CheckDB() found %d mismatches, want %d: %+v", len(r.Mismatches), len(want), r.Mismatches)
+		}
+		for _, m := range r.Mismatches {
+			if !want[m.AddrDesc] {
+				t.Errorf("CheckDB() unexpected mismatch %+v", m)
+			}
+			if m.Repaired != repaired {
+				t.Errorf("CheckDB() mismatch %+v, want repaired %v", m, repaired)
+			}
+		}
+		if r.Unrepairable != 0 {
+			t.Errorf("CheckDB() unrepairable %d, want 0", r.Unrepairable)
+		}
+	}
+
+	// check without repair does not change the db
+	r, err = d.CheckDB(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMismatches(r, false)
+	if r.Repaired != 0 {
+		t.Errorf("CheckDB() repaired %d, want 0", r.Repaired)
+	}
+	for _, m := range r.Mismatches {
+		if m.AddrDesc == hex.EncodeToString(addr2) && (m.Stored != nil || m.Computed == nil) {
+			t.Errorf("CheckDB() mismatch of missing balance %+v", m)
+		}
+		if m.AddrDesc == hex.EncodeToString(orphan) && (m.Stored == nil || m.Computed != nil) {
+			t.Errorf("CheckDB() mismatch of balance without transactions %+v", m)
+		}
+	}
+	if ab, _ = d.GetAddrDescBalance(orphan); ab == nil {
+		t.Error("CheckDB() without repair removed balance")
+	}
+
+	// repair restores the original balances
+	r, err = d.CheckDB(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMismatches(r, true)
+	if r.Repaired != 3 {
+		t.Errorf("CheckDB() repaired %d, want 3", r.Repaired)
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+	if r, err = d.CheckDB(false, nil); err != nil || len(r.Mismatches) != 0 {
+		t.Fatalf("CheckDB() after repair = %+v, %v, want no mismatches", r, err)
+	}
+
+	// balances of addresses with inconsistent transaction data cannot be repaired
+	btxID, err := d.chainParser.PackTxid(dbtestdata.TxidB2T1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.DeleteCF(d.wo, d.cfh[cfTxAddresses], btxID); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = d.CheckDB(true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.Unrepairable == 0 || r.Repaired != 0 {
+		t.Errorf("CheckDB() with missing txAddresses repaired %d, unrepairable %d, want 0 and more than 0", r.Repaired, r.Unrepairable)
+	}
+	for _, m := range r.Mismatches {
+		if len(m.Errors) == 0 {
+			t.Errorf("CheckDB() mismatch %+v, want errors", m)
+		}
+	}
+}
+
+func TestRocksDB_CheckDB_EthereumType(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	if _, err := d.CheckDB(false, nil); err == nil {
+		t.Error("CheckDB() expected error for EthereumType coin")
+	}
+}
+
+func TestRocksDB_CheckDB_PrefixAddrDesc(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	// find an address with rows in both blocks
+	var addrDesc, firstKey []byte
+	var prev []byte
+	if err := d.walkColumn(cfAddresses, nil, func(key, value []byte) error {
+		ad, _, err := unpackAddressKey(key)
+		if err != nil {
+			return err
+		}
+		if addrDesc == nil && bytes.Equal(ad, prev) {
+			addrDesc = ad
+		}
+		if addrDesc == nil {
+			prev = ad
+			firstKey = key
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if addrDesc == nil {
+		t.Fatal("no address with rows in both blocks")
+	}
+	// the rows of the longer address descriptor starting with addrDesc are stored between the rows of addrDesc
+	longer := append([]byte(nil), firstKey...)
+	val, err := d.db.GetCF(d.ro, d.cfh[cfAddresses], firstKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.PutCF(d.wo, d.cfh[cfAddresses], packAddressKey(longer, 1), val.Data()); err != nil {
+		t.Fatal(err)
+	}
+	val.Free()
+
+	r, err := d.CheckDB(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Mismatches) != 1 || r.Mismatches[0].AddrDesc != hex.EncodeToString(longer) || r.Unrepairable != 1 || r.Repaired != 0 {
+		t.Fatalf("CheckDB() = %+v, want only unrepairable mismatch of %v", r, hex.EncodeToString(longer))
+	}
+	if err := d.db.DeleteCF(d.wo, d.cfh[cfAddresses], packAddressKey(longer, 1)); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+}
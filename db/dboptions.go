@@ -10,31 +10,6 @@ import (
 	"github.com/tecbot/gorocksdb"
 )
 
-/*
-	possible additional tuning, using options not accessible by gorocksdb
-
-// #include "rocksdb/c.h"
-import "C"
-
-	cNativeOpts := C.rocksdb_options_create()
-	opts := &gorocksdb.Options{}
-	cField := reflect.Indirect(reflect.ValueOf(opts)).FieldByName("c")
-	cPtr := (**C.rocksdb_options_t)(unsafe.Pointer(cField.UnsafeAddr()))
-	*cPtr = cNativeOpts
-
-	cNativeBlockOpts := C.rocksdb_block_based_options_create()
-	blockOpts := &gorocksdb.BlockBasedTableOptions{}
-	cBlockField := reflect.Indirect(reflect.ValueOf(blockOpts)).FieldByName("c")
-	cBlockPtr := (**C.rocksdb_block_based_table_options_t)(unsafe.Pointer(cBlockField.UnsafeAddr()))
-	*cBlockPtr = cNativeBlockOpts
-
-	// https://github.com/facebook/rocksdb/wiki/Partitioned-Index-Filters
-	blockOpts.SetIndexType(gorocksdb.KTwoLevelIndexSearchIndexType)
-	C.rocksdb_block_based_options_set_partition_filters(cNativeBlockOpts, boolToChar(true))
-	C.rocksdb_block_based_options_set_metadata_block_size(cNativeBlockOpts, C.uint64_t(4096))
-	C.rocksdb_block_based_options_set_cache_index_and_filter_blocks_with_high_priority(cNativeBlockOpts, boolToChar(true))
-	blockOpts.SetPinL0FilterAndIndexBlocksInCache(true)
-
 // boolToChar converts a bool value to C.uchar.
 func boolToChar(b bool) C.uchar {
 	if b {
@@ -42,32 +17,48 @@ func boolToChar(b bool) C.uchar {
 	}
 	return 0
 }
-*/
 
-func createAndSetDBOptions(bloomBits int, c *gorocksdb.Cache, maxOpenFiles int) *gorocksdb.Options {
+// createAndSetDBOptions creates the options of the column family with tuning ct, the other options are from the profile t
+// some of the options are not accessible by gorocksdb, they are set using the C api
+func createAndSetDBOptions(t *DBTuning, ct *ColumnTuning, c *gorocksdb.Cache, maxOpenFiles int) *gorocksdb.Options {
 	// blockOpts := gorocksdb.NewDefaultBlockBasedTableOptions()
 	cNativeBlockOpts := C.rocksdb_block_based_options_create()
 	blockOpts := &gorocksdb.BlockBasedTableOptions{}
 	cBlockField := reflect.Indirect(reflect.ValueOf(blockOpts)).FieldByName("c")
 	cBlockPtr := (**C.rocksdb_block_based_table_options_t)(unsafe.Pointer(cBlockField.UnsafeAddr()))
 	*cBlockPtr = cNativeBlockOpts
-	blockOpts.SetBlockSize(32 << 10) // 32kB
+	if ct.BlockSize > 0 {
+		blockOpts.SetBlockSize(ct.BlockSize)
+	}
 	blockOpts.SetBlockCache(c)
-	if bloomBits > 0 {
-		blockOpts.SetFilterPolicy(gorocksdb.NewBloomFilter(bloomBits))
+	if ct.BloomBits > 0 {
+		blockOpts.SetFilterPolicy(gorocksdb.NewBloomFilter(ct.BloomBits))
 	}
 	C.rocksdb_block_based_options_set_format_version(cNativeBlockOpts, 3)
+	if ct.PartitionedIndexFilters {
+		// https://github.com/facebook/rocksdb/wiki/Partitioned-Index-Filters
+		blockOpts.SetIndexType(gorocksdb.KTwoLevelIndexSearchIndexType)
+		C.rocksdb_block_based_options_set_partition_filters(cNativeBlockOpts, boolToChar(true))
+		C.rocksdb_block_based_options_set_metadata_block_size(cNativeBlockOpts, C.uint64_t(4096))
+	}
+	if ct.CachePriority != "" {
+		blockOpts.SetCacheIndexAndFilterBlocks(true)
+		if ct.CachePriority == "high" {
+			C.rocksdb_block_based_options_set_cache_index_and_filter_blocks_with_high_priority(cNativeBlockOpts, boolToChar(true))
+			blockOpts.SetPinL0FilterAndIndexBlocksInCache(true)
+		}
+	}
 
 	opts := gorocksdb.NewDefaultOptions()
 	opts.SetBlockBasedTableFactory(blockOpts)
 	opts.SetCreateIfMissing(true)
 	opts.SetCreateIfMissingColumnFamilies(true)
-	opts.SetMaxBackgroundCompactions(6)
-	opts.SetMaxBackgroundFlushes(6)
-	opts.SetBytesPerSync(8 << 20)         // 8MB
-	opts.SetWriteBufferSize(1 << 27)      // 128MB
-	opts.SetMaxBytesForLevelBase(1 << 27) // 128MB
+	opts.SetMaxBackgroundCompactions(t.MaxBackgroundCompactions)
+	opts.SetMaxBackgroundFlushes(t.MaxBackgroundFlushes)
+	opts.SetBytesPerSync(t.BytesPerSync)
+	opts.SetWriteBufferSize(t.WriteBufferSize)
+	opts.SetMaxBytesForLevelBase(t.MaxBytesForLevelBase)
 	opts.SetMaxOpenFiles(maxOpenFiles)
-	opts.SetCompression(gorocksdb.LZ4HCCompression)
+	opts.SetCompression(compressionTypes[ct.Compression])
 	return opts
 }
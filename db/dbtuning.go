@@ -0,0 +1,197 @@
+package db
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// DefaultDBTuning is the name of the tuning profile used if no profile is configured
+const DefaultDBTuning = "default"
+
+// DBTuning is the profile of the RocksDB options
+// DefaultColumn is used for all column families, Columns contain the overrides of its values for individual column families
+type DBTuning struct {
+	Name                     string                     `json:"name"`
+	MaxBackgroundCompactions int                        `json:"max_background_compactions"`
+	MaxBackgroundFlushes     int                        `json:"max_background_flushes"`
+	BytesPerSync             uint64                     `json:"bytes_per_sync"`
+	WriteBufferSize          int                        `json:"write_buffer_size"`
+	MaxBytesForLevelBase     uint64                     `json:"max_bytes_for_level_base"`
+	DefaultColumn            ColumnTuning               `json:"default_column"`
+	Columns                  map[string]json.RawMessage `json:"columns,omitempty"`
+}
+
+// ColumnTuning is the tuning of one column family
+type ColumnTuning struct {
+	// Compression is one of none, snappy, zlib, bz2, lz4, lz4hc
+	Compression string `json:"compression"`
+	// BloomBits is the number of bits of the bloom filter per key, 0 means no filter
+	// if most of the queries of the column are executed using iterators, the bloom filter is not useful
+	BloomBits int `json:"bloom_bits"`
+	BlockSize int `json:"block_size"`
+	// PartitionedIndexFilters enables two level index and partitioned filters, which reduce the memory of big databases
+	PartitionedIndexFilters bool `json:"partitioned_index_filters"`
+	// CachePriority empty keeps the index and filter blocks outside of the block cache,
+	// low stores them in the block cache, high stores them in the block cache with high priority and pins them in the level 0
+	CachePriority string `json:"cache_priority"`
+}
+
+// DBTuningInfo describes the tuning applied to the db
+type DBTuningInfo struct {
+	Profile *DBTuning               `json:"profile"`
+	Columns map[string]ColumnTuning `json:"columns"`
+}
+
+var compressionTypes = map[string]gorocksdb.CompressionType{
+	"none":   gorocksdb.NoCompression,
+	"snappy": gorocksdb.SnappyCompression,
+	"zlib":   gorocksdb.ZLibCompression,
+	"bz2":    gorocksdb.Bz2Compression,
+	"lz4":    gorocksdb.LZ4Compression,
+	"lz4hc":  gorocksdb.LZ4HCCompression,
+}
+
+// built in tuning profiles
+var dbTuningProfiles = map[string]string{
+	// default profile for most of the coins
+	DefaultDBTuning: `{
+		"max_background_compactions": 6,
+		"max_background_flushes": 6,
+		"bytes_per_sync": 8388608,
+		"write_buffer_size": 134217728,
+		"max_bytes_for_level_base": 134217728,
+		"default_column": {"compression": "lz4hc", "bloom_bits": 10, "block_size": 32768},
+		"columns": {
+			"addresses": {"bloom_bits": 0}
+		}
+	}`,
+	// profile for big databases, the index and filters are partitioned and kept in the block cache
+	"large": `{
+		"max_background_compactions": 8,
+		"max_background_flushes": 4,
+		"bytes_per_sync": 8388608,
+		"write_buffer_size": 268435456,
+		"max_bytes_for_level_base": 536870912,
+		"default_column": {"compression": "lz4hc", "bloom_bits": 10, "block_size": 32768, "partitioned_index_filters": true, "cache_priority": "high"},
+		"columns": {
+			"addresses": {"bloom_bits": 0, "block_size": 65536},
+			"transactions": {"compression": "lz4", "cache_priority": "low"}
+		}
+	}`,
+}
+
+// LoadDBTuning returns the built in tuning profile with the name profile or loads the profile from the json file profile
+// the file profile is applied over the built in profile given by its field base, default profile is used if base is not set
+// override of a column in the file replaces the override of the column in the built in profile
+func LoadDBTuning(profile string) (*DBTuning, error) {
+	if profile == "" {
+		profile = DefaultDBTuning
+	}
+	if _, ok := dbTuningProfiles[profile]; ok {
+		t, err := builtInDBTuning(profile)
+		if err != nil {
+			return nil, err
+		}
+		return t, t.validate()
+	}
+	data, err := ioutil.ReadFile(profile)
+	if err != nil {
+		return nil, errors.Annotatef(err, "DB tuning profile %v is neither built in profile nor a readable file", profile)
+	}
+	var base struct {
+		Base string `json:"base"`
+	}
+	if err = json.Unmarshal(data, &base); err != nil {
+		return nil, errors.Annotatef(err, "DB tuning profile %v", profile)
+	}
+	if base.Base == "" {
+		base.Base = DefaultDBTuning
+	}
+	if _, ok := dbTuningProfiles[base.Base]; !ok {
+		return nil, errors.Errorf("DB tuning profile %v is based on unknown profile %v", profile, base.Base)
+	}
+	t, err := builtInDBTuning(base.Base)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, t); err != nil {
+		return nil, errors.Annotatef(err, "DB tuning profile %v", profile)
+	}
+	t.Name = profile
+	return t, t.validate()
+}
+
+func builtInDBTuning(name string) (*DBTuning, error) {
+	t := &DBTuning{}
+	if err := json.Unmarshal([]byte(dbTuningProfiles[name]), t); err != nil {
+		return nil, errors.Annotatef(err, "DB tuning profile %v", name)
+	}
+	t.Name = name
+	return t, nil
+}
+
+// Column returns the tuning of the column family
+func (t *DBTuning) Column(name string) (ColumnTuning, error) {
+	ct := t.DefaultColumn
+	if o, ok := t.Columns[name]; ok {
+		if err := json.Unmarshal(o, &ct); err != nil {
+			return ct, errors.Annotatef(err, "DB tuning profile %v, column %v", t.Name, name)
+		}
+	}
+	return ct, nil
+}
+
+func (ct *ColumnTuning) validate() error {
+	if _, ok := compressionTypes[ct.Compression]; !ok {
+		return errors.Errorf("unknown compression %v", ct.Compression)
+	}
+	if ct.BloomBits < 0 || ct.BlockSize < 0 {
+		return errors.New("bloom_bits and block_size must not be negative")
+	}
+	if ct.CachePriority != "" && ct.CachePriority != "low" && ct.CachePriority != "high" {
+		return errors.Errorf("unknown cache priority %v", ct.CachePriority)
+	}
+	return nil
+}
+
+func (t *DBTuning) validate() error {
+	if t.MaxBackgroundCompactions <= 0 || t.MaxBackgroundFlushes <= 0 || t.WriteBufferSize <= 0 || t.MaxBytesForLevelBase == 0 {
+		return errors.Errorf("DB tuning profile %v, background jobs, write buffer size and max bytes for level base must be positive", t.Name)
+	}
+	if err := t.DefaultColumn.validate(); err != nil {
+		return errors.Annotatef(err, "DB tuning profile %v, default column", t.Name)
+	}
+	for name := range t.Columns {
+		ct, err := t.Column(name)
+		if err != nil {
+			return err
+		}
+		if err = ct.validate(); err != nil {
+			return errors.Annotatef(err, "DB tuning profile %v, column %v", t.Name, name)
+		}
+	}
+	return nil
+}
+
+// columnTunings returns the tuning of the columns, the profile must not contain overrides of unknown columns
+func (t *DBTuning) columnTunings(columns []string) ([]ColumnTuning, error) {
+	known := make(map[string]struct{}, len(columns))
+	r := make([]ColumnTuning, len(columns))
+	for i, name := range columns {
+		ct, err := t.Column(name)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = ct
+		known[name] = struct{}{}
+	}
+	for name := range t.Columns {
+		if _, ok := known[name]; !ok {
+			return nil, errors.Errorf("DB tuning profile %v contains unknown column %v", t.Name, name)
+		}
+	}
+	return r, nil
+}
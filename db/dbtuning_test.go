@@ -0,0 +1,129 @@
+// build unittest
+
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDBTuning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testtuning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeProfile := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	columns := []string{"default", "height", "addresses", "blockTxs", "transactions"}
+	tests := []struct {
+		name    string
+		profile string
+		want    []ColumnTuning
+		wantErr bool
+	}{
+		{
+			name:    "default",
+			profile: "",
+			want: []ColumnTuning{
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768},
+				{Compression: "lz4hc", BloomBits: 0, BlockSize: 32768},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768},
+			},
+		},
+		{
+			name:    "large",
+			profile: "large",
+			want: []ColumnTuning{
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4hc", BloomBits: 0, BlockSize: 65536, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "low"},
+			},
+		},
+		{
+			name: "file based on default",
+			profile: writeProfile("file.json", `{
+				"default_column": {"compression": "snappy"},
+				"columns": {"height": {"block_size": 4096}}
+			}`),
+			want: []ColumnTuning{
+				{Compression: "snappy", BloomBits: 10, BlockSize: 32768},
+				{Compression: "snappy", BloomBits: 10, BlockSize: 4096},
+				{Compression: "snappy", BloomBits: 0, BlockSize: 32768},
+				{Compression: "snappy", BloomBits: 10, BlockSize: 32768},
+				{Compression: "snappy", BloomBits: 10, BlockSize: 32768},
+			},
+		},
+		{
+			name: "file based on large",
+			profile: writeProfile("large.json", `{
+				"base": "large",
+				"columns": {"transactions": {"compression": "none"}}
+			}`),
+			want: []ColumnTuning{
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4hc", BloomBits: 0, BlockSize: 65536, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "lz4hc", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+				{Compression: "none", BloomBits: 10, BlockSize: 32768, PartitionedIndexFilters: true, CachePriority: "high"},
+			},
+		},
+		{
+			name:    "unknown profile",
+			profile: filepath.Join(dir, "missing.json"),
+			wantErr: true,
+		},
+		{
+			name:    "unknown base",
+			profile: writeProfile("base.json", `{"base": "huge"}`),
+			wantErr: true,
+		},
+		{
+			name:    "unknown compression",
+			profile: writeProfile("compression.json", `{"columns": {"addresses": {"compression": "zip"}}}`),
+			wantErr: true,
+		},
+		{
+			name:    "unknown cache priority",
+			profile: writeProfile("priority.json", `{"default_column": {"cache_priority": "top"}}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid write buffer size",
+			profile: writeProfile("buffer.json", `{"write_buffer_size": 0}`),
+			wantErr: true,
+		},
+		{
+			name:    "unknown column",
+			profile: writeProfile("column.json", `{"columns": {"contractHolders": {"bloom_bits": 0}}}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tuning, err := LoadDBTuning(tt.profile)
+			var got []ColumnTuning
+			if err == nil {
+				got, err = tuning.columnTunings(columns)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadDBTuning(%v) error = %v, wantErr %v", tt.profile, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadDBTuning(%v) columns = %+v, want %+v", tt.profile, got, tt.want)
+			}
+		})
+	}
+}
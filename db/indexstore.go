@@ -0,0 +1,63 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"math/big"
+)
+
+// IndexStore is the storage of the index used by the api, servers, tx cache and sync worker
+// RocksDB is the production implementation, MemoryStore keeps the index in memory
+type IndexStore interface {
+	// Close closes the store
+	Close() error
+
+	// ConnectBlock indexes the block, the block must be the next block after the best block
+	ConnectBlock(block *bchain.Block) error
+	// DisconnectBlockRangeBitcoinType removes blocks in range lower-higher of BitcoinType coins
+	DisconnectBlockRangeBitcoinType(lower uint32, higher uint32, getBlock BlockGetter) error
+	// DisconnectBlockRangeEthereumType removes blocks in range lower-higher of EthereumType coins
+	DisconnectBlockRangeEthereumType(lower uint32, higher uint32) error
+
+	// GetBestBlock returns the height and hash of the best indexed block
+	GetBestBlock() (uint32, string, error)
+	// GetBlockHash returns hash of the indexed block at height or empty string if not found
+	GetBlockHash(height uint32) (string, error)
+	// GetBlockInfo returns info about the indexed block at height or nil if not found
+	GetBlockInfo(height uint32) (*BlockInfo, error)
+
+	// GetTransactions passes all input/output transactions of the address in blocks lower-higher to fn
+	GetTransactions(address string, lower uint32, higher uint32, fn func(txid string, vout int32, isOutput bool) error) error
+	// GetAddrDescTransactions passes all input/output transactions of the address descriptor in blocks lower-higher to fn
+	GetAddrDescTransactions(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(txid string, vout int32, isOutput bool) error) error
+	// GetAddrDescTransactionsReverse passes all input/output transactions of the address descriptor in blocks higher-lower to fn, the newest first
+	GetAddrDescTransactionsReverse(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(txid string, height uint32, vout int32, isOutput bool) error) error
+	// GetAddrDescBalance returns balance of the address descriptor or nil if not found
+	GetAddrDescBalance(addrDesc bchain.AddressDescriptor) (*AddrBalance, error)
+	// GetTxAddresses returns addresses and amounts of inputs and outputs of the transaction or nil if not found
+	GetTxAddresses(txid string) (*TxAddresses, error)
+
+	// GetAddrDescContracts returns the contracts of the EthereumType address descriptor or nil if not found
+	GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*AddrContracts, error)
+	// GetAddrDescTokenBalance returns the balance of the contract token of the EthereumType address descriptor or nil if not found
+	GetAddrDescTokenBalance(addrDesc, contract bchain.AddressDescriptor) (*big.Int, error)
+	// GetContractHolders passes the holders of the EthereumType contract to fn
+	GetContractHolders(contract bchain.AddressDescriptor, fn func(addrDesc bchain.AddressDescriptor) error) error
+	// LogIndexEnabled returns true if the logs of EthereumType transactions are indexed
+	LogIndexEnabled() bool
+	// GetLogs passes the indexed logs of the address with topic0 in blocks lower-higher to fn, ordered by height, tx position and log index
+	GetLogs(address, topic0 []byte, lower uint32, higher uint32, fn func(txid string, logIndex int, height uint32) error) error
+
+	// GetTx returns the cached transaction and height of the block containing it or nil if not found
+	GetTx(txid string) (*bchain.Tx, uint32, error)
+	// PutTx stores the transaction in the cache
+	PutTx(tx *bchain.Tx, height uint32, blockTime int64) error
+
+	// Backup creates a consistent copy of the store in the directory dir
+	Backup(dir string) error
+	// DatabaseSizeOnDisk returns the size of the store in bytes
+	DatabaseSizeOnDisk() int64
+	// GetMemoryStats returns description of the memory used by the store
+	GetMemoryStats() string
+	// GetAndResetConnectBlockStats returns statistics of ConnectBlock and resets them
+	GetAndResetConnectBlockStats() string
+}
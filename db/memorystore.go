@@ -0,0 +1,579 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// MemoryStore is IndexStore keeping the index of BitcoinType coins in memory
+// It is intended for unit tests and lightweight regtest deployments, the index is lost when the process exits.
+// All blocks are kept, therefore any range of blocks at the top of the index can be disconnected without the backend.
+type MemoryStore struct {
+	mux         sync.RWMutex
+	chainParser bchain.BlockChainParser
+	blocks      map[uint32]*memoryBlock
+	bestHeight  uint32
+	txAddresses map[string]*TxAddresses
+	addresses   map[string][]memoryAddressRow
+	balances    map[string]*AddrBalance
+	txs         map[string][]byte
+}
+
+type memoryBlock struct {
+	info *BlockInfo
+	txs  []blockTxs
+}
+
+// memoryAddressRow is the equivalent of a row of the addresses column
+type memoryAddressRow struct {
+	height    uint32
+	outpoints []outpoint
+}
+
+// NewMemoryStore returns new empty MemoryStore
+func NewMemoryStore(parser bchain.BlockChainParser) (*MemoryStore, error) {
+	if parser.GetChainType() != bchain.ChainBitcoinType {
+		return nil, errors.New("MemoryStore supports only BitcoinType coins")
+	}
+	return &MemoryStore{
+		chainParser: parser,
+		blocks:      make(map[uint32]*memoryBlock),
+		txAddresses: make(map[string]*TxAddresses),
+		addresses:   make(map[string][]memoryAddressRow),
+		balances:    make(map[string]*AddrBalance),
+		txs:         make(map[string][]byte),
+	}, nil
+}
+
+// Close releases the index
+func (m *MemoryStore) Close() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.blocks = make(map[uint32]*memoryBlock)
+	m.bestHeight = 0
+	m.txAddresses = make(map[string]*TxAddresses)
+	m.addresses = make(map[string][]memoryAddressRow)
+	m.balances = make(map[string]*AddrBalance)
+	m.txs = make(map[string][]byte)
+	return nil
+}
+
+func copyAddrBalance(ab *AddrBalance) *AddrBalance {
+	if ab == nil {
+		return nil
+	}
+	r := &AddrBalance{Txs: ab.Txs}
+	r.SentSat.Set(&ab.SentSat)
+	r.BalanceSat.Set(&ab.BalanceSat)
+	return r
+}
+
+func copyTxAddresses(ta *TxAddresses) *TxAddresses {
+	if ta == nil {
+		return nil
+	}
+	r := &TxAddresses{
+		Height:  ta.Height,
+		Inputs:  make([]TxInput, len(ta.Inputs)),
+		Outputs: make([]TxOutput, len(ta.Outputs)),
+	}
+	for i := range ta.Inputs {
+		r.Inputs[i].AddrDesc = ta.Inputs[i].AddrDesc
+		r.Inputs[i].ValueSat.Set(&ta.Inputs[i].ValueSat)
+	}
+	for i := range ta.Outputs {
+		r.Outputs[i].AddrDesc = ta.Outputs[i].AddrDesc
+		r.Outputs[i].Spent = ta.Outputs[i].Spent
+		r.Outputs[i].ValueSat.Set(&ta.Outputs[i].ValueSat)
+	}
+	return r
+}
+
+// memoryUpdate collects changes of txAddresses and balances, which are applied to the store at once
+// the store is not modified if the update fails
+type memoryUpdate struct {
+	m           *MemoryStore
+	txAddresses map[string]*TxAddresses
+	balances    map[string]*AddrBalance
+}
+
+func (u *memoryUpdate) getTxAddresses(btxID []byte) *TxAddresses {
+	s := string(btxID)
+	ta, found := u.txAddresses[s]
+	if !found {
+		ta = copyTxAddresses(u.m.txAddresses[s])
+		if ta != nil {
+			u.txAddresses[s] = ta
+		}
+	}
+	return ta
+}
+
+func (u *memoryUpdate) getBalance(addrDesc bchain.AddressDescriptor) *AddrBalance {
+	s := string(addrDesc)
+	ab, found := u.balances[s]
+	if !found {
+		ab = copyAddrBalance(u.m.balances[s])
+		if ab == nil {
+			ab = &AddrBalance{}
+		}
+		u.balances[s] = ab
+	}
+	return ab
+}
+
+func (u *memoryUpdate) apply() {
+	for s, ta := range u.txAddresses {
+		u.m.txAddresses[s] = ta
+	}
+	for s, ab := range u.balances {
+		// balance with 0 transactions is removed, the same as in RocksDB
+		if ab.Txs == 0 {
+			delete(u.m.balances, s)
+		} else {
+			u.m.balances[s] = ab
+		}
+	}
+}
+
+// ConnectBlock indexes the block, the block must be the next block after the best block
+func (m *MemoryStore) ConnectBlock(block *bchain.Block) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.blocks) > 0 && block.Height != m.bestHeight+1 {
+		return errors.Errorf("Block %v is not the next block after the best block %v", block.Height, m.bestHeight)
+	}
+	u := memoryUpdate{
+		m:           m,
+		txAddresses: make(map[string]*TxAddresses),
+		balances:    make(map[string]*AddrBalance),
+	}
+	addresses := make(map[string][]outpoint)
+	// the addresses of the block in the order of the first occurrence
+	var order []string
+	addOutpoint := func(addrDesc bchain.AddressDescriptor, btxID []byte, index int32) *AddrBalance {
+		s := string(addrDesc)
+		o, processed := addresses[s]
+		if !processed {
+			order = append(order, s)
+		} else {
+			processed = processedInTx(o, btxID)
+		}
+		addresses[s] = append(o, outpoint{btxID: btxID, index: index})
+		ab := u.getBalance(addrDesc)
+		// add number of trx in balance only once, address can be multiple times in tx
+		if !processed {
+			ab.Txs++
+		}
+		return ab
+	}
+	bt := make([]blockTxs, len(block.Txs))
+	// first process all outputs so that inputs can point to txs in this block
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
+		btxID, err := m.chainParser.PackTxid(tx.Txid)
+		if err != nil {
+			return err
+		}
+		bt[txi].btxID = btxID
+		ta := &TxAddresses{Height: block.Height, Outputs: make([]TxOutput, len(tx.Vout))}
+		u.txAddresses[string(btxID)] = ta
+		for i := range tx.Vout {
+			output := &tx.Vout[i]
+			ta.Outputs[i].ValueSat.Set(&output.ValueSat)
+			addrDesc, err := m.chainParser.GetAddrDescFromVout(output)
+			if err != nil || len(addrDesc) == 0 || len(addrDesc) > maxAddrDescLen {
+				if err != nil && err != bchain.ErrAddressMissing {
+					glog.Warningf("memorystore: addrDesc: %v - height %d, tx %v, output %v", err, block.Height, tx.Txid, output)
+				}
+				continue
+			}
+			ta.Outputs[i].AddrDesc = addrDesc
+			ab := addOutpoint(addrDesc, btxID, int32(i))
+			ab.BalanceSat.Add(&ab.BalanceSat, &output.ValueSat)
+		}
+	}
+	// process inputs
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
+		ta := u.txAddresses[string(bt[txi].btxID)]
+		ta.Inputs = make([]TxInput, len(tx.Vin))
+		bt[txi].inputs = make([]outpoint, len(tx.Vin))
+		for i := range tx.Vin {
+			input := &tx.Vin[i]
+			btxID, err := m.chainParser.PackTxid(input.Txid)
+			if err != nil {
+				// do not process inputs without input txid
+				if err == bchain.ErrTxidMissing {
+					continue
+				}
+				return err
+			}
+			bt[txi].inputs[i] = outpoint{btxID: btxID, index: int32(input.Vout)}
+			ita := u.getTxAddresses(btxID)
+			if ita == nil {
+				glog.Warningf("memorystore: height %d, tx %v, input tx %v not found in txAddresses", block.Height, tx.Txid, input.Txid)
+				continue
+			}
+			if len(ita.Outputs) <= int(input.Vout) {
+				glog.Warningf("memorystore: height %d, tx %v, input tx %v vout %v is out of bounds of stored tx", block.Height, tx.Txid, input.Txid, input.Vout)
+				continue
+			}
+			ot := &ita.Outputs[int(input.Vout)]
+			if ot.Spent {
+				glog.Warningf("memorystore: height %d, tx %v, input tx %v vout %v is double spend", block.Height, tx.Txid, input.Txid, input.Vout)
+			}
+			ta.Inputs[i].AddrDesc = ot.AddrDesc
+			ta.Inputs[i].ValueSat.Set(&ot.ValueSat)
+			ot.Spent = true
+			if len(ot.AddrDesc) == 0 {
+				continue
+			}
+			ab := addOutpoint(ot.AddrDesc, bt[txi].btxID, ^int32(i))
+			ab.BalanceSat.Sub(&ab.BalanceSat, &ot.ValueSat)
+			if ab.BalanceSat.Sign() < 0 {
+				ab.BalanceSat.SetInt64(0)
+			}
+			ab.SentSat.Add(&ab.SentSat, &ot.ValueSat)
+		}
+	}
+	u.apply()
+	for _, s := range order {
+		m.addresses[s] = append(m.addresses[s], memoryAddressRow{height: block.Height, outpoints: addresses[s]})
+	}
+	m.blocks[block.Height] = &memoryBlock{
+		info: &BlockInfo{
+			Hash:   block.Hash,
+			Time:   block.Time,
+			Txs:    uint32(len(block.Txs)),
+			Size:   uint32(block.Size),
+			Height: block.Height,
+		},
+		txs: bt,
+	}
+	m.bestHeight = block.Height
+	return nil
+}
+
+// disconnectTx reverts the changes of balances and spent outputs made by the transaction
+func (u *memoryUpdate) disconnectTx(bt *blockTxs, ta *TxAddresses) {
+	addresses := make(map[string]struct{})
+	update := func(addrDesc bchain.AddressDescriptor) *AddrBalance {
+		s := string(addrDesc)
+		ab := u.getBalance(addrDesc)
+		// subtract number of txs only once
+		if _, exist := addresses[s]; !exist {
+			addresses[s] = struct{}{}
+			if ab.Txs > 0 {
+				ab.Txs--
+			}
+		}
+		return ab
+	}
+	for i := range ta.Inputs {
+		t := &ta.Inputs[i]
+		if len(t.AddrDesc) == 0 {
+			continue
+		}
+		ab := update(t.AddrDesc)
+		ab.SentSat.Sub(&ab.SentSat, &t.ValueSat)
+		if ab.SentSat.Sign() < 0 {
+			ab.SentSat.SetInt64(0)
+		}
+		ab.BalanceSat.Add(&ab.BalanceSat, &t.ValueSat)
+		if sa := u.getTxAddresses(bt.inputs[i].btxID); sa != nil && int(bt.inputs[i].index) < len(sa.Outputs) {
+			sa.Outputs[bt.inputs[i].index].Spent = false
+		}
+	}
+	for i := range ta.Outputs {
+		t := &ta.Outputs[i]
+		if len(t.AddrDesc) == 0 {
+			continue
+		}
+		ab := update(t.AddrDesc)
+		ab.BalanceSat.Sub(&ab.BalanceSat, &t.ValueSat)
+		if ab.BalanceSat.Sign() < 0 {
+			ab.BalanceSat.SetInt64(0)
+		}
+	}
+}
+
+// DisconnectBlockRangeBitcoinType removes all data belonging to blocks in range lower-higher
+// only the blocks at the top of the index can be disconnected, getBlock is not used as all blocks are kept in memory
+func (m *MemoryStore) DisconnectBlockRangeBitcoinType(lower uint32, higher uint32, getBlock BlockGetter) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.blocks) == 0 || higher != m.bestHeight || lower > higher {
+		return errors.Errorf("Cannot disconnect blocks %v-%v, only the blocks at the top of the index can be disconnected", lower, higher)
+	}
+	if _, found := m.blocks[lower]; !found {
+		return errors.Errorf("Cannot disconnect blocks with height %v and lower, they are not in index", lower)
+	}
+	u := memoryUpdate{
+		m:           m,
+		txAddresses: make(map[string]*TxAddresses),
+		balances:    make(map[string]*AddrBalance),
+	}
+	var txsToDelete []string
+	for height := higher; ; height-- {
+		b := m.blocks[height]
+		glog.Info("Disconnecting block ", height, " containing ", len(b.txs), " transactions")
+		// go backwards to avoid interim negative balance
+		for i := len(b.txs) - 1; i >= 0; i-- {
+			s := string(b.txs[i].btxID)
+			txsToDelete = append(txsToDelete, s)
+			ta := u.getTxAddresses(b.txs[i].btxID)
+			if ta == nil {
+				ut, _ := m.chainParser.UnpackTxid(b.txs[i].btxID)
+				glog.Warning("TxAddress for txid ", ut, " not found")
+				continue
+			}
+			u.disconnectTx(&b.txs[i], ta)
+		}
+		if height == lower {
+			break
+		}
+	}
+	u.apply()
+	for _, s := range txsToDelete {
+		delete(m.txAddresses, s)
+		delete(m.txs, s)
+	}
+	for s, rows := range m.addresses {
+		i := len(rows)
+		for i > 0 && rows[i-1].height >= lower {
+			i--
+		}
+		if i == 0 {
+			delete(m.addresses, s)
+		} else if i < len(rows) {
+			// limit the capacity so that the next append does not overwrite rows possibly read by GetAddrDescTransactions
+			m.addresses[s] = rows[:i:i]
+		}
+	}
+	for height := lower; height <= higher; height++ {
+		delete(m.blocks, height)
+	}
+	if lower > 0 {
+		m.bestHeight = lower - 1
+	} else {
+		m.bestHeight = 0
+	}
+	glog.Infof("memorystore: blocks %d-%d disconnected", lower, higher)
+	return nil
+}
+
+// DisconnectBlockRangeEthereumType is not supported by MemoryStore
+func (m *MemoryStore) DisconnectBlockRangeEthereumType(lower uint32, higher uint32) error {
+	return errors.New("MemoryStore supports only BitcoinType coins")
+}
+
+// GetBestBlock returns the height and hash of the best indexed block
+func (m *MemoryStore) GetBestBlock() (uint32, string, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if b, found := m.blocks[m.bestHeight]; found {
+		return m.bestHeight, b.info.Hash, nil
+	}
+	return 0, "", nil
+}
+
+// GetBlockHash returns block hash at given height or empty string if not found
+func (m *MemoryStore) GetBlockHash(height uint32) (string, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if b, found := m.blocks[height]; found {
+		return b.info.Hash, nil
+	}
+	return "", nil
+}
+
+// GetBlockInfo returns block info at given height or nil if not found
+func (m *MemoryStore) GetBlockInfo(height uint32) (*BlockInfo, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if b, found := m.blocks[height]; found {
+		bi := *b.info
+		return &bi, nil
+	}
+	return nil, nil
+}
+
+// GetTransactions finds all input/output transactions for address
+func (m *MemoryStore) GetTransactions(address string, lower uint32, higher uint32, fn func(txid string, vout int32, isOutput bool) error) error {
+	addrDesc, err := m.chainParser.GetAddrDescFromAddress(address)
+	if err != nil {
+		return err
+	}
+	return m.GetAddrDescTransactions(addrDesc, lower, higher, fn)
+}
+
+// GetAddrDescTransactions finds all input/output transactions for address descriptor
+// the transactions are passed to fn in the same order as by RocksDB
+func (m *MemoryStore) GetAddrDescTransactions(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(txid string, vout int32, isOutput bool) error) error {
+	m.mux.RLock()
+	rows := m.addresses[string(addrDesc)]
+	m.mux.RUnlock()
+	// the rows are only appended or truncated without reuse of the truncated part, the slice taken under lock is not modified
+	for _, r := range rows {
+		if r.height < lower {
+			continue
+		}
+		if r.height > higher {
+			break
+		}
+		for _, o := range r.outpoints {
+			vout, isOutput := o.index, true
+			if o.index < 0 {
+				vout, isOutput = ^o.index, false
+			}
+			txid, err := m.chainParser.UnpackTxid(o.btxID)
+			if err != nil {
+				return err
+			}
+			if err := fn(txid, vout, isOutput); err != nil {
+				if _, ok := err.(*StopIteration); ok {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetAddrDescTransactionsReverse finds all input/output transactions for address descriptor in blocks higher-lower,
+// the transactions are passed to fn in the reverse order together with the block height, the same as by RocksDB
+func (m *MemoryStore) GetAddrDescTransactionsReverse(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(txid string, height uint32, vout int32, isOutput bool) error) error {
+	m.mux.RLock()
+	rows := m.addresses[string(addrDesc)]
+	m.mux.RUnlock()
+	for i := len(rows) - 1; i >= 0; i-- {
+		r := &rows[i]
+		if r.height > higher {
+			continue
+		}
+		if r.height < lower {
+			break
+		}
+		for j := len(r.outpoints) - 1; j >= 0; j-- {
+			o := &r.outpoints[j]
+			vout, isOutput := o.index, true
+			if o.index < 0 {
+				vout, isOutput = ^o.index, false
+			}
+			txid, err := m.chainParser.UnpackTxid(o.btxID)
+			if err != nil {
+				return err
+			}
+			if err := fn(txid, r.height, vout, isOutput); err != nil {
+				if _, ok := err.(*StopIteration); ok {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetAddrDescBalance returns AddrBalance for given addrDesc or nil if not found
+func (m *MemoryStore) GetAddrDescBalance(addrDesc bchain.AddressDescriptor) (*AddrBalance, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return copyAddrBalance(m.balances[string(addrDesc)]), nil
+}
+
+// GetTxAddresses returns TxAddresses for given txid or nil if not found
+func (m *MemoryStore) GetTxAddresses(txid string) (*TxAddresses, error) {
+	btxID, err := m.chainParser.PackTxid(txid)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return copyTxAddresses(m.txAddresses[string(btxID)]), nil
+}
+
+// GetAddrDescContracts is not supported by MemoryStore
+func (m *MemoryStore) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*AddrContracts, error) {
+	return nil, errors.New("MemoryStore supports only BitcoinType coins")
+}
+
+// GetAddrDescTokenBalance is not supported by MemoryStore
+func (m *MemoryStore) GetAddrDescTokenBalance(addrDesc, contract bchain.AddressDescriptor) (*big.Int, error) {
+	return nil, errors.New("MemoryStore supports only BitcoinType coins")
+}
+
+// GetContractHolders is not supported by MemoryStore
+func (m *MemoryStore) GetContractHolders(contract bchain.AddressDescriptor, fn func(addrDesc bchain.AddressDescriptor) error) error {
+	return errors.New("MemoryStore supports only BitcoinType coins")
+}
+
+// LogIndexEnabled returns false, MemoryStore does not index logs
+func (m *MemoryStore) LogIndexEnabled() bool {
+	return false
+}
+
+// GetLogs is not supported by MemoryStore
+func (m *MemoryStore) GetLogs(address, topic0 []byte, lower uint32, higher uint32, fn func(txid string, logIndex int, height uint32) error) error {
+	return errors.New("MemoryStore supports only BitcoinType coins")
+}
+
+// GetTx returns cached transaction and height of the block containing it or nil if not found
+func (m *MemoryStore) GetTx(txid string) (*bchain.Tx, uint32, error) {
+	key, err := m.chainParser.PackTxid(txid)
+	if err != nil {
+		return nil, 0, err
+	}
+	m.mux.RLock()
+	data := m.txs[string(key)]
+	m.mux.RUnlock()
+	if len(data) > 4 {
+		return m.chainParser.UnpackTx(data)
+	}
+	return nil, 0, nil
+}
+
+// PutTx stores transaction in the cache, it is packed the same way as in RocksDB so that the returned tx is a copy
+func (m *MemoryStore) PutTx(tx *bchain.Tx, height uint32, blockTime int64) error {
+	key, err := m.chainParser.PackTxid(tx.Txid)
+	if err != nil {
+		return nil
+	}
+	buf, err := m.chainParser.PackTx(tx, height, blockTime)
+	if err != nil {
+		return err
+	}
+	m.mux.Lock()
+	m.txs[string(key)] = buf
+	m.mux.Unlock()
+	return nil
+}
+
+// Backup is not supported by MemoryStore
+func (m *MemoryStore) Backup(dir string) error {
+	return errors.New("MemoryStore does not support backups")
+}
+
+// DatabaseSizeOnDisk returns 0, MemoryStore does not use disk
+func (m *MemoryStore) DatabaseSizeOnDisk() int64 {
+	return 0
+}
+
+// GetMemoryStats returns number of items in the index
+func (m *MemoryStore) GetMemoryStats() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return fmt.Sprintf("Blocks %d, txAddresses %d, addresses %d, balances %d, txs %d", len(m.blocks), len(m.txAddresses), len(m.addresses), len(m.balances), len(m.txs))
+}
+
+// GetAndResetConnectBlockStats returns empty string, MemoryStore does not collect connect block statistics
+func (m *MemoryStore) GetAndResetConnectBlockStats() string {
+	return ""
+}
@@ -0,0 +1,197 @@
+// build unittest
+
+package db
+
+import (
+	"blockbook/bchain"
+	"blockbook/tests/dbtestdata"
+	"reflect"
+	"testing"
+)
+
+type memoryStoreAddressTxs struct {
+	txid     string
+	height   uint32
+	vout     int32
+	isOutput bool
+}
+
+// compareStores checks that the MemoryStore returns the same data as RocksDB
+func compareStores(t *testing.T, d *RocksDB, m *MemoryStore) {
+	dh, dhash, err := d.GetBestBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mh, mhash, err := m.GetBestBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dh != mh || dhash != mhash {
+		t.Errorf("GetBestBlock() = %v %v, want %v %v", mh, mhash, dh, dhash)
+	}
+	for _, h := range []uint32{225493, 225494} {
+		dbi, err := d.GetBlockInfo(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mbi, err := m.GetBlockInfo(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mbi, dbi) {
+			t.Errorf("GetBlockInfo(%v) = %+v, want %+v", h, mbi, dbi)
+		}
+	}
+	for _, txid := range []string{dbtestdata.TxidB1T1, dbtestdata.TxidB1T2, dbtestdata.TxidB2T1, dbtestdata.TxidB2T2, dbtestdata.TxidB2T3, dbtestdata.TxidB2T4} {
+		dta, err := d.GetTxAddresses(txid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mta, err := m.GetTxAddresses(txid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mta, dta) {
+			t.Errorf("GetTxAddresses(%v) = %+v, want %+v", txid, mta, dta)
+		}
+	}
+	for _, address := range []string{dbtestdata.Addr1, dbtestdata.Addr2, dbtestdata.Addr3, dbtestdata.Addr4, dbtestdata.Addr5,
+		dbtestdata.Addr6, dbtestdata.Addr7, dbtestdata.Addr8, dbtestdata.Addr9, dbtestdata.AddrA} {
+		addrDesc, err := d.chainParser.GetAddrDescFromAddress(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dab, err := d.GetAddrDescBalance(addrDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mab, err := m.GetAddrDescBalance(addrDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mab, dab) {
+			t.Errorf("GetAddrDescBalance(%v) = %+v, want %+v", address, mab, dab)
+		}
+		var dtxs, mtxs []memoryStoreAddressTxs
+		if err = d.GetTransactions(address, 0, ^uint32(0), func(txid string, vout int32, isOutput bool) error {
+			dtxs = append(dtxs, memoryStoreAddressTxs{txid, 0, vout, isOutput})
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err = m.GetTransactions(address, 0, ^uint32(0), func(txid string, vout int32, isOutput bool) error {
+			mtxs = append(mtxs, memoryStoreAddressTxs{txid, 0, vout, isOutput})
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mtxs, dtxs) {
+			t.Errorf("GetTransactions(%v) = %+v, want %+v", address, mtxs, dtxs)
+		}
+		dtxs, mtxs = nil, nil
+		if err = d.GetAddrDescTransactionsReverse(addrDesc, 0, ^uint32(0), func(txid string, height uint32, vout int32, isOutput bool) error {
+			dtxs = append(dtxs, memoryStoreAddressTxs{txid, height, vout, isOutput})
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err = m.GetAddrDescTransactionsReverse(addrDesc, 0, ^uint32(0), func(txid string, height uint32, vout int32, isOutput bool) error {
+			mtxs = append(mtxs, memoryStoreAddressTxs{txid, height, vout, isOutput})
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mtxs, dtxs) {
+			t.Errorf("GetAddrDescTransactionsReverse(%v) = %+v, want %+v", address, mtxs, dtxs)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	m, err := NewMemoryStore(d.chainParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s IndexStore = m
+
+	block1 := dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)
+	block2 := dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)
+	if err := s.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ConnectBlock(block2); err == nil {
+		t.Error("ConnectBlock() expected error for block which is not the next block")
+	}
+	if err := s.DisconnectBlockRangeBitcoinType(225494, 225494, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []*bchain.Block{block1, block2} {
+		if err := d.ConnectBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ConnectBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		compareStores(t, d, m)
+	}
+
+	// returned data are copies, their modification does not change the store
+	ta, err := s.GetTxAddresses(dbtestdata.TxidB1T1)
+	if err != nil || ta == nil {
+		t.Fatal("GetTxAddresses", err)
+	}
+	ta.Outputs[0].Spent = true
+	ta.Outputs[0].ValueSat.SetInt64(1)
+	compareStores(t, d, m)
+
+	// reverse iteration over the range of blocks stops at StopIteration
+	addrDesc, err := d.chainParser.GetAddrDescFromAddress(dbtestdata.Addr5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rtxs []memoryStoreAddressTxs
+	if err = s.GetAddrDescTransactionsReverse(addrDesc, 225493, 225493, func(txid string, height uint32, vout int32, isOutput bool) error {
+		rtxs = append(rtxs, memoryStoreAddressTxs{txid, height, vout, isOutput})
+		return &StopIteration{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []memoryStoreAddressTxs{{dbtestdata.TxidB1T2, 225493, 2, true}}; !reflect.DeepEqual(rtxs, want) {
+		t.Errorf("GetAddrDescTransactionsReverse() = %+v, want %+v", rtxs, want)
+	}
+
+	if err := s.DisconnectBlockRangeBitcoinType(225493, 225493, nil); err == nil {
+		t.Error("DisconnectBlockRangeBitcoinType() expected error for block which is not at the top")
+	}
+	if err := d.DisconnectBlockRangeBitcoinType(225494, 225494, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DisconnectBlockRangeBitcoinType(225494, 225494, nil); err != nil {
+		t.Fatal(err)
+	}
+	compareStores(t, d, m)
+
+	// tx cache
+	tx := &block2.Txs[1]
+	if err := s.PutTx(tx, 225494, block2.Time); err != nil {
+		t.Fatal(err)
+	}
+	ctx, h, err := s.GetTx(tx.Txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx == nil || ctx.Txid != tx.Txid || h != 225494 {
+		t.Errorf("GetTx(%v) = %+v, %v, want the stored tx at height 225494", tx.Txid, ctx, h)
+	}
+	if ctx, _, err = s.GetTx(dbtestdata.TxidB1T1); err != nil || ctx != nil {
+		t.Errorf("GetTx(%v) = %+v, %v, want nil", dbtestdata.TxidB1T1, ctx, err)
+	}
+
+	if _, err := NewMemoryStore(ethereumTestnetParser()); err == nil {
+		t.Error("NewMemoryStore() expected error for EthereumType coin")
+	}
+}
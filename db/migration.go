@@ -0,0 +1,227 @@
+package db
+
+import (
+	"blockbook/common"
+	"bytes"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// migrationFunc transforms the data of the db from one version to the next one
+type migrationFunc func(m *migrationRun) error
+
+type migration struct {
+	fromVersion uint32
+	description string
+	migrate     migrationFunc
+}
+
+// migrations is the registry of db migrations, a migration upgrades the db from fromVersion to fromVersion+1
+var migrations []migration
+
+// registerMigration adds a migration from the fromVersion to the registry
+func registerMigration(fromVersion uint32, description string, fn migrationFunc) {
+	migrations = append(migrations, migration{
+		fromVersion: fromVersion,
+		description: description,
+		migrate:     fn,
+	})
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].fromVersion < migrations[j].fromVersion })
+}
+
+func findMigration(fromVersion uint32) *migration {
+	for i := range migrations {
+		if migrations[i].fromVersion == fromVersion {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+// number of rows processed in one write batch, after each batch the migration progress is stored
+var migrationBatchSize = 10000
+
+// migrationRun holds the state of a running migration
+type migrationRun struct {
+	d    *RocksDB
+	is   *common.InternalState
+	stop chan os.Signal
+}
+
+func (m *migrationRun) storeProgress(wb *gorocksdb.WriteBatch) error {
+	buf, err := m.is.Pack()
+	if err != nil {
+		return err
+	}
+	wb.PutCF(m.d.cfh[cfDefault], []byte(internalStateKey), buf)
+	return m.d.db.Write(m.d.wo, wb)
+}
+
+// migrateColumn calls fn for each row of the column, fn modifies the db using the write batch
+// the progress is stored together with the changes, therefore an interrupted migration continues after the last processed row
+// fn must not add new keys to the column after the key that is being processed, they would be processed again
+func (m *migrationRun) migrateColumn(col int, fn func(wb *gorocksdb.WriteBatch, key, value []byte) error) error {
+	name := cfNames[col]
+	ms := m.is.Migration
+	for _, c := range ms.DoneColumns {
+		if c == name {
+			glog.Info("migration: column ", name, " already migrated")
+			return nil
+		}
+	}
+	var seekKey []byte
+	if ms.Column == name {
+		seekKey = ms.LastKey
+		glog.Info("migration: column ", name, " resumed after ", ms.Rows, " rows")
+	} else {
+		ms.Column = name
+		ms.LastKey = nil
+		ms.Rows = 0
+	}
+	// do not use cache
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetFillCache(false)
+	defer ro.Destroy()
+	for {
+		select {
+		case <-m.stop:
+			return errors.New("Interrupted")
+		default:
+		}
+		var lastKey []byte
+		count := 0
+		wb := gorocksdb.NewWriteBatch()
+		it := m.d.db.NewIteratorCF(ro, m.d.cfh[col])
+		if seekKey == nil {
+			it.SeekToFirst()
+		} else {
+			it.Seek(seekKey)
+			if it.Valid() && bytes.Equal(it.Key().Data(), seekKey) {
+				it.Next()
+			}
+		}
+		var err error
+		for ; it.Valid() && count < migrationBatchSize; it.Next() {
+			key := append([]byte(nil), it.Key().Data()...)
+			value := append([]byte(nil), it.Value().Data()...)
+			if err = fn(wb, key, value); err != nil {
+				break
+			}
+			lastKey = key
+			count++
+		}
+		it.Close()
+		if err == nil && count > 0 {
+			ms.LastKey = lastKey
+			ms.Rows += int64(count)
+			err = m.storeProgress(wb)
+		}
+		wb.Destroy()
+		if err != nil {
+			return errors.Annotatef(err, "column %v", name)
+		}
+		if count == 0 {
+			break
+		}
+		seekKey = lastKey
+		glog.Info("migration: column ", name, ": processed ", ms.Rows, " rows")
+	}
+	ms.DoneColumns = append(ms.DoneColumns, name)
+	ms.Column = ""
+	ms.LastKey = nil
+	ms.Rows = 0
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	return m.storeProgress(wb)
+}
+
+// loadStoredInternalState returns internal state as stored in the db, without any checks, nil if not stored
+func (d *RocksDB) loadStoredInternalState() (*common.InternalState, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(internalStateKey))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	data := val.Data()
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return common.UnpackInternalState(data)
+}
+
+// dataVersion returns the lowest version of the stored columns
+func dataVersion(is *common.InternalState) uint32 {
+	v := uint32(dbVersion)
+	for _, c := range is.DbColumns {
+		if c.Version < v {
+			v = c.Version
+		}
+	}
+	return v
+}
+
+// Migrate upgrades the data in the db to dbVersion using the registered migrations
+// the progress is stored in the internal state and an interrupted migration can be resumed by running Migrate again
+func (d *RocksDB) Migrate(rpcCoin string, stop chan os.Signal) error {
+	is, err := d.loadStoredInternalState()
+	if err != nil {
+		return err
+	}
+	if is == nil {
+		glog.Info("migration: empty db, nothing to migrate")
+		return nil
+	}
+	if is.Coin != "" && is.Coin != rpcCoin {
+		return errors.Errorf("Coins do not match. DB coin %v, RPC coin %v", is.Coin, rpcCoin)
+	}
+	if is.DbState == common.DbStateInconsistent {
+		return errors.New("DB is in inconsistent state and cannot be migrated")
+	}
+	version := dataVersion(is)
+	if version == dbVersion {
+		glog.Info("migration: db is in the current version ", dbVersion, ", nothing to migrate")
+		return nil
+	}
+	// check that the whole migration path exists before any data is changed
+	for v := version; v < dbVersion; v++ {
+		if findMigration(v) == nil {
+			return errors.Errorf("No migration of DB version %v to version %v. DB must be recreated.", v, v+1)
+		}
+	}
+	for v := version; v < dbVersion; v++ {
+		mg := findMigration(v)
+		if is.Migration == nil || is.Migration.FromVersion != v {
+			is.Migration = &common.MigrationState{
+				FromVersion: v,
+				ToVersion:   v + 1,
+				Started:     time.Now(),
+			}
+			glog.Infof("migration: migrating db from version %d to %d: %s", v, v+1, mg.description)
+		} else {
+			glog.Infof("migration: resuming migration of db from version %d to %d started %v: %s", v, v+1, is.Migration.Started, mg.description)
+		}
+		if err = d.storeState(is); err != nil {
+			return err
+		}
+		start := time.Now()
+		if err = mg.migrate(&migrationRun{d: d, is: is, stop: stop}); err != nil {
+			return errors.Annotatef(err, "migration from version %v", v)
+		}
+		for i := range is.DbColumns {
+			if is.DbColumns[i].Version < v+1 {
+				is.DbColumns[i].Version = v + 1
+			}
+		}
+		is.Migration = nil
+		if err = d.storeState(is); err != nil {
+			return err
+		}
+		glog.Infof("migration: db migrated to version %d in %v", v+1, time.Since(start))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// build unittest
+
+package db
+
+import (
+	"blockbook/tests/dbtestdata"
+	"bytes"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+func getColumnValues(t *testing.T, d *RocksDB, col int) map[string][]byte {
+	r := make(map[string][]byte)
+	it := d.db.NewIteratorCF(d.ro, d.cfh[col])
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		r[string(it.Key().Data())] = append([]byte(nil), it.Value().Data()...)
+	}
+	return r
+}
+
+func TestRocksDB_Migrate(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	original := getColumnValues(t, d, cfHeight)
+	if len(original) != 2 {
+		t.Fatalf("height column has %d rows, want 2", len(original))
+	}
+
+	// store the state as if the db was created by the previous version
+	for i := range d.is.DbColumns {
+		d.is.DbColumns[i].Version = dbVersion - 1
+	}
+	if err := d.StoreInternalState(d.is); err != nil {
+		t.Fatal(err)
+	}
+
+	savedMigrations, savedBatchSize := migrations, migrationBatchSize
+	defer func() {
+		migrations, migrationBatchSize = savedMigrations, savedBatchSize
+	}()
+
+	// without a registered migration the db cannot be migrated nor opened
+	migrations = nil
+	if err := d.Migrate("coin-unittest", nil); err == nil {
+		t.Fatal("Migrate() expected error for missing migration")
+	}
+	if _, err := d.LoadInternalState("coin-unittest"); err == nil {
+		t.Fatal("LoadInternalState() expected error for old version")
+	}
+
+	// the test migration prefixes the values of the height column by 0xff, the first run crashes in the second batch
+	migrationBatchSize = 1
+	calls := 0
+	crash := true
+	registerMigration(dbVersion-1, "test migration", func(m *migrationRun) error {
+		return m.migrateColumn(cfHeight, func(wb *gorocksdb.WriteBatch, key, value []byte) error {
+			calls++
+			if crash && calls == 2 {
+				return errors.New("simulated crash")
+			}
+			wb.PutCF(m.d.cfh[cfHeight], key, append([]byte{0xff}, value...))
+			return nil
+		})
+	})
+	if err := d.Migrate("coin-unittest", nil); err == nil {
+		t.Fatal("Migrate() expected simulated crash")
+	}
+	is, err := d.loadStoredInternalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is.Migration == nil || is.Migration.Column != cfNames[cfHeight] || is.Migration.Rows != 1 {
+		t.Fatalf("stored migration state %+v, want 1 row of column %v", is.Migration, cfNames[cfHeight])
+	}
+	if _, err := d.LoadInternalState("coin-unittest"); err == nil {
+		t.Fatal("LoadInternalState() expected error for unfinished migration")
+	}
+
+	// resumed migration must process only the remaining row
+	crash = false
+	if err := d.Migrate("coin-unittest", nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("migration function called %d times, want 3", calls)
+	}
+	migrated := getColumnValues(t, d, cfHeight)
+	for k, v := range original {
+		if !bytes.Equal(migrated[k], append([]byte{0xff}, v...)) {
+			t.Errorf("key %x: migrated value %x, original %x", k, migrated[k], v)
+		}
+	}
+	is, err = d.LoadInternalState("coin-unittest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is.Migration != nil {
+		t.Errorf("Migration = %+v, want nil", is.Migration)
+	}
+	for _, c := range is.DbColumns {
+		if c.Version != dbVersion {
+			t.Errorf("column %v version %v, want %v", c.Name, c.Version, dbVersion)
+		}
+	}
+
+	// nothing more to migrate
+	calls = 0
+	if err := d.Migrate("coin-unittest", nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Errorf("migration function called %d times, want 0", calls)
+	}
+}
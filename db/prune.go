@@ -0,0 +1,366 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// Pruned index keeps only data necessary for balances and utxos of addresses.
+// Blocks up to height bestHeight-PruneDepth are pruned:
+//  - inputs are removed from the addresses column
+//  - fully spent transactions are removed from the txAddresses column and their outputs from the addresses column
+// A transaction can be removed only if none of its outputs is spent in a block above the prune horizon,
+// otherwise the disconnect of such block would not be possible. The txids spent above the horizon are kept
+// in pruneWindow, which is built from the blockTxs column. Therefore the PruneDepth must be lower than KeepBlockAddresses.
+
+// number of pruned transactions or address rows written in one batch during the full prune
+const pruneBatchSize = 10000
+
+// pruneWindow contains txids spent in blocks lower-higher with the number of spending inputs
+type pruneWindow struct {
+	lower, higher uint32
+	spends        map[string]int
+}
+
+// SetPruneDepth enables the pruned index profile, blocks older than depth are pruned
+// depth 0 means full index, it is not possible to use full index on already pruned db
+// must be called after SetInternalState
+func (d *RocksDB) SetPruneDepth(depth uint32) error {
+	if d.is == nil {
+		return errors.New("Internal state not set")
+	}
+	if depth == 0 {
+		if ph := d.is.GetPrunedHeight(); ph > 0 {
+			return errors.Errorf("DB is pruned up to height %v, it cannot be used as a full index. It is necessary to rebuild index.", ph)
+		}
+		d.is.PruneDepth = 0
+		return nil
+	}
+	if d.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("Pruning is supported only for BitcoinType coins")
+	}
+	if keep := d.chainParser.KeepBlockAddresses(); int(depth) >= keep {
+		return errors.Errorf("Prune depth %v must be lower than the number of kept blocks %v", depth, keep)
+	}
+	d.is.PruneDepth = depth
+	return nil
+}
+
+// updateSpends adds (delta 1) or removes (delta -1) the txids spent in the block at height to spends
+func (d *RocksDB) updateSpends(spends map[string]int, height uint32, delta int) ([]blockTxs, error) {
+	bt, err := d.getBlockTxs(height)
+	if err != nil {
+		return nil, err
+	}
+	// each block contains at least the coinbase transaction, blockTxs can be missing only for blocks which are not indexed
+	if len(bt) == 0 {
+		bi, err := d.GetBlockInfo(height)
+		if err != nil {
+			return nil, err
+		}
+		if bi != nil {
+			return nil, errors.Errorf("BlockTxs of block %v not found", height)
+		}
+	}
+	for i := range bt {
+		for _, o := range bt[i].inputs {
+			s := string(o.btxID)
+			if n := spends[s] + delta; n > 0 {
+				spends[s] = n
+			} else {
+				delete(spends, s)
+			}
+		}
+	}
+	return bt, nil
+}
+
+func (d *RocksDB) loadPruneWindow(lower, higher uint32) (*pruneWindow, error) {
+	w := &pruneWindow{
+		lower:  lower,
+		higher: higher,
+		spends: make(map[string]int),
+	}
+	for h := lower; h <= higher; h++ {
+		if _, err := d.updateSpends(w.spends, h, 1); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// pruneRows holds modified rows of the addresses column
+type pruneRows struct {
+	d    *RocksDB
+	rows map[string][]outpoint
+}
+
+func (r *pruneRows) remove(addrDesc bchain.AddressDescriptor, height uint32, btxID []byte, index int32) error {
+	key := string(packAddressKey(addrDesc, height))
+	outpoints, found := r.rows[key]
+	if !found {
+		val, err := r.d.db.GetCF(r.d.ro, r.d.cfh[cfAddresses], []byte(key))
+		if err != nil {
+			return err
+		}
+		outpoints, err = r.d.unpackOutpoints(val.Data())
+		val.Free()
+		if err != nil {
+			return err
+		}
+	}
+	for i := range outpoints {
+		if outpoints[i].index == index && string(outpoints[i].btxID) == string(btxID) {
+			outpoints = append(outpoints[:i], outpoints[i+1:]...)
+			break
+		}
+	}
+	r.rows[key] = outpoints
+	return nil
+}
+
+func (r *pruneRows) write(wb *gorocksdb.WriteBatch) {
+	for key, outpoints := range r.rows {
+		if len(outpoints) == 0 {
+			wb.DeleteCF(r.d.cfh[cfAddresses], []byte(key))
+		} else {
+			wb.PutCF(r.d.cfh[cfAddresses], []byte(key), r.d.packOutpoints(outpoints))
+		}
+	}
+	r.rows = make(map[string][]outpoint)
+}
+
+// fullySpent returns true if all outputs of the transaction with an address are spent
+func fullySpent(ta *TxAddresses) bool {
+	for i := range ta.Outputs {
+		if len(ta.Outputs[i].AddrDesc) > 0 && !ta.Outputs[i].Spent {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *RocksDB) pruneTx(wb *gorocksdb.WriteBatch, rows *pruneRows, btxID []byte, ta *TxAddresses) error {
+	wb.DeleteCF(d.cfh[cfTxAddresses], btxID)
+	for i := range ta.Outputs {
+		if len(ta.Outputs[i].AddrDesc) > 0 {
+			if err := rows.remove(ta.Outputs[i].AddrDesc, ta.Height, btxID, int32(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writePruneState writes the batch together with the internal state with the new pruned height and the first block
+// of an unfinished prune, the state in memory is kept unchanged if the write fails
+func (d *RocksDB) writePruneState(wb *gorocksdb.WriteBatch, prunedHeight, pruningFromHeight uint32) error {
+	ph, pf := d.is.GetPruneState()
+	d.is.SetPruneState(prunedHeight, pruningFromHeight)
+	buf, err := d.is.Pack()
+	if err == nil {
+		wb.PutCF(d.cfh[cfDefault], []byte(internalStateKey), buf)
+		err = d.db.Write(d.wo, wb)
+	}
+	if err != nil {
+		d.is.SetPruneState(ph, pf)
+	}
+	return err
+}
+
+// pruneBlock prunes block at height, spends must contain the txids spent in the blocks above the height
+func (d *RocksDB) pruneBlock(height uint32, bt []blockTxs, spends map[string]int) error {
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows := &pruneRows{d: d, rows: make(map[string][]outpoint)}
+	var txs []*TxAddresses
+	for i := range bt {
+		ta, err := d.getTxAddresses(bt[i].btxID)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, ta)
+		if ta == nil {
+			continue
+		}
+		for j := range ta.Inputs {
+			if len(ta.Inputs[j].AddrDesc) > 0 {
+				if err := rows.remove(ta.Inputs[j].AddrDesc, height, bt[i].btxID, ^int32(j)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	processed := make(map[string]struct{})
+	for i := range bt {
+		// the transactions of the block, which are fully spent, can be pruned too
+		candidates := append([]outpoint{{btxID: bt[i].btxID}}, bt[i].inputs...)
+		for c, o := range candidates {
+			s := string(o.btxID)
+			if _, found := processed[s]; found || spends[s] > 0 {
+				continue
+			}
+			processed[s] = struct{}{}
+			ta := txs[i]
+			if c > 0 {
+				var err error
+				if ta, err = d.getTxAddresses(o.btxID); err != nil {
+					return err
+				}
+			}
+			if ta == nil || ta.Height > height || !fullySpent(ta) {
+				continue
+			}
+			if err := d.pruneTx(wb, rows, o.btxID, ta); err != nil {
+				return err
+			}
+		}
+	}
+	rows.write(wb)
+	return d.writePruneState(wb, height, 0)
+}
+
+// fullPrune prunes all blocks in range lower-higher, spends must contain the txids spent in the blocks above higher
+// it is used when the pruning is enabled on an existing db or after the initial synchronization, can be very slow operation
+// the data is written in several batches, therefore the range is marked as pruned before the first batch
+// and as finished with the last one, an interrupted prune is repeated from lower
+func (d *RocksDB) fullPrune(lower, higher uint32, spends map[string]int) error {
+	start := time.Now()
+	glog.Infof("rocksdb: prune: pruning blocks %d-%d", lower, higher)
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	if err := d.writePruneState(wb, higher, lower); err != nil {
+		return err
+	}
+	wb.Clear()
+	rows := &pruneRows{d: d, rows: make(map[string][]outpoint)}
+	var count, pruned int
+	flush := func() error {
+		rows.write(wb)
+		err := d.db.Write(d.wo, wb)
+		wb.Clear()
+		count = 0
+		return err
+	}
+	// remove fully spent transactions, the outputs of the pruned transactions are removed from the addresses column
+	err := d.walkColumn(cfTxAddresses, nil, func(key, value []byte) error {
+		if spends[string(key)] > 0 {
+			return nil
+		}
+		ta, err := unpackTxAddresses(value)
+		if err != nil {
+			return err
+		}
+		if ta.Height > higher || !fullySpent(ta) {
+			return nil
+		}
+		if err = d.pruneTx(wb, rows, key, ta); err != nil {
+			return err
+		}
+		pruned++
+		if count++; count >= pruneBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+	glog.Info("rocksdb: prune: pruned ", pruned, " transactions")
+	// remove inputs from the addresses column
+	err = d.walkColumn(cfAddresses, nil, func(key, value []byte) error {
+		_, height, err := unpackAddressKey(key)
+		if err != nil {
+			return err
+		}
+		if height < lower || height > higher {
+			return nil
+		}
+		outpoints, err := d.unpackOutpoints(value)
+		if err != nil {
+			return err
+		}
+		o := outpoints[:0]
+		for _, op := range outpoints {
+			if op.index >= 0 {
+				o = append(o, op)
+			}
+		}
+		if len(o) == len(outpoints) {
+			return nil
+		}
+		rows.rows[string(key)] = o
+		if count++; count >= pruneBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		rows.write(wb)
+		err = d.writePruneState(wb, higher, 0)
+	}
+	if err != nil {
+		return err
+	}
+	glog.Infof("rocksdb: prune: blocks %d-%d pruned in %v", lower, higher, time.Since(start))
+	return nil
+}
+
+// pruneIndex prunes the blocks which got below the prune horizon after the block at bestHeight was connected
+func (d *RocksDB) pruneIndex(bestHeight uint32) (err error) {
+	depth := d.is.PruneDepth
+	if bestHeight <= depth {
+		return nil
+	}
+	horizon := bestHeight - depth
+	prunedHeight, pruningFromHeight := d.is.GetPruneState()
+	if prunedHeight >= horizon {
+		return nil
+	}
+	defer func() {
+		// the window is in unknown state after an error, it will be loaded again
+		if err != nil {
+			d.pruneWindow = nil
+		}
+	}()
+	w := d.pruneWindow
+	if pruningFromHeight > 0 || prunedHeight+1 < horizon {
+		// more blocks to prune, at least the last one must be pruned in the standard way to keep the window consistent
+		lower := prunedHeight + 1
+		if pruningFromHeight > 0 {
+			// the unfinished prune is repeated
+			lower = pruningFromHeight
+		}
+		if w, err = d.loadPruneWindow(horizon, bestHeight); err != nil {
+			return err
+		}
+		if err = d.fullPrune(lower, horizon-1, w.spends); err != nil {
+			return err
+		}
+		w.lower = horizon
+	} else if w == nil || w.lower != horizon || w.higher+1 != bestHeight {
+		if w, err = d.loadPruneWindow(horizon, bestHeight); err != nil {
+			return err
+		}
+	} else {
+		if _, err = d.updateSpends(w.spends, bestHeight, 1); err != nil {
+			return err
+		}
+		w.higher = bestHeight
+	}
+	d.pruneWindow = w
+	// move the block at horizon out of the window and prune it
+	bt, err := d.updateSpends(w.spends, horizon, -1)
+	if err != nil {
+		return err
+	}
+	w.lower = horizon + 1
+	return d.pruneBlock(horizon, bt, w.spends)
+}
@@ -0,0 +1,234 @@
+// build unittest
+
+package db
+
+import (
+	"blockbook/bchain"
+	"blockbook/bchain/coins/btc"
+	"blockbook/tests/dbtestdata"
+	"reflect"
+	"testing"
+)
+
+func getTestBitcoinTypeBlock3(parser bchain.BlockChainParser) *bchain.Block {
+	return &bchain.Block{
+		BlockHeader: bchain.BlockHeader{
+			Height: 225495,
+			Hash:   "00000000a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c",
+			Size:   345,
+			Time:   1534860001,
+		},
+		Txs: []bchain.Tx{
+			bchain.Tx{
+				Txid: "7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25",
+				Vin: []bchain.Vin{
+					bchain.Vin{
+						Coinbase: "03c01e1504aede765b",
+					},
+				},
+				Vout: []bchain.Vout{
+					bchain.Vout{
+						N: 0,
+						ScriptPubKey: bchain.ScriptPubKey{
+							Hex: dbtestdata.AddressToPubKeyHex(dbtestdata.AddrA, parser),
+						},
+						ValueSat: *dbtestdata.SatB2T4AA,
+					},
+				},
+				Blocktime: 22549500000,
+				Time:      22549500000,
+			},
+		},
+	}
+}
+
+type addressTxs struct {
+	txid     string
+	vout     int32
+	isOutput bool
+}
+
+func getAddressTxs(t *testing.T, d *RocksDB, address string) []addressTxs {
+	r := []addressTxs{}
+	if err := d.GetTransactions(address, 0, ^uint32(0), func(txid string, vout int32, isOutput bool) error {
+		r = append(r, addressTxs{txid, vout, isOutput})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// verifyAfterPrunedBitcoinTypeBlock2 checks the state of db after the 2nd block got below the prune horizon
+func verifyAfterPrunedBitcoinTypeBlock2(t *testing.T, d *RocksDB, balances map[string]*AddrBalance) {
+	if ph := d.is.GetPrunedHeight(); ph != 225494 {
+		t.Fatalf("PrunedHeight = %v, want 225494", ph)
+	}
+	// all outputs of TxidB1T2 are spent in the 2nd block
+	for _, txid := range []string{dbtestdata.TxidB1T1, dbtestdata.TxidB1T2, dbtestdata.TxidB2T1, dbtestdata.TxidB2T2, dbtestdata.TxidB2T3} {
+		ta, err := d.GetTxAddresses(txid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (ta == nil) != (txid == dbtestdata.TxidB1T2) {
+			t.Errorf("GetTxAddresses(%v) = %+v, only %v should be pruned", txid, ta, dbtestdata.TxidB1T2)
+		}
+	}
+	// inputs and outputs of the pruned transaction are removed, unspent outputs are kept
+	tests := []struct {
+		address string
+		want    []addressTxs
+	}{
+		{dbtestdata.Addr1, []addressTxs{{dbtestdata.TxidB1T1, 0, true}}},
+		{dbtestdata.Addr2, []addressTxs{{dbtestdata.TxidB1T1, 1, true}}},
+		{dbtestdata.Addr3, []addressTxs{}},
+		{dbtestdata.Addr4, []addressTxs{}},
+		{dbtestdata.Addr5, []addressTxs{{dbtestdata.TxidB2T3, 0, true}}},
+		{dbtestdata.Addr6, []addressTxs{{dbtestdata.TxidB2T1, 0, true}}},
+		{dbtestdata.Addr8, []addressTxs{{dbtestdata.TxidB2T2, 0, true}}},
+	}
+	for _, tt := range tests {
+		if got := getAddressTxs(t, d, tt.address); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetTransactions(%v) = %+v, want %+v", tt.address, got, tt.want)
+		}
+	}
+	// balances are not affected by pruning
+	for address, want := range balances {
+		got, err := d.GetAddressBalance(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAddressBalance(%v) = %+v, want %+v", address, got, want)
+		}
+	}
+}
+
+func setupPrunedRocksDB(t *testing.T) *RocksDB {
+	return setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: btc.NewBitcoinParser(
+			btc.GetChainParams("test"),
+			&btc.Configuration{BlockAddressesToKeep: 3}),
+	})
+}
+
+func getTestBalances(t *testing.T, d *RocksDB) map[string]*AddrBalance {
+	r := make(map[string]*AddrBalance)
+	for _, address := range []string{dbtestdata.Addr1, dbtestdata.Addr3, dbtestdata.Addr5, dbtestdata.Addr6} {
+		ab, err := d.GetAddressBalance(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r[address] = ab
+	}
+	return r
+}
+
+func TestRocksDB_Prune(t *testing.T) {
+	d := setupPrunedRocksDB(t)
+	defer closeAndDestroyRocksDB(t, d)
+
+	if err := d.SetPruneDepth(3); err == nil {
+		t.Error("SetPruneDepth(3) expected error for depth not lower than KeepBlockAddresses")
+	}
+	if err := d.SetPruneDepth(1); err != nil {
+		t.Fatal(err)
+	}
+
+	block1 := dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock1(t, d, false)
+	// all outputs of the 1st block are spent in the 2nd block, which is above the horizon, nothing can be pruned
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	if ph := d.is.GetPrunedHeight(); ph != 225493 {
+		t.Fatalf("PrunedHeight = %v, want 225493", ph)
+	}
+	if ta, err := d.GetTxAddresses(dbtestdata.TxidB1T2); err != nil || ta == nil {
+		t.Fatalf("GetTxAddresses(%v) = %+v, %v, want not pruned", dbtestdata.TxidB1T2, ta, err)
+	}
+	balances := getTestBalances(t, d)
+
+	block3 := getTestBitcoinTypeBlock3(d.chainParser)
+	if err := d.ConnectBlock(block3); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterPrunedBitcoinTypeBlock2(t, d, balances)
+
+	// pruned blocks cannot be disconnected, the blocks above the horizon can
+	if err := d.DisconnectBlockRangeBitcoinType(225494, 225495, nil); err == nil {
+		t.Error("DisconnectBlockRangeBitcoinType() expected error for pruned block")
+	}
+	if err := d.DisconnectBlockRangeBitcoinType(225495, 225495, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(block3); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterPrunedBitcoinTypeBlock2(t, d, balances)
+
+	// pruned db cannot be used as a full index
+	if err := d.SetPruneDepth(0); err == nil {
+		t.Error("SetPruneDepth(0) expected error for pruned db")
+	}
+}
+
+func TestRocksDB_Prune_ExistingIndex(t *testing.T) {
+	d := setupPrunedRocksDB(t)
+	defer closeAndDestroyRocksDB(t, d)
+
+	// index created without pruning is pruned in one step when pruning is enabled
+	for _, block := range []*bchain.Block{
+		dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser),
+		dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser),
+		getTestBitcoinTypeBlock3(d.chainParser),
+	} {
+		if err := d.ConnectBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ph := d.is.GetPrunedHeight(); ph != 0 {
+		t.Fatalf("PrunedHeight = %v, want 0", ph)
+	}
+	balances := getTestBalances(t, d)
+
+	if err := d.SetPruneDepth(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.pruneIndex(225495); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterPrunedBitcoinTypeBlock2(t, d, balances)
+}
+
+func TestRocksDB_Prune_Unfinished(t *testing.T) {
+	d := setupPrunedRocksDB(t)
+	defer closeAndDestroyRocksDB(t, d)
+
+	for _, block := range []*bchain.Block{
+		dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser),
+		dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser),
+		getTestBitcoinTypeBlock3(d.chainParser),
+	} {
+		if err := d.ConnectBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	balances := getTestBalances(t, d)
+
+	if err := d.SetPruneDepth(1); err != nil {
+		t.Fatal(err)
+	}
+	// the prune of the 1st block was interrupted before any data was removed, it must be repeated
+	d.is.SetPruneState(225493, 225493)
+	if err := d.pruneIndex(225495); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterPrunedBitcoinTypeBlock2(t, d, balances)
+	if _, pf := d.is.GetPruneState(); pf != 0 {
+		t.Fatalf("PruningFromHeight = %v, want 0", pf)
+	}
+}
@@ -42,17 +42,21 @@ type connectBlockStats struct {
 
 // RocksDB handle
 type RocksDB struct {
-	path         string
-	db           *gorocksdb.DB
-	wo           *gorocksdb.WriteOptions
-	ro           *gorocksdb.ReadOptions
-	cfh          []*gorocksdb.ColumnFamilyHandle
-	chainParser  bchain.BlockChainParser
-	is           *common.InternalState
-	metrics      *common.Metrics
-	cache        *gorocksdb.Cache
-	maxOpenFiles int
-	cbs          connectBlockStats
+	path            string
+	db              *gorocksdb.DB
+	wo              *gorocksdb.WriteOptions
+	ro              *gorocksdb.ReadOptions
+	cfh             []*gorocksdb.ColumnFamilyHandle
+	chainParser     bchain.BlockChainParser
+	is              *common.InternalState
+	metrics         *common.Metrics
+	cache           *gorocksdb.Cache
+	maxOpenFiles    int
+	cbs             connectBlockStats
+	logIndex        bool
+	pruneWindow     *pruneWindow
+	tuning          *DBTuning
+	scriptHashIndex bool
 }
 
 const (
@@ -61,34 +65,38 @@ const (
 	cfAddresses
 	cfBlockTxs
 	cfTransactions
+	cfWebhooks
 	// BitcoinType
 	cfAddressBalance
 	cfTxAddresses
+	cfScriptHashes
 	// EthereumType
-	cfAddressContracts = cfAddressBalance
+	cfAddressContracts     = cfAddressBalance
+	cfContractHolders      = cfTxAddresses
+	cfAddressTokenBalances = cfTxAddresses + 1
+	cfBlockTokenTransfers  = cfTxAddresses + 2
+	cfLogs                 = cfTxAddresses + 3
+	cfBlockLogs            = cfTxAddresses + 4
 )
 
 // common columns
-var cfNames = []string{"default", "height", "addresses", "blockTxs", "transactions"}
+var cfNames = []string{"default", "height", "addresses", "blockTxs", "transactions", "webhooks"}
 
 // type specific columns
-var cfNamesBitcoinType = []string{"addressBalance", "txAddresses"}
-var cfNamesEthereumType = []string{"addressContracts"}
-
-func openDB(path string, c *gorocksdb.Cache, openFiles int) (*gorocksdb.DB, []*gorocksdb.ColumnFamilyHandle, error) {
-	// opts with bloom filter
-	opts := createAndSetDBOptions(10, c, openFiles)
-	// opts for addresses without bloom filter
-	// from documentation: if most of your queries are executed using iterators, you shouldn't set bloom filter
-	optsAddresses := createAndSetDBOptions(0, c, openFiles)
-	// default, height, addresses, blockTxids, transactions
-	cfOptions := []*gorocksdb.Options{opts, opts, optsAddresses, opts, opts}
-	// append type specific options
-	count := len(cfNames) - len(cfOptions)
-	for i := 0; i < count; i++ {
-		cfOptions = append(cfOptions, opts)
-	}
-	db, cfh, err := gorocksdb.OpenDbColumnFamilies(opts, path, cfNames, cfOptions)
+var cfNamesBitcoinType = []string{"addressBalance", "txAddresses", "scriptHashes"}
+var cfNamesEthereumType = []string{"addressContracts", "contractHolders", "addressTokenBalances", "blockTokenTransfers", "logs", "blockLogs"}
+
+func openDB(path string, c *gorocksdb.Cache, openFiles int, tuning *DBTuning) (*gorocksdb.DB, []*gorocksdb.ColumnFamilyHandle, error) {
+	cts, err := tuning.columnTunings(cfNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfOptions := make([]*gorocksdb.Options, len(cfNames))
+	for i := range cfNames {
+		cfOptions[i] = createAndSetDBOptions(tuning, &cts[i], c, openFiles)
+	}
+	// the options of the default column are used for the whole db
+	db, cfh, err := gorocksdb.OpenDbColumnFamilies(cfOptions[cfDefault], path, cfNames, cfOptions)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -96,9 +104,14 @@ func openDB(path string, c *gorocksdb.Cache, openFiles int) (*gorocksdb.DB, []*g
 }
 
 // NewRocksDB opens an internal handle to RocksDB environment.  Close
-// needs to be called to release it.
-func NewRocksDB(path string, cacheSize, maxOpenFiles int, parser bchain.BlockChainParser, metrics *common.Metrics) (d *RocksDB, err error) {
-	glog.Infof("rocksdb: opening %s, required data version %v, cache size %v, max open files %v", path, dbVersion, cacheSize, maxOpenFiles)
+// needs to be called to release it. If tuning is nil, the default tuning profile is used.
+func NewRocksDB(path string, cacheSize, maxOpenFiles int, parser bchain.BlockChainParser, metrics *common.Metrics, tuning *DBTuning) (d *RocksDB, err error) {
+	if tuning == nil {
+		if tuning, err = LoadDBTuning(DefaultDBTuning); err != nil {
+			return nil, err
+		}
+	}
+	glog.Infof("rocksdb: opening %s, required data version %v, cache size %v, max open files %v, tuning profile %v", path, dbVersion, cacheSize, maxOpenFiles, tuning.Name)
 
 	chainType := parser.GetChainType()
 	if chainType == bchain.ChainBitcoinType {
@@ -110,13 +123,13 @@ func NewRocksDB(path string, cacheSize, maxOpenFiles int, parser bchain.BlockCha
 	}
 
 	c := gorocksdb.NewLRUCache(cacheSize)
-	db, cfh, err := openDB(path, c, maxOpenFiles)
+	db, cfh, err := openDB(path, c, maxOpenFiles, tuning)
 	if err != nil {
 		return nil, err
 	}
 	wo := gorocksdb.NewDefaultWriteOptions()
 	ro := gorocksdb.NewDefaultReadOptions()
-	return &RocksDB{path, db, wo, ro, cfh, parser, nil, metrics, c, maxOpenFiles, connectBlockStats{}}, nil
+	return &RocksDB{path, db, wo, ro, cfh, parser, nil, metrics, c, maxOpenFiles, connectBlockStats{}, false, nil, tuning, false}, nil
 }
 
 func (d *RocksDB) closeDB() error {
@@ -154,7 +167,7 @@ func (d *RocksDB) Reopen() error {
 		return err
 	}
 	d.db = nil
-	db, cfh, err := openDB(d.path, d.cache, d.maxOpenFiles)
+	db, cfh, err := openDB(d.path, d.cache, d.maxOpenFiles, d.tuning)
 	if err != nil {
 		return err
 	}
@@ -266,6 +279,55 @@ func (d *RocksDB) GetAddrDescTransactions(addrDesc bchain.AddressDescriptor, low
 	return nil
 }
 
+// GetAddrDescTransactionsReverse finds all input/output transactions for address descriptor in blocks higher-lower,
+// the newest block first. Transactions are passed to callback function in the reverse order together with the block height.
+func (d *RocksDB) GetAddrDescTransactionsReverse(addrDesc bchain.AddressDescriptor, lower uint32, higher uint32, fn func(txid string, height uint32, vout int32, isOutput bool) error) (err error) {
+	kstart := packAddressKey(addrDesc, lower)
+	kstop := packAddressKey(addrDesc, higher)
+
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddresses])
+	defer it.Close()
+
+	for it.SeekForPrev(kstop); it.Valid(); it.Prev() {
+		key := it.Key().Data()
+		if bytes.Compare(key, kstart) < 0 {
+			break
+		}
+		// skip keys of other address descriptors starting with addrDesc
+		if len(key) != len(kstart) {
+			continue
+		}
+		height := unpackUint(key[len(addrDesc):])
+		outpoints, err := d.unpackOutpoints(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		for i := len(outpoints) - 1; i >= 0; i-- {
+			o := &outpoints[i]
+			var vout int32
+			var isOutput bool
+			if o.index < 0 {
+				vout = int32(^o.index)
+				isOutput = false
+			} else {
+				vout = int32(o.index)
+				isOutput = true
+			}
+			tx, err := d.chainParser.UnpackTxid(o.btxID)
+			if err != nil {
+				return err
+			}
+			if err := fn(tx, height, vout, isOutput); err != nil {
+				if _, ok := err.(*StopIteration); ok {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 const (
 	opInsert = 0
 	opDelete = 1
@@ -303,16 +365,36 @@ func (d *RocksDB) ConnectBlock(block *bchain.Block) error {
 		}
 	} else if chainType == bchain.ChainEthereumType {
 		addressContracts := make(map[string]*AddrContracts)
-		blockTxs, err := d.processAddressesEthereumType(block, addresses, addressContracts)
+		contractHolders := make(map[string]bool)
+		tokenBalances := make(map[string]*big.Int)
+		blockTxs, transfers, err := d.processAddressesEthereumType(block, addresses, addressContracts, contractHolders, tokenBalances)
 		if err != nil {
 			return err
 		}
 		if err := d.storeAddressContracts(wb, addressContracts); err != nil {
 			return err
 		}
+		if err := d.storeContractHolders(wb, contractHolders); err != nil {
+			return err
+		}
+		if err := d.storeTokenBalances(wb, tokenBalances); err != nil {
+			return err
+		}
 		if err := d.storeAndCleanupBlockTxsEthereumType(wb, block, blockTxs); err != nil {
 			return err
 		}
+		if err := d.storeAndCleanupBlockTokenTransfers(wb, block, transfers); err != nil {
+			return err
+		}
+		if d.logIndex {
+			logs := make(map[string][]logOutpoint)
+			if err := d.processLogsEthereumType(block, logs); err != nil {
+				return err
+			}
+			if err := d.storeAndCleanupLogs(wb, block, logs); err != nil {
+				return err
+			}
+		}
 	} else {
 		return errors.New("Unknown chain type")
 	}
@@ -320,7 +402,20 @@ func (d *RocksDB) ConnectBlock(block *bchain.Block) error {
 		return err
 	}
 
-	return d.db.Write(d.wo, wb)
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	if chainType == bchain.ChainBitcoinType && d.is != nil && d.is.PruneDepth > 0 {
+		// the connected block is not affected by pruning, the pruning is retried with the next block
+		// the failure is not returned as the block is already stored, it is reported by the resync errors metric
+		if err := d.pruneIndex(block.Height); err != nil {
+			glog.Error("rocksdb: prune: ", err)
+			if d.metrics != nil {
+				d.metrics.IndexResyncErrors.With(common.Labels{"error": "prune: " + err.Error()}).Inc()
+			}
+		}
+	}
+	return nil
 }
 
 // Addresses index
@@ -586,18 +681,26 @@ func (d *RocksDB) storeBalances(wb *gorocksdb.WriteBatch, abm map[string]*AddrBa
 			ll = packBigint(&ab.BalanceSat, buf[l:])
 			l += ll
 			wb.PutCF(d.cfh[cfAddressBalance], bchain.AddressDescriptor(addrDesc), buf[:l])
+			if d.scriptHashIndex {
+				d.storeScriptHash(wb, bchain.AddressDescriptor(addrDesc))
+			}
 		}
 	}
 	return nil
 }
 
 func (d *RocksDB) cleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchain.Block) error {
+	return d.cleanupBlockColumn(cfBlockTxs, block)
+}
+
+// cleanupBlockColumn removes data older than KeepBlockAddresses blocks from a column keyed by block height
+func (d *RocksDB) cleanupBlockColumn(col int, block *bchain.Block) error {
 	keep := d.chainParser.KeepBlockAddresses()
 	// cleanup old block address
 	if block.Height > uint32(keep) {
 		for rh := block.Height - uint32(keep); rh > 0; rh-- {
 			key := packUint(rh)
-			val, err := d.db.GetCF(d.ro, d.cfh[cfBlockTxs], key)
+			val, err := d.db.GetCF(d.ro, d.cfh[col], key)
 			if err != nil {
 				return err
 			}
@@ -606,17 +709,35 @@ func (d *RocksDB) cleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchain.Block)
 				break
 			}
 			val.Free()
-			d.db.DeleteCF(d.wo, d.cfh[cfBlockTxs], key)
+			d.db.DeleteCF(d.wo, d.cfh[col], key)
 		}
 	}
 	return nil
 }
 
 func (d *RocksDB) storeAndCleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchain.Block) error {
+	bt, err := d.blockTxsFromBlock(block)
+	if err != nil {
+		return err
+	}
 	pl := d.chainParser.PackedTxidLen()
 	buf := make([]byte, 0, pl*len(block.Txs))
 	varBuf := make([]byte, vlq.MaxLen64)
-	zeroTx := make([]byte, pl)
+	for i := range bt {
+		buf = append(buf, bt[i].btxID...)
+		l := packVaruint(uint(len(bt[i].inputs)), varBuf)
+		buf = append(buf, varBuf[:l]...)
+		buf = append(buf, d.packOutpoints(bt[i].inputs)...)
+	}
+	key := packUint(block.Height)
+	wb.PutCF(d.cfh[cfBlockTxs], key, buf)
+	return d.cleanupBlockTxs(wb, block)
+}
+
+// blockTxsFromBlock returns the txids and inputs of the block transactions in the form stored in the blockTxs column
+func (d *RocksDB) blockTxsFromBlock(block *bchain.Block) ([]blockTxs, error) {
+	zeroTx := make([]byte, d.chainParser.PackedTxidLen())
+	bt := make([]blockTxs, len(block.Txs))
 	for i := range block.Txs {
 		tx := &block.Txs[i]
 		o := make([]outpoint, len(tx.Vin))
@@ -628,7 +749,7 @@ func (d *RocksDB) storeAndCleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchai
 				if err == bchain.ErrTxidMissing {
 					btxID = zeroTx
 				} else {
-					return err
+					return nil, err
 				}
 			}
 			o[v].btxID = btxID
@@ -636,16 +757,14 @@ func (d *RocksDB) storeAndCleanupBlockTxs(wb *gorocksdb.WriteBatch, block *bchai
 		}
 		btxID, err := d.chainParser.PackTxid(tx.Txid)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		bt[i] = blockTxs{
+			btxID:  btxID,
+			inputs: o,
 		}
-		buf = append(buf, btxID...)
-		l := packVaruint(uint(len(o)), varBuf)
-		buf = append(buf, varBuf[:l]...)
-		buf = append(buf, d.packOutpoints(o)...)
 	}
-	key := packUint(block.Height)
-	wb.PutCF(d.cfh[cfBlockTxs], key, buf)
-	return d.cleanupBlockTxs(wb, block)
+	return bt, nil
 }
 
 func (d *RocksDB) getBlockTxs(height uint32) ([]blockTxs, error) {
@@ -1069,9 +1188,41 @@ func (d *RocksDB) disconnectTxAddresses(wb *gorocksdb.WriteBatch, height uint32,
 	return nil
 }
 
+// BlockGetter returns block from the backend by hash or height
+type BlockGetter func(hash string, height uint32) (*bchain.Block, error)
+
+// getBlockTxsFromBackend gets the block indexed at the height from the backend and returns its transactions
+// in the form of the blockTxs column, the rest of the data necessary for disconnect is in the txAddresses column
+func (d *RocksDB) getBlockTxsFromBackend(height uint32, getBlock BlockGetter) ([]blockTxs, error) {
+	bi, err := d.GetBlockInfo(height)
+	if err != nil {
+		return nil, err
+	}
+	if bi == nil {
+		return nil, errors.Errorf("Block %v is not in index", height)
+	}
+	// get the block by the indexed hash, in case of reorg the backend has a different block at the height
+	block, err := getBlock(bi.Hash, height)
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBlock %v %v", height, bi.Hash)
+	}
+	if block.Hash != bi.Hash {
+		return nil, errors.Errorf("Backend returned block %v instead of indexed block %v at height %v", block.Hash, bi.Hash, height)
+	}
+	return d.blockTxsFromBlock(block)
+}
+
 // DisconnectBlockRangeBitcoinType removes all data belonging to blocks in range lower-higher
-// it is able to disconnect only blocks for which there are data in the blockTxs column
-func (d *RocksDB) DisconnectBlockRangeBitcoinType(lower uint32, higher uint32) error {
+// the blocks are disconnected using the data in the blockTxs column, which is kept only for KeepBlockAddresses blocks
+// the older blocks are retrieved using getBlock from the backend, if getBlock is nil, they cannot be disconnected
+func (d *RocksDB) DisconnectBlockRangeBitcoinType(lower uint32, higher uint32, getBlock BlockGetter) error {
+	if d.is != nil {
+		if ph := d.is.GetPrunedHeight(); ph > 0 && lower <= ph {
+			return errors.Errorf("Cannot disconnect blocks with height %v and lower. The index is pruned up to height %v.", lower, ph)
+		}
+	}
+	// the spends of the disconnected blocks must be removed from the prune window
+	d.pruneWindow = nil
 	blocks := make([][]blockTxs, higher-lower+1)
 	for height := lower; height <= higher; height++ {
 		blockTxs, err := d.getBlockTxs(height)
@@ -1079,7 +1230,13 @@ func (d *RocksDB) DisconnectBlockRangeBitcoinType(lower uint32, higher uint32) e
 			return err
 		}
 		if len(blockTxs) == 0 {
-			return errors.Errorf("Cannot disconnect blocks with height %v and lower. It is necessary to rebuild index.", height)
+			if getBlock == nil {
+				return errors.Errorf("Cannot disconnect blocks with height %v and lower. It is necessary to rebuild index.", height)
+			}
+			if blockTxs, err = d.getBlockTxsFromBackend(height, getBlock); err != nil {
+				return errors.Annotatef(err, "Cannot disconnect block %v", height)
+			}
+			glog.Info("rocksdb: block ", height, " is older than kept blockTxs, using block from backend")
 		}
 		blocks[height-lower] = blockTxs
 	}
@@ -1240,6 +1397,9 @@ func (d *RocksDB) LoadInternalState(rpcCoin string) (*common.InternalState, erro
 		} else if is.Coin != rpcCoin {
 			return nil, errors.Errorf("Coins do not match. DB coin %v, RPC coin %v", is.Coin, rpcCoin)
 		}
+		if is.Migration != nil {
+			return nil, errors.Errorf("Migration of DB from version %v to %v was not finished. Run blockbook with -migrate to finish it.", is.Migration.FromVersion, is.Migration.ToVersion)
+		}
 	}
 	// make sure that column stats match the columns
 	sc := is.DbColumns
@@ -1251,6 +1411,9 @@ func (d *RocksDB) LoadInternalState(rpcCoin string) (*common.InternalState, erro
 			if sc[j].Name == nc[i].Name {
 				// check the version of the column, if it does not match, the db is not compatible
 				if sc[j].Version != dbVersion {
+					if sc[j].Version < dbVersion && findMigration(sc[j].Version) != nil {
+						return nil, errors.Errorf("DB version %v of column '%v' does not match the required version %v. Run blockbook with -migrate to upgrade the DB.", sc[j].Version, sc[j].Name, dbVersion)
+					}
 					return nil, errors.Errorf("DB version %v of column '%v' does not match the required version %v. DB is not compatible.", sc[j].Version, sc[j].Name, dbVersion)
 				}
 				nc[i].Rows = sc[j].Rows
@@ -1288,6 +1451,22 @@ func (d *RocksDB) SetInconsistentState(inconsistent bool) error {
 // SetInternalState sets the InternalState to be used by db to collect internal state
 func (d *RocksDB) SetInternalState(is *common.InternalState) {
 	d.is = is
+	if is != nil {
+		is.DbTuning = d.tuning.Name
+	}
+}
+
+// GetDBTuningInfo returns the tuning profile of the db and the tuning of its columns
+func (d *RocksDB) GetDBTuningInfo() (*DBTuningInfo, error) {
+	cts, err := d.tuning.columnTunings(cfNames)
+	if err != nil {
+		return nil, err
+	}
+	ti := &DBTuningInfo{Profile: d.tuning, Columns: make(map[string]ColumnTuning, len(cfNames))}
+	for i := range cfNames {
+		ti.Columns[cfNames[i]] = cts[i]
+	}
+	return ti, nil
 }
 
 // StoreInternalState stores the internal state to db
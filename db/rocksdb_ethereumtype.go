@@ -5,6 +5,9 @@ import (
 	"blockbook/bchain/coins/eth"
 	"bytes"
 	"encoding/hex"
+	"math/big"
+	"sort"
+	"strings"
 
 	"github.com/bsm/go-vlq"
 	"github.com/golang/glog"
@@ -53,7 +56,10 @@ func (d *RocksDB) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*Addr
 		return nil, err
 	}
 	defer val.Free()
-	buf := val.Data()
+	return unpackAddrContracts(val.Data(), addrDesc)
+}
+
+func unpackAddrContracts(buf []byte, addrDesc bchain.AddressDescriptor) (*AddrContracts, error) {
 	if len(buf) == 0 {
 		return nil, nil
 	}
@@ -75,6 +81,125 @@ func (d *RocksDB) GetAddrDescContracts(addrDesc bchain.AddressDescriptor) (*Addr
 	return &AddrContracts{EthTxs: et, Contracts: c}, nil
 }
 
+func packContractHolderKey(contract, addrDesc bchain.AddressDescriptor) []byte {
+	buf := make([]byte, 0, len(contract)+len(addrDesc))
+	buf = append(buf, contract...)
+	return append(buf, addrDesc...)
+}
+
+// storeContractHolders adds (true) or removes (false) holders of contracts, the map key is contract+addrDesc
+func (d *RocksDB) storeContractHolders(wb *gorocksdb.WriteBatch, chm map[string]bool) error {
+	for key, add := range chm {
+		if add {
+			wb.PutCF(d.cfh[cfContractHolders], []byte(key), []byte{})
+		} else {
+			wb.DeleteCF(d.cfh[cfContractHolders], []byte(key))
+		}
+	}
+	return nil
+}
+
+// GetContractHolders calls fn for each address which has or had a transfer of the given contract
+// the addresses are returned in the order of the address descriptors
+func (d *RocksDB) GetContractHolders(contract bchain.AddressDescriptor, fn func(addrDesc bchain.AddressDescriptor) error) error {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfContractHolders])
+	defer it.Close()
+	for it.Seek(contract); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, contract) {
+			break
+		}
+		if len(key) != len(contract)+eth.EthereumTypeAddressDescriptorLen {
+			return errors.New("Invalid data stored in cfContractHolders for contract " + contract.String())
+		}
+		addrDesc := append(bchain.AddressDescriptor(nil), key[len(contract):]...)
+		if err := fn(addrDesc); err != nil {
+			if _, ok := err.(*StopIteration); ok {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func packTokenBalanceKey(addrDesc, contract bchain.AddressDescriptor) []byte {
+	buf := make([]byte, 0, len(addrDesc)+len(contract))
+	buf = append(buf, addrDesc...)
+	return append(buf, contract...)
+}
+
+func isZeroAddress(addrDesc bchain.AddressDescriptor) bool {
+	for _, b := range addrDesc {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// storeTokenBalances stores token balances, the map key is addrDesc+contract
+// nil balance means that the balance is not tracked by the index and it is removed from db
+func (d *RocksDB) storeTokenBalances(wb *gorocksdb.WriteBatch, tbm map[string]*big.Int) error {
+	buf := make([]byte, maxPackedBigintBytes)
+	for key, b := range tbm {
+		if b == nil {
+			wb.DeleteCF(d.cfh[cfAddressTokenBalances], []byte(key))
+		} else {
+			l := packBigint(b, buf)
+			wb.PutCF(d.cfh[cfAddressTokenBalances], []byte(key), buf[:l])
+		}
+	}
+	return nil
+}
+
+// GetAddrDescTokenBalance returns balance of the contract token held by addrDesc
+// nil is returned if the balance is not tracked by the index
+func (d *RocksDB) GetAddrDescTokenBalance(addrDesc, contract bchain.AddressDescriptor) (*big.Int, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfAddressTokenBalances], packTokenBalanceKey(addrDesc, contract))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	b, _ := unpackBigint(buf)
+	return &b, nil
+}
+
+// addToTokenBalance adds value (negative for the sender) to the balance of the contract token held by addrDesc
+func (d *RocksDB) addToTokenBalance(addrDesc, contract bchain.AddressDescriptor, value *big.Int, tokenBalances map[string]*big.Int) error {
+	var err error
+	key := string(packTokenBalanceKey(addrDesc, contract))
+	b, found := tokenBalances[key]
+	if !found {
+		b, err = d.GetAddrDescTokenBalance(addrDesc, contract)
+		if err != nil {
+			return err
+		}
+		tokenBalances[key] = b
+	}
+	// balance not tracked by the index, it can be set by the reconciliation
+	if b == nil {
+		return nil
+	}
+	var n big.Int
+	n.Add(b, value)
+	if n.Sign() < 0 {
+		// non standard tokens can change balances without Transfer events, the balance is not tracked anymore
+		// and is taken from the backend until the reconciliation sets it again
+		if glog.V(1) {
+			glog.Infof("rocksdb: address %v contract %v token balance would be negative %v, not tracked anymore", addrDesc, contract, &n)
+		}
+		tokenBalances[key] = nil
+		return nil
+	}
+	b.Set(&n)
+	return nil
+}
+
 func findContractInAddressContracts(contract bchain.AddressDescriptor, contracts []AddrContract) (int, bool) {
 	for i := range contracts {
 		if bytes.Equal(contract, contracts[i].Contract) {
@@ -84,7 +209,7 @@ func findContractInAddressContracts(contract bchain.AddressDescriptor, contracts
 	return 0, false
 }
 
-func (d *RocksDB) addToAddressesAndContractsEthereumType(addrDesc bchain.AddressDescriptor, btxID []byte, index int32, contract bchain.AddressDescriptor, addresses map[string][]outpoint, addressContracts map[string]*AddrContracts) error {
+func (d *RocksDB) addToAddressesAndContractsEthereumType(addrDesc bchain.AddressDescriptor, btxID []byte, index int32, contract bchain.AddressDescriptor, value *big.Int, addresses map[string][]outpoint, addressContracts map[string]*AddrContracts, contractHolders map[string]bool, tokenBalances map[string]*big.Int) error {
 	var err error
 	strAddrDesc := string(addrDesc)
 	ac, e := addressContracts[strAddrDesc]
@@ -109,6 +234,18 @@ func (d *RocksDB) addToAddressesAndContractsEthereumType(addrDesc bchain.Address
 		if !found {
 			i = len(ac.Contracts)
 			ac.Contracts = append(ac.Contracts, AddrContract{Contract: contract})
+			// the address is a new holder of the contract
+			contractHolders[string(packContractHolderKey(contract, addrDesc))] = true
+			// the balance of a new holder is known, it is tracked from the first transfer
+			// the zero address is used as sender of minted tokens, its balance is not tracked
+			if !isZeroAddress(addrDesc) {
+				tokenBalances[string(packTokenBalanceKey(addrDesc, contract))] = new(big.Int)
+			}
+		}
+		if value != nil {
+			if err = d.addToTokenBalance(addrDesc, contract, value, tokenBalances); err != nil {
+				return err
+			}
 		}
 		// index 0 is for ETH transfers, contract indexes start with 1
 		if index < 0 {
@@ -135,12 +272,18 @@ type ethBlockTx struct {
 	contracts []ethBlockTxContract
 }
 
-func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses map[string][]outpoint, addressContracts map[string]*AddrContracts) ([]ethBlockTx, error) {
+type ethBlockTokenTransfer struct {
+	from, to, contract bchain.AddressDescriptor
+	value              big.Int
+}
+
+func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses map[string][]outpoint, addressContracts map[string]*AddrContracts, contractHolders map[string]bool, tokenBalances map[string]*big.Int) ([]ethBlockTx, []ethBlockTokenTransfer, error) {
 	blockTxs := make([]ethBlockTx, len(block.Txs))
+	transfers := make([]ethBlockTokenTransfer, 0)
 	for txi, tx := range block.Txs {
 		btxID, err := d.chainParser.PackTxid(tx.Txid)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		blockTx := &blockTxs[txi]
 		blockTx.btxID = btxID
@@ -154,8 +297,8 @@ func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses ma
 				}
 				continue
 			}
-			if err = d.addToAddressesAndContractsEthereumType(addrDesc, btxID, 0, nil, addresses, addressContracts); err != nil {
-				return nil, err
+			if err = d.addToAddressesAndContractsEthereumType(addrDesc, btxID, 0, nil, nil, addresses, addressContracts, contractHolders, tokenBalances); err != nil {
+				return nil, nil, err
 			}
 			blockTx.to = addrDesc
 		}
@@ -168,8 +311,8 @@ func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses ma
 				}
 				continue
 			}
-			if err = d.addToAddressesAndContractsEthereumType(addrDesc, btxID, ^int32(0), nil, addresses, addressContracts); err != nil {
-				return nil, err
+			if err = d.addToAddressesAndContractsEthereumType(addrDesc, btxID, ^int32(0), nil, nil, addresses, addressContracts, contractHolders, tokenBalances); err != nil {
+				return nil, nil, err
 			}
 			blockTx.from = addrDesc
 		}
@@ -192,21 +335,29 @@ func (d *RocksDB) processAddressesEthereumType(block *bchain.Block, addresses ma
 				glog.Warningf("rocksdb: GetErc20FromTx %v - height %d, tx %v, transfer %v", err, block.Height, tx.Txid, t)
 				continue
 			}
-			if err = d.addToAddressesAndContractsEthereumType(from, btxID, ^int32(i), contract, addresses, addressContracts); err != nil {
-				return nil, err
+			var sent big.Int
+			sent.Neg(&t.Tokens)
+			if err = d.addToAddressesAndContractsEthereumType(from, btxID, ^int32(i), contract, &sent, addresses, addressContracts, contractHolders, tokenBalances); err != nil {
+				return nil, nil, err
 			}
 			bc := &blockTx.contracts[i*2]
 			bc.addr = from
 			bc.contract = contract
-			if err = d.addToAddressesAndContractsEthereumType(to, btxID, int32(i), contract, addresses, addressContracts); err != nil {
-				return nil, err
+			if err = d.addToAddressesAndContractsEthereumType(to, btxID, int32(i), contract, &t.Tokens, addresses, addressContracts, contractHolders, tokenBalances); err != nil {
+				return nil, nil, err
 			}
 			bc = &blockTx.contracts[i*2+1]
 			bc.addr = to
 			bc.contract = contract
+			transfers = append(transfers, ethBlockTokenTransfer{
+				from:     from,
+				to:       to,
+				contract: contract,
+				value:    t.Tokens,
+			})
 		}
 	}
-	return blockTxs, nil
+	return blockTxs, transfers, nil
 }
 
 func (d *RocksDB) storeAndCleanupBlockTxsEthereumType(wb *gorocksdb.WriteBatch, block *bchain.Block, blockTxs []ethBlockTx) error {
@@ -306,8 +457,86 @@ func (d *RocksDB) getBlockTxsEthereumType(height uint32) ([]ethBlockTx, error) {
 	return bt, nil
 }
 
-func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, height uint32, blockTxs []ethBlockTx, contracts map[string]*AddrContracts) error {
+// storeAndCleanupBlockTokenTransfers stores token transfers of the block, used to revert token balances in case of rollback
+// the data are kept for the same number of blocks as blockTxs
+func (d *RocksDB) storeAndCleanupBlockTokenTransfers(wb *gorocksdb.WriteBatch, block *bchain.Block, transfers []ethBlockTokenTransfer) error {
+	buf := make([]byte, 0, (3*eth.EthereumTypeAddressDescriptorLen+8)*len(transfers))
+	bigBuf := make([]byte, maxPackedBigintBytes)
+	for i := range transfers {
+		t := &transfers[i]
+		buf = append(buf, t.from...)
+		buf = append(buf, t.to...)
+		buf = append(buf, t.contract...)
+		l := packBigint(&t.value, bigBuf)
+		buf = append(buf, bigBuf[:l]...)
+	}
+	wb.PutCF(d.cfh[cfBlockTokenTransfers], packUint(block.Height), buf)
+	return d.cleanupBlockColumn(cfBlockTokenTransfers, block)
+}
+
+func (d *RocksDB) getBlockTokenTransfers(height uint32) ([]ethBlockTokenTransfer, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfBlockTokenTransfers], packUint(height))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	// nil data means the key was not found in DB
+	if buf == nil {
+		return nil, nil
+	}
+	const al = eth.EthereumTypeAddressDescriptorLen
+	transfers := make([]ethBlockTokenTransfer, 0)
+	for i := 0; i < len(buf); {
+		if len(buf)-i < 3*al+1 {
+			glog.Error("rocksdb: Inconsistent data in blockTokenTransfers ", hex.EncodeToString(buf))
+			return nil, errors.New("Inconsistent data in blockTokenTransfers")
+		}
+		t := ethBlockTokenTransfer{
+			from:     append(bchain.AddressDescriptor(nil), buf[i:i+al]...),
+			to:       append(bchain.AddressDescriptor(nil), buf[i+al:i+2*al]...),
+			contract: append(bchain.AddressDescriptor(nil), buf[i+2*al:i+3*al]...),
+		}
+		var l int
+		t.value, l = unpackBigint(buf[i+3*al:])
+		i += 3*al + l
+		transfers = append(transfers, t)
+	}
+	return transfers, nil
+}
+
+func (d *RocksDB) disconnectBlockTokenTransfers(transfers []ethBlockTokenTransfer, tokenBalances map[string]*big.Int) error {
+	for i := len(transfers) - 1; i >= 0; i-- {
+		t := &transfers[i]
+		if err := d.addToTokenBalance(t.from, t.contract, &t.value, tokenBalances); err != nil {
+			return err
+		}
+		var received big.Int
+		received.Neg(&t.value)
+		if err := d.addToTokenBalance(t.to, t.contract, &received, tokenBalances); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, height uint32, blockTxs []ethBlockTx, transfers []ethBlockTokenTransfer, contracts map[string]*AddrContracts, contractHolders map[string]bool, tokenBalances map[string]*big.Int) error {
 	glog.Info("Disconnecting block ", height, " containing ", len(blockTxs), " transactions")
+	if transfers != nil {
+		if err := d.disconnectBlockTokenTransfers(transfers, tokenBalances); err != nil {
+			return err
+		}
+	} else {
+		// without the stored transfers the token balances cannot be reverted, stop tracking them
+		glog.Warning("rocksdb: token transfers of block ", height, " not found, affected token balances must be reconciled")
+		for i := range blockTxs {
+			for _, c := range blockTxs[i].contracts {
+				if len(c.addr) > 0 && len(c.contract) > 0 {
+					tokenBalances[string(packTokenBalanceKey(c.addr, c.contract))] = nil
+				}
+			}
+		}
+	}
 	addresses := make(map[string]struct{})
 	disconnectAddress := func(btxID []byte, addrDesc, contract bchain.AddressDescriptor) error {
 		var err error
@@ -339,6 +568,8 @@ func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, heigh
 						c.Contracts[i].Txs--
 						if c.Contracts[i].Txs == 0 {
 							c.Contracts = append(c.Contracts[:i], c.Contracts[i+1:]...)
+							contractHolders[string(packContractHolderKey(contract, addrDesc))] = false
+							tokenBalances[string(packTokenBalanceKey(addrDesc, contract))] = nil
 						}
 					} else {
 						glog.Warning("AddressContracts ", addrDesc, ", contract ", i, " Txs would be negative, tx ", hex.EncodeToString(btxID))
@@ -378,6 +609,7 @@ func (d *RocksDB) disconnectBlockTxsEthereumType(wb *gorocksdb.WriteBatch, heigh
 // it is able to disconnect only blocks for which there are data in the blockTxs column
 func (d *RocksDB) DisconnectBlockRangeEthereumType(lower uint32, higher uint32) error {
 	blocks := make([][]ethBlockTx, higher-lower+1)
+	blockTransfers := make([][]ethBlockTokenTransfer, higher-lower+1)
 	for height := lower; height <= higher; height++ {
 		blockTxs, err := d.getBlockTxsEthereumType(height)
 		if err != nil {
@@ -388,22 +620,348 @@ func (d *RocksDB) DisconnectBlockRangeEthereumType(lower uint32, higher uint32)
 			return errors.Errorf("Cannot disconnect blocks with height %v and lower. It is necessary to rebuild index.", height)
 		}
 		blocks[height-lower] = blockTxs
+		blockTransfers[height-lower], err = d.getBlockTokenTransfers(height)
+		if err != nil {
+			return err
+		}
 	}
 	wb := gorocksdb.NewWriteBatch()
 	defer wb.Destroy()
 	contracts := make(map[string]*AddrContracts)
+	contractHolders := make(map[string]bool)
+	tokenBalances := make(map[string]*big.Int)
 	for height := higher; height >= lower; height-- {
-		if err := d.disconnectBlockTxsEthereumType(wb, height, blocks[height-lower], contracts); err != nil {
+		if err := d.disconnectBlockTxsEthereumType(wb, height, blocks[height-lower], blockTransfers[height-lower], contracts, contractHolders, tokenBalances); err != nil {
+			return err
+		}
+		if err := d.disconnectBlockLogs(wb, height); err != nil {
 			return err
 		}
 		key := packUint(height)
 		wb.DeleteCF(d.cfh[cfBlockTxs], key)
+		wb.DeleteCF(d.cfh[cfBlockTokenTransfers], key)
 		wb.DeleteCF(d.cfh[cfHeight], key)
 	}
 	d.storeAddressContracts(wb, contracts)
+	d.storeContractHolders(wb, contractHolders)
+	d.storeTokenBalances(wb, tokenBalances)
 	err := d.db.Write(d.wo, wb)
 	if err == nil {
 		glog.Infof("rocksdb: blocks %d-%d disconnected", lower, higher)
 	}
 	return err
 }
+
+// length of the key prefix in the logs column - address and topic0
+const logKeyPrefixLen = eth.EthereumTypeAddressDescriptorLen + 32
+
+// SetLogIndex enables or disables indexing of receipt logs by address and topic0, must be called after SetInternalState
+// the index contains only the logs of blocks connected while it is enabled, therefore the height of the first indexed block
+// is stored in the internal state when the index is enabled and it is cleared when the index is disabled
+func (d *RocksDB) SetLogIndex(enabled bool) error {
+	if d.is == nil {
+		return errors.New("Internal state not set")
+	}
+	if !enabled {
+		d.logIndex = false
+		if d.is.LogIndex {
+			d.is.SetLogIndex(false, 0)
+			return d.storeState(d.is)
+		}
+		return nil
+	}
+	if d.chainParser.GetChainType() != bchain.ChainEthereumType {
+		return errors.New("Log index is supported only for EthereumType coins")
+	}
+	d.logIndex = true
+	if d.is.LogIndex {
+		return nil
+	}
+	height, hash, err := d.GetBestBlock()
+	if err != nil {
+		return err
+	}
+	var from uint32
+	if hash != "" {
+		from = height + 1
+		glog.Info("rocksdb: log index enabled, logs are indexed from block ", from)
+	}
+	d.is.SetLogIndex(true, from)
+	return d.storeState(d.is)
+}
+
+// LogIndexEnabled returns true if receipt logs are indexed
+func (d *RocksDB) LogIndexEnabled() bool {
+	return d.logIndex
+}
+
+func packLogKey(address, topic0 []byte, height uint32) []byte {
+	buf := make([]byte, 0, len(address)+len(topic0)+packedHeightBytes)
+	buf = append(buf, address...)
+	buf = append(buf, topic0...)
+	return append(buf, packUint(height)...)
+}
+
+// logOutpoint is a receipt log in the logs column, position is the index of the transaction in the block
+// and index is the index of the log in the transaction receipt
+type logOutpoint struct {
+	btxID    []byte
+	position uint32
+	index    int32
+}
+
+func (d *RocksDB) packLogOutpoints(logs []logOutpoint) []byte {
+	buf := make([]byte, 0, 40)
+	bvout := make([]byte, vlq.MaxLen32)
+	for _, o := range logs {
+		buf = append(buf, o.btxID...)
+		l := packVaruint(uint(o.position), bvout)
+		buf = append(buf, bvout[:l]...)
+		l = packVarint32(o.index, bvout)
+		buf = append(buf, bvout[:l]...)
+	}
+	return buf
+}
+
+func (d *RocksDB) unpackLogOutpoints(buf []byte) ([]logOutpoint, error) {
+	txidUnpackedLen := d.chainParser.PackedTxidLen()
+	logs := make([]logOutpoint, 0, 4)
+	for i := 0; i < len(buf); {
+		if i+txidUnpackedLen >= len(buf) {
+			return nil, errors.New("Inconsistent data in logs")
+		}
+		btxID := append([]byte(nil), buf[i:i+txidUnpackedLen]...)
+		i += txidUnpackedLen
+		position, l := unpackVaruint(buf[i:])
+		i += l
+		index, l := unpackVarint32(buf[i:])
+		i += l
+		logs = append(logs, logOutpoint{
+			btxID:    btxID,
+			position: uint32(position),
+			index:    index,
+		})
+	}
+	return logs, nil
+}
+
+// processLogsEthereumType collects receipt logs of the block, the map key is address+topic0
+func (d *RocksDB) processLogsEthereumType(block *bchain.Block, logs map[string][]logOutpoint) error {
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
+		txLogs, err := d.chainParser.EthereumTypeGetLogsFromTx(tx)
+		if err != nil {
+			glog.Warningf("rocksdb: GetLogsFromTx %v - height %d, tx %v", err, block.Height, tx.Txid)
+			continue
+		}
+		if len(txLogs) == 0 {
+			continue
+		}
+		btxID, err := d.chainParser.PackTxid(tx.Txid)
+		if err != nil {
+			return err
+		}
+		for i, l := range txLogs {
+			// anonymous events without topics are not indexed
+			if len(l.Topics) == 0 {
+				continue
+			}
+			address, err := d.chainParser.GetAddrDescFromAddress(l.Address)
+			if err != nil {
+				glog.Warningf("rocksdb: log address %v - height %d, tx %v, log %d", err, block.Height, tx.Txid, i)
+				continue
+			}
+			topic0, err := hex.DecodeString(strings.TrimPrefix(l.Topics[0], "0x"))
+			if err != nil || len(topic0) != 32 {
+				glog.Warningf("rocksdb: log topic %v - height %d, tx %v, log %d", l.Topics[0], block.Height, tx.Txid, i)
+				continue
+			}
+			key := string(address) + string(topic0)
+			logs[key] = append(logs[key], logOutpoint{
+				btxID:    btxID,
+				position: uint32(txi),
+				index:    int32(i),
+			})
+		}
+	}
+	return nil
+}
+
+// storeLogs stores the logs of the block at height to the logs column
+func (d *RocksDB) storeLogs(wb *gorocksdb.WriteBatch, height uint32, logs map[string][]logOutpoint) {
+	for k, o := range logs {
+		wb.PutCF(d.cfh[cfLogs], packLogKey([]byte(k), nil, height), d.packLogOutpoints(o))
+	}
+}
+
+// storeAndCleanupLogs stores the logs of the block and the list of their keys in blockLogs column,
+// the blockLogs are used to remove the logs in case of rollback
+func (d *RocksDB) storeAndCleanupLogs(wb *gorocksdb.WriteBatch, block *bchain.Block, logs map[string][]logOutpoint) error {
+	d.storeLogs(wb, block.Height, logs)
+	keys := make([]string, 0, len(logs))
+	for k := range logs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := make([]byte, 0, len(keys)*logKeyPrefixLen)
+	for _, k := range keys {
+		buf = append(buf, k...)
+	}
+	wb.PutCF(d.cfh[cfBlockLogs], packUint(block.Height), buf)
+	return d.cleanupBlockColumn(cfBlockLogs, block)
+}
+
+func (d *RocksDB) disconnectBlockLogs(wb *gorocksdb.WriteBatch, height uint32) error {
+	key := packUint(height)
+	val, err := d.db.GetCF(d.ro, d.cfh[cfBlockLogs], key)
+	if err != nil {
+		return err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if buf == nil {
+		if d.logIndex {
+			glog.Warning("rocksdb: logs of block ", height, " not found, they cannot be removed from the log index")
+		}
+		return nil
+	}
+	if len(buf)%logKeyPrefixLen != 0 {
+		glog.Error("rocksdb: Inconsistent data in blockLogs ", hex.EncodeToString(buf))
+		return errors.New("Inconsistent data in blockLogs")
+	}
+	for i := 0; i < len(buf); i += logKeyPrefixLen {
+		wb.DeleteCF(d.cfh[cfLogs], packLogKey(buf[i:i+logKeyPrefixLen], nil, height))
+	}
+	wb.DeleteCF(d.cfh[cfBlockLogs], key)
+	return nil
+}
+
+// logsCursor iterates the rows of the logs column of one address and topic0 up to the height higher
+type logsCursor struct {
+	it     *gorocksdb.Iterator
+	prefix []byte
+	higher uint32
+	height uint32
+	done   bool
+}
+
+// check reads the height of the current row, the cursor is done if the row is not in the range
+func (c *logsCursor) check() error {
+	c.done = true
+	if !c.it.Valid() {
+		return nil
+	}
+	key := c.it.Key().Data()
+	if !bytes.HasPrefix(key, c.prefix) {
+		return nil
+	}
+	if len(key) != logKeyPrefixLen+packedHeightBytes {
+		return errors.New("Invalid key in logs column " + hex.EncodeToString(key))
+	}
+	c.height = unpackUint(key[logKeyPrefixLen:])
+	c.done = c.height > c.higher
+	return nil
+}
+
+// getLogTopics returns all topic0 of the logs of the address in the logs column,
+// the rows of each topic0 are skipped by seeking past the highest possible key of the topic0
+func (d *RocksDB) getLogTopics(address []byte) ([][]byte, error) {
+	var topics [][]byte
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfLogs])
+	defer it.Close()
+	for it.Seek(address); it.Valid(); {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, address) {
+			break
+		}
+		if len(key) != logKeyPrefixLen+packedHeightBytes {
+			return nil, errors.New("Invalid key in logs column " + hex.EncodeToString(key))
+		}
+		topic := append([]byte(nil), key[len(address):logKeyPrefixLen]...)
+		topics = append(topics, topic)
+		last := packLogKey(address, topic, ^uint32(0))
+		it.Seek(last)
+		if it.Valid() && bytes.Equal(it.Key().Data(), last) {
+			it.Next()
+		}
+	}
+	return topics, nil
+}
+
+// GetLogs finds receipt logs emitted by the address with the topic0 in blocks lower-higher
+// if topic0 is empty, logs with all topics are returned
+// Logs are passed to callback function ordered by height, position of the transaction in the block and index of the log.
+// The rows of the logs column are read in the key order, the iteration can be stopped by returning StopIteration.
+func (d *RocksDB) GetLogs(address, topic0 []byte, lower uint32, higher uint32, fn func(txid string, logIndex int, height uint32) error) error {
+	topics := [][]byte{topic0}
+	if len(topic0) == 0 {
+		var err error
+		if topics, err = d.getLogTopics(address); err != nil {
+			return err
+		}
+	}
+	// the logs of the topics are merged by height, each topic has its own cursor
+	cursors := make([]*logsCursor, len(topics))
+	for i, topic := range topics {
+		c := &logsCursor{
+			it:     d.db.NewIteratorCF(d.ro, d.cfh[cfLogs]),
+			prefix: append(append([]byte(nil), address...), topic...),
+			higher: higher,
+		}
+		defer c.it.Close()
+		c.it.Seek(packLogKey(address, topic, lower))
+		if err := c.check(); err != nil {
+			return err
+		}
+		cursors[i] = c
+	}
+	for {
+		var height uint32
+		found := false
+		for _, c := range cursors {
+			if !c.done && (!found || c.height < height) {
+				height = c.height
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+		var logs []logOutpoint
+		for _, c := range cursors {
+			if c.done || c.height != height {
+				continue
+			}
+			l, err := d.unpackLogOutpoints(c.it.Value().Data())
+			if err != nil {
+				return err
+			}
+			logs = append(logs, l...)
+			c.it.Next()
+			if err := c.check(); err != nil {
+				return err
+			}
+		}
+		// the logs of one row are already ordered, only the logs of several topics must be sorted
+		if len(cursors) > 1 {
+			sort.SliceStable(logs, func(i, j int) bool {
+				if logs[i].position != logs[j].position {
+					return logs[i].position < logs[j].position
+				}
+				return logs[i].index < logs[j].index
+			})
+		}
+		for _, o := range logs {
+			txid, err := d.chainParser.UnpackTxid(o.btxID)
+			if err != nil {
+				return err
+			}
+			if err := fn(txid, int(o.index), height); err != nil {
+				if _, ok := err.(*StopIteration); ok {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
@@ -3,9 +3,12 @@
 package db
 
 import (
+	"blockbook/bchain"
 	"blockbook/bchain/coins/eth"
+	"blockbook/common"
 	"blockbook/tests/dbtestdata"
 	"encoding/hex"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -55,6 +58,41 @@ func verifyAfterEthereumTypeBlock1(t *testing.T, d *RocksDB, afterDisconnect boo
 		}
 	}
 
+	if err := checkColumn(d, cfContractHolders, []keyPair{
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser), "", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
+	if err := checkColumn(d, cfAddressTokenBalances, []keyPair{
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser), "0a021e19e0c9bab2400000", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
+	var blockTokenTransfersKp []keyPair
+	if afterDisconnect {
+		blockTokenTransfersKp = []keyPair{}
+	} else {
+		blockTokenTransfersKp = []keyPair{
+			keyPair{
+				"0041eee8",
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "0a021e19e0c9bab2400000",
+				nil,
+			},
+		}
+	}
+	if err := checkColumn(d, cfBlockTokenTransfers, blockTokenTransfersKp); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
 	var blockTxsKp []keyPair
 	if afterDisconnect {
 		blockTxsKp = []keyPair{}
@@ -129,6 +167,44 @@ func verifyAfterEthereumTypeBlock2(t *testing.T, d *RocksDB) {
 		}
 	}
 
+	if err := checkColumn(d, cfContractHolders, []keyPair{
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr20, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser), "", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser), "", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
+	if err := checkColumn(d, cfAddressTokenBalances, []keyPair{
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser), "0a021e19e3d2b7c0b98ac0", nil},
+		keyPair{dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser), "086a8313d60b1f8000", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
+	if err := checkColumn(d, cfBlockTokenTransfers, []keyPair{
+		keyPair{
+			"0041eee9",
+			dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "086a8313d60b1f606b" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr55, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "070308fd0e798ac0" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract4a, d.chainParser) + "07031855667df7a8" +
+				dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr4b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddr7b, d.chainParser) + dbtestdata.AddressToPubKeyHex(dbtestdata.EthAddrContract0d, d.chainParser) + "086a8313d60b1f8000",
+			nil,
+		},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
 	if err := checkColumn(d, cfBlockTxs, []keyPair{
 		keyPair{
 			"0041eee9",
@@ -193,6 +269,23 @@ func TestRocksDB_Index_EthereumType(t *testing.T) {
 	}, nil)
 	verifyGetTransactions(t, d, "mtGXQvBowMkBpnhLckhxhbwYK44Gs9eBad", 500000, 1000000, []txidVoutOutput{}, errors.New("Address missing"))
 
+	// GetContractHolders
+	contract, err := d.chainParser.GetAddrDescFromAddress("0x" + dbtestdata.EthAddrContract0d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	holders := []string{}
+	if err = d.GetContractHolders(contract, func(addrDesc bchain.AddressDescriptor) error {
+		holders = append(holders, hex.EncodeToString(addrDesc))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wantHolders := []string{dbtestdata.EthAddr4b, dbtestdata.EthAddr55, dbtestdata.EthAddr7b}
+	if !reflect.DeepEqual(holders, wantHolders) {
+		t.Errorf("GetContractHolders() = %v, want %v", holders, wantHolders)
+	}
+
 	// GetBestBlock
 	height, hash, err := d.GetBestBlock()
 	if err != nil {
@@ -284,3 +377,270 @@ func TestRocksDB_Index_EthereumType(t *testing.T) {
 	verifyAfterEthereumTypeBlock2(t, d)
 
 }
+
+func Test_BulkConnect_EthereumType(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	if err := d.SetLogIndex(true); err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := d.InitBulkConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.is.DbState != common.DbStateInconsistent {
+		t.Fatal("DB not in DbStateInconsistent")
+	}
+
+	if err := bc.ConnectBlock(dbtestdata.GetTestEthereumTypeBlock1(d.chainParser), false); err != nil {
+		t.Fatal(err)
+	}
+	// the data are cached, nothing is written yet
+	for _, col := range []int{cfAddresses, cfAddressContracts, cfBlockTxs, cfLogs} {
+		if err := checkColumn(d, col, []keyPair{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := bc.ConnectBlock(dbtestdata.GetTestEthereumTypeBlock2(d.chainParser), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.is.DbState != common.DbStateOpen {
+		t.Fatal("DB not in DbStateOpen")
+	}
+	verifyAfterEthereumTypeBlock2(t, d)
+
+	const transferTopic = "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	const otherTopic = "0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3"
+	if err := checkColumn(d, cfLogs, []keyPair{
+		keyPair{dbtestdata.EthAddrContract4a + transferTopic + "0041eee8", dbtestdata.EthTxidB1T2 + "01" + "00", nil},
+		keyPair{dbtestdata.EthAddrContract0d + transferTopic + "0041eee9", dbtestdata.EthTxidB2T2 + "01" + "00" + dbtestdata.EthTxidB2T2 + "01" + "08", nil},
+		keyPair{dbtestdata.EthAddrContract4a + transferTopic + "0041eee9", dbtestdata.EthTxidB2T2 + "01" + "02" + dbtestdata.EthTxidB2T2 + "01" + "06", nil},
+		keyPair{dbtestdata.EthAddrContract47 + otherTopic + "0041eee9", dbtestdata.EthTxidB2T2 + "01" + "04" + dbtestdata.EthTxidB2T2 + "01" + "0a", nil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// the rollback data are stored only for the last block
+	if err := checkColumn(d, cfBlockLogs, []keyPair{
+		keyPair{
+			"0041eee9",
+			dbtestdata.EthAddrContract0d + transferTopic + dbtestdata.EthAddrContract47 + otherTopic + dbtestdata.EthAddrContract4a + transferTopic,
+			nil,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last block connected in bulk mode can be disconnected
+	if err := d.DisconnectBlockRangeEthereumType(4321001, 4321001); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterEthereumTypeBlock1(t, d, true)
+}
+
+func TestRocksDB_SetLogIndex_EthereumType(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	block1 := dbtestdata.GetTestEthereumTypeBlock1(d.chainParser)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	checkState := func(enabled bool, from uint32) {
+		is, err := d.LoadInternalState("coin-unittest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.LogIndexEnabled() != enabled || d.is.LogIndex != enabled || d.is.GetLogIndexFromHeight() != from ||
+			is.LogIndex != enabled || is.LogIndexFromHeight != from {
+			t.Errorf("log index enabled %v, state %v %v, stored state %v %v, want %v %v",
+				d.LogIndexEnabled(), d.is.LogIndex, d.is.GetLogIndexFromHeight(), is.LogIndex, is.LogIndexFromHeight, enabled, from)
+		}
+	}
+	// the logs of the blocks connected before the index was enabled are not indexed
+	if err := d.SetLogIndex(true); err != nil {
+		t.Fatal(err)
+	}
+	checkState(true, block1.Height+1)
+	if err := d.ConnectBlock(dbtestdata.GetTestEthereumTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	// the index enabled again keeps its height
+	if err := d.SetLogIndex(true); err != nil {
+		t.Fatal(err)
+	}
+	checkState(true, block1.Height+1)
+	if err := d.SetLogIndex(false); err != nil {
+		t.Fatal(err)
+	}
+	checkState(false, 0)
+}
+
+func TestRocksDB_LogIndex_EthereumType(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	if err := d.SetLogIndex(true); err != nil {
+		t.Fatal(err)
+	}
+
+	const transferTopic = "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	const otherTopic = "0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3"
+
+	block1 := dbtestdata.GetTestEthereumTypeBlock1(d.chainParser)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	logsBlock1 := []keyPair{
+		keyPair{dbtestdata.EthAddrContract4a + transferTopic + "0041eee8", dbtestdata.EthTxidB1T2 + "01" + "00", nil},
+	}
+	if err := checkColumn(d, cfLogs, logsBlock1); err != nil {
+		t.Fatal(err)
+	}
+
+	block2 := dbtestdata.GetTestEthereumTypeBlock2(d.chainParser)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkColumn(d, cfLogs, []keyPair{
+		keyPair{dbtestdata.EthAddrContract4a + transferTopic + "0041eee8", dbtestdata.EthTxidB1T2 + "01" + "00", nil},
+		keyPair{dbtestdata.EthAddrContract0d + transferTopic + "0041eee9", dbtestdata.EthTxidB2T2 + "01" + "00" + dbtestdata.EthTxidB2T2 + "01" + "08", nil},
+		keyPair{dbtestdata.EthAddrContract4a + transferTopic + "0041eee9", dbtestdata.EthTxidB2T2 + "01" + "02" + dbtestdata.EthTxidB2T2 + "01" + "06", nil},
+		keyPair{dbtestdata.EthAddrContract47 + otherTopic + "0041eee9", dbtestdata.EthTxidB2T2 + "01" + "04" + dbtestdata.EthTxidB2T2 + "01" + "0a", nil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// only the last block is kept in blockLogs
+	if err := checkColumn(d, cfBlockLogs, []keyPair{
+		keyPair{
+			"0041eee9",
+			dbtestdata.EthAddrContract0d + transferTopic + dbtestdata.EthAddrContract47 + otherTopic + dbtestdata.EthAddrContract4a + transferTopic,
+			nil,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	type logResult struct {
+		txid     string
+		logIndex int
+		height   uint32
+	}
+	contract, _ := hex.DecodeString(dbtestdata.EthAddrContract4a)
+	topic, _ := hex.DecodeString(transferTopic)
+	tests := []struct {
+		name          string
+		topic0        []byte
+		lower, higher uint32
+		limit         int
+		want          []logResult
+	}{
+		{
+			name:   "all blocks",
+			topic0: topic,
+			lower:  0,
+			higher: ^uint32(0),
+			want: []logResult{
+				{"0x" + dbtestdata.EthTxidB1T2, 0, 4321000},
+				{"0x" + dbtestdata.EthTxidB2T2, 1, 4321001},
+				{"0x" + dbtestdata.EthTxidB2T2, 3, 4321001},
+			},
+		},
+		{
+			name:   "block range",
+			topic0: topic,
+			lower:  4321001,
+			higher: 4321001,
+			want: []logResult{
+				{"0x" + dbtestdata.EthTxidB2T2, 1, 4321001},
+				{"0x" + dbtestdata.EthTxidB2T2, 3, 4321001},
+			},
+		},
+		{
+			name:   "stopped iteration",
+			topic0: topic,
+			lower:  0,
+			higher: ^uint32(0),
+			limit:  2,
+			want: []logResult{
+				{"0x" + dbtestdata.EthTxidB1T2, 0, 4321000},
+				{"0x" + dbtestdata.EthTxidB2T2, 1, 4321001},
+			},
+		},
+		{
+			name:   "without topic0",
+			lower:  0,
+			higher: 4321000,
+			want: []logResult{
+				{"0x" + dbtestdata.EthTxidB1T2, 0, 4321000},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []logResult{}
+			if err := d.GetLogs(contract, tt.topic0, tt.lower, tt.higher, func(txid string, logIndex int, height uint32) error {
+				got = append(got, logResult{txid, logIndex, height})
+				if len(got) == tt.limit {
+					return &StopIteration{}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLogs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// disconnect the 2nd block, its logs must be removed
+	if err := d.DisconnectBlockRangeEthereumType(4321001, 4321001); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkColumn(d, cfLogs, logsBlock1); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkColumn(d, cfBlockLogs, []keyPair{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_addToTokenBalance(t *testing.T) {
+	d := setupRocksDB(t, &testEthereumParser{
+		EthereumParser: ethereumTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	addrDesc, _ := hex.DecodeString(dbtestdata.EthAddr20)
+	contract, _ := hex.DecodeString(dbtestdata.EthAddrContract4a)
+	key := string(packTokenBalanceKey(addrDesc, contract))
+	tokenBalances := map[string]*big.Int{key: big.NewInt(10)}
+	if err := d.addToTokenBalance(addrDesc, contract, big.NewInt(-10), tokenBalances); err != nil {
+		t.Fatal(err)
+	}
+	if b := tokenBalances[key]; b == nil || b.Sign() != 0 {
+		t.Fatalf("addToTokenBalance() = %v, want 0", b)
+	}
+	// the balance which would be negative is not tracked anymore
+	if err := d.addToTokenBalance(addrDesc, contract, big.NewInt(-1), tokenBalances); err != nil {
+		t.Fatal(err)
+	}
+	if b, found := tokenBalances[key]; !found || b != nil {
+		t.Fatalf("addToTokenBalance() = %v, want nil", b)
+	}
+	if err := d.addToTokenBalance(addrDesc, contract, big.NewInt(1), tokenBalances); err != nil {
+		t.Fatal(err)
+	}
+	if b := tokenBalances[key]; b != nil {
+		t.Fatalf("addToTokenBalance() of untracked balance = %v, want nil", b)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	vlq "github.com/bsm/go-vlq"
 	"github.com/jakm/btcutil/chaincfg"
@@ -47,7 +48,7 @@ func setupRocksDB(t *testing.T, p bchain.BlockChainParser) *RocksDB {
 	if err != nil {
 		t.Fatal(err)
 	}
-	d, err := NewRocksDB(tmp, 100000, -1, p, nil)
+	d, err := NewRocksDB(tmp, 100000, -1, p, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -391,6 +392,31 @@ func verifyGetTransactions(t *testing.T, d *RocksDB, addr string, low, high uint
 	}
 }
 
+type txidHeightVoutOutput struct {
+	txid     string
+	height   uint32
+	vout     int32
+	isOutput bool
+}
+
+func verifyGetTransactionsReverse(t *testing.T, d *RocksDB, addr string, low, high uint32, wantTxids []txidHeightVoutOutput) {
+	addrDesc, err := d.chainParser.GetAddrDescFromAddress(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotTxids := make([]txidHeightVoutOutput, 0)
+	addToTxids := func(txid string, height uint32, vout int32, isOutput bool) error {
+		gotTxids = append(gotTxids, txidHeightVoutOutput{txid, height, vout, isOutput})
+		return nil
+	}
+	if err := d.GetAddrDescTransactionsReverse(addrDesc, low, high, addToTxids); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotTxids, wantTxids) {
+		t.Errorf("GetAddrDescTransactionsReverse() = %v, want %v", gotTxids, wantTxids)
+	}
+}
+
 // override PackTx and UnpackTx to default BaseParser functionality
 // BitcoinParser uses tx hex which is not available for the test transactions
 func (p *testBitcoinParser) PackTx(tx *bchain.Tx, height uint32, blockTime int64) ([]byte, error) {
@@ -467,6 +493,14 @@ func TestRocksDB_Index_BitcoinType(t *testing.T) {
 		txidVoutOutput{dbtestdata.TxidB2T2, 0, true},
 	}, nil)
 	verifyGetTransactions(t, d, "mtGXQvBowMkBpnhLckhxhbwYK44Gs9eBad", 500000, 1000000, []txidVoutOutput{}, errors.New("checksum mismatch"))
+	verifyGetTransactionsReverse(t, d, dbtestdata.Addr2, 0, 1000000, []txidHeightVoutOutput{
+		{dbtestdata.TxidB2T1, 225494, 1, false},
+		{dbtestdata.TxidB1T1, 225493, 1, true},
+	})
+	verifyGetTransactionsReverse(t, d, dbtestdata.Addr2, 0, 225493, []txidHeightVoutOutput{
+		{dbtestdata.TxidB1T1, 225493, 1, true},
+	})
+	verifyGetTransactionsReverse(t, d, dbtestdata.Addr2, 500000, 1000000, []txidHeightVoutOutput{})
 
 	// GetBestBlock
 	height, hash, err := d.GetBestBlock()
@@ -531,7 +565,7 @@ func TestRocksDB_Index_BitcoinType(t *testing.T) {
 	}
 
 	// try to disconnect both blocks, however only the last one is kept, it is not possible
-	err = d.DisconnectBlockRangeBitcoinType(225493, 225494)
+	err = d.DisconnectBlockRangeBitcoinType(225493, 225494, nil)
 	if err == nil || err.Error() != "Cannot disconnect blocks with height 225493 and lower. It is necessary to rebuild index." {
 		t.Fatal(err)
 	}
@@ -539,7 +573,7 @@ func TestRocksDB_Index_BitcoinType(t *testing.T) {
 
 	// disconnect the 2nd block, verify that the db contains only data from the 1st block with restored unspentTxs
 	// and that the cached tx is removed
-	err = d.DisconnectBlockRangeBitcoinType(225494, 225494)
+	err = d.DisconnectBlockRangeBitcoinType(225494, 225494, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -617,6 +651,62 @@ func TestRocksDB_Index_BitcoinType(t *testing.T) {
 
 }
 
+func TestRocksDB_DeepRollback_BitcoinType(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	chain, err := dbtestdata.NewFakeBlockChain(d.chainParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block1 := dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	block2 := dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+
+	// the backend must return the indexed block, not a different block at the same height
+	err = d.DisconnectBlockRangeBitcoinType(225493, 225494, func(hash string, height uint32) (*bchain.Block, error) {
+		return chain.GetBlock("", 225494)
+	})
+	if err == nil {
+		t.Fatal("DisconnectBlockRangeBitcoinType() expected error for wrong block from backend")
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+
+	// blockTxs of the 1st block are not kept, the block is retrieved from the backend
+	if err = d.DisconnectBlockRangeBitcoinType(225493, 225494, chain.GetBlock); err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []int{cfHeight, cfAddresses, cfTxAddresses, cfAddressBalance, cfBlockTxs, cfTransactions} {
+		if err := checkColumn(d, col, []keyPair{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// connect both blocks again and verify the state of db
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock1(t, d, false)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+
+	// the 2nd block is disconnected using blockTxs, the backend is not needed
+	if err = d.DisconnectBlockRangeBitcoinType(225494, 225494, chain.GetBlock); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock1(t, d, true)
+}
+
 func Test_BulkConnect_BitcoinType(t *testing.T) {
 	d := setupRocksDB(t, &testBitcoinParser{
 		BitcoinParser: bitcoinTestnetParser(),
@@ -656,6 +746,95 @@ func Test_BulkConnect_BitcoinType(t *testing.T) {
 	verifyAfterBitcoinTypeBlock2(t, d)
 }
 
+func Test_BulkConnect_Resume(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	chain, err := dbtestdata.NewFakeBlockChain(d.chainParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.ResumeBulkConnect(chain.GetBlockHash, chain.GetBlock); err == nil {
+		t.Fatal("ResumeBulkConnect() expected error for db without checkpoint")
+	}
+
+	bc, err := d.InitBulkConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// force checkpoint after the first block
+	bc.lastCheckpoint = time.Time{}
+	if err := bc.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser), false); err != nil {
+		t.Fatal(err)
+	}
+	// only the blockTxs and the addresses of the second block are stored, the bulk connect is interrupted
+	if err := bc.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.storeBulkAddressesAndBlockTxs(true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	is, err := d.LoadInternalState("coin-unittest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is.DbState != common.DbStateInconsistent {
+		t.Fatal("DB not in DbStateInconsistent")
+	}
+	if h, hash := is.GetBulkCheckpoint(); h != 225493 || hash != "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997" {
+		t.Fatalf("GetBulkCheckpoint() = %v %v, want the first block", h, hash)
+	}
+	d.SetInternalState(is)
+
+	if _, err := d.ResumeBulkConnect(func(height uint32) (string, error) {
+		return "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6", nil
+	}, chain.GetBlock); err == nil {
+		t.Fatal("ResumeBulkConnect() expected error for checkpoint not in the chain")
+	}
+	h, err := d.ResumeBulkConnect(chain.GetBlockHash, chain.GetBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != 225493 {
+		t.Errorf("ResumeBulkConnect() = %v, want 225493", h)
+	}
+	if d.is.DbState != common.DbStateOpen {
+		t.Fatal("DB not in DbStateOpen")
+	}
+	// the addresses of the second block are removed
+	verifyAfterBitcoinTypeBlock1(t, d, true)
+
+	// the import continues from the checkpoint
+	bc, err = d.InitBulkConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.lastCheckpoint = time.Time{}
+	if err := bc.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser), true); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := d.is.GetBulkCheckpoint(); h != 225494 {
+		t.Fatalf("GetBulkCheckpoint() = %v, want the second block", h)
+	}
+	// the checkpoint is removed before a partial store
+	if err := bc.removeCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if is, err = d.LoadInternalState("coin-unittest"); err != nil {
+		t.Fatal(err)
+	}
+	if _, hash := is.GetBulkCheckpoint(); hash != "" {
+		t.Fatalf("GetBulkCheckpoint() = %v, want no checkpoint", hash)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterBitcoinTypeBlock2(t, d)
+}
+
 func Test_packBigint_unpackBigint(t *testing.T) {
 	bigbig1, _ := big.NewInt(0).SetString("123456789123456789012345", 10)
 	bigbig2, _ := big.NewInt(0).SetString("12345678912345678901234512389012345123456789123456789012345123456789123456789012345", 10)
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"crypto/sha256"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// The script hash index maps sha256 of the address descriptor (i.e. of the output script) to the address descriptor.
+// It is used by the Electrum protocol, which identifies addresses by the hash of their script.
+// The index is written together with the balances, addresses connected while the index is disabled are not indexed.
+// Therefore the index is marked in the internal state as complete only while it is enabled and it is rebuilt
+// from the addressBalance column when it is enabled again.
+
+// number of script hashes written in one batch during the build of the index
+const buildScriptHashIndexBatch = 100000
+
+// SetScriptHashIndex enables or disables the script hash index of BitcoinType coins
+// if the index is enabled and the db does not contain the complete index, it is built, which may take a long time
+func (d *RocksDB) SetScriptHashIndex(enabled bool) error {
+	if d.is == nil {
+		return errors.New("Internal state not set")
+	}
+	if !enabled {
+		d.scriptHashIndex = false
+		if d.is.ScriptHashIndex {
+			d.is.ScriptHashIndex = false
+			return d.storeState(d.is)
+		}
+		return nil
+	}
+	if d.chainParser.GetChainType() != bchain.ChainBitcoinType {
+		return errors.New("Script hash index is supported only for BitcoinType coins")
+	}
+	d.scriptHashIndex = true
+	if d.is.ScriptHashIndex {
+		return nil
+	}
+	if err := d.buildScriptHashIndex(); err != nil {
+		return err
+	}
+	d.is.ScriptHashIndex = true
+	return d.storeState(d.is)
+}
+
+// ScriptHashIndexEnabled returns true if the script hash index is enabled
+func (d *RocksDB) ScriptHashIndexEnabled() bool {
+	return d.scriptHashIndex
+}
+
+func (d *RocksDB) storeScriptHash(wb *gorocksdb.WriteBatch, addrDesc bchain.AddressDescriptor) {
+	h := sha256.Sum256(addrDesc)
+	wb.PutCF(d.cfh[cfScriptHashes], h[:], addrDesc)
+}
+
+// buildScriptHashIndex adds script hashes of all addresses with balance record to the index
+func (d *RocksDB) buildScriptHashIndex() error {
+	glog.Info("rocksdb: building script hash index")
+	start := time.Now()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddressBalance])
+	defer it.Close()
+	var count int
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		d.storeScriptHash(wb, append(bchain.AddressDescriptor(nil), it.Key().Data()...))
+		count++
+		if count%buildScriptHashIndexBatch == 0 {
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			wb.Clear()
+			glog.Info("rocksdb: script hash index, indexed ", count, " addresses")
+		}
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	glog.Info("rocksdb: script hash index built, indexed ", count, " addresses in ", time.Since(start))
+	return nil
+}
+
+// GetAddrDescFromScriptHash returns the address descriptor with sha256 hash scriptHash or nil if not found
+func (d *RocksDB) GetAddrDescFromScriptHash(scriptHash []byte) (bchain.AddressDescriptor, error) {
+	if !d.scriptHashIndex {
+		return nil, errors.New("Script hash index is not enabled")
+	}
+	val, err := d.db.GetCF(d.ro, d.cfh[cfScriptHashes], scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return append(bchain.AddressDescriptor(nil), buf...), nil
+}
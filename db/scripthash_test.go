@@ -0,0 +1,74 @@
+// build unittest
+
+package db
+
+import (
+	"blockbook/bchain"
+	"blockbook/tests/dbtestdata"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func checkScriptHashes(t *testing.T, d *RocksDB, addrs []string, indexed bool) {
+	for _, a := range addrs {
+		addrDesc, err := hex.DecodeString(dbtestdata.AddressToPubKeyHex(a, d.chainParser))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := sha256.Sum256(addrDesc)
+		got, err := d.GetAddrDescFromScriptHash(h[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if indexed && !bytes.Equal(got, addrDesc) {
+			t.Errorf("GetAddrDescFromScriptHash(%v) = %v, want %v", a, got, bchain.AddressDescriptor(addrDesc))
+		}
+		if !indexed && got != nil {
+			t.Errorf("GetAddrDescFromScriptHash(%v) = %v, want nil", a, got)
+		}
+	}
+}
+
+func TestRocksDB_ScriptHashIndex(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	if err := d.SetScriptHashIndex(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock1(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+	block1Addrs := []string{dbtestdata.Addr1, dbtestdata.Addr2, dbtestdata.Addr3, dbtestdata.Addr4, dbtestdata.Addr5}
+	block2Addrs := []string{dbtestdata.Addr6, dbtestdata.Addr7, dbtestdata.Addr8, dbtestdata.Addr9, dbtestdata.AddrA}
+	checkScriptHashes(t, d, block1Addrs, true)
+	checkScriptHashes(t, d, block2Addrs, false)
+
+	// addresses connected while the index is disabled are not indexed
+	if err := d.SetScriptHashIndex(false); err != nil {
+		t.Fatal(err)
+	}
+	if d.is.ScriptHashIndex {
+		t.Fatal("ScriptHashIndex is set in the internal state of disabled index")
+	}
+	if _, err := d.GetAddrDescFromScriptHash(make([]byte, 32)); err == nil {
+		t.Fatal("GetAddrDescFromScriptHash() expected error for disabled index")
+	}
+	if err := d.ConnectBlock(dbtestdata.GetTestBitcoinTypeBlock2(d.chainParser)); err != nil {
+		t.Fatal(err)
+	}
+
+	// enabling the index again rebuilds it
+	if err := d.SetScriptHashIndex(true); err != nil {
+		t.Fatal(err)
+	}
+	if !d.is.ScriptHashIndex {
+		t.Fatal("ScriptHashIndex is not set in the internal state of enabled index")
+	}
+	checkScriptHashes(t, d, block1Addrs, true)
+	checkScriptHashes(t, d, block2Addrs, true)
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"blockbook/bchain"
 	"blockbook/common"
+	"math/big"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -10,11 +11,12 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
 )
 
 // SyncWorker is handle to SyncWorker
 type SyncWorker struct {
-	db                     *RocksDB
+	db                     IndexStore
 	chain                  bchain.BlockChain
 	syncWorkers, syncChunk int
 	dryRun                 bool
@@ -23,10 +25,11 @@ type SyncWorker struct {
 	chanOsSignal           chan os.Signal
 	metrics                *common.Metrics
 	is                     *common.InternalState
+	blocksDir              string
 }
 
 // NewSyncWorker creates new SyncWorker and returns its handle
-func NewSyncWorker(db *RocksDB, chain bchain.BlockChain, syncWorkers, syncChunk int, minStartHeight int, dryRun bool, chanOsSignal chan os.Signal, metrics *common.Metrics, is *common.InternalState) (*SyncWorker, error) {
+func NewSyncWorker(db IndexStore, chain bchain.BlockChain, syncWorkers, syncChunk int, minStartHeight int, dryRun bool, chanOsSignal chan os.Signal, metrics *common.Metrics, is *common.InternalState) (*SyncWorker, error) {
 	if minStartHeight < 0 {
 		minStartHeight = 0
 	}
@@ -111,6 +114,23 @@ func (w *SyncWorker) resyncIndex(onNewBlock bchain.OnNewBlockFunc, initialSync b
 	if err != nil {
 		return err
 	}
+	// in initial sync, import the majority of blocks from the block files of the backend, if configured
+	if initialSync && w.blocksDir != "" {
+		remoteBestHeight, err := w.chain.GetBestBlockHeight()
+		if err != nil {
+			return err
+		}
+		// the block files are used only once, the rest is synchronized using the backend
+		dir := w.blocksDir
+		w.blocksDir = ""
+		if remoteBestHeight > w.startHeight+blocksDirTipDistance {
+			glog.Infof("resync: import of blocks %d-%d from block files in %s", w.startHeight, remoteBestHeight-blocksDirTipDistance, dir)
+			if err = w.ConnectBlocksFromFiles(dir, w.startHeight, remoteBestHeight-blocksDirTipDistance, remoteBestHeight); err != nil {
+				return err
+			}
+			return w.resyncIndex(onNewBlock, initialSync)
+		}
+	}
 	// if parallel operation is enabled and the number of blocks to be connected is large,
 	// use parallel routine to load majority of blocks
 	if w.syncWorkers > 1 {
@@ -242,40 +262,6 @@ func (w *SyncWorker) ConnectBlocksParallel(lower, higher uint32) error {
 	hchClosed.Store(false)
 	writeBlockDone := make(chan struct{})
 	terminating := make(chan struct{})
-	writeBlockWorker := func() {
-		defer close(writeBlockDone)
-		bc, err := w.db.InitBulkConnect()
-		if err != nil {
-			glog.Error("sync: InitBulkConnect error ", err)
-		}
-		lastBlock := lower - 1
-		keep := uint32(w.chain.GetChainParser().KeepBlockAddresses())
-	WriteBlockLoop:
-		for {
-			select {
-			case b := <-bch[(lastBlock+1)%uint32(w.syncWorkers)]:
-				if b == nil {
-					// channel is closed and empty - work is done
-					break WriteBlockLoop
-				}
-				if b.Height != lastBlock+1 {
-					glog.Fatal("writeBlockWorker skipped block, expected block ", lastBlock+1, ", new block ", b.Height)
-				}
-				err := bc.ConnectBlock(b, b.Height+keep > higher)
-				if err != nil {
-					glog.Fatal("writeBlockWorker ", b.Height, " ", b.Hash, " error ", err)
-				}
-				lastBlock = b.Height
-			case <-terminating:
-				break WriteBlockLoop
-			}
-		}
-		err = bc.Close()
-		if err != nil {
-			glog.Error("sync: bulkconnect.Close error ", err)
-		}
-		glog.Info("WriteBlock exiting...")
-	}
 	getBlockWorker := func(i int) {
 		defer wg.Done()
 		var err error
@@ -312,7 +298,7 @@ func (w *SyncWorker) ConnectBlocksParallel(lower, higher uint32) error {
 		wg.Add(1)
 		go getBlockWorker(i)
 	}
-	go writeBlockWorker()
+	go w.writeBlocksInOrder(bch, lower, higher, terminating, writeBlockDone)
 	var hash string
 	start := time.Now()
 	msTime := time.Now().Add(1 * time.Minute)
@@ -357,6 +343,55 @@ ConnectLoop:
 	return err
 }
 
+// writeBlocksInOrder connects the blocks received in channels bch in the order of their height, starting from the height lower
+// the block of height h is expected in the channel bch[h%len(bch)], nil block from the channel stops the writing
+// data for rollback are stored for the blocks near the tip, done is closed when the writing finishes
+func (w *SyncWorker) writeBlocksInOrder(bch []chan *bchain.Block, lower, tip uint32, terminating chan struct{}, done chan struct{}) {
+	defer close(done)
+	// bulk connect is specific to RocksDB, other stores connect the blocks one by one
+	var bc *BulkConnect
+	var err error
+	if d, ok := w.db.(*RocksDB); ok {
+		bc, err = d.InitBulkConnect()
+		if err != nil {
+			glog.Error("sync: InitBulkConnect error ", err)
+		}
+	}
+	lastBlock := lower - 1
+	keep := uint32(w.chain.GetChainParser().KeepBlockAddresses())
+WriteBlockLoop:
+	for {
+		select {
+		case b := <-bch[(lastBlock+1)%uint32(len(bch))]:
+			if b == nil {
+				// channel is closed and empty - work is done
+				break WriteBlockLoop
+			}
+			if b.Height != lastBlock+1 {
+				glog.Fatal("writeBlockWorker skipped block, expected block ", lastBlock+1, ", new block ", b.Height)
+			}
+			if bc != nil {
+				err = bc.ConnectBlock(b, b.Height+keep > tip)
+			} else {
+				err = w.db.ConnectBlock(b)
+			}
+			if err != nil {
+				glog.Fatal("writeBlockWorker ", b.Height, " ", b.Hash, " error ", err)
+			}
+			lastBlock = b.Height
+		case <-terminating:
+			break WriteBlockLoop
+		}
+	}
+	if bc != nil {
+		err = bc.Close()
+		if err != nil {
+			glog.Error("sync: bulkconnect.Close error ", err)
+		}
+	}
+	glog.Info("WriteBlock exiting...")
+}
+
 type blockResult struct {
 	block *bchain.Block
 	err   error
@@ -395,9 +430,128 @@ func (w *SyncWorker) DisconnectBlocks(lower uint32, higher uint32, hashes []stri
 	glog.Infof("sync: disconnecting blocks %d-%d", lower, higher)
 	ct := w.chain.GetChainParser().GetChainType()
 	if ct == bchain.ChainBitcoinType {
-		return w.db.DisconnectBlockRangeBitcoinType(lower, higher)
+		return w.db.DisconnectBlockRangeBitcoinType(lower, higher, w.chain.GetBlock)
 	} else if ct == bchain.ChainEthereumType {
 		return w.db.DisconnectBlockRangeEthereumType(lower, higher)
 	}
 	return errors.New("Unknown chain type")
 }
+
+// number of token balances reconciled in one batch
+const reconcileTokenBalancesBatch = 100
+
+type reconcileTokenBalance struct {
+	addrDesc, contract bchain.AddressDescriptor
+}
+
+// ReconcileTokenBalances compares the token balances stored in the index with the balances returned by the backend
+// and stores the backend balances where they differ. This fixes balances of non standard tokens, which change balances
+// without Transfer events, and sets balances which are not tracked by the index (for example after rollback without stored transfers).
+// The backend returns balances of its best block, therefore the index is kept synchronized with the backend
+// and a batch is repeated if the backend received a new block while the batch was processed.
+func (w *SyncWorker) ReconcileTokenBalances() error {
+	if w.chain.GetChainParser().GetChainType() != bchain.ChainEthereumType {
+		return errors.New("Token balances are supported only for EthereumType coins")
+	}
+	d, ok := w.db.(*RocksDB)
+	if !ok {
+		return errors.New("Token balances can be reconciled only in RocksDB index")
+	}
+	if err := w.ResyncIndex(nil, false); err != nil {
+		return err
+	}
+	start := time.Now()
+	var checked, fixed int
+	batch := make([]reconcileTokenBalance, 0, reconcileTokenBalancesBatch)
+	processBatch := func() error {
+		f, err := w.reconcileTokenBalancesBatch(d, batch)
+		if err != nil {
+			return err
+		}
+		checked += len(batch)
+		fixed += f
+		batch = batch[:0]
+		return nil
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddressContracts])
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-w.chanOsSignal:
+			return errors.Errorf("ReconcileTokenBalances interrupted after %d balances", checked)
+		default:
+		}
+		addrDesc := append(bchain.AddressDescriptor(nil), it.Key().Data()...)
+		if isZeroAddress(addrDesc) {
+			continue
+		}
+		acs, err := unpackAddrContracts(it.Value().Data(), addrDesc)
+		if err != nil {
+			return err
+		}
+		if acs == nil {
+			continue
+		}
+		for _, c := range acs.Contracts {
+			batch = append(batch, reconcileTokenBalance{addrDesc: addrDesc, contract: c.Contract})
+			if len(batch) >= reconcileTokenBalancesBatch {
+				if err = processBatch(); err != nil {
+					return err
+				}
+				if checked%(1000*reconcileTokenBalancesBatch) == 0 {
+					glog.Info("sync: reconciled ", checked, " token balances, fixed ", fixed, ", in progress...")
+				}
+			}
+		}
+	}
+	if err := processBatch(); err != nil {
+		return err
+	}
+	glog.Info("sync: reconciled ", checked, " token balances, fixed ", fixed, ", finished in ", time.Since(start))
+	return nil
+}
+
+func (w *SyncWorker) reconcileTokenBalancesBatch(d *RocksDB, batch []reconcileTokenBalance) (int, error) {
+	for {
+		height, _, err := w.db.GetBestBlock()
+		if err != nil {
+			return 0, err
+		}
+		tokenBalances := make(map[string]*big.Int)
+		for _, r := range batch {
+			b, err := w.chain.EthereumTypeGetErc20ContractBalance(r.addrDesc, r.contract)
+			if err != nil {
+				glog.Warningf("sync: EthereumTypeGetErc20ContractBalance addr %v, contract %v, %v", r.addrDesc, r.contract, err)
+				continue
+			}
+			stored, err := w.db.GetAddrDescTokenBalance(r.addrDesc, r.contract)
+			if err != nil {
+				return 0, err
+			}
+			if stored == nil || stored.Cmp(b) != 0 {
+				tokenBalances[string(packTokenBalanceKey(r.addrDesc, r.contract))] = b
+			}
+		}
+		backendHeight, err := w.chain.GetBestBlockHeight()
+		if err != nil {
+			return 0, err
+		}
+		if backendHeight == height {
+			if len(tokenBalances) > 0 {
+				wb := gorocksdb.NewWriteBatch()
+				defer wb.Destroy()
+				if err = d.storeTokenBalances(wb, tokenBalances); err != nil {
+					return 0, err
+				}
+				if err = d.db.Write(d.wo, wb); err != nil {
+					return 0, err
+				}
+			}
+			return len(tokenBalances), nil
+		}
+		// the balances from the backend do not match the index height, synchronize the index and repeat the batch
+		if err = w.ResyncIndex(nil, false); err != nil {
+			return 0, err
+		}
+	}
+}
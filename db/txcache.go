@@ -11,7 +11,7 @@ import (
 
 // TxCache is handle to TxCacheServer
 type TxCache struct {
-	db        *RocksDB
+	db        IndexStore
 	chain     bchain.BlockChain
 	metrics   *common.Metrics
 	is        *common.InternalState
@@ -20,7 +20,7 @@ type TxCache struct {
 }
 
 // NewTxCache creates new TxCache interface and returns its handle
-func NewTxCache(db *RocksDB, chain bchain.BlockChain, metrics *common.Metrics, is *common.InternalState, enabled bool) (*TxCache, error) {
+func NewTxCache(db IndexStore, chain bchain.BlockChain, metrics *common.Metrics, is *common.InternalState, enabled bool) (*TxCache, error) {
 	if !enabled {
 		glog.Info("txcache: disabled")
 	}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// The webhooks column stores the registrations of the outgoing webhooks and the log of their deliveries.
+// The registrations are stored under the key 'w'+id, the deliveries under the key 'd'+webhook id+sequence number,
+// i.e. the deliveries of a webhook are ordered by their creation. Both are stored in json format,
+// the column is small and the records are not written during the sync of the blocks.
+
+const (
+	webhookKeyPrefix         = 'w'
+	webhookDeliveryKeyPrefix = 'd'
+)
+
+// Webhook is a registration of an outgoing webhook
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret is the key of the HMAC-SHA256 signature of the payload
+	Secret    string   `json:"secret,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Blocks enables the notifications about new blocks
+	Blocks bool `json:"blocks,omitempty"`
+	// Confirmations is the number of confirmations of the transactions of the addresses that are notified,
+	// zero means that only the mempool transactions are notified
+	Confirmations int   `json:"confirmations,omitempty"`
+	Created       int64 `json:"created"`
+}
+
+// Status of WebhookDelivery
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is a record of the delivery log of a webhook
+type WebhookDelivery struct {
+	Webhook string `json:"webhook"`
+	// Seq is the sequence number of the delivery, unique in the webhook and increasing with the time of creation
+	Seq            uint64          `json:"seq"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	Created        int64           `json:"created"`
+	LastAttempt    int64           `json:"lastAttempt,omitempty"`
+	NextAttempt    int64           `json:"nextAttempt,omitempty"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+func packWebhookKey(id string) []byte {
+	return append([]byte{webhookKeyPrefix}, id...)
+}
+
+func packWebhookDeliveryKey(webhook string, seq uint64) []byte {
+	key := make([]byte, 1+len(webhook)+8)
+	key[0] = webhookDeliveryKeyPrefix
+	copy(key[1:], webhook)
+	binary.BigEndian.PutUint64(key[1+len(webhook):], seq)
+	return key
+}
+
+// StoreWebhook stores the registration of the webhook, existing registration with the same id is replaced
+func (d *RocksDB) StoreWebhook(wh *Webhook) error {
+	if wh.ID == "" {
+		return errors.New("Missing webhook id")
+	}
+	buf, err := json.Marshal(wh)
+	if err != nil {
+		return err
+	}
+	return d.db.PutCF(d.wo, d.cfh[cfWebhooks], packWebhookKey(wh.ID), buf)
+}
+
+// GetWebhook returns the registration of the webhook or nil if not found
+func (d *RocksDB) GetWebhook(id string) (*Webhook, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfWebhooks], packWebhookKey(id))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	var wh Webhook
+	if err = json.Unmarshal(buf, &wh); err != nil {
+		return nil, errors.Annotatef(err, "webhook %v", id)
+	}
+	return &wh, nil
+}
+
+// GetWebhooks returns all registered webhooks ordered by id
+func (d *RocksDB) GetWebhooks() ([]*Webhook, error) {
+	var whs []*Webhook
+	prefix := []byte{webhookKeyPrefix}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfWebhooks])
+	defer it.Close()
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		var wh Webhook
+		if err := json.Unmarshal(it.Value().Data(), &wh); err != nil {
+			return nil, errors.Annotatef(err, "webhook %v", string(key[1:]))
+		}
+		whs = append(whs, &wh)
+	}
+	return whs, nil
+}
+
+// DeleteWebhook removes the registration of the webhook together with its delivery log
+func (d *RocksDB) DeleteWebhook(id string) error {
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	wb.DeleteCF(d.cfh[cfWebhooks], packWebhookKey(id))
+	prefix := append([]byte{webhookDeliveryKeyPrefix}, id...)
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfWebhooks])
+	defer it.Close()
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		if len(key) == len(prefix)+8 {
+			wb.DeleteCF(d.cfh[cfWebhooks], append([]byte(nil), key...))
+		}
+	}
+	return d.db.Write(d.wo, wb)
+}
+
+// StoreWebhookDelivery stores the delivery to the delivery log, existing delivery with the same sequence number is replaced
+func (d *RocksDB) StoreWebhookDelivery(wd *WebhookDelivery) error {
+	buf, err := json.Marshal(wd)
+	if err != nil {
+		return err
+	}
+	return d.db.PutCF(d.wo, d.cfh[cfWebhooks], packWebhookDeliveryKey(wd.Webhook, wd.Seq), buf)
+}
+
+// GetWebhookDeliveries returns the deliveries of the webhook, the newest first
+// deliveries are filtered by status if it is not empty, at most limit deliveries older than the sequence number before are returned,
+// zero before means from the newest delivery
+func (d *RocksDB) GetWebhookDeliveries(webhook, status string, before uint64, limit int) ([]*WebhookDelivery, error) {
+	var wds []*WebhookDelivery
+	prefix := append([]byte{webhookDeliveryKeyPrefix}, webhook...)
+	if before == 0 {
+		before = ^uint64(0)
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfWebhooks])
+	defer it.Close()
+	for it.SeekForPrev(packWebhookDeliveryKey(webhook, before-1)); it.Valid() && len(wds) < limit; it.Prev() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		// skip deliveries of other webhooks with id starting with webhook
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		wd, err := unpackWebhookDelivery(key, it.Value().Data())
+		if err != nil {
+			return nil, err
+		}
+		if status == "" || wd.Status == status {
+			wds = append(wds, wd)
+		}
+	}
+	return wds, nil
+}
+
+// GetPendingWebhookDeliveries passes all pending deliveries of all webhooks to fn
+func (d *RocksDB) GetPendingWebhookDeliveries(fn func(wd *WebhookDelivery) error) error {
+	return d.iterateWebhookDeliveries(func(key []byte, wd *WebhookDelivery) error {
+		if wd.Status == WebhookDeliveryPending {
+			return fn(wd)
+		}
+		return nil
+	})
+}
+
+// PruneWebhookDeliveries removes the finished deliveries created before the unix time before, returns the number of removed deliveries
+func (d *RocksDB) PruneWebhookDeliveries(before int64) (int, error) {
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	var count int
+	err := d.iterateWebhookDeliveries(func(key []byte, wd *WebhookDelivery) error {
+		if wd.Status != WebhookDeliveryPending && wd.Created < before {
+			wb.DeleteCF(d.cfh[cfWebhooks], append([]byte(nil), key...))
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, d.db.Write(d.wo, wb)
+}
+
+func (d *RocksDB) iterateWebhookDeliveries(fn func(key []byte, wd *WebhookDelivery) error) error {
+	prefix := []byte{webhookDeliveryKeyPrefix}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfWebhooks])
+	defer it.Close()
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		wd, err := unpackWebhookDelivery(key, it.Value().Data())
+		if err != nil {
+			return err
+		}
+		if err = fn(key, wd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpackWebhookDelivery(key, buf []byte) (*WebhookDelivery, error) {
+	var wd WebhookDelivery
+	if err := json.Unmarshal(buf, &wd); err != nil {
+		return nil, errors.Annotatef(err, "webhook delivery %x", key)
+	}
+	return &wd, nil
+}
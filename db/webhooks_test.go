@@ -0,0 +1,143 @@
+// build unittest
+
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func webhookDeliverySeqs(wds []*WebhookDelivery) []uint64 {
+	r := make([]uint64, len(wds))
+	for i := range wds {
+		r[i] = wds[i].Seq
+	}
+	return r
+}
+
+func TestRocksDB_Webhooks(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: bitcoinTestnetParser(),
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	wh1 := &Webhook{ID: "01", URL: "http://localhost/hook", Secret: "s1", Addresses: []string{"a", "b"}, Confirmations: 3, Created: 1}
+	// the id of wh2 starts with the id of wh1, the deliveries of the webhooks must not be mixed
+	wh2 := &Webhook{ID: "012", URL: "https://localhost/hook2", Secret: "s2", Blocks: true, Created: 2}
+	if err := d.StoreWebhook(&Webhook{}); err == nil {
+		t.Error("StoreWebhook() without id expected error")
+	}
+	for _, wh := range []*Webhook{wh2, wh1} {
+		if err := d.StoreWebhook(wh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	whs, err := d.GetWebhooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(whs, []*Webhook{wh1, wh2}) {
+		t.Errorf("GetWebhooks() = %+v, want %+v", whs, []*Webhook{wh1, wh2})
+	}
+	wh, err := d.GetWebhook("012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wh, wh2) {
+		t.Errorf("GetWebhook() = %+v, want %+v", wh, wh2)
+	}
+	if wh, err = d.GetWebhook("0"); err != nil || wh != nil {
+		t.Errorf("GetWebhook(unknown) = %+v, %v, want nil", wh, err)
+	}
+
+	deliveries := []*WebhookDelivery{
+		{Webhook: "01", Seq: 10, Event: "block", Payload: json.RawMessage(`{"seq":10}`), Status: WebhookDeliveryDelivered, Attempts: 1, Created: 100},
+		{Webhook: "01", Seq: 11, Event: "mempool", Payload: json.RawMessage(`{"seq":11}`), Status: WebhookDeliveryFailed, Attempts: 12, Created: 100, Error: "Response status 500"},
+		{Webhook: "01", Seq: 12, Event: "confirmation", Payload: json.RawMessage(`{"seq":12}`), Status: WebhookDeliveryPending, Created: 100, NextAttempt: 200},
+		{Webhook: "01", Seq: 13, Event: "block", Payload: json.RawMessage(`{"seq":13}`), Status: WebhookDeliveryDelivered, Attempts: 2, Created: 300},
+		{Webhook: "012", Seq: 5, Event: "block", Payload: json.RawMessage(`{"seq":5}`), Status: WebhookDeliveryPending, Created: 100},
+	}
+	for _, wd := range deliveries {
+		if err := d.StoreWebhookDelivery(wd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		webhook string
+		status  string
+		before  uint64
+		limit   int
+		want    []uint64
+	}{
+		{name: "all", webhook: "01", limit: 100, want: []uint64{13, 12, 11, 10}},
+		{name: "limit", webhook: "01", limit: 2, want: []uint64{13, 12}},
+		{name: "before", webhook: "01", before: 12, limit: 100, want: []uint64{11, 10}},
+		{name: "status", webhook: "01", status: WebhookDeliveryDelivered, limit: 100, want: []uint64{13, 10}},
+		{name: "other webhook", webhook: "012", limit: 100, want: []uint64{5}},
+		{name: "unknown webhook", webhook: "02", limit: 100, want: []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wds, err := d.GetWebhookDeliveries(tt.webhook, tt.status, tt.before, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := webhookDeliverySeqs(wds); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetWebhookDeliveries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	wds, err := d.GetWebhookDeliveries("01", "", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wds, deliveries[3:4]) {
+		t.Errorf("GetWebhookDeliveries() = %+v, want %+v", wds, deliveries[3:4])
+	}
+
+	var pending []*WebhookDelivery
+	if err = d.GetPendingWebhookDeliveries(func(wd *WebhookDelivery) error {
+		pending = append(pending, wd)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := webhookDeliverySeqs(pending); !reflect.DeepEqual(got, []uint64{12, 5}) {
+		t.Errorf("GetPendingWebhookDeliveries() = %v, want %v", got, []uint64{12, 5})
+	}
+
+	// finished deliveries created before 200 are removed, pending deliveries are kept
+	n, err := d.PruneWebhookDeliveries(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("PruneWebhookDeliveries() = %v, want 2", n)
+	}
+	if wds, err = d.GetWebhookDeliveries("01", "", 0, 100); err != nil {
+		t.Fatal(err)
+	}
+	if got := webhookDeliverySeqs(wds); !reflect.DeepEqual(got, []uint64{13, 12}) {
+		t.Errorf("GetWebhookDeliveries() after prune = %v, want %v", got, []uint64{13, 12})
+	}
+
+	// delete removes the webhook and its deliveries but not the deliveries of other webhooks
+	if err = d.DeleteWebhook("01"); err != nil {
+		t.Fatal(err)
+	}
+	if whs, err = d.GetWebhooks(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(whs, []*Webhook{wh2}) {
+		t.Errorf("GetWebhooks() after delete = %+v, want %+v", whs, []*Webhook{wh2})
+	}
+	if wds, err = d.GetWebhookDeliveries("01", "", 0, 100); err != nil || len(wds) != 0 {
+		t.Errorf("GetWebhookDeliveries() of deleted webhook = %+v, %v, want none", wds, err)
+	}
+	if wds, err = d.GetWebhookDeliveries("012", "", 0, 100); err != nil || len(wds) != 1 {
+		t.Errorf("GetWebhookDeliveries() of other webhook = %+v, %v, want 1 delivery", wds, err)
+	}
+}
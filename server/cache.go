@@ -0,0 +1,114 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// max-age in seconds of the responses with immutable transactions and blocks
+	cacheMaxAgeImmutable = 7 * 24 * 3600
+	// max-age in seconds of the other responses, which change with new blocks and mempool transactions
+	cacheMaxAgeShort = 10
+)
+
+// voutsSpent returns true if all outputs are spent in blocks up to the height, their spending cannot change anymore
+// the spending height is known only if the spending transactions were requested
+func voutsSpent(vouts []api.Vout, height int) bool {
+	for i := range vouts {
+		if !vouts[i].Spent || vouts[i].SpentHeight <= 0 || vouts[i].SpentHeight > height {
+			return false
+		}
+	}
+	return true
+}
+
+func voutsV1Spent(vouts []api.VoutV1, height int) bool {
+	for i := range vouts {
+		if !vouts[i].Spent || vouts[i].SpentHeight <= 0 || vouts[i].SpentHeight > height {
+			return false
+		}
+	}
+	return true
+}
+
+// immutableData returns true if the transaction or block in data and the spending of its outputs
+// are past the reorg window, i.e. the response can differ only in the number of confirmations
+func (s *PublicServer) immutableData(data interface{}, bestHeight uint32) bool {
+	// blocks above the height can be replaced by a reorg
+	height := int(bestHeight) - s.chainParser.KeepBlockAddresses()
+	// EthereumType transactions have no spendable outputs
+	spending := s.chainParser.GetChainType() != bchain.ChainEthereumType
+	switch d := data.(type) {
+	case *api.Tx:
+		return d.Blockheight > 0 && d.Blockheight <= height && (!spending || voutsSpent(d.Vout, height))
+	case *api.TxV1:
+		return d.Blockheight > 0 && d.Blockheight <= height && (!spending || voutsV1Spent(d.Vout, height))
+	case *api.Block:
+		if int(d.Height) > height {
+			return false
+		}
+		for _, tx := range d.Transactions {
+			if spending && !voutsSpent(tx.Vout, height) {
+				return false
+			}
+		}
+		return true
+	case *api.BlockV1:
+		if int(d.Height) > height {
+			return false
+		}
+		for _, tx := range d.Transactions {
+			if spending && !voutsV1Spent(tx.Vout, height) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setCacheHeaders sets the ETag and Cache-Control headers of the successful api response
+// and returns true if the response matches the If-None-Match header of the request
+func (s *PublicServer) setCacheHeaders(w http.ResponseWriter, r *http.Request, data interface{}, body []byte) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if name, _ := splitAPIPath(r.URL.Path); name == "sendtx" {
+		w.Header().Set("Cache-Control", "no-store")
+		return false
+	}
+	_, bestHeight, _ := s.is.GetSyncState()
+	// the weak ETag is derived from the body, the responses with the same body are equivalent
+	h := sha256.Sum256(body)
+	var etag, cacheControl string
+	if s.immutableData(data, bestHeight) {
+		etag = fmt.Sprintf(`W/"%x"`, h[:16])
+		cacheControl = fmt.Sprintf("public, max-age=%d", cacheMaxAgeImmutable)
+	} else {
+		etag = fmt.Sprintf(`W/"%d-%x"`, bestHeight, h[:16])
+		cacheControl = fmt.Sprintf("public, max-age=%d", cacheMaxAgeShort)
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	return etagMatches(r.Header.Get("If-None-Match"), etag)
+}
+
+// etagMatches checks the etag against the list of ETags of the If-None-Match header using the weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
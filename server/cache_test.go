@@ -0,0 +1,152 @@
+// +build unittest
+
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain/coins/btc"
+	"blockbook/common"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_etagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{"", `W/"abcd-1"`, false},
+		{`W/"abcd-1"`, `W/"abcd-1"`, true},
+		{`"abcd-1"`, `W/"abcd-1"`, true},
+		{`W/"abcd-2", W/"abcd-1"`, `W/"abcd-1"`, true},
+		{`W/"abcd-2"`, `W/"abcd-1"`, false},
+		{`*`, `W/"abcd-1"`, true},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, tt.etag); got != tt.want {
+			t.Errorf("etagMatches(%v, %v) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestPublicServer_setCacheHeaders(t *testing.T) {
+	s := &PublicServer{
+		is: &common.InternalState{BestHeight: 1100},
+		// the reorg window is 100 blocks, the blocks up to 1000 are immutable
+		chainParser: btc.NewBitcoinParser(btc.GetChainParams("test"), &btc.Configuration{BlockAddressesToKeep: 100}),
+	}
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		data         interface{}
+		body         string
+		cacheControl string
+	}{
+		{
+			name:         "immutable tx",
+			method:       http.MethodGet,
+			url:          "/api/v2/tx/abcd",
+			data:         &api.Tx{Txid: "abcd", Blockheight: 1000, Confirmations: 101},
+			body:         `{"txid":"abcd","confirmations":101}`,
+			cacheControl: "public, max-age=604800",
+		},
+		{
+			name:         "tx with unspent output",
+			method:       http.MethodGet,
+			url:          "/api/v2/tx/abcd",
+			data:         &api.Tx{Txid: "abcd", Blockheight: 1000, Confirmations: 101, Vout: []api.Vout{{Spent: true, SpentHeight: 900}, {}}},
+			body:         `{"txid":"abcd","confirmations":101}`,
+			cacheControl: "public, max-age=10",
+		},
+		{
+			name:         "tx with output spent in the reorg window",
+			method:       http.MethodGet,
+			url:          "/api/v2/tx/abcd?spending=true",
+			data:         &api.Tx{Txid: "abcd", Blockheight: 1000, Confirmations: 101, Vout: []api.Vout{{Spent: true, SpentHeight: 1001}}},
+			body:         `{"txid":"abcd","confirmations":101}`,
+			cacheControl: "public, max-age=10",
+		},
+		{
+			name:         "tx with output spent past the reorg window",
+			method:       http.MethodGet,
+			url:          "/api/v2/tx/abcd?spending=true",
+			data:         &api.Tx{Txid: "abcd", Blockheight: 1000, Confirmations: 101, Vout: []api.Vout{{Spent: true, SpentHeight: 1000}}},
+			body:         `{"txid":"abcd","confirmations":101}`,
+			cacheControl: "public, max-age=604800",
+		},
+		{
+			name:         "tx in the reorg window",
+			method:       http.MethodGet,
+			url:          "/api/v2/tx/abcd",
+			data:         &api.Tx{Txid: "abcd", Blockheight: 1001, Confirmations: 100},
+			body:         `{"txid":"abcd","confirmations":100}`,
+			cacheControl: "public, max-age=10",
+		},
+		{
+			name:         "unconfirmed tx",
+			method:       http.MethodGet,
+			url:          "/api/v2/tx/abcd",
+			data:         &api.Tx{Txid: "abcd"},
+			body:         `{"txid":"abcd","confirmations":0}`,
+			cacheControl: "public, max-age=10",
+		},
+		{
+			name:         "address",
+			method:       http.MethodGet,
+			url:          "/api/v2/address/abcd",
+			data:         &api.Address{AddrStr: "abcd"},
+			body:         `{"addrStr":"abcd"}`,
+			cacheControl: "public, max-age=10",
+		},
+		{
+			name:         "sendtx",
+			method:       http.MethodGet,
+			url:          "/api/v2/sendtx/abcd",
+			data:         struct{}{},
+			body:         `{}`,
+			cacheControl: "no-store",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.url, nil)
+			w := httptest.NewRecorder()
+			if s.setCacheHeaders(w, r, tt.data, []byte(tt.body)) {
+				t.Fatal("setCacheHeaders() matched request without If-None-Match")
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %v, want %v", got, tt.cacheControl)
+			}
+			etag := w.Header().Get("ETag")
+			if tt.cacheControl == "no-store" {
+				if etag != "" {
+					t.Errorf("ETag = %v, want none", etag)
+				}
+				return
+			}
+			r.Header.Set("If-None-Match", etag)
+			if !s.setCacheHeaders(httptest.NewRecorder(), r, tt.data, []byte(tt.body)) {
+				t.Errorf("setCacheHeaders() did not match ETag %v", etag)
+			}
+		})
+	}
+	// the ETag of immutable data changes only with the body, the ETag of other data changes also with new blocks
+	r := httptest.NewRequest(http.MethodGet, "/api/v2/tx/abcd", nil)
+	w1, w2 := httptest.NewRecorder(), httptest.NewRecorder()
+	s.setCacheHeaders(w1, r, &api.Tx{Blockheight: 1000, Confirmations: 101}, []byte(`{"confirmations":101}`))
+	s.setCacheHeaders(w2, r, &api.Tx{Blockheight: 1000, Confirmations: 102}, []byte(`{"confirmations":102}`))
+	if w1.Header().Get("ETag") == w2.Header().Get("ETag") {
+		t.Errorf("ETag of immutable tx did not change with the body, %v", w1.Header().Get("ETag"))
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/v2/address/abcd", nil)
+	w1, w2 = httptest.NewRecorder(), httptest.NewRecorder()
+	s.setCacheHeaders(w1, r, &api.Address{}, []byte(`{"balance":"1"}`))
+	s.is.BestHeight++
+	s.setCacheHeaders(w2, r, &api.Address{}, []byte(`{"balance":"1"}`))
+	if w1.Header().Get("ETag") == w2.Header().Get("ETag") {
+		t.Errorf("ETag of address did not change with best height, %v", w1.Header().Get("ETag"))
+	}
+}
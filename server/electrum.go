@@ -0,0 +1,923 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain"
+	"blockbook/common"
+	"blockbook/db"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// The Electrum protocol is a line based JSON-RPC over TCP or TLS, used by the Electrum family of wallets.
+// The addresses are identified by the script hash, i.e. by the sha256 hash of the output script (address descriptor),
+// in hex encoding with reversed byte order. The server resolves the script hashes using the script hash index of the db.
+
+const electrumProtocolVersion = "1.4"
+const electrumMaxLineLength = 1 << 20
+const electrumIdleTimeout = 10 * time.Minute
+
+// address descriptors of mempool transactions not yet in the index are kept to resolve their script hashes
+const electrumMempoolScriptHashExpiration = 72 * time.Hour
+
+// electrum JSON-RPC error codes
+const (
+	electrumErrorParse          = -32700
+	electrumErrorMethodNotFound = -32601
+	electrumErrorBadRequest     = 1
+)
+
+var (
+	// ErrElectrumServerClosed is returned by ElectrumServer.Run after the server is closed
+	ErrElectrumServerClosed = errors.New("electrum: Server closed")
+
+	errElectrumUnknownMethod = errors.New("unknown method")
+)
+
+type electrumReq struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type electrumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type electrumRes struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *electrumError  `json:"error,omitempty"`
+}
+
+type electrumNotification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type electrumHeader struct {
+	Height uint32 `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+type electrumTx struct {
+	TxHash string `json:"tx_hash"`
+	Height uint32 `json:"height"`
+}
+
+type electrumUtxo struct {
+	TxHash string      `json:"tx_hash"`
+	TxPos  int32       `json:"tx_pos"`
+	Height int         `json:"height"`
+	Value  json.Number `json:"value"`
+}
+
+type electrumBalance struct {
+	Confirmed   json.Number `json:"confirmed"`
+	Unconfirmed json.Number `json:"unconfirmed"`
+}
+
+type electrumConn struct {
+	id        uint64
+	conn      net.Conn
+	out       chan []byte
+	ip        string
+	alive     bool
+	aliveLock sync.Mutex
+}
+
+type electrumSubscription struct {
+	// scriptHash is the script hash in the electrum format
+	scriptHash string
+	status     string
+	conns      map[*electrumConn]struct{}
+}
+
+type electrumMempoolScriptHash struct {
+	addrDesc bchain.AddressDescriptor
+	seen     time.Time
+}
+
+// ElectrumServer is a handle to the Electrum protocol server
+type ElectrumServer struct {
+	binding                 string
+	certFiles               string
+	listener                net.Listener
+	closed                  bool
+	listenerLock            sync.Mutex
+	db                      *db.RocksDB
+	txCache                 *db.TxCache
+	chain                   bchain.BlockChain
+	chainParser             bchain.BlockChainParser
+	metrics                 *common.Metrics
+	is                      *common.InternalState
+	api                     *api.Worker
+	conns                   map[*electrumConn]struct{}
+	connsLock               sync.Mutex
+	headersSubscriptions    map[*electrumConn]struct{}
+	subscriptions           map[string]*electrumSubscription
+	subscriptionsLock       sync.Mutex
+	updateSubscriptionsLock sync.Mutex
+	mempoolScriptHashes     map[string]electrumMempoolScriptHash
+	mempoolScriptHashesLock sync.Mutex
+}
+
+// NewElectrumServer creates new Electrum protocol server listening on binding, TLS is used if certFiles is set
+// the server requires the script hash index of the db
+func NewElectrumServer(binding, certFiles string, db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*ElectrumServer, error) {
+	if !db.ScriptHashIndexEnabled() {
+		return nil, errors.New("Electrum server requires the script hash index")
+	}
+	api, err := api.NewWorker(db, chain, txCache, is)
+	if err != nil {
+		return nil, err
+	}
+	s := &ElectrumServer{
+		binding:              binding,
+		certFiles:            certFiles,
+		db:                   db,
+		txCache:              txCache,
+		chain:                chain,
+		chainParser:          chain.GetChainParser(),
+		metrics:              metrics,
+		is:                   is,
+		api:                  api,
+		conns:                make(map[*electrumConn]struct{}),
+		headersSubscriptions: make(map[*electrumConn]struct{}),
+		subscriptions:        make(map[string]*electrumSubscription),
+		mempoolScriptHashes:  make(map[string]electrumMempoolScriptHash),
+	}
+	return s, nil
+}
+
+// Run starts the server and accepts the connections until the server is closed
+func (s *ElectrumServer) Run() error {
+	var l net.Listener
+	var err error
+	if s.certFiles == "" {
+		glog.Info("electrum server: starting to listen on tcp://", s.binding)
+		l, err = net.Listen("tcp", s.binding)
+	} else {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(fmt.Sprint(s.certFiles, ".crt"), fmt.Sprint(s.certFiles, ".key"))
+		if err != nil {
+			return err
+		}
+		glog.Info("electrum server: starting to listen on ssl://", s.binding)
+		l, err = tls.Listen("tcp", s.binding, &tls.Config{Certificates: []tls.Certificate{cert}})
+	}
+	if err != nil {
+		return err
+	}
+	s.listenerLock.Lock()
+	if s.closed {
+		s.listenerLock.Unlock()
+		l.Close()
+		return ErrElectrumServerClosed
+	}
+	s.listener = l
+	s.listenerLock.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.listenerLock.Lock()
+			closed := s.closed
+			s.listenerLock.Unlock()
+			if closed {
+				return ErrElectrumServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				glog.Error("electrum server: accept error ", err)
+				time.Sleep(50 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		c := &electrumConn{
+			id:    atomic.AddUint64(&connectionCounter, 1),
+			conn:  conn,
+			out:   make(chan []byte, outChannelSize),
+			ip:    conn.RemoteAddr().String(),
+			alive: true,
+		}
+		s.onConnect(c)
+		go s.inputLoop(c)
+		go s.outputLoop(c)
+	}
+}
+
+// Close closes the listener and all client connections
+func (s *ElectrumServer) Close() error {
+	glog.Infof("electrum server: closing")
+	s.listenerLock.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.listenerLock.Unlock()
+	s.connsLock.Lock()
+	conns := make([]*electrumConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.connsLock.Unlock()
+	for _, c := range conns {
+		s.closeConn(c)
+	}
+	return err
+}
+
+// Shutdown shuts down the server, the electrum connections are long lived, therefore they are closed immediately
+func (s *ElectrumServer) Shutdown(ctx context.Context) error {
+	glog.Infof("electrum server: shutdown")
+	return s.Close()
+}
+
+func (s *ElectrumServer) closeConn(c *electrumConn) {
+	c.aliveLock.Lock()
+	if !c.alive {
+		c.aliveLock.Unlock()
+		return
+	}
+	c.conn.Close()
+	c.alive = false
+	close(c.out)
+	c.aliveLock.Unlock()
+	s.onDisconnect(c)
+}
+
+// send queues the message to the client, the connection of a client not reading its messages is closed
+func (s *ElectrumServer) send(c *electrumConn, m []byte) {
+	full := false
+	c.aliveLock.Lock()
+	if c.alive {
+		select {
+		case c.out <- m:
+		default:
+			full = true
+		}
+	}
+	c.aliveLock.Unlock()
+	if full {
+		glog.Error("electrum server: client ", c.id, ", ", c.ip, " does not read messages, closing connection")
+		s.closeConn(c)
+	}
+}
+
+func (s *ElectrumServer) inputLoop(c *electrumConn) {
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Error("electrum server: recovered from panic: ", r, ", ", c.id)
+			debug.PrintStack()
+		}
+		s.closeConn(c)
+	}()
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 4096), electrumMaxLineLength)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(electrumIdleTimeout))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				glog.V(1).Info("electrum server: client ", c.id, " read error ", err)
+			}
+			return
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) > 0 {
+			if m := s.onMessage(c, line); m != nil {
+				s.send(c, m)
+			}
+		}
+	}
+}
+
+func (s *ElectrumServer) outputLoop(c *electrumConn) {
+	w := bufio.NewWriter(c.conn)
+	for m := range c.out {
+		c.conn.SetWriteDeadline(time.Now().Add(defaultTimeout))
+		w.Write(m)
+		w.WriteByte('\n')
+		// write out the buffer only if there are no more queued messages
+		if len(c.out) == 0 {
+			if err := w.Flush(); err != nil {
+				glog.V(1).Info("electrum server: client ", c.id, " write error ", err)
+				s.closeConn(c)
+			}
+		}
+	}
+}
+
+func (s *ElectrumServer) onConnect(c *electrumConn) {
+	s.connsLock.Lock()
+	s.conns[c] = struct{}{}
+	s.connsLock.Unlock()
+	glog.Info("electrum server: client connected ", c.id, ", ", c.ip)
+	s.metrics.ElectrumClients.Inc()
+}
+
+func (s *ElectrumServer) onDisconnect(c *electrumConn) {
+	s.connsLock.Lock()
+	delete(s.conns, c)
+	s.connsLock.Unlock()
+	s.subscriptionsLock.Lock()
+	delete(s.headersSubscriptions, c)
+	for k, sub := range s.subscriptions {
+		delete(sub.conns, c)
+		if len(sub.conns) == 0 {
+			delete(s.subscriptions, k)
+		}
+	}
+	s.subscriptionsLock.Unlock()
+	glog.Info("electrum server: client disconnected ", c.id, ", ", c.ip)
+	s.metrics.ElectrumClients.Dec()
+}
+
+// onMessage processes a request or a batch of requests and returns the marshalled response, nil means no response
+func (s *ElectrumServer) onMessage(c *electrumConn, line []byte) []byte {
+	var res interface{}
+	if line[0] == '[' {
+		var reqs []electrumReq
+		if err := json.Unmarshal(line, &reqs); err != nil {
+			res = electrumErrorRes(nil, electrumErrorParse, err.Error())
+		} else {
+			rs := make([]*electrumRes, 0, len(reqs))
+			for i := range reqs {
+				if r := s.onRequest(c, &reqs[i]); r != nil {
+					rs = append(rs, r)
+				}
+			}
+			if len(rs) == 0 {
+				return nil
+			}
+			res = rs
+		}
+	} else {
+		var req electrumReq
+		if err := json.Unmarshal(line, &req); err != nil {
+			res = electrumErrorRes(nil, electrumErrorParse, err.Error())
+		} else {
+			r := s.onRequest(c, &req)
+			if r == nil {
+				return nil
+			}
+			res = r
+		}
+	}
+	m, err := json.Marshal(res)
+	if err != nil {
+		glog.Error("electrum server: client ", c.id, " marshal error ", err)
+		return nil
+	}
+	return m
+}
+
+func electrumErrorRes(id json.RawMessage, code int, message string) *electrumRes {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &electrumRes{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &electrumError{Code: code, Message: message},
+	}
+}
+
+var electrumHandlers = map[string]func(*ElectrumServer, *electrumConn, json.RawMessage) (interface{}, error){
+	"server.version": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		return []string{"Blockbook " + common.GetVersionInfo().Version, electrumProtocolVersion}, nil
+	},
+	"server.banner": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		return "Blockbook " + common.GetVersionInfo().Version + ", " + s.is.Coin, nil
+	},
+	"server.ping": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		return nil, nil
+	},
+	"blockchain.headers.subscribe": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		return s.subscribeHeaders(c)
+	},
+	"blockchain.block.header": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		var height, cpHeight uint32
+		if err := unmarshalElectrumParams(params, &height, &cpHeight); err != nil {
+			return nil, err
+		}
+		if cpHeight != 0 {
+			return nil, errors.New("Checkpoint proofs are not supported")
+		}
+		return s.blockHeader(height)
+	},
+	"blockchain.scripthash.get_history": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		h, err := unmarshalElectrumScriptHash(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.getHistory(h)
+	},
+	"blockchain.scripthash.get_balance": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		h, err := unmarshalElectrumScriptHash(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.getBalance(h)
+	},
+	"blockchain.scripthash.listunspent": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		h, err := unmarshalElectrumScriptHash(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.listUnspent(h)
+	},
+	"blockchain.scripthash.subscribe": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		h, err := unmarshalElectrumScriptHash(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.subscribeScriptHash(c, h)
+	},
+	"blockchain.scripthash.unsubscribe": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		h, err := unmarshalElectrumScriptHash(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.unsubscribeScriptHash(c, h), nil
+	},
+	"blockchain.transaction.get": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		var txid string
+		var verbose bool
+		if err := unmarshalElectrumParams(params, &txid, &verbose); err != nil {
+			return nil, err
+		}
+		return s.getTransaction(txid, verbose)
+	},
+	"blockchain.transaction.broadcast": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		var tx string
+		if err := unmarshalElectrumParams(params, &tx); err != nil {
+			return nil, err
+		}
+		return s.chain.SendRawTransaction(tx)
+	},
+	"blockchain.estimatefee": func(s *ElectrumServer, c *electrumConn, params json.RawMessage) (interface{}, error) {
+		var blocks int
+		if err := unmarshalElectrumParams(params, &blocks); err != nil {
+			return nil, err
+		}
+		return s.estimateFee(blocks), nil
+	},
+}
+
+func (s *ElectrumServer) onRequest(c *electrumConn, req *electrumReq) (res *electrumRes) {
+	var err error
+	var data interface{}
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Error("electrum server: client ", c.id, ", onRequest ", req.Method, " recovered from panic: ", r)
+			debug.PrintStack()
+			res = electrumErrorRes(req.ID, electrumErrorBadRequest, "Internal error")
+		}
+		// requests without id are notifications, which are not answered
+		if len(req.ID) == 0 {
+			res = nil
+		}
+	}()
+	f, ok := electrumHandlers[req.Method]
+	if ok {
+		data, err = f(s, c, req.Params)
+	} else {
+		err = errElectrumUnknownMethod
+	}
+	if err == nil {
+		var m json.RawMessage
+		if m, err = json.Marshal(data); err == nil {
+			glog.V(1).Info("electrum server: client ", c.id, " onRequest ", req.Method, " success")
+			s.metrics.ElectrumRequests.With(common.Labels{"method": req.Method, "status": "success"}).Inc()
+			return &electrumRes{JSONRPC: "2.0", ID: req.ID, Result: m}
+		}
+	}
+	code := electrumErrorBadRequest
+	method := req.Method
+	if err == errElectrumUnknownMethod {
+		code = electrumErrorMethodNotFound
+		// do not create metrics labels from arbitrary method names
+		method = "unknown"
+	} else {
+		glog.Error("electrum server: client ", c.id, " onRequest ", req.Method, ": ", errors.ErrorStack(err))
+	}
+	s.metrics.ElectrumRequests.With(common.Labels{"method": method, "status": "failure"}).Inc()
+	return electrumErrorRes(req.ID, code, err.Error())
+}
+
+// unmarshalElectrumParams unmarshals positional params to v, params not present in the request keep their values
+func unmarshalElectrumParams(params json.RawMessage, v ...interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	var p []json.RawMessage
+	if err := json.Unmarshal(params, &p); err != nil {
+		return errors.New("Params must be an array")
+	}
+	for i := 0; i < len(p) && i < len(v); i++ {
+		if err := json.Unmarshal(p[i], v[i]); err != nil {
+			return errors.Annotatef(err, "Param %d", i)
+		}
+	}
+	return nil
+}
+
+func unmarshalElectrumScriptHash(params json.RawMessage) ([]byte, error) {
+	var sh string
+	if err := unmarshalElectrumParams(params, &sh); err != nil {
+		return nil, err
+	}
+	return electrumScriptHashToKey(sh)
+}
+
+// electrumScriptHashToKey converts the script hash in the electrum format to the key of the script hash index
+func electrumScriptHashToKey(sh string) ([]byte, error) {
+	b, err := hex.DecodeString(sh)
+	if err != nil || len(b) != sha256.Size {
+		return nil, errors.New("Invalid script hash")
+	}
+	reverseBytes(b)
+	return b, nil
+}
+
+// electrumScriptHash returns the script hash of the address descriptor in the electrum format
+func electrumScriptHash(addrDesc bchain.AddressDescriptor) string {
+	h := sha256.Sum256(addrDesc)
+	reverseBytes(h[:])
+	return hex.EncodeToString(h[:])
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// electrumStatus computes the status of the history, returns empty string if there is no history
+// unconfirmed transactions are reported at height 0 regardless of their unconfirmed inputs
+func electrumStatus(h *api.AddrDescHistory) string {
+	if len(h.Confirmed) == 0 && len(h.Unconfirmed) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	for _, t := range h.Confirmed {
+		buf.WriteString(t.Txid)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.FormatUint(uint64(t.Height), 10))
+		buf.WriteByte(':')
+	}
+	for _, t := range h.Unconfirmed {
+		buf.WriteString(t.Txid)
+		buf.WriteString(":0:")
+	}
+	s := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(s[:])
+}
+
+// electrumBlockHeader serializes the block header to the 80 bytes bitcoin format
+func electrumBlockHeader(bi *bchain.BlockInfo) (string, error) {
+	version, err := bi.Version.Int64()
+	if err != nil {
+		return "", errors.Annotatef(err, "version %v", bi.Version)
+	}
+	nonce, err := bi.Nonce.Int64()
+	if err != nil {
+		return "", errors.Annotatef(err, "nonce %v", bi.Nonce)
+	}
+	bits, err := strconv.ParseUint(bi.Bits, 16, 32)
+	if err != nil {
+		return "", errors.Annotatef(err, "bits %v", bi.Bits)
+	}
+	prev := make([]byte, 32)
+	if bi.Prev != "" {
+		if prev, err = hex.DecodeString(bi.Prev); err != nil || len(prev) != 32 {
+			return "", errors.Errorf("Invalid previous block hash %v", bi.Prev)
+		}
+	}
+	merkle, err := hex.DecodeString(bi.MerkleRoot)
+	if err != nil || len(merkle) != 32 {
+		return "", errors.Errorf("Invalid merkle root %v", bi.MerkleRoot)
+	}
+	reverseBytes(prev)
+	reverseBytes(merkle)
+	b := make([]byte, 80)
+	binary.LittleEndian.PutUint32(b[0:], uint32(version))
+	copy(b[4:], prev)
+	copy(b[36:], merkle)
+	binary.LittleEndian.PutUint32(b[68:], uint32(bi.Time))
+	binary.LittleEndian.PutUint32(b[72:], uint32(bits))
+	binary.LittleEndian.PutUint32(b[76:], uint32(nonce))
+	return hex.EncodeToString(b), nil
+}
+
+func (s *ElectrumServer) blockHeaderByHash(hash string) (string, error) {
+	bi, err := s.chain.GetBlockInfo(hash)
+	if err != nil {
+		return "", err
+	}
+	return electrumBlockHeader(bi)
+}
+
+func (s *ElectrumServer) blockHeader(height uint32) (interface{}, error) {
+	hash, err := s.db.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, errors.Errorf("Block %v not found", height)
+	}
+	return s.blockHeaderByHash(hash)
+}
+
+func (s *ElectrumServer) subscribeHeaders(c *electrumConn) (interface{}, error) {
+	height, hash, err := s.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.blockHeaderByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	s.subscriptionsLock.Lock()
+	s.headersSubscriptions[c] = struct{}{}
+	s.subscriptionsLock.Unlock()
+	return &electrumHeader{Height: height, Hex: h}, nil
+}
+
+// addrDescFromScriptHash resolves the script hash using the index or the address descriptors of the mempool transactions,
+// returns nil if the script hash is not known
+func (s *ElectrumServer) addrDescFromScriptHash(h []byte) (bchain.AddressDescriptor, error) {
+	addrDesc, err := s.db.GetAddrDescFromScriptHash(h)
+	if err != nil || addrDesc != nil {
+		return addrDesc, err
+	}
+	s.mempoolScriptHashesLock.Lock()
+	defer s.mempoolScriptHashesLock.Unlock()
+	if m, ok := s.mempoolScriptHashes[string(h)]; ok {
+		return m.addrDesc, nil
+	}
+	return nil, nil
+}
+
+func (s *ElectrumServer) addrDescHistory(h []byte, unconfirmedBalance bool) (*api.AddrDescHistory, error) {
+	addrDesc, err := s.addrDescFromScriptHash(h)
+	if err != nil {
+		return nil, err
+	}
+	if addrDesc == nil {
+		return &api.AddrDescHistory{}, nil
+	}
+	return s.api.GetAddrDescHistory(addrDesc, unconfirmedBalance)
+}
+
+func (s *ElectrumServer) getHistory(h []byte) (interface{}, error) {
+	ah, err := s.addrDescHistory(h, false)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]electrumTx, 0, len(ah.Confirmed)+len(ah.Unconfirmed))
+	for _, t := range ah.Confirmed {
+		r = append(r, electrumTx{TxHash: t.Txid, Height: t.Height})
+	}
+	for _, t := range ah.Unconfirmed {
+		r = append(r, electrumTx{TxHash: t.Txid})
+	}
+	return r, nil
+}
+
+func (s *ElectrumServer) getBalance(h []byte) (interface{}, error) {
+	ah, err := s.addrDescHistory(h, true)
+	if err != nil {
+		return nil, err
+	}
+	return &electrumBalance{
+		Confirmed:   json.Number(ah.BalanceSat.String()),
+		Unconfirmed: json.Number(ah.UnconfirmedBalanceSat.String()),
+	}, nil
+}
+
+func (s *ElectrumServer) listUnspent(h []byte) (interface{}, error) {
+	addrDesc, err := s.addrDescFromScriptHash(h)
+	if err != nil {
+		return nil, err
+	}
+	r := []electrumUtxo{}
+	if addrDesc == nil {
+		return r, nil
+	}
+	utxos, err := s.api.GetAddrDescUtxo(addrDesc, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range utxos {
+		r = append(r, electrumUtxo{
+			TxHash: u.Txid,
+			TxPos:  u.Vout,
+			Height: u.Height,
+			Value:  json.Number((*big.Int)(u.AmountSat).String()),
+		})
+	}
+	return r, nil
+}
+
+func (s *ElectrumServer) getTransaction(txid string, verbose bool) (interface{}, error) {
+	if verbose {
+		tx, err := s.chain.GetTransaction(txid)
+		if err != nil {
+			return nil, err
+		}
+		return s.chain.GetTransactionSpecific(tx)
+	}
+	tx, err := s.api.GetTransaction(txid, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Hex == "" {
+		return nil, errors.Errorf("Transaction %v not available in raw format", txid)
+	}
+	return tx.Hex, nil
+}
+
+// estimateFee returns the fee in coins per kilobyte, -1 if the fee cannot be estimated
+func (s *ElectrumServer) estimateFee(blocks int) json.Number {
+	if blocks < 1 {
+		blocks = 1
+	}
+	fee, err := s.chain.EstimateSmartFee(blocks, true)
+	if err != nil || fee.Sign() <= 0 {
+		if err != nil {
+			glog.Error("electrum server: EstimateSmartFee ", blocks, ": ", err)
+		}
+		return json.Number("-1")
+	}
+	return json.Number(s.chainParser.AmountToDecimalString(&fee))
+}
+
+func (s *ElectrumServer) subscribeScriptHash(c *electrumConn, h []byte) (interface{}, error) {
+	ah, err := s.addrDescHistory(h, false)
+	if err != nil {
+		return nil, err
+	}
+	status := electrumStatus(ah)
+	s.subscriptionsLock.Lock()
+	sub, ok := s.subscriptions[string(h)]
+	if !ok {
+		sh := make([]byte, len(h))
+		copy(sh, h)
+		reverseBytes(sh)
+		sub = &electrumSubscription{
+			scriptHash: hex.EncodeToString(sh),
+			conns:      make(map[*electrumConn]struct{}),
+		}
+		s.subscriptions[string(h)] = sub
+	}
+	sub.status = status
+	sub.conns[c] = struct{}{}
+	s.subscriptionsLock.Unlock()
+	if status == "" {
+		return nil, nil
+	}
+	return status, nil
+}
+
+func (s *ElectrumServer) unsubscribeScriptHash(c *electrumConn, h []byte) bool {
+	s.subscriptionsLock.Lock()
+	defer s.subscriptionsLock.Unlock()
+	sub, ok := s.subscriptions[string(h)]
+	if !ok {
+		return false
+	}
+	if _, ok = sub.conns[c]; !ok {
+		return false
+	}
+	delete(sub.conns, c)
+	if len(sub.conns) == 0 {
+		delete(s.subscriptions, string(h))
+	}
+	return true
+}
+
+func (s *ElectrumServer) notify(conns []*electrumConn, method string, params ...interface{}) {
+	if len(conns) == 0 {
+		return
+	}
+	m, err := json.Marshal(&electrumNotification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		glog.Error("electrum server: marshal notification ", method, ": ", err)
+		return
+	}
+	for _, c := range conns {
+		s.send(c, m)
+	}
+}
+
+// updateSubscription recomputes the status of the subscribed script hash and notifies the clients if the status changed
+func (s *ElectrumServer) updateSubscription(h []byte) {
+	s.subscriptionsLock.Lock()
+	_, ok := s.subscriptions[string(h)]
+	s.subscriptionsLock.Unlock()
+	if !ok {
+		return
+	}
+	ah, err := s.addrDescHistory(h, false)
+	if err != nil {
+		glog.Error("electrum server: history of script hash ", hex.EncodeToString(h), ": ", err)
+		return
+	}
+	status := electrumStatus(ah)
+	s.subscriptionsLock.Lock()
+	sub, ok := s.subscriptions[string(h)]
+	if !ok || sub.status == status {
+		s.subscriptionsLock.Unlock()
+		return
+	}
+	sub.status = status
+	conns := make([]*electrumConn, 0, len(sub.conns))
+	for c := range sub.conns {
+		conns = append(conns, c)
+	}
+	scriptHash := sub.scriptHash
+	s.subscriptionsLock.Unlock()
+	var st interface{}
+	if status != "" {
+		st = status
+	}
+	s.notify(conns, "blockchain.scripthash.subscribe", scriptHash, st)
+}
+
+func (s *ElectrumServer) updateSubscriptions() {
+	s.updateSubscriptionsLock.Lock()
+	defer s.updateSubscriptionsLock.Unlock()
+	s.subscriptionsLock.Lock()
+	keys := make([]string, 0, len(s.subscriptions))
+	for k := range s.subscriptions {
+		keys = append(keys, k)
+	}
+	s.subscriptionsLock.Unlock()
+	for _, k := range keys {
+		s.updateSubscription([]byte(k))
+	}
+}
+
+// OnNewBlock is a callback that notifies the clients subscribed to headers and updates the status of subscribed script hashes
+func (s *ElectrumServer) OnNewBlock(hash string, height uint32) {
+	s.subscriptionsLock.Lock()
+	conns := make([]*electrumConn, 0, len(s.headersSubscriptions))
+	for c := range s.headersSubscriptions {
+		conns = append(conns, c)
+	}
+	s.subscriptionsLock.Unlock()
+	if len(conns) > 0 {
+		h, err := s.blockHeaderByHash(hash)
+		if err != nil {
+			glog.Error("electrum server: header of block ", height, " ", hash, ": ", err)
+		} else {
+			s.notify(conns, "blockchain.headers.subscribe", &electrumHeader{Height: height, Hex: h})
+			glog.Info("electrum server: broadcasting new block ", height, " ", hash, " to ", len(conns), " clients")
+		}
+	}
+	s.mempoolScriptHashesLock.Lock()
+	for k, m := range s.mempoolScriptHashes {
+		if time.Since(m.seen) > electrumMempoolScriptHashExpiration {
+			delete(s.mempoolScriptHashes, k)
+		}
+	}
+	s.mempoolScriptHashesLock.Unlock()
+	// the confirmation of transactions changes the status of many script hashes, do not block the sync
+	go s.updateSubscriptions()
+}
+
+// OnNewTxAddr is a callback that updates the status of the script hash of the address affected by a new mempool transaction
+func (s *ElectrumServer) OnNewTxAddr(tx *bchain.Tx, addrDesc bchain.AddressDescriptor) {
+	h := sha256.Sum256(addrDesc)
+	ad, err := s.db.GetAddrDescFromScriptHash(h[:])
+	if err != nil {
+		glog.Error("electrum server: GetAddrDescFromScriptHash ", addrDesc, ": ", err)
+	} else if ad == nil {
+		s.mempoolScriptHashesLock.Lock()
+		s.mempoolScriptHashes[string(h[:])] = electrumMempoolScriptHash{addrDesc: addrDesc, seen: time.Now()}
+		s.mempoolScriptHashesLock.Unlock()
+	}
+	s.updateSubscription(h[:])
+}
@@ -0,0 +1,106 @@
+// +build unittest
+
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func Test_electrumBlockHeader(t *testing.T) {
+	// bitcoin genesis block
+	bi := &bchain.BlockInfo{
+		BlockHeader: bchain.BlockHeader{
+			Hash: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			Time: 1231006505,
+		},
+		Version:    "1",
+		MerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		Nonce:      "2083236893",
+		Bits:       "1d00ffff",
+	}
+	want := "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c"
+	got, err := electrumBlockHeader(bi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("electrumBlockHeader() = %v, want %v", got, want)
+	}
+	b, _ := hex.DecodeString(got)
+	h := sha256.Sum256(b)
+	h = sha256.Sum256(h[:])
+	reverseBytes(h[:])
+	if hex.EncodeToString(h[:]) != bi.Hash {
+		t.Errorf("hash of electrumBlockHeader() = %x, want %v", h, bi.Hash)
+	}
+	bi.Bits = "xyz"
+	if _, err := electrumBlockHeader(bi); err == nil {
+		t.Error("electrumBlockHeader() expected error for invalid bits")
+	}
+}
+
+func Test_electrumScriptHash(t *testing.T) {
+	// the example from the electrum protocol documentation, address 1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa
+	addrDesc, _ := hex.DecodeString("76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac")
+	want := "8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161"
+	if got := electrumScriptHash(addrDesc); got != want {
+		t.Errorf("electrumScriptHash() = %v, want %v", got, want)
+	}
+	key, err := electrumScriptHashToKey(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := sha256.Sum256(addrDesc); string(key) != string(h[:]) {
+		t.Errorf("electrumScriptHashToKey() = %x, want %x", key, h)
+	}
+	for _, sh := range []string{"", "8b01df4e", "zz01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161"} {
+		if _, err := electrumScriptHashToKey(sh); err == nil {
+			t.Errorf("electrumScriptHashToKey(%v) expected error", sh)
+		}
+	}
+}
+
+func Test_electrumStatus(t *testing.T) {
+	if got := electrumStatus(&api.AddrDescHistory{}); got != "" {
+		t.Errorf("electrumStatus() of empty history = %v, want empty", got)
+	}
+	h := &api.AddrDescHistory{
+		Confirmed:   []api.AddrDescTx{{Txid: "aa", Height: 100}, {Txid: "bb", Height: 101}},
+		Unconfirmed: []api.AddrDescTx{{Txid: "cc"}},
+	}
+	s := sha256.Sum256([]byte("aa:100:bb:101:cc:0:"))
+	if got, want := electrumStatus(h), hex.EncodeToString(s[:]); got != want {
+		t.Errorf("electrumStatus() = %v, want %v", got, want)
+	}
+}
+
+func Test_unmarshalElectrumParams(t *testing.T) {
+	var txid string
+	verbose := true
+	if err := unmarshalElectrumParams(json.RawMessage(`["abcd"]`), &txid, &verbose); err != nil {
+		t.Fatal(err)
+	}
+	if txid != "abcd" || !verbose {
+		t.Errorf("unmarshalElectrumParams() = %v %v, want abcd true", txid, verbose)
+	}
+	if err := unmarshalElectrumParams(json.RawMessage(`["ef", false, 1]`), &txid, &verbose); err != nil {
+		t.Fatal(err)
+	}
+	if txid != "ef" || verbose {
+		t.Errorf("unmarshalElectrumParams() = %v %v, want ef false", txid, verbose)
+	}
+	if err := unmarshalElectrumParams(nil, &txid); err != nil {
+		t.Errorf("unmarshalElectrumParams() of missing params error %v", err)
+	}
+	if err := unmarshalElectrumParams(json.RawMessage(`{"txid":"ab"}`), &txid); err == nil {
+		t.Error("unmarshalElectrumParams() expected error for named params")
+	}
+	if err := unmarshalElectrumParams(json.RawMessage(`[1]`), &txid); err == nil {
+		t.Error("unmarshalElectrumParams() expected error for param of wrong type")
+	}
+}
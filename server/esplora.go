@@ -0,0 +1,535 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/common"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// The subset of the Esplora REST API (https://github.com/Blockstream/esplora/blob/master/API.md) for BitcoinType coins,
+// served under an optional path prefix of the public server. The responses are built from api.Worker.
+// Differences from Esplora: the spending transactions in outspends are looked up only in the index (not in mempool)
+// and all unconfirmed transactions are returned, not only those with the confirmed inputs.
+
+const esploraTxsChainPage = 25
+const esploraTxsMempoolMax = 50
+const esploraFeeEstimatesCacheTime = time.Minute
+
+// max size of the body of the broadcast request, the hex of the largest standard transaction (400000 weight units) fits in it
+const esploraMaxBroadcastSize = 1024 * 1024
+
+// esploraFeeTargets are the confirmation targets of the fee-estimates endpoint
+var esploraFeeTargets = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 144, 504, 1008}
+
+var errEsploraNotFound = errors.New("Not found")
+var errEsploraTooLarge = errors.New("Request is too large")
+
+const esploraCoinbaseTxid = "0000000000000000000000000000000000000000000000000000000000000000"
+
+type esploraStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight uint32 `json:"block_height,omitempty"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockTime   int64  `json:"block_time,omitempty"`
+}
+
+type esploraVout struct {
+	ScriptPubKey        string      `json:"scriptpubkey"`
+	ScriptPubKeyAsm     string      `json:"scriptpubkey_asm,omitempty"`
+	ScriptPubKeyType    string      `json:"scriptpubkey_type"`
+	ScriptPubKeyAddress string      `json:"scriptpubkey_address,omitempty"`
+	Value               json.Number `json:"value"`
+}
+
+type esploraVin struct {
+	Txid         string       `json:"txid"`
+	Vout         uint32       `json:"vout"`
+	Prevout      *esploraVout `json:"prevout"`
+	ScriptSig    string       `json:"scriptsig"`
+	ScriptSigAsm string       `json:"scriptsig_asm,omitempty"`
+	Witness      []string     `json:"witness,omitempty"`
+	IsCoinbase   bool         `json:"is_coinbase"`
+	Sequence     int64        `json:"sequence"`
+}
+
+type esploraTx struct {
+	Txid     string        `json:"txid"`
+	Version  int32         `json:"version"`
+	Locktime uint32        `json:"locktime"`
+	Vin      []esploraVin  `json:"vin"`
+	Vout     []esploraVout `json:"vout"`
+	Size     int           `json:"size"`
+	Weight   int           `json:"weight"`
+	Fee      json.Number   `json:"fee"`
+	Status   esploraStatus `json:"status"`
+}
+
+type esploraOutspend struct {
+	Spent  bool           `json:"spent"`
+	Txid   string         `json:"txid,omitempty"`
+	Vin    *int           `json:"vin,omitempty"`
+	Status *esploraStatus `json:"status,omitempty"`
+}
+
+type esploraUtxo struct {
+	Txid   string        `json:"txid"`
+	Vout   int32         `json:"vout"`
+	Status esploraStatus `json:"status"`
+	Value  json.Number   `json:"value"`
+}
+
+// esploraHandler serves the Esplora API requests with the path starting with prefix
+// the responses are either json or plain text, the errors are always plain text
+func (s *PublicServer) esploraHandler(prefix string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := s.rateLimiter.allow("http", s.rateLimiter.getClient(r.RemoteAddr, r.Header, r.URL.Query())); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		var data interface{}
+		var err error
+		defer func() {
+			if e := recover(); e != nil {
+				glog.Error("esplora ", r.URL.Path, " recovered from panic: ", e)
+				debug.PrintStack()
+				if s.debug {
+					err = errors.New(fmt.Sprint("Internal server error: recovered from panic ", e))
+				} else {
+					err = errors.New("Internal server error")
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err != nil {
+				status := http.StatusInternalServerError
+				text := "Internal server error"
+				if err == errEsploraNotFound {
+					status = http.StatusNotFound
+					text = err.Error()
+				} else if err == errEsploraTooLarge {
+					status = http.StatusRequestEntityTooLarge
+					text = err.Error()
+				} else if apiErr, ok := err.(*api.APIError); ok && apiErr.Public {
+					status = http.StatusBadRequest
+					text = apiErr.Error()
+				} else {
+					glog.Error("esplora ", r.URL.Path, " error: ", err)
+					if s.debug {
+						text = fmt.Sprintf("Internal server error: %v", err)
+					}
+				}
+				http.Error(w, text, status)
+				return
+			}
+			if t, ok := data.(string); ok {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.Write([]byte(t))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(data)
+		}()
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, esploraMaxBroadcastSize)
+		}
+		p := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+		var action string
+		action, data, err = s.esploraRequest(r, p)
+		if action != "" {
+			s.metrics.ExplorerViews.With(common.Labels{"action": "esplora-" + action}).Inc()
+		}
+	}
+}
+
+// esploraRequest dispatches the request with path p to the Esplora endpoints, returns the name of the endpoint for the metrics
+func (s *PublicServer) esploraRequest(r *http.Request, p []string) (string, interface{}, error) {
+	if r.Method == http.MethodPost {
+		if len(p) == 1 && p[0] == "tx" {
+			d, err := s.esploraBroadcast(r)
+			return "broadcast", d, err
+		}
+		return "", nil, errEsploraNotFound
+	}
+	switch {
+	case len(p) == 3 && p[0] == "blocks" && p[1] == "tip" && p[2] == "height":
+		height, _, err := s.db.GetBestBlock()
+		return "tip-height", strconv.FormatUint(uint64(height), 10), err
+	case len(p) == 3 && p[0] == "blocks" && p[1] == "tip" && p[2] == "hash":
+		_, hash, err := s.db.GetBestBlock()
+		return "tip-hash", hash, err
+	case len(p) == 2 && p[0] == "block-height":
+		d, err := s.esploraBlockHeight(p[1])
+		return "block-height", d, err
+	case len(p) == 1 && p[0] == "fee-estimates":
+		d, err := s.esploraFeeEstimates()
+		return "fee-estimates", d, err
+	case len(p) >= 2 && p[0] == "tx":
+		return s.esploraTxRequest(p[1], p[2:])
+	case len(p) >= 3 && p[0] == "address":
+		return s.esploraAddressRequest(p[1], p[2:])
+	}
+	return "", nil, errEsploraNotFound
+}
+
+func (s *PublicServer) esploraTxRequest(txid string, p []string) (string, interface{}, error) {
+	switch {
+	case len(p) == 0:
+		tx, err := s.api.GetTransaction(txid, false, false)
+		if err != nil {
+			return "tx", nil, err
+		}
+		return "tx", s.esploraTx(tx), nil
+	case len(p) == 1 && p[0] == "status":
+		tx, err := s.api.GetTransaction(txid, false, false)
+		if err != nil {
+			return "tx-status", nil, err
+		}
+		return "tx-status", esploraTxStatus(tx), nil
+	case len(p) == 1 && p[0] == "hex":
+		tx, err := s.api.GetTransaction(txid, false, false)
+		if err != nil {
+			return "tx-hex", nil, err
+		}
+		return "tx-hex", tx.Hex, nil
+	case len(p) == 1 && p[0] == "outspends":
+		d, err := s.esploraOutspends(txid, -1)
+		return "tx-outspends", d, err
+	case len(p) == 2 && p[0] == "outspend":
+		vout, err := strconv.Atoi(p[1])
+		if err != nil || vout < 0 {
+			return "tx-outspend", nil, api.NewAPIError("Invalid output index", true)
+		}
+		d, err := s.esploraOutspends(txid, vout)
+		if err != nil {
+			return "tx-outspend", nil, err
+		}
+		return "tx-outspend", d[0], nil
+	}
+	return "", nil, errEsploraNotFound
+}
+
+func (s *PublicServer) esploraAddressRequest(address string, p []string) (string, interface{}, error) {
+	switch {
+	case len(p) == 1 && p[0] == "txs":
+		d, err := s.esploraAddressTxs(address, true, true, "")
+		return "address-txs", d, err
+	case len(p) >= 2 && len(p) <= 3 && p[0] == "txs" && p[1] == "chain":
+		var lastSeen string
+		if len(p) == 3 {
+			lastSeen = p[2]
+		}
+		d, err := s.esploraAddressTxs(address, false, true, lastSeen)
+		return "address-txs", d, err
+	case len(p) == 2 && p[0] == "txs" && p[1] == "mempool":
+		d, err := s.esploraAddressTxs(address, true, false, "")
+		return "address-txs", d, err
+	case len(p) == 1 && p[0] == "utxo":
+		d, err := s.esploraAddressUtxo(address)
+		return "address-utxo", d, err
+	}
+	return "", nil, errEsploraNotFound
+}
+
+func (s *PublicServer) esploraBlockHeight(h string) (interface{}, error) {
+	height, err := strconv.ParseUint(h, 10, 32)
+	if err != nil {
+		return nil, api.NewAPIError("Invalid block height", true)
+	}
+	hash, err := s.db.GetBlockHash(uint32(height))
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, errEsploraNotFound
+	}
+	return hash, nil
+}
+
+func (s *PublicServer) esploraBroadcast(r *http.Request) (interface{}, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		// the body is limited by http.MaxBytesReader, which fails after reading esploraMaxBroadcastSize bytes
+		if len(data) == esploraMaxBroadcastSize {
+			return nil, errEsploraTooLarge
+		}
+		return nil, api.NewAPIError("Missing tx blob", true)
+	}
+	if len(data) == 0 {
+		return nil, api.NewAPIError("Missing tx blob", true)
+	}
+	txid, err := s.chain.SendRawTransaction(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, api.NewAPIError(err.Error(), true)
+	}
+	return txid, nil
+}
+
+// esploraFeeEstimates returns the estimated fee rates in sat/vB by confirmation target, the estimates are cached
+func (s *PublicServer) esploraFeeEstimates() (interface{}, error) {
+	s.esploraFeeEstimatesLock.Lock()
+	defer s.esploraFeeEstimatesLock.Unlock()
+	if s.esploraFeeEstimatesCache != nil && time.Since(s.esploraFeeEstimatesTime) < esploraFeeEstimatesCacheTime {
+		return s.esploraFeeEstimatesCache, nil
+	}
+	r := make(map[string]float64, len(esploraFeeTargets))
+	for _, blocks := range esploraFeeTargets {
+		fee, err := s.chain.EstimateSmartFee(blocks, true)
+		if err != nil {
+			glog.Error("esplora EstimateSmartFee ", blocks, ": ", err)
+			continue
+		}
+		if fee.Sign() <= 0 {
+			continue
+		}
+		// the fee is in satoshis per kB
+		f, _ := new(big.Float).SetInt(&fee).Float64()
+		r[strconv.Itoa(blocks)] = f / 1000
+	}
+	s.esploraFeeEstimatesCache = r
+	s.esploraFeeEstimatesTime = time.Now()
+	return r, nil
+}
+
+func (s *PublicServer) esploraAddressTxs(address string, mempool, confirmed bool, lastSeen string) (interface{}, error) {
+	var txids []string
+	if mempool {
+		m, err := s.api.GetAddressTxids(address, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(m) > esploraTxsMempoolMax {
+			m = m[:esploraTxsMempoolMax]
+		}
+		txids = append(txids, m...)
+	}
+	if confirmed {
+		c, err := s.api.GetAddressTxids(address, false)
+		if err != nil {
+			return nil, err
+		}
+		if lastSeen != "" {
+			i := 0
+			for ; i < len(c); i++ {
+				if c[i] == lastSeen {
+					break
+				}
+			}
+			// i is len(c) if lastSeen is not in the list
+			c = c[minInt(i+1, len(c)):]
+		}
+		if len(c) > esploraTxsChainPage {
+			c = c[:esploraTxsChainPage]
+		}
+		txids = append(txids, c...)
+	}
+	r := make([]*esploraTx, 0, len(txids))
+	for _, txid := range txids {
+		tx, err := s.api.GetTransaction(txid, false, false)
+		if err != nil {
+			// mempool transactions may disappear
+			glog.Error("esplora GetTransaction ", txid, ": ", err)
+			continue
+		}
+		r = append(r, s.esploraTx(tx))
+	}
+	return r, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (s *PublicServer) esploraAddressUtxo(address string) (interface{}, error) {
+	utxos, err := s.api.GetAddressUtxo(address, false)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]esploraUtxo, len(utxos))
+	for i := range utxos {
+		u := &utxos[i]
+		r[i] = esploraUtxo{
+			Txid:   u.Txid,
+			Vout:   u.Vout,
+			Status: s.esploraStatusAtHeight(u.Height),
+			Value:  esploraAmount(u.AmountSat),
+		}
+	}
+	return r, nil
+}
+
+// esploraOutspends returns the spending status of the outputs of the transaction, all outputs if vout is negative
+func (s *PublicServer) esploraOutspends(txid string, vout int) ([]esploraOutspend, error) {
+	tx, err := s.api.GetTransaction(txid, true, false)
+	if err != nil {
+		return nil, err
+	}
+	vouts := tx.Vout
+	if vout >= 0 {
+		if vout >= len(tx.Vout) {
+			return nil, api.NewAPIError("Invalid output index", true)
+		}
+		vouts = tx.Vout[vout : vout+1]
+	}
+	r := make([]esploraOutspend, len(vouts))
+	for i := range vouts {
+		v := &vouts[i]
+		r[i].Spent = v.Spent
+		if v.SpentTxID != "" {
+			vin := v.SpentIndex
+			st := s.esploraStatusAtHeight(v.SpentHeight)
+			r[i].Txid = v.SpentTxID
+			r[i].Vin = &vin
+			r[i].Status = &st
+		}
+	}
+	return r, nil
+}
+
+func (s *PublicServer) esploraStatusAtHeight(height int) esploraStatus {
+	if height <= 0 {
+		return esploraStatus{}
+	}
+	st := esploraStatus{Confirmed: true, BlockHeight: uint32(height)}
+	bi, err := s.db.GetBlockInfo(uint32(height))
+	if err != nil {
+		glog.Error("esplora GetBlockInfo ", height, ": ", err)
+	} else if bi != nil {
+		st.BlockHash = bi.Hash
+		st.BlockTime = bi.Time
+	}
+	return st
+}
+
+func esploraTxStatus(tx *api.Tx) esploraStatus {
+	if tx.Confirmations == 0 {
+		return esploraStatus{}
+	}
+	return esploraStatus{
+		Confirmed:   true,
+		BlockHeight: uint32(tx.Blockheight),
+		BlockHash:   tx.Blockhash,
+		BlockTime:   tx.Blocktime,
+	}
+}
+
+func esploraAmount(a *api.Amount) json.Number {
+	if a == nil {
+		return json.Number("0")
+	}
+	return json.Number(a.String())
+}
+
+func (s *PublicServer) esploraVout(script string, addresses []string, searchable bool, value *api.Amount) *esploraVout {
+	v := &esploraVout{
+		ScriptPubKey: script,
+		Value:        esploraAmount(value),
+	}
+	b, err := hex.DecodeString(script)
+	if err == nil {
+		v.ScriptPubKeyType = esploraScriptType(b)
+	} else {
+		v.ScriptPubKeyType = "unknown"
+	}
+	if searchable && len(addresses) == 1 {
+		v.ScriptPubKeyAddress = addresses[0]
+	}
+	return v
+}
+
+// esploraTx converts the transaction to the Esplora format
+// the witness and the weight are available only if the raw transaction can be parsed in the bitcoin format
+func (s *PublicServer) esploraTx(tx *api.Tx) *esploraTx {
+	r := &esploraTx{
+		Txid:     tx.Txid,
+		Version:  tx.Version,
+		Locktime: tx.Locktime,
+		Vin:      make([]esploraVin, len(tx.Vin)),
+		Vout:     make([]esploraVout, len(tx.Vout)),
+		Size:     len(tx.Hex) / 2,
+		Fee:      esploraAmount(tx.FeesSat),
+		Status:   esploraTxStatus(tx),
+	}
+	r.Weight = r.Size * 4
+	var msgTx *wire.MsgTx
+	if b, err := hex.DecodeString(tx.Hex); err == nil && len(b) > 0 {
+		msgTx = wire.NewMsgTx(wire.TxVersion)
+		if err = msgTx.Deserialize(bytes.NewReader(b)); err != nil || len(msgTx.TxIn) != len(tx.Vin) {
+			msgTx = nil
+		} else {
+			r.Weight = msgTx.SerializeSizeStripped()*3 + msgTx.SerializeSize()
+		}
+	}
+	for i := range tx.Vin {
+		vin := &tx.Vin[i]
+		v := &r.Vin[i]
+		v.Txid = vin.Txid
+		v.Vout = vin.Vout
+		v.ScriptSig = vin.Hex
+		v.ScriptSigAsm = vin.Asm
+		v.Sequence = vin.Sequence
+		if vin.Txid == "" {
+			v.IsCoinbase = true
+			v.Txid = esploraCoinbaseTxid
+			v.Vout = ^uint32(0)
+		} else {
+			v.Prevout = s.esploraVout(hex.EncodeToString(vin.AddrDesc), vin.Addresses, vin.Searchable, vin.ValueSat)
+		}
+		if msgTx != nil && len(msgTx.TxIn[i].Witness) > 0 {
+			v.Witness = make([]string, len(msgTx.TxIn[i].Witness))
+			for j, w := range msgTx.TxIn[i].Witness {
+				v.Witness[j] = hex.EncodeToString(w)
+			}
+		}
+	}
+	for i := range tx.Vout {
+		vout := &tx.Vout[i]
+		r.Vout[i] = *s.esploraVout(vout.Hex, vout.Addresses, vout.Searchable, vout.ValueSat)
+		r.Vout[i].ScriptPubKeyAsm = vout.Asm
+	}
+	return r
+}
+
+// esploraScriptType returns the type of the output script as named by Esplora
+func esploraScriptType(script []byte) string {
+	l := len(script)
+	switch {
+	case l == 0:
+		return "empty"
+	case script[0] == 0x6a:
+		return "op_return"
+	case l == 25 && script[0] == 0x76 && script[1] == 0xa9 && script[2] == 0x14 && script[23] == 0x88 && script[24] == 0xac:
+		return "p2pkh"
+	case l == 23 && script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87:
+		return "p2sh"
+	case l == 22 && script[0] == 0x00 && script[1] == 0x14:
+		return "v0_p2wpkh"
+	case l == 34 && script[0] == 0x00 && script[1] == 0x20:
+		return "v0_p2wsh"
+	case l == 34 && script[0] == 0x51 && script[1] == 0x20:
+		return "v1_p2tr"
+	case (l == 35 && script[0] == 0x21 || l == 67 && script[0] == 0x41) && script[l-1] == 0xac:
+		return "p2pk"
+	case script[l-1] == 0xae:
+		return "multisig"
+	}
+	return "unknown"
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/common"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// number of exported transactions after which the output is flushed to the client
+const exportFlushTxs = 100
+
+var exportCSVHeader = []string{"txid", "blockHash", "blockHeight", "blockTime", "received", "sent", "fees", "netValue", "balance"}
+
+// exportEnd is the last record of the export, an export without it is incomplete
+type exportEnd struct {
+	Complete bool   `json:"complete"`
+	Txs      int    `json:"txs"`
+	Error    string `json:"error,omitempty"`
+}
+
+// addressExportHandler serves the export of the address history at the path address/<address>/export,
+// the other requests are passed to addressHandler
+func (s *PublicServer) addressExportHandler(addressHandler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/export") {
+			s.apiAddressExport(w, r)
+			return
+		}
+		addressHandler(w, r)
+	}
+}
+
+func (s *PublicServer) writeExportError(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": text})
+}
+
+// apiAddressExport streams the confirmed transactions of the address as csv or json lines
+func (s *PublicServer) apiAddressExport(w http.ResponseWriter, r *http.Request) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-address-export"}).Inc()
+	if ok, wait := s.rateLimiter.allow("http", s.rateLimiter.getClient(r.RemoteAddr, r.Header, r.URL.Query())); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		s.writeExportError(w, http.StatusTooManyRequests, "Too many requests")
+		return
+	}
+	e := findAPIEndpoint("address-export", r.Method)
+	if e == nil {
+		s.writeExportError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	_, address := splitAPIPath(r.URL.Path)
+	err := validateAPIParams(e, r, address)
+	if err == nil && address == "" {
+		err = api.NewAPIError("Missing parameter 'address'", true)
+	}
+	if err != nil {
+		s.writeExportError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	filter := &api.AddressFilter{Vout: api.AddressFilterVoutOff}
+	if from, ec := strconv.Atoi(q.Get("from")); ec == nil && from > 0 {
+		filter.FromHeight = uint32(from)
+	}
+	if to, ec := strconv.Atoi(q.Get("to")); ec == nil && to > 0 {
+		filter.ToHeight = uint32(to)
+	}
+	jsonl := q.Get("format") == "jsonl"
+	// the response is started by the first transaction so that the errors of the request can be returned with a proper status
+	var out *bufio.Writer
+	var cw *csv.Writer
+	var enc *json.Encoder
+	started := false
+	startResponse := func() {
+		if jsonl {
+			w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.jsonl\"", address))
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", address))
+		}
+		w.WriteHeader(http.StatusOK)
+		out = bufio.NewWriter(w)
+		if jsonl {
+			enc = json.NewEncoder(out)
+		} else {
+			cw = csv.NewWriter(out)
+			cw.Write(exportCSVHeader)
+		}
+		started = true
+	}
+	flush := func() error {
+		if cw != nil {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+	count := 0
+	// the export is ended by the completion record, or by the error record if it fails after the response was started
+	writeEnd := func(errText string) {
+		if jsonl {
+			enc.Encode(exportEnd{Complete: errText == "", Txs: count, Error: errText})
+		} else if errText == "" {
+			cw.Write([]string{"#complete", strconv.Itoa(count)})
+		} else {
+			cw.Write([]string{"#error", strconv.Itoa(count), errText})
+		}
+	}
+	err = s.api.ExportAddressHistory(address, filter, func(tx *api.AddressExportTx) error {
+		if !started {
+			startResponse()
+		}
+		if jsonl {
+			if err := enc.Encode(tx); err != nil {
+				return err
+			}
+		} else {
+			cw.Write([]string{
+				tx.Txid,
+				tx.Blockhash,
+				strconv.Itoa(tx.Blockheight),
+				time.Unix(tx.Blocktime, 0).UTC().Format(time.RFC3339),
+				tx.ReceivedSat.String(),
+				tx.SentSat.String(),
+				tx.FeesSat.String(),
+				tx.NetValueSat.String(),
+				tx.BalanceSat.String(),
+			})
+		}
+		count++
+		if count%exportFlushTxs == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		text := err.Error()
+		if apiErr, ok := err.(*api.APIError); !ok || !apiErr.Public {
+			glog.Error("apiAddressExport ", address, " error after ", count, " txs: ", err)
+			status = http.StatusInternalServerError
+			if s.debug {
+				text = fmt.Sprintf("Internal server error: %v", err)
+			} else {
+				text = "Internal server error"
+			}
+		}
+		if !started {
+			s.writeExportError(w, status, text)
+			return
+		}
+		// the status was already sent, the error is reported by the last record
+		writeEnd(text)
+	} else {
+		if !started {
+			startResponse()
+		}
+		writeEnd("")
+	}
+	if err = flush(); err != nil {
+		glog.Error("apiAddressExport ", address, " error: ", err)
+	}
+}
@@ -0,0 +1,1017 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/common"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+const (
+	// max size of the graphql request
+	graphQLMaxRequestSize = 64 * 1024
+	// max nesting of the fields in a query
+	graphQLMaxDepth = 12
+	// max cost of a query, each call of the api worker costs 1 and each loaded transaction costs 1
+	graphQLMaxCost = 1000
+	// max number of selections checked by the validation of a query
+	graphQLMaxSelections   = 5000
+	graphQLDefaultPageSize = 25
+)
+
+// The graphql schema mirrors the api types, the object types and their fields are derived from the json tags of the types.
+// Some object types are extended by fields, which are resolved lazily by the api worker,
+// so that nested data (for example address -> transactions -> spending transactions -> block) can be fetched by a single query.
+
+type gqlKind int
+
+const (
+	gqlKindScalar gqlKind = iota
+	gqlKindObject
+	gqlKindList
+)
+
+type gqlType struct {
+	kind   gqlKind
+	name   string
+	elem   *gqlType
+	fields map[string]*gqlField
+	order  []string
+}
+
+func (t *gqlType) String() string {
+	if t.kind == gqlKindList {
+		return "[" + t.elem.String() + "]"
+	}
+	return t.name
+}
+
+type gqlArgument struct {
+	name     string
+	typ      string
+	defValue interface{}
+}
+
+type gqlResolver func(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error)
+
+type gqlField struct {
+	name string
+	typ  *gqlType
+	args []gqlArgument
+	// index of the field in the struct of the parent object, used if resolve is nil
+	index   []int
+	resolve gqlResolver
+}
+
+type gqlSchema struct {
+	query   *gqlType
+	types   map[string]*gqlType
+	goTypes map[reflect.Type]*gqlType
+}
+
+var (
+	gqlString  = &gqlType{kind: gqlKindScalar, name: "String"}
+	gqlInt     = &gqlType{kind: gqlKindScalar, name: "Int"}
+	gqlFloat   = &gqlType{kind: gqlKindScalar, name: "Float"}
+	gqlBoolean = &gqlType{kind: gqlKindScalar, name: "Boolean"}
+	// gqlJSON is used for the data without fixed structure, the value is returned as is
+	gqlJSON = &gqlType{kind: gqlKindScalar, name: "JSON"}
+)
+
+func newGraphQLSchema() *gqlSchema {
+	s := &gqlSchema{
+		types:   make(map[string]*gqlType),
+		goTypes: make(map[reflect.Type]*gqlType),
+	}
+	for _, t := range []*gqlType{gqlString, gqlInt, gqlFloat, gqlBoolean, gqlJSON} {
+		s.types[t.name] = t
+	}
+	tx := s.typeOf(reflect.TypeOf(api.Tx{}))
+	vin := s.typeOf(reflect.TypeOf(api.Vin{}))
+	vout := s.typeOf(reflect.TypeOf(api.Vout{}))
+	block := s.typeOf(reflect.TypeOf(api.Block{}))
+	address := s.typeOf(reflect.TypeOf(api.Address{}))
+	info := s.typeOf(reflect.TypeOf(api.BlockbookInfo{}))
+	pageArgs := []gqlArgument{
+		{name: "page", typ: "Int", defValue: 1},
+		{name: "pageSize", typ: "Int", defValue: graphQLDefaultPageSize},
+	}
+	addGQLField(vin, &gqlField{name: "tx", typ: tx, resolve: gqlResolveVinTx})
+	addGQLField(vout, &gqlField{name: "spendingTx", typ: tx, resolve: gqlResolveSpendingTx})
+	addGQLField(tx, &gqlField{name: "block", typ: block, args: pageArgs, resolve: gqlResolveTxBlock})
+	s.query = &gqlType{kind: gqlKindObject, name: "Query", fields: make(map[string]*gqlField)}
+	s.types[s.query.name] = s.query
+	addGQLField(s.query, &gqlField{name: "info", typ: info, resolve: gqlResolveInfo})
+	addGQLField(s.query, &gqlField{
+		name: "address",
+		typ:  address,
+		args: append([]gqlArgument{{name: "address", typ: "String!"}}, append(pageArgs,
+			gqlArgument{name: "from", typ: "Int"},
+			gqlArgument{name: "to", typ: "Int"},
+			gqlArgument{name: "cursor", typ: "String"},
+		)...),
+		resolve: gqlResolveAddress,
+	})
+	addGQLField(s.query, &gqlField{name: "tx", typ: tx, args: []gqlArgument{{name: "txid", typ: "String!"}}, resolve: gqlResolveTx})
+	addGQLField(s.query, &gqlField{
+		name:    "block",
+		typ:     block,
+		args:    append([]gqlArgument{{name: "id", typ: "String!"}}, pageArgs...),
+		resolve: gqlResolveBlock,
+	})
+	return s
+}
+
+func addGQLField(t *gqlType, f *gqlField) {
+	if _, ok := t.fields[f.name]; !ok {
+		t.order = append(t.order, f.name)
+	}
+	t.fields[f.name] = f
+}
+
+// typeOf returns the graphql type of the go type, the object types are created from structs on the first use
+func (s *gqlSchema) typeOf(t reflect.Type) *gqlType {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case amountType, bigIntType, timeType, numberType:
+		return gqlString
+	case rawMessageType:
+		return gqlJSON
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return gqlBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gqlInt
+	case reflect.Float32, reflect.Float64:
+		return gqlFloat
+	case reflect.String:
+		return gqlString
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return gqlString
+		}
+		return &gqlType{kind: gqlKindList, elem: s.typeOf(t.Elem())}
+	case reflect.Struct:
+		if gt, ok := s.goTypes[t]; ok {
+			return gt
+		}
+		gt := &gqlType{kind: gqlKindObject, name: t.Name(), fields: make(map[string]*gqlField)}
+		s.goTypes[t] = gt
+		s.types[gt.name] = gt
+		s.addStructFields(gt, t, nil)
+		return gt
+	}
+	return gqlJSON
+}
+
+func (s *gqlSchema) addStructFields(gt *gqlType, t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name = tag[:j]
+		}
+		fi := append(append([]int{}, index...), i)
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// fields of embedded structs without json name are promoted
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.addStructFields(gt, ft, fi)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := gt.fields[name]; ok {
+			continue
+		}
+		addGQLField(gt, &gqlField{name: name, typ: s.typeOf(f.Type), index: fi})
+	}
+}
+
+// sdl returns the schema in the graphql schema definition language
+func (s *gqlSchema) sdl() string {
+	var b strings.Builder
+	names := make([]string, 0, len(s.types))
+	for n, t := range s.types {
+		if t.kind == gqlKindObject && t != s.query {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	b.WriteString("scalar JSON\n")
+	for _, n := range append([]string{s.query.name}, names...) {
+		t := s.types[n]
+		b.WriteString("\ntype " + t.name + " {\n")
+		for _, fn := range t.order {
+			f := t.fields[fn]
+			b.WriteString("  " + f.name)
+			if len(f.args) > 0 {
+				args := make([]string, len(f.args))
+				for i, a := range f.args {
+					args[i] = a.name + ": " + a.typ
+					if a.defValue != nil {
+						args[i] += " = " + fmt.Sprint(a.defValue)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.typ.String() + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// gqlObject is an object of the response, it keeps the order of the fields of the query
+type gqlObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *gqlObject) add(key string, value interface{}) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+// MarshalJSON marshals the fields of the object in the order of the query
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kb)
+		b.WriteByte(':')
+		vb, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type gqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+type gqlResponse struct {
+	Data   *gqlObject `json:"data,omitempty"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+type gqlCollectedField struct {
+	key  string
+	sels []*gqlSelection
+}
+
+type gqlExecutor struct {
+	schema    *gqlSchema
+	worker    *api.Worker
+	debug     bool
+	fragments map[string]*gqlFragment
+	variables map[string]interface{}
+	cost      int
+	maxCost   int
+	// validated contains the fragments already validated for the parent type and depth, selections counts the validated selections
+	validated  map[string]bool
+	selections int
+	// aborted is set when the cost of the query exceeds maxCost, the execution is stopped
+	aborted bool
+	errors  []gqlError
+	path    []interface{}
+	// parents is the stack of the objects being resolved
+	parents []interface{}
+}
+
+// execute runs the query of the request, it returns the response and the http status
+func (s *gqlSchema) execute(worker *api.Worker, req *gqlRequest, debug bool) (*gqlResponse, int) {
+	fail := func(err error) (*gqlResponse, int) {
+		return &gqlResponse{Errors: []gqlError{{Message: err.Error()}}}, http.StatusBadRequest
+	}
+	if req.Query == "" {
+		return fail(api.NewAPIError("Missing query", true))
+	}
+	if len(req.Query) > graphQLMaxRequestSize {
+		return fail(api.NewAPIError("Query is too large", true))
+	}
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		return fail(err)
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return fail(err)
+	}
+	e := &gqlExecutor{
+		schema:    s,
+		worker:    worker,
+		debug:     debug,
+		fragments: doc.fragments,
+		maxCost:   graphQLMaxCost,
+		validated: make(map[string]bool),
+	}
+	if e.variables, err = coerceGQLVariables(op, req.Variables); err != nil {
+		return fail(err)
+	}
+	if err = e.validate(s.query, op.selection, 1, make(map[string]bool)); err != nil {
+		return fail(err)
+	}
+	data := e.executeSelection(s.query, nil, op.selection)
+	r := &gqlResponse{Errors: e.errors}
+	if !e.aborted {
+		r.Data = data
+	}
+	return r, http.StatusOK
+}
+
+func (doc *gqlDocument) operation(name string) (*gqlOperation, error) {
+	var op *gqlOperation
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, api.NewAPIError("Must provide operation name if query contains multiple operations", true)
+		}
+		op = doc.operations[0]
+	} else {
+		for _, o := range doc.operations {
+			if o.name == name {
+				op = o
+				break
+			}
+		}
+		if op == nil {
+			return nil, api.NewAPIError(fmt.Sprintf("Unknown operation named \"%s\"", name), true)
+		}
+	}
+	if op.typ != "query" {
+		return nil, api.NewAPIError(fmt.Sprintf("Operation %s is not supported", op.typ), true)
+	}
+	return op, nil
+}
+
+func coerceGQLVariables(op *gqlOperation, values map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{}, len(op.variables))
+	for _, d := range op.variables {
+		v, ok := values[d.name]
+		if !ok {
+			v = d.defValue
+		}
+		typ := d.typ
+		if d.nonNull {
+			typ += "!"
+		}
+		c, err := coerceGQLValue(typ, v)
+		if err != nil {
+			return nil, api.NewAPIError(fmt.Sprintf("Variable \"$%s\" of type \"%s\": %v", d.name, typ, err), true)
+		}
+		vars[d.name] = c
+	}
+	return vars, nil
+}
+
+// coerceGQLValue converts the value from the query or from the json variables to the go value of the scalar type
+func coerceGQLValue(typ string, v interface{}) (interface{}, error) {
+	base := strings.TrimSuffix(typ, "!")
+	if v == nil {
+		if base != typ {
+			return nil, errors.New("expected non-null value")
+		}
+		return nil, nil
+	}
+	switch base {
+	case "Int":
+		switch x := v.(type) {
+		case int:
+			return x, nil
+		case int64:
+			if x >= math.MinInt32 && x <= math.MaxInt32 {
+				return int(x), nil
+			}
+		case float64:
+			if x == math.Trunc(x) && x >= math.MinInt32 && x <= math.MaxInt32 {
+				return int(x), nil
+			}
+		}
+	case "Float":
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case int64:
+			return float64(x), nil
+		}
+	case "String":
+		if x, ok := v.(string); ok {
+			return x, nil
+		}
+	case "Boolean":
+		if x, ok := v.(bool); ok {
+			return x, nil
+		}
+	default:
+		return nil, errors.Errorf("unsupported type %s", typ)
+	}
+	return nil, errors.Errorf("expected value of type %s", typ)
+}
+
+// value replaces the variables in the value from the query, ok is false for an undefined variable
+func (e *gqlExecutor) value(v interface{}) (interface{}, bool) {
+	if name, isVar := v.(gqlVariable); isVar {
+		v, ok := e.variables[string(name)]
+		return v, ok
+	}
+	return v, true
+}
+
+// validate checks the fields, arguments and fragments of the selection against the schema and limits the depth of the query
+func (e *gqlExecutor) validate(t *gqlType, sels []*gqlSelection, depth int, spreads map[string]bool) error {
+	if depth > graphQLMaxDepth {
+		return api.NewAPIError(fmt.Sprintf("Query exceeds the maximum depth %d", graphQLMaxDepth), true)
+	}
+	typeCondition := func(name, typeCond string) error {
+		if typeCond != "" && typeCond != t.name {
+			return api.NewAPIError(fmt.Sprintf("Fragment %s cannot be spread here as objects of type \"%s\" can never be of type \"%s\"", name, t.name, typeCond), true)
+		}
+		return nil
+	}
+	for _, s := range sels {
+		if e.selections++; e.selections > graphQLMaxSelections {
+			return api.NewAPIError(fmt.Sprintf("Query exceeds the maximum number of selections %d", graphQLMaxSelections), true)
+		}
+		for d := range s.directives {
+			if d != "include" && d != "skip" {
+				return api.NewAPIError(fmt.Sprintf("Unknown directive \"@%s\"", d), true)
+			}
+		}
+		switch {
+		case s.fragment != "":
+			f, ok := e.fragments[s.fragment]
+			if !ok {
+				return api.NewAPIError(fmt.Sprintf("Unknown fragment \"%s\"", s.fragment), true)
+			}
+			if spreads[s.fragment] {
+				return api.NewAPIError(fmt.Sprintf("Cannot spread fragment \"%s\" within itself", s.fragment), true)
+			}
+			if err := typeCondition("\""+s.fragment+"\"", f.typeCond); err != nil {
+				return err
+			}
+			// the fragment validated in the same context does not have to be checked again,
+			// it cannot contain a cycle as it would have been found by the first validation
+			key := fmt.Sprintf("%s %s %d", s.fragment, t.name, depth)
+			if e.validated[key] {
+				continue
+			}
+			spreads[s.fragment] = true
+			err := e.validate(t, f.selection, depth, spreads)
+			delete(spreads, s.fragment)
+			if err != nil {
+				return err
+			}
+			e.validated[key] = true
+		case s.inline:
+			if err := typeCondition("", s.typeCond); err != nil {
+				return err
+			}
+			if err := e.validate(t, s.selection, depth, spreads); err != nil {
+				return err
+			}
+		case s.name == "__typename":
+			if s.selection != nil {
+				return api.NewAPIError("Field \"__typename\" must not have a selection", true)
+			}
+		default:
+			f, ok := t.fields[s.name]
+			if !ok {
+				return api.NewAPIError(fmt.Sprintf("Cannot query field \"%s\" on type \"%s\"", s.name, t.name), true)
+			}
+			for a := range s.args {
+				found := false
+				for i := range f.args {
+					if f.args[i].name == a {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return api.NewAPIError(fmt.Sprintf("Unknown argument \"%s\" on field \"%s.%s\"", a, t.name, s.name), true)
+				}
+			}
+			ft := f.typ
+			for ft.kind == gqlKindList {
+				ft = ft.elem
+			}
+			if ft.kind == gqlKindScalar {
+				if s.selection != nil {
+					return api.NewAPIError(fmt.Sprintf("Field \"%s\" must not have a selection since type \"%s\" has no subfields", s.name, f.typ), true)
+				}
+			} else {
+				if s.selection == nil {
+					return api.NewAPIError(fmt.Sprintf("Field \"%s\" of type \"%s\" must have a selection of subfields", s.name, f.typ), true)
+				}
+				if err := e.validate(ft, s.selection, depth+1, spreads); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// included evaluates the directives @include and @skip of the selection
+func (e *gqlExecutor) included(s *gqlSelection) bool {
+	if d, ok := s.directives["skip"]; ok {
+		if v, _ := e.value(d["if"]); v == true {
+			return false
+		}
+	}
+	if d, ok := s.directives["include"]; ok {
+		if v, _ := e.value(d["if"]); v == false {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFields merges the fields of the selection and of the fragments by the response key
+func (e *gqlExecutor) collectFields(sels []*gqlSelection, fields []gqlCollectedField, visited map[string]bool) []gqlCollectedField {
+	for _, s := range sels {
+		if !e.included(s) {
+			continue
+		}
+		switch {
+		case s.fragment != "":
+			if !visited[s.fragment] {
+				visited[s.fragment] = true
+				fields = e.collectFields(e.fragments[s.fragment].selection, fields, visited)
+			}
+		case s.inline:
+			fields = e.collectFields(s.selection, fields, visited)
+		default:
+			k := s.key()
+			found := false
+			for i := range fields {
+				if fields[i].key == k {
+					fields[i].sels = append(fields[i].sels, s)
+					found = true
+					break
+				}
+			}
+			if !found {
+				fields = append(fields, gqlCollectedField{key: k, sels: []*gqlSelection{s}})
+			}
+		}
+	}
+	return fields
+}
+
+// subselection returns the merged selections of the fields with the given name
+func (e *gqlExecutor) subselection(sels []*gqlSelection, name string) []*gqlSelection {
+	var r []*gqlSelection
+	for _, f := range e.collectFields(sels, nil, make(map[string]bool)) {
+		for _, s := range f.sels {
+			if s.name == name {
+				r = append(r, s.selection...)
+			}
+		}
+	}
+	return r
+}
+
+// selects returns true if any of the fields is selected
+func (e *gqlExecutor) selects(sels []*gqlSelection, names ...string) bool {
+	for _, f := range e.collectFields(sels, nil, make(map[string]bool)) {
+		for _, n := range names {
+			if f.sels[0].name == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *gqlExecutor) executeSelection(t *gqlType, v interface{}, sels []*gqlSelection) *gqlObject {
+	o := &gqlObject{}
+	for _, f := range e.collectFields(sels, nil, make(map[string]bool)) {
+		if e.aborted {
+			break
+		}
+		s := f.sels[0]
+		if s.name == "__typename" {
+			o.add(f.key, t.name)
+			continue
+		}
+		field := t.fields[s.name]
+		var sub []*gqlSelection
+		for _, fs := range f.sels {
+			sub = append(sub, fs.selection...)
+		}
+		e.path = append(e.path, f.key)
+		value, err := e.resolveField(field, v, s.args, sub)
+		if err != nil {
+			e.addError(err)
+			value = nil
+		} else {
+			value = e.complete(field.typ, value, sub)
+		}
+		e.path = e.path[:len(e.path)-1]
+		o.add(f.key, value)
+	}
+	return o
+}
+
+func (e *gqlExecutor) resolveField(f *gqlField, parent interface{}, rawArgs map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	args := make(map[string]interface{}, len(f.args))
+	for _, a := range f.args {
+		v, ok := rawArgs[a.name]
+		if ok {
+			if v, ok = e.value(v); !ok {
+				return nil, api.NewAPIError(fmt.Sprintf("Variable \"$%s\" is not defined", rawArgs[a.name]), true)
+			}
+		}
+		if v == nil {
+			v = a.defValue
+		}
+		c, err := coerceGQLValue(a.typ, v)
+		if err != nil {
+			return nil, api.NewAPIError(fmt.Sprintf("Argument \"%s\": %v", a.name, err), true)
+		}
+		args[a.name] = c
+	}
+	if f.resolve != nil {
+		return f.resolve(e, parent, args, sel)
+	}
+	fv := gqlFieldValue(reflect.ValueOf(parent), f.index)
+	if !fv.IsValid() {
+		return nil, nil
+	}
+	if fv.Kind() == reflect.Struct && fv.CanAddr() {
+		return fv.Addr().Interface(), nil
+	}
+	return fv.Interface(), nil
+}
+
+// gqlFieldValue returns the field of the struct by the index, the value is invalid if an embedded pointer is nil
+func gqlFieldValue(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// complete converts the resolved value to the response value of the type
+func (e *gqlExecutor) complete(t *gqlType, v interface{}, sel []*gqlSelection) interface{} {
+	if isNilValue(v) {
+		return nil
+	}
+	switch t.kind {
+	case gqlKindScalar:
+		return gqlScalarValue(v)
+	case gqlKindList:
+		rv := reflect.ValueOf(v)
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			if e.aborted {
+				break
+			}
+			ev := rv.Index(i)
+			var item interface{}
+			if ev.Kind() == reflect.Struct && ev.CanAddr() {
+				item = ev.Addr().Interface()
+			} else {
+				item = ev.Interface()
+			}
+			e.path = append(e.path, i)
+			l[i] = e.complete(t.elem, item, sel)
+			e.path = e.path[:len(e.path)-1]
+		}
+		return l
+	}
+	e.parents = append(e.parents, v)
+	o := e.executeSelection(t, v, sel)
+	e.parents = e.parents[:len(e.parents)-1]
+	return o
+}
+
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// gqlScalarValue returns the value of the scalar in the same format as the json api
+func gqlScalarValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *api.Amount:
+		return x.String()
+	case *big.Int:
+		return x.String()
+	case *time.Time:
+		return x.Format(time.RFC3339Nano)
+	case json.Number:
+		return string(x)
+	case json.RawMessage:
+		if len(x) == 0 {
+			return nil
+		}
+		return x
+	}
+	return v
+}
+
+func (e *gqlExecutor) addError(err error) {
+	var msg string
+	if apiErr, ok := errors.Cause(err).(*api.APIError); ok && apiErr.Public {
+		msg = apiErr.Error()
+	} else {
+		glog.Error("graphql ", e.path, " error: ", err)
+		if e.debug {
+			msg = fmt.Sprintf("Internal server error: %v", err)
+		} else {
+			msg = "Internal server error"
+		}
+	}
+	e.errors = append(e.errors, gqlError{Message: msg, Path: append([]interface{}{}, e.path...)})
+}
+
+// charge adds the cost of the resolution to the cost of the query, the query is aborted if the cost exceeds the limit
+func (e *gqlExecutor) charge(cost int) error {
+	e.cost += cost
+	if e.cost > e.maxCost {
+		e.aborted = true
+		return api.NewAPIError(fmt.Sprintf("Query exceeds the maximum cost %d", e.maxCost), true)
+	}
+	return nil
+}
+
+// parentTx returns the nearest transaction in the stack of the resolved objects
+func (e *gqlExecutor) parentTx() *api.Tx {
+	for i := len(e.parents) - 1; i >= 0; i-- {
+		if tx, ok := e.parents[i].(*api.Tx); ok {
+			return tx
+		}
+	}
+	return nil
+}
+
+func gqlPaging(args map[string]interface{}) (int, int, error) {
+	page := args["page"].(int)
+	pageSize := args["pageSize"].(int)
+	if pageSize < 1 || pageSize > txsInAPI {
+		return 0, 0, api.NewAPIError(fmt.Sprintf("Argument \"pageSize\" must be between 1 and %d", txsInAPI), true)
+	}
+	return page, pageSize, nil
+}
+
+// getTransaction loads the transaction, the spending transactions of the outputs are loaded only if they are selected
+func (e *gqlExecutor) getTransaction(txid string, sel []*gqlSelection) (interface{}, error) {
+	if err := e.charge(1); err != nil {
+		return nil, err
+	}
+	spendingTxs := e.selects(e.subselection(sel, "vout"), "spent", "spentTxId", "spentIndex", "spentHeight")
+	tx, err := e.worker.GetTransaction(txid, spendingTxs, false)
+	if err != nil {
+		return nil, err
+	}
+	if spendingTxs {
+		if err = e.charge(len(tx.Vout)); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// getBlock loads the block, the transactions of the block are loaded only if they are selected
+func (e *gqlExecutor) getBlock(id string, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	page, pageSize, err := gqlPaging(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.charge(1); err != nil {
+		return nil, err
+	}
+	txs := e.selects(sel, "txs")
+	if !txs {
+		pageSize = 1
+	}
+	b, err := e.worker.GetBlock(id, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if txs {
+		if err = e.charge(len(b.Transactions)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func gqlResolveInfo(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	if err := e.charge(1); err != nil {
+		return nil, err
+	}
+	si, err := e.worker.GetSystemInfo(false)
+	if err != nil {
+		return nil, err
+	}
+	return si.Blockbook, nil
+}
+
+func gqlResolveAddress(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	page, pageSize, err := gqlPaging(args)
+	if err != nil {
+		return nil, err
+	}
+	filter := &api.AddressFilter{Vout: api.AddressFilterVoutOff}
+	if from, ok := args["from"].(int); ok && from > 0 {
+		filter.FromHeight = uint32(from)
+	}
+	if to, ok := args["to"].(int); ok && to > 0 {
+		filter.ToHeight = uint32(to)
+	}
+	if cursor, ok := args["cursor"].(string); ok && cursor != "" {
+		if filter.Cursor, err = api.ParseTxCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	// load only the data needed by the selected fields
+	option := api.Balance
+	if e.selects(sel, "transactions") {
+		option = api.TxHistory
+	} else if e.selects(sel, "txids", "unconfirmedBalance", "unconfirmedTxApperances", "page", "totalPages", "itemsOnPage", "nextCursor") {
+		option = api.TxidHistory
+	}
+	if err = e.charge(1); err != nil {
+		return nil, err
+	}
+	a, err := e.worker.GetAddress(args["address"].(string), page, pageSize, option, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.charge(len(a.Transactions)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func gqlResolveTx(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	return e.getTransaction(args["txid"].(string), sel)
+}
+
+func gqlResolveBlock(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	return e.getBlock(args["id"].(string), args, sel)
+}
+
+// gqlResolveVinTx resolves the transaction spent by the input
+func gqlResolveVinTx(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	vin := parent.(*api.Vin)
+	if vin.Txid == "" {
+		return nil, nil
+	}
+	return e.getTransaction(vin.Txid, sel)
+}
+
+// gqlResolveSpendingTx resolves the transaction spending the output
+func gqlResolveSpendingTx(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	vout := parent.(*api.Vout)
+	txid := vout.SpentTxID
+	if txid == "" {
+		tx := e.parentTx()
+		if tx == nil {
+			return nil, nil
+		}
+		if err := e.charge(1); err != nil {
+			return nil, err
+		}
+		var err error
+		if txid, err = e.worker.GetSpendingTxid(tx.Txid, vout.N); err != nil {
+			return nil, err
+		}
+		if txid == "" {
+			return nil, nil
+		}
+	}
+	return e.getTransaction(txid, sel)
+}
+
+// gqlResolveTxBlock resolves the block of the transaction, it is null for mempool transactions
+func gqlResolveTxBlock(e *gqlExecutor, parent interface{}, args map[string]interface{}, sel []*gqlSelection) (interface{}, error) {
+	tx := parent.(*api.Tx)
+	if tx.Blockhash == "" {
+		return nil, nil
+	}
+	return e.getBlock(tx.Blockhash, args, sel)
+}
+
+func writeGraphQLResponse(w http.ResponseWriter, status int, r *gqlResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		glog.Error("graphql write response error: ", err)
+	}
+}
+
+// apiGraphQL executes the graphql query passed by GET parameters or in the POST body,
+// GET without the query returns the schema in the schema definition language
+func (s *PublicServer) apiGraphQL(w http.ResponseWriter, r *http.Request) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-graphql"}).Inc()
+	failed := func(msg string) *gqlResponse {
+		return &gqlResponse{Errors: []gqlError{{Message: msg}}}
+	}
+	if ok, wait := s.rateLimiter.allow("http", s.rateLimiter.getClient(r.RemoteAddr, r.Header, r.URL.Query())); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeGraphQLResponse(w, http.StatusTooManyRequests, failed("Too many requests"))
+		return
+	}
+	var req gqlRequest
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		if req.Query == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, s.graphQL.sdl())
+			return
+		}
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQLResponse(w, http.StatusBadRequest, failed("Invalid variables"))
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, graphQLMaxRequestSize+1))
+		if err != nil {
+			writeGraphQLResponse(w, http.StatusBadRequest, failed("Invalid request"))
+			return
+		}
+		if len(body) > graphQLMaxRequestSize {
+			writeGraphQLResponse(w, http.StatusRequestEntityTooLarge, failed("Request is too large"))
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			req.Query = string(body)
+		} else if err = json.Unmarshal(body, &req); err != nil {
+			writeGraphQLResponse(w, http.StatusBadRequest, failed("Invalid request"))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQLResponse(w, http.StatusMethodNotAllowed, failed("Method not allowed"))
+		return
+	}
+	resp, status := s.graphQL.execute(s.api, &req, s.debug)
+	writeGraphQLResponse(w, status, resp)
+}
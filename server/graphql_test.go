@@ -0,0 +1,293 @@
+// +build unittest
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		# comment
+		query Address($addr: String!, $page: Int = 2) {
+			a: address(address: $addr, page: $page) {
+				addrStr
+				transactions @include(if: true) { ...TxFields }
+				... on Address { balance }
+			}
+		}
+		fragment TxFields on Tx { txid, vin { n } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 1 {
+		t.Fatalf("operations = %d, want 1", len(doc.operations))
+	}
+	op := doc.operations[0]
+	if op.typ != "query" || op.name != "Address" {
+		t.Errorf("operation = %v %v, want query Address", op.typ, op.name)
+	}
+	wantVars := []gqlVariableDef{{name: "addr", typ: "String", nonNull: true}, {name: "page", typ: "Int", defValue: int64(2)}}
+	if !reflect.DeepEqual(op.variables, wantVars) {
+		t.Errorf("variables = %+v, want %+v", op.variables, wantVars)
+	}
+	a := op.selection[0]
+	if a.key() != "a" || a.name != "address" {
+		t.Errorf("field = %v %v, want a address", a.key(), a.name)
+	}
+	wantArgs := map[string]interface{}{"address": gqlVariable("addr"), "page": gqlVariable("page")}
+	if !reflect.DeepEqual(a.args, wantArgs) {
+		t.Errorf("args = %+v, want %+v", a.args, wantArgs)
+	}
+	if len(a.selection) != 3 {
+		t.Fatalf("selection = %d, want 3", len(a.selection))
+	}
+	if s := a.selection[1]; s.name != "transactions" || s.directives["include"]["if"] != true || s.selection[0].fragment != "TxFields" {
+		t.Errorf("transactions = %+v", s)
+	}
+	if s := a.selection[2]; !s.inline || s.typeCond != "Address" || s.selection[0].name != "balance" {
+		t.Errorf("inline fragment = %+v", s)
+	}
+	f := doc.fragments["TxFields"]
+	if f == nil || f.typeCond != "Tx" || len(f.selection) != 2 {
+		t.Errorf("fragment = %+v", f)
+	}
+}
+
+func Test_parseGraphQL_values(t *testing.T) {
+	doc, err := parseGraphQL(`{ f(i: -12, f: 1.5e2, s: "a\"bA", b: false, n: null, e: ENUM, l: [1 2], o: {x: "y"}) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"i": int64(-12),
+		"f": float64(150),
+		"s": `a"bA`,
+		"b": false,
+		"n": nil,
+		"e": gqlEnum("ENUM"),
+		"l": []interface{}{int64(1), int64(2)},
+		"o": map[string]interface{}{"x": "y"},
+	}
+	if got := doc.operations[0].selection[0].args; !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %+v, want %+v", got, want)
+	}
+}
+
+func Test_parseGraphQL_errors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: ``, want: "Syntax error: no operation in the document"},
+		{query: `{ tx(txid: "a") { txid }`, want: "Syntax error at 1:25: expected name, found end of document"},
+		{query: "{\n  tx(txid: \"a) { txid } }", want: "Syntax error at 2:12: unterminated string"},
+		{query: `{ tx { } }`, want: "Syntax error at 1:8: empty selection set"},
+		{query: `subscribe { tx }`, want: `Syntax error at 1:1: unknown operation "subscribe"`},
+		{query: `query ($a: Int = $b) { tx }`, want: "Syntax error at 1:18: variable in constant value"},
+		{query: `{ tx } fragment F on Tx { txid } fragment F on Tx { txid }`, want: "Syntax error at 1:59: duplicate fragment F"},
+	}
+	for _, tt := range tests {
+		_, err := parseGraphQL(tt.query)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("parseGraphQL(%q) error = %v, want %v", tt.query, err, tt.want)
+		}
+	}
+}
+
+func Test_coerceGQLValue(t *testing.T) {
+	tests := []struct {
+		typ     string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{typ: "Int", v: int64(5), want: 5},
+		{typ: "Int", v: float64(5), want: 5},
+		{typ: "Int", v: 5.5, wantErr: true},
+		{typ: "Int", v: int64(1) << 40, wantErr: true},
+		{typ: "Int", v: nil, want: nil},
+		{typ: "Int!", v: nil, wantErr: true},
+		{typ: "String!", v: "a", want: "a"},
+		{typ: "String", v: int64(1), wantErr: true},
+		{typ: "Boolean", v: true, want: true},
+		{typ: "Float", v: int64(2), want: float64(2)},
+		{typ: "[Int]", v: []interface{}{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := coerceGQLValue(tt.typ, tt.v)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("coerceGQLValue(%v, %v) = %v, %v, want %v, error %v", tt.typ, tt.v, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func Test_gqlSchema(t *testing.T) {
+	s := newGraphQLSchema()
+	tests := []struct {
+		typ   string
+		field string
+		want  string
+	}{
+		{"Query", "address", "Address"},
+		{"Address", "transactions", "[Tx]"},
+		{"Address", "balance", "String"},
+		{"Tx", "vin", "[Vin]"},
+		{"Tx", "blockheight", "Int"},
+		{"Tx", "block", "Block"},
+		{"Vin", "tx", "Tx"},
+		{"Vout", "spendingTx", "Tx"},
+		{"Block", "hash", "String"},
+		{"Block", "txs", "[Tx]"},
+		{"BlockbookInfo", "lastBlockTime", "String"},
+		{"BlockbookInfo", "dbColumns", "[InternalStateColumn]"},
+	}
+	for _, tt := range tests {
+		typ := s.types[tt.typ]
+		if typ == nil {
+			t.Errorf("type %v not found", tt.typ)
+			continue
+		}
+		f := typ.fields[tt.field]
+		if f == nil {
+			t.Errorf("field %v.%v not found", tt.typ, tt.field)
+			continue
+		}
+		if got := f.typ.String(); got != tt.want {
+			t.Errorf("type of %v.%v = %v, want %v", tt.typ, tt.field, got, tt.want)
+		}
+	}
+	if _, ok := s.types["Tx"].fields["CoinSpecificJSON"]; ok {
+		t.Error("field without json name is in the schema")
+	}
+	if sdl := s.sdl(); !strings.Contains(sdl, "type Vout {\n  value: String\n  n: Int\n") {
+		t.Errorf("unexpected sdl %v", sdl)
+	}
+}
+
+func Test_gqlSchema_execute_errors(t *testing.T) {
+	s := newGraphQLSchema()
+	deep := "{tx(txid:\"a\"){" + strings.Repeat("vin{tx{", 6) + "txid" + strings.Repeat("}}", 6) + "}}"
+	// each fragment spreads the following one twice, the validation must not expand the fragments 2^40 times
+	doubling := `{tx(txid:"a"){...F0 foo}}`
+	for i := 0; i < 40; i++ {
+		doubling += fmt.Sprintf(" fragment F%d on Tx {...F%d ...F%d}", i, i+1, i+1)
+	}
+	doubling += " fragment F40 on Tx {txid}"
+	tests := []struct {
+		name string
+		req  gqlRequest
+		want string
+	}{
+		{
+			name: "missing query",
+			req:  gqlRequest{},
+			want: `{"errors":[{"message":"Missing query"}]}`,
+		},
+		{
+			name: "unknown field",
+			req:  gqlRequest{Query: `{tx(txid:"a"){txid foo}}`},
+			want: `{"errors":[{"message":"Cannot query field \"foo\" on type \"Tx\""}]}`,
+		},
+		{
+			name: "unknown argument",
+			req:  gqlRequest{Query: `{tx(txid:"a", page: 1){txid}}`},
+			want: `{"errors":[{"message":"Unknown argument \"page\" on field \"Query.tx\""}]}`,
+		},
+		{
+			name: "missing selection",
+			req:  gqlRequest{Query: `{tx(txid:"a")}`},
+			want: `{"errors":[{"message":"Field \"tx\" of type \"Tx\" must have a selection of subfields"}]}`,
+		},
+		{
+			name: "selection of scalar",
+			req:  gqlRequest{Query: `{tx(txid:"a"){txid{a}}}`},
+			want: `{"errors":[{"message":"Field \"txid\" must not have a selection since type \"String\" has no subfields"}]}`,
+		},
+		{
+			name: "fragment type",
+			req:  gqlRequest{Query: `{tx(txid:"a"){...F}} fragment F on Block {hash}`},
+			want: `{"errors":[{"message":"Fragment \"F\" cannot be spread here as objects of type \"Tx\" can never be of type \"Block\""}]}`,
+		},
+		{
+			name: "fragment cycle",
+			req:  gqlRequest{Query: `{tx(txid:"a"){...F}} fragment F on Tx {...G} fragment G on Tx {...F}`},
+			want: `{"errors":[{"message":"Cannot spread fragment \"F\" within itself"}]}`,
+		},
+		{
+			name: "max depth",
+			req:  gqlRequest{Query: deep},
+			want: `{"errors":[{"message":"Query exceeds the maximum depth 12"}]}`,
+		},
+		{
+			name: "doubling fragments",
+			req:  gqlRequest{Query: doubling},
+			want: `{"errors":[{"message":"Cannot query field \"foo\" on type \"Tx\""}]}`,
+		},
+		{
+			name: "max selections",
+			req:  gqlRequest{Query: "{tx(txid:\"a\"){" + strings.Repeat("txid ", graphQLMaxSelections) + "}}"},
+			want: `{"errors":[{"message":"Query exceeds the maximum number of selections 5000"}]}`,
+		},
+		{
+			name: "missing variable",
+			req:  gqlRequest{Query: `query($t: String!){tx(txid:$t){txid}}`},
+			want: `{"errors":[{"message":"Variable \"$t\" of type \"String!\": expected non-null value"}]}`,
+		},
+		{
+			name: "operation name",
+			req:  gqlRequest{Query: `query A {info{coin}} query B {info{coin}}`},
+			want: `{"errors":[{"message":"Must provide operation name if query contains multiple operations"}]}`,
+		},
+		{
+			name: "mutation",
+			req:  gqlRequest{Query: `mutation {info{coin}}`},
+			want: `{"errors":[{"message":"Operation mutation is not supported"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, status := s.execute(nil, &tt.req, false)
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %v, want %v", status, http.StatusBadRequest)
+			}
+			b, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("response = %v, want %v", string(b), tt.want)
+			}
+		})
+	}
+}
+
+func Test_gqlExecutor_charge(t *testing.T) {
+	e := &gqlExecutor{maxCost: 10}
+	if err := e.charge(10); err != nil || e.aborted {
+		t.Errorf("charge() up to the limit = %v, aborted %v", err, e.aborted)
+	}
+	if err := e.charge(1); err == nil || !e.aborted {
+		t.Errorf("charge() over the limit = %v, aborted %v", err, e.aborted)
+	}
+}
+
+func Test_gqlObject_MarshalJSON(t *testing.T) {
+	o := &gqlObject{}
+	o.add("z", 1)
+	o.add("a", []interface{}{"x", nil})
+	o.add("m", &gqlObject{})
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"z":1,"a":["x",null],"m":{}}`; string(b) != want {
+		t.Errorf("MarshalJSON() = %v, want %v", string(b), want)
+	}
+}
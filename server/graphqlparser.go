@@ -0,0 +1,516 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+)
+
+// Parser of the subset of the GraphQL query language used by the graphql endpoint.
+// It supports query operations with variables, aliases, arguments, fragments, inline fragments
+// and the directives @include and @skip. Mutations, subscriptions and type system definitions are not supported.
+
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	typ       string
+	name      string
+	variables []gqlVariableDef
+	selection []*gqlSelection
+}
+
+type gqlVariableDef struct {
+	name     string
+	typ      string
+	nonNull  bool
+	defValue interface{}
+}
+
+type gqlFragment struct {
+	name      string
+	typeCond  string
+	selection []*gqlSelection
+}
+
+// gqlSelection is a field, a fragment spread or an inline fragment
+type gqlSelection struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives map[string]map[string]interface{}
+	selection  []*gqlSelection
+	// fragment is the name of the spread fragment
+	fragment string
+	// inline is set for inline fragments, which can have typeCond
+	inline   bool
+	typeCond string
+}
+
+// key is the name of the field in the response
+func (s *gqlSelection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// gqlVariable is a reference to a variable in a value
+type gqlVariable string
+
+// gqlEnum is an enum value
+type gqlEnum string
+
+const (
+	gqlTokenEOF = iota
+	gqlTokenPunct
+	gqlTokenName
+	gqlTokenInt
+	gqlTokenFloat
+	gqlTokenString
+)
+
+type gqlToken struct {
+	kind  int
+	value string
+	pos   int
+}
+
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+}
+
+func parseGraphQL(src string) (doc *gqlDocument, err error) {
+	p := &gqlParser{src: src}
+	// the parser panics on syntax errors to keep the recursive descent simple
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(gqlSyntaxError); ok {
+				doc = nil
+				err = errors.New(string(e))
+				return
+			}
+			panic(r)
+		}
+	}()
+	p.next()
+	doc = &gqlDocument{fragments: make(map[string]*gqlFragment)}
+	for p.tok.kind != gqlTokenEOF {
+		switch {
+		case p.isPunct("{"):
+			doc.operations = append(doc.operations, &gqlOperation{typ: "query", selection: p.parseSelectionSet()})
+		case p.tok.kind == gqlTokenName && p.tok.value == "fragment":
+			f := p.parseFragment()
+			if _, ok := doc.fragments[f.name]; ok {
+				p.fail("duplicate fragment " + f.name)
+			}
+			doc.fragments[f.name] = f
+		case p.tok.kind == gqlTokenName:
+			doc.operations = append(doc.operations, p.parseOperation())
+		default:
+			p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("Syntax error: no operation in the document")
+	}
+	return doc, nil
+}
+
+type gqlSyntaxError string
+
+func (p *gqlParser) fail(msg string) {
+	line := 1 + strings.Count(p.src[:p.tok.pos], "\n")
+	col := p.tok.pos - strings.LastIndexByte(p.src[:p.tok.pos], '\n')
+	panic(gqlSyntaxError("Syntax error at " + strconv.Itoa(line) + ":" + strconv.Itoa(col) + ": " + msg))
+}
+
+// found describes the current token in the syntax errors
+func (p *gqlParser) found() string {
+	if p.tok.kind == gqlTokenEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.tok.value)
+}
+
+func (p *gqlParser) unexpected() {
+	p.fail("unexpected " + p.found())
+}
+
+func (p *gqlParser) isPunct(v string) bool {
+	return p.tok.kind == gqlTokenPunct && p.tok.value == v
+}
+
+func (p *gqlParser) expectPunct(v string) {
+	if !p.isPunct(v) {
+		p.fail("expected " + strconv.Quote(v) + ", found " + p.found())
+	}
+	p.next()
+}
+
+func (p *gqlParser) expectName() string {
+	if p.tok.kind != gqlTokenName {
+		p.fail("expected name, found " + p.found())
+	}
+	v := p.tok.value
+	p.next()
+	return v
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else if strings.HasPrefix(p.src[p.pos:], "\ufeff") {
+			p.pos += 3
+		} else {
+			break
+		}
+	}
+	start := p.pos
+	p.tok = gqlToken{pos: start}
+	if p.pos >= len(p.src) {
+		p.tok.kind = gqlTokenEOF
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("!$()&:=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok.kind = gqlTokenPunct
+		p.tok.value = string(c)
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind = gqlTokenPunct
+		p.tok.value = "..."
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		for p.pos < len(p.src) && isGQLNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok.kind = gqlTokenName
+		p.tok.value = p.src[start:p.pos]
+	case c == '-' || (c >= '0' && c <= '9'):
+		p.readNumber()
+	case c == '"':
+		p.readString()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.tok.value = string(r)
+		p.fail("unexpected character " + strconv.Quote(string(r)))
+	}
+}
+
+func isGQLNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *gqlParser) readNumber() {
+	start := p.pos
+	p.tok.kind = gqlTokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		s := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if s == p.pos {
+			p.tok.value = p.src[start:p.pos]
+			p.fail("invalid number " + strconv.Quote(p.tok.value))
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		p.tok.kind = gqlTokenFloat
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		p.tok.kind = gqlTokenFloat
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok.value = p.src[start:p.pos]
+}
+
+func (p *gqlParser) readString() {
+	p.tok.kind = gqlTokenString
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated string")
+		}
+		p.tok.value = strings.TrimSpace(p.src[p.pos+3 : p.pos+3+end])
+		p.pos += end + 6
+		return
+	}
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		e := p.src[p.pos+1]
+		p.pos += 2
+		switch e {
+		case '"', '\\', '/':
+			b.WriteByte(e)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			p.fail("invalid escape sequence")
+		}
+	}
+	p.tok.value = b.String()
+}
+
+func (p *gqlParser) parseOperation() *gqlOperation {
+	if v := p.tok.value; v != "query" && v != "mutation" && v != "subscription" {
+		p.fail("unknown operation " + strconv.Quote(v))
+	}
+	op := &gqlOperation{typ: p.expectName()}
+	if p.tok.kind == gqlTokenName {
+		op.name = p.expectName()
+	}
+	if p.isPunct("(") {
+		p.next()
+		for !p.isPunct(")") {
+			p.expectPunct("$")
+			v := gqlVariableDef{name: p.expectName()}
+			p.expectPunct(":")
+			v.typ, v.nonNull = p.parseType()
+			if p.isPunct("=") {
+				p.next()
+				v.defValue = p.parseValue(true)
+			}
+			op.variables = append(op.variables, v)
+		}
+		p.next()
+	}
+	p.parseDirectives()
+	op.selection = p.parseSelectionSet()
+	return op
+}
+
+// parseType returns the type of the variable, list types are returned in the form [Type]
+func (p *gqlParser) parseType() (string, bool) {
+	var t string
+	if p.isPunct("[") {
+		p.next()
+		et, nonNull := p.parseType()
+		if nonNull {
+			et += "!"
+		}
+		p.expectPunct("]")
+		t = "[" + et + "]"
+	} else {
+		t = p.expectName()
+	}
+	if p.isPunct("!") {
+		p.next()
+		return t, true
+	}
+	return t, false
+}
+
+func (p *gqlParser) parseFragment() *gqlFragment {
+	p.next()
+	f := &gqlFragment{name: p.expectName()}
+	if f.name == "on" {
+		p.fail("invalid fragment name on")
+	}
+	if p.tok.kind != gqlTokenName || p.tok.value != "on" {
+		p.fail("expected type condition of fragment " + f.name)
+	}
+	p.next()
+	f.typeCond = p.expectName()
+	p.parseDirectives()
+	f.selection = p.parseSelectionSet()
+	return f
+}
+
+func (p *gqlParser) parseSelectionSet() []*gqlSelection {
+	p.expectPunct("{")
+	var sels []*gqlSelection
+	for !p.isPunct("}") {
+		sels = append(sels, p.parseSelection())
+	}
+	if len(sels) == 0 {
+		p.fail("empty selection set")
+	}
+	p.next()
+	return sels
+}
+
+func (p *gqlParser) parseSelection() *gqlSelection {
+	s := &gqlSelection{}
+	if p.isPunct("...") {
+		p.next()
+		if p.tok.kind == gqlTokenName && p.tok.value != "on" {
+			s.fragment = p.expectName()
+			s.directives = p.parseDirectives()
+			return s
+		}
+		s.inline = true
+		if p.tok.kind == gqlTokenName {
+			p.next()
+			s.typeCond = p.expectName()
+		}
+		s.directives = p.parseDirectives()
+		s.selection = p.parseSelectionSet()
+		return s
+	}
+	s.name = p.expectName()
+	if p.isPunct(":") {
+		p.next()
+		s.alias = s.name
+		s.name = p.expectName()
+	}
+	if p.isPunct("(") {
+		s.args = p.parseArguments(false)
+	}
+	s.directives = p.parseDirectives()
+	if p.isPunct("{") {
+		s.selection = p.parseSelectionSet()
+	}
+	return s
+}
+
+func (p *gqlParser) parseArguments(constant bool) map[string]interface{} {
+	p.expectPunct("(")
+	args := make(map[string]interface{})
+	for !p.isPunct(")") {
+		name := p.expectName()
+		p.expectPunct(":")
+		args[name] = p.parseValue(constant)
+	}
+	p.next()
+	return args
+}
+
+func (p *gqlParser) parseDirectives() map[string]map[string]interface{} {
+	var d map[string]map[string]interface{}
+	for p.isPunct("@") {
+		p.next()
+		name := p.expectName()
+		var args map[string]interface{}
+		if p.isPunct("(") {
+			args = p.parseArguments(false)
+		}
+		if d == nil {
+			d = make(map[string]map[string]interface{})
+		}
+		d[name] = args
+	}
+	return d
+}
+
+func (p *gqlParser) parseValue(constant bool) interface{} {
+	t := p.tok
+	switch t.kind {
+	case gqlTokenInt:
+		p.next()
+		i, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			p.fail("invalid integer " + t.value)
+		}
+		return i
+	case gqlTokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			p.fail("invalid float " + t.value)
+		}
+		return f
+	case gqlTokenString:
+		p.next()
+		return t.value
+	case gqlTokenName:
+		p.next()
+		switch t.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return gqlEnum(t.value)
+	case gqlTokenPunct:
+		switch t.value {
+		case "$":
+			if constant {
+				p.fail("variable in constant value")
+			}
+			p.next()
+			return gqlVariable(p.expectName())
+		case "[":
+			p.next()
+			l := make([]interface{}, 0)
+			for !p.isPunct("]") {
+				l = append(l, p.parseValue(constant))
+			}
+			p.next()
+			return l
+		case "{":
+			p.next()
+			o := make(map[string]interface{})
+			for !p.isPunct("}") {
+				name := p.expectName()
+				p.expectPunct(":")
+				o[name] = p.parseValue(constant)
+			}
+			p.next()
+			return o
+		}
+	}
+	p.unexpected()
+	return nil
+}
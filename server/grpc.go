@@ -0,0 +1,494 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain"
+	"blockbook/common"
+	"blockbook/db"
+	"blockbook/server/grpcapi"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcServer is a handle to the gRPC server, the service is defined in grpcapi/blockbook.proto
+type GrpcServer struct {
+	binding     string
+	certFiles   string
+	server      *grpc.Server
+	db          db.IndexStore
+	txCache     *db.TxCache
+	chain       bchain.BlockChain
+	chainParser bchain.BlockChainParser
+	metrics     *common.Metrics
+	is          *common.InternalState
+	api         *api.Worker
+	// closed is set on shutdown, new subscriptions are refused
+	closed             bool
+	blockSubscriptions map[*grpcSubscription]struct{}
+	// addressSubscriptions maps address descriptor to the subscriptions and the subscribed address
+	addressSubscriptions map[string]map[*grpcSubscription]string
+	subscriptionsLock    sync.Mutex
+}
+
+// grpcSubscription passes the notifications to a streaming call,
+// the notifications are not blocking, the subscription is ended if the client does not read them
+type grpcSubscription struct {
+	out    chan interface{}
+	closed bool
+	// err is the status of the streaming call when out is closed
+	err error
+}
+
+// NewGrpcServer creates new gRPC server listening on binding, TLS is used if certFiles is set
+func NewGrpcServer(binding, certFiles string, db db.IndexStore, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*GrpcServer, error) {
+	api, err := api.NewWorker(db, chain, txCache, is)
+	if err != nil {
+		return nil, err
+	}
+	s := &GrpcServer{
+		binding:              binding,
+		certFiles:            certFiles,
+		db:                   db,
+		txCache:              txCache,
+		chain:                chain,
+		chainParser:          chain.GetChainParser(),
+		metrics:              metrics,
+		is:                   is,
+		api:                  api,
+		blockSubscriptions:   make(map[*grpcSubscription]struct{}),
+		addressSubscriptions: make(map[string]map[*grpcSubscription]string),
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	}
+	if certFiles != "" {
+		creds, err := credentials.NewServerTLSFromFile(fmt.Sprint(certFiles, ".crt"), fmt.Sprint(certFiles, ".key"))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s.server = grpc.NewServer(opts...)
+	grpcapi.RegisterBlockbookServer(s.server, s)
+	return s, nil
+}
+
+// Run starts the server and serves the calls until the server is stopped
+func (s *GrpcServer) Run() error {
+	l, err := net.Listen("tcp", s.binding)
+	if err != nil {
+		return err
+	}
+	if s.certFiles == "" {
+		glog.Info("grpc server: starting to listen on ", s.binding)
+	} else {
+		glog.Info("grpc server: starting to listen on ", s.binding, " with TLS")
+	}
+	return s.server.Serve(l)
+}
+
+// Close stops the server immediately, closing all connections
+func (s *GrpcServer) Close() error {
+	glog.Infof("grpc server: closing")
+	s.closeSubscriptions()
+	s.server.Stop()
+	return nil
+}
+
+// Shutdown ends the subscriptions and waits for the running calls until the ctx is done
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	glog.Infof("grpc server: shutdown")
+	s.closeSubscriptions()
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (s *GrpcServer) closeSubscriptions() {
+	s.subscriptionsLock.Lock()
+	defer s.subscriptionsLock.Unlock()
+	s.closed = true
+	err := status.Error(codes.Unavailable, "Server is shutting down")
+	for sub := range s.blockSubscriptions {
+		sub.close(err)
+	}
+	for _, as := range s.addressSubscriptions {
+		for sub := range as {
+			sub.close(err)
+		}
+	}
+}
+
+func grpcMethodName(fullMethod string) string {
+	return fullMethod[strings.LastIndexByte(fullMethod, '/')+1:]
+}
+
+func (s *GrpcServer) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	method := grpcMethodName(info.FullMethod)
+	resp, err := handler(ctx, req)
+	s.metrics.GrpcRequests.With(common.Labels{"method": method, "status": status.Code(err).String()}).Inc()
+	glog.V(1).Info("grpc server: ", method, " finished in ", time.Since(start))
+	return resp, err
+}
+
+func (s *GrpcServer) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	method := grpcMethodName(info.FullMethod)
+	s.metrics.GrpcSubscriptions.Inc()
+	err := handler(srv, stream)
+	s.metrics.GrpcSubscriptions.Dec()
+	s.metrics.GrpcRequests.With(common.Labels{"method": method, "status": status.Code(err).String()}).Inc()
+	return err
+}
+
+// grpcError converts the error to the status of the call, the public api errors are returned to the client
+func grpcError(method string, err error) error {
+	if apiErr, ok := err.(*api.APIError); ok && apiErr.Public {
+		return status.Error(codes.InvalidArgument, apiErr.Error())
+	}
+	glog.Error("grpc server: ", method, " error: ", err)
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// grpcPaging checks the page size, zero page size means the default page size of the api
+func grpcPaging(page, pageSize int32) (int, int, error) {
+	if pageSize == 0 {
+		pageSize = txsInAPI
+	}
+	if pageSize < 0 || pageSize > txsInAPI {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "Page size must be between 1 and %d", txsInAPI)
+	}
+	return int(page), int(pageSize), nil
+}
+
+// GetTransaction returns the transaction
+func (s *GrpcServer) GetTransaction(ctx context.Context, req *grpcapi.GetTransactionRequest) (*grpcapi.Transaction, error) {
+	if req.Txid == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing txid")
+	}
+	tx, err := s.api.GetTransaction(req.Txid, req.SpendingTxs, false)
+	if err != nil {
+		return nil, grpcError("GetTransaction", err)
+	}
+	return grpcTransaction(tx), nil
+}
+
+// GetAddress returns the balances and a page of the transactions of the address
+func (s *GrpcServer) GetAddress(ctx context.Context, req *grpcapi.GetAddressRequest) (*grpcapi.Address, error) {
+	page, pageSize, err := grpcPaging(req.Page, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	filter := &api.AddressFilter{
+		Vout:       api.AddressFilterVoutOff,
+		FromHeight: req.FromHeight,
+		ToHeight:   req.ToHeight,
+	}
+	if req.Cursor != "" {
+		if filter.Cursor, err = api.ParseTxCursor(req.Cursor); err != nil {
+			return nil, grpcError("GetAddress", err)
+		}
+	}
+	option := api.TxidHistory
+	if req.Transactions {
+		option = api.TxHistory
+	}
+	a, err := s.api.GetAddress(req.Address, page, pageSize, option, filter)
+	if err != nil {
+		return nil, grpcError("GetAddress", err)
+	}
+	r := &grpcapi.Address{
+		Address:            a.AddrStr,
+		Balance:            a.BalanceSat.String(),
+		TotalReceived:      a.TotalReceivedSat.String(),
+		TotalSent:          a.TotalSentSat.String(),
+		UnconfirmedBalance: a.UnconfirmedBalanceSat.String(),
+		UnconfirmedTxs:     int32(a.UnconfirmedTxApperances),
+		Txs:                int32(a.TxApperances),
+		Txids:              a.Txids,
+		Page:               int32(a.Page),
+		TotalPages:         int32(a.TotalPages),
+		ItemsOnPage:        int32(a.ItemsOnPage),
+		NextCursor:         a.NextCursor,
+	}
+	for _, tx := range a.Transactions {
+		r.Transactions = append(r.Transactions, grpcTransaction(tx))
+	}
+	return r, nil
+}
+
+// GetUtxo returns the unspent outputs of the address
+func (s *GrpcServer) GetUtxo(ctx context.Context, req *grpcapi.GetUtxoRequest) (*grpcapi.GetUtxoResponse, error) {
+	utxos, err := s.api.GetAddressUtxo(req.Address, req.Confirmed)
+	if err != nil {
+		return nil, grpcError("GetUtxo", err)
+	}
+	r := &grpcapi.GetUtxoResponse{Utxos: make([]*grpcapi.Utxo, len(utxos))}
+	for i := range utxos {
+		u := &utxos[i]
+		r.Utxos[i] = &grpcapi.Utxo{
+			Txid:          u.Txid,
+			Vout:          u.Vout,
+			Value:         u.AmountSat.String(),
+			Height:        int32(u.Height),
+			Confirmations: int32(u.Confirmations),
+		}
+	}
+	return r, nil
+}
+
+// GetBlock returns the block with a page of its transactions
+func (s *GrpcServer) GetBlock(ctx context.Context, req *grpcapi.GetBlockRequest) (*grpcapi.Block, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing block hash or height")
+	}
+	page, pageSize, err := grpcPaging(req.Page, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.api.GetBlock(req.Id, page, pageSize)
+	if err != nil {
+		return nil, grpcError("GetBlock", err)
+	}
+	r := &grpcapi.Block{
+		Hash:              b.Hash,
+		PreviousBlockHash: b.Prev,
+		NextBlockHash:     b.Next,
+		Height:            b.Height,
+		Confirmations:     int32(b.Confirmations),
+		Size:              int32(b.Size),
+		Time:              b.Time,
+		Version:           string(b.Version),
+		MerkleRoot:        b.MerkleRoot,
+		Nonce:             string(b.Nonce),
+		Bits:              b.Bits,
+		Difficulty:        string(b.Difficulty),
+		TxCount:           int32(b.TxCount),
+		Page:              int32(b.Page),
+		TotalPages:        int32(b.TotalPages),
+		ItemsOnPage:       int32(b.ItemsOnPage),
+	}
+	for _, tx := range b.Transactions {
+		r.Txs = append(r.Txs, grpcTransaction(tx))
+	}
+	return r, nil
+}
+
+// SendTransaction sends the hex encoded transaction to the backend
+func (s *GrpcServer) SendTransaction(ctx context.Context, req *grpcapi.SendTransactionRequest) (*grpcapi.SendTransactionResponse, error) {
+	if req.Hex == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing tx blob")
+	}
+	txid, err := s.chain.SendRawTransaction(req.Hex)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &grpcapi.SendTransactionResponse{Txid: txid}, nil
+}
+
+// SubscribeBlocks streams the notifications of the new blocks
+func (s *GrpcServer) SubscribeBlocks(req *grpcapi.SubscribeBlocksRequest, stream grpcapi.Blockbook_SubscribeBlocksServer) error {
+	sub := &grpcSubscription{out: make(chan interface{}, outChannelSize)}
+	s.subscriptionsLock.Lock()
+	if s.closed {
+		s.subscriptionsLock.Unlock()
+		return status.Error(codes.Unavailable, "Server is shutting down")
+	}
+	s.blockSubscriptions[sub] = struct{}{}
+	s.subscriptionsLock.Unlock()
+	defer func() {
+		s.subscriptionsLock.Lock()
+		delete(s.blockSubscriptions, sub)
+		s.subscriptionsLock.Unlock()
+	}()
+	return sub.run(stream.Context(), func(n interface{}) error {
+		return stream.Send(n.(*grpcapi.BlockNotification))
+	})
+}
+
+// SubscribeAddresses streams the new transactions of the addresses
+func (s *GrpcServer) SubscribeAddresses(req *grpcapi.SubscribeAddressesRequest, stream grpcapi.Blockbook_SubscribeAddressesServer) error {
+	if len(req.Addresses) == 0 {
+		return status.Error(codes.InvalidArgument, "Missing addresses")
+	}
+	addrDescs := make(map[string]string, len(req.Addresses))
+	for _, a := range req.Addresses {
+		addrDesc, err := s.chainParser.GetAddrDescFromAddress(a)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid address %s, %v", a, err)
+		}
+		addrDescs[string(addrDesc)] = a
+	}
+	sub := &grpcSubscription{out: make(chan interface{}, outChannelSize)}
+	s.subscriptionsLock.Lock()
+	if s.closed {
+		s.subscriptionsLock.Unlock()
+		return status.Error(codes.Unavailable, "Server is shutting down")
+	}
+	for ad, a := range addrDescs {
+		as, ok := s.addressSubscriptions[ad]
+		if !ok {
+			as = make(map[*grpcSubscription]string)
+			s.addressSubscriptions[ad] = as
+		}
+		as[sub] = a
+	}
+	s.subscriptionsLock.Unlock()
+	defer func() {
+		s.subscriptionsLock.Lock()
+		for ad := range addrDescs {
+			as := s.addressSubscriptions[ad]
+			delete(as, sub)
+			if len(as) == 0 {
+				delete(s.addressSubscriptions, ad)
+			}
+		}
+		s.subscriptionsLock.Unlock()
+	}()
+	return sub.run(stream.Context(), func(n interface{}) error {
+		return stream.Send(n.(*grpcapi.AddressNotification))
+	})
+}
+
+// run sends the notifications to the stream until the client cancels the call or the subscription is closed
+func (sub *grpcSubscription) run(ctx context.Context, send func(interface{}) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n, ok := <-sub.out:
+			if !ok {
+				return sub.err
+			}
+			if err := send(n); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// notify passes the notification to the subscription without blocking, must be called with subscriptionsLock held
+func (sub *grpcSubscription) notify(n interface{}) {
+	if sub.closed {
+		return
+	}
+	select {
+	case sub.out <- n:
+	default:
+		sub.close(status.Error(codes.ResourceExhausted, "Notifications are not read fast enough"))
+	}
+}
+
+// close ends the subscription with the err, must be called with subscriptionsLock held
+func (sub *grpcSubscription) close(err error) {
+	if !sub.closed {
+		sub.closed = true
+		sub.err = err
+		close(sub.out)
+	}
+}
+
+// OnNewBlock is a callback that sends the new block to the block subscriptions
+func (s *GrpcServer) OnNewBlock(hash string, height uint32) {
+	s.subscriptionsLock.Lock()
+	defer s.subscriptionsLock.Unlock()
+	n := &grpcapi.BlockNotification{Height: height, Hash: hash}
+	for sub := range s.blockSubscriptions {
+		sub.notify(n)
+	}
+	if len(s.blockSubscriptions) > 0 {
+		glog.Info("grpc server: broadcasting new block ", height, " ", hash, " to ", len(s.blockSubscriptions), " subscriptions")
+	}
+}
+
+// OnNewTxAddr is a callback that sends the new transaction to the subscriptions of the address
+func (s *GrpcServer) OnNewTxAddr(tx *bchain.Tx, addrDesc bchain.AddressDescriptor) {
+	// check if there is any subscription but release the lock immediately, GetTransactionFromBchainTx may take some time
+	s.subscriptionsLock.Lock()
+	count := len(s.addressSubscriptions[string(addrDesc)])
+	s.subscriptionsLock.Unlock()
+	if count == 0 {
+		return
+	}
+	atx, err := s.api.GetTransactionFromBchainTx(tx, 0, false, false)
+	if err != nil {
+		glog.Error("grpc server: GetTransactionFromBchainTx error ", err, " for ", tx.Txid)
+		return
+	}
+	gtx := grpcTransaction(atx)
+	s.subscriptionsLock.Lock()
+	defer s.subscriptionsLock.Unlock()
+	as := s.addressSubscriptions[string(addrDesc)]
+	for sub, a := range as {
+		sub.notify(&grpcapi.AddressNotification{Address: a, Tx: gtx})
+	}
+	if len(as) > 0 {
+		glog.Info("grpc server: broadcasting new tx ", tx.Txid, " to ", len(as), " subscriptions")
+	}
+}
+
+func grpcTransaction(tx *api.Tx) *grpcapi.Transaction {
+	r := &grpcapi.Transaction{
+		Txid:          tx.Txid,
+		Version:       tx.Version,
+		Locktime:      tx.Locktime,
+		Vin:           make([]*grpcapi.Vin, len(tx.Vin)),
+		Vout:          make([]*grpcapi.Vout, len(tx.Vout)),
+		Blockhash:     tx.Blockhash,
+		Blockheight:   int32(tx.Blockheight),
+		Confirmations: tx.Confirmations,
+		Blocktime:     tx.Blocktime,
+		Size:          int32(tx.Size),
+		Value:         tx.ValueOutSat.String(),
+		ValueIn:       tx.ValueInSat.String(),
+		Fees:          tx.FeesSat.String(),
+		Hex:           tx.Hex,
+	}
+	for i := range tx.Vin {
+		vin := &tx.Vin[i]
+		r.Vin[i] = &grpcapi.Vin{
+			Txid:      vin.Txid,
+			Vout:      vin.Vout,
+			Sequence:  vin.Sequence,
+			N:         int32(vin.N),
+			Addresses: vin.Addresses,
+			Value:     vin.ValueSat.String(),
+			Hex:       vin.Hex,
+		}
+	}
+	for i := range tx.Vout {
+		vout := &tx.Vout[i]
+		r.Vout[i] = &grpcapi.Vout{
+			Value:       vout.ValueSat.String(),
+			N:           int32(vout.N),
+			Spent:       vout.Spent,
+			SpentTxid:   vout.SpentTxID,
+			SpentIndex:  int32(vout.SpentIndex),
+			SpentHeight: int32(vout.SpentHeight),
+			Hex:         vout.Hex,
+			Addresses:   vout.Addresses,
+			Type:        vout.Type,
+		}
+	}
+	return r
+}
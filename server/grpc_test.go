@@ -0,0 +1,162 @@
+// +build unittest
+
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/server/grpcapi"
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_grpcPaging(t *testing.T) {
+	tests := []struct {
+		page, pageSize int32
+		wantPage       int
+		wantPageSize   int
+		wantErr        bool
+	}{
+		{page: 0, pageSize: 0, wantPage: 0, wantPageSize: txsInAPI},
+		{page: 2, pageSize: 10, wantPage: 2, wantPageSize: 10},
+		{page: 1, pageSize: txsInAPI, wantPage: 1, wantPageSize: txsInAPI},
+		{page: 1, pageSize: txsInAPI + 1, wantErr: true},
+		{page: 1, pageSize: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		page, pageSize, err := grpcPaging(tt.page, tt.pageSize)
+		if tt.wantErr {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("grpcPaging(%v, %v) error = %v, want InvalidArgument", tt.page, tt.pageSize, err)
+			}
+			continue
+		}
+		if err != nil || page != tt.wantPage || pageSize != tt.wantPageSize {
+			t.Errorf("grpcPaging(%v, %v) = %v, %v, %v, want %v, %v", tt.page, tt.pageSize, page, pageSize, err, tt.wantPage, tt.wantPageSize)
+		}
+	}
+}
+
+func Test_grpcError(t *testing.T) {
+	err := grpcError("Test", api.NewAPIError("Invalid address", true))
+	if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != "Invalid address" {
+		t.Errorf("grpcError(public) = %v", err)
+	}
+	err = grpcError("Test", api.NewAPIError("db failure", false))
+	if status.Code(err) != codes.Internal || status.Convert(err).Message() != "Internal server error" {
+		t.Errorf("grpcError(private) = %v", err)
+	}
+}
+
+func Test_grpcTransaction(t *testing.T) {
+	tx := &api.Tx{
+		Txid:          "txid",
+		Version:       2,
+		Locktime:      100,
+		Blockhash:     "hash",
+		Blockheight:   225493,
+		Confirmations: 3,
+		Blocktime:     1534859988,
+		Size:          225,
+		ValueOutSat:   (*api.Amount)(big.NewInt(1100)),
+		ValueInSat:    (*api.Amount)(big.NewInt(1200)),
+		FeesSat:       (*api.Amount)(big.NewInt(100)),
+		Vin: []api.Vin{
+			{Txid: "in", Vout: 1, Sequence: 4294967295, N: 0, Addresses: []string{"a1"}, ValueSat: (*api.Amount)(big.NewInt(1200))},
+		},
+		Vout: []api.Vout{
+			{ValueSat: (*api.Amount)(big.NewInt(1000)), N: 0, Spent: true, SpentTxID: "spending", SpentIndex: 2, SpentHeight: 225494, Addresses: []string{"a2"}, Type: "pubkeyhash"},
+			{N: 1, Addresses: []string{"a3"}},
+		},
+	}
+	want := &grpcapi.Transaction{
+		Txid:          "txid",
+		Version:       2,
+		Locktime:      100,
+		Blockhash:     "hash",
+		Blockheight:   225493,
+		Confirmations: 3,
+		Blocktime:     1534859988,
+		Size:          225,
+		Value:         "1100",
+		ValueIn:       "1200",
+		Fees:          "100",
+		Vin: []*grpcapi.Vin{
+			{Txid: "in", Vout: 1, Sequence: 4294967295, N: 0, Addresses: []string{"a1"}, Value: "1200"},
+		},
+		Vout: []*grpcapi.Vout{
+			{Value: "1000", N: 0, Spent: true, SpentTxid: "spending", SpentIndex: 2, SpentHeight: 225494, Addresses: []string{"a2"}, Type: "pubkeyhash"},
+			{Value: "", N: 1, Addresses: []string{"a3"}},
+		},
+	}
+	if got := grpcTransaction(tx); !reflect.DeepEqual(got, want) {
+		t.Errorf("grpcTransaction() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_GrpcServer_OnNewBlock(t *testing.T) {
+	s := &GrpcServer{blockSubscriptions: make(map[*grpcSubscription]struct{})}
+	fast := &grpcSubscription{out: make(chan interface{}, 2)}
+	slow := &grpcSubscription{out: make(chan interface{}, 1)}
+	s.blockSubscriptions[fast] = struct{}{}
+	s.blockSubscriptions[slow] = struct{}{}
+
+	s.OnNewBlock("hash1", 1)
+	s.OnNewBlock("hash2", 2)
+
+	var got []*grpcapi.BlockNotification
+	err := fast.run(context.Background(), func(n interface{}) error {
+		got = append(got, n.(*grpcapi.BlockNotification))
+		if len(got) == 2 {
+			s.subscriptionsLock.Lock()
+			fast.close(nil)
+			s.subscriptionsLock.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("run() error = %v", err)
+	}
+	want := []*grpcapi.BlockNotification{{Height: 1, Hash: "hash1"}, {Height: 2, Hash: "hash2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notifications = %+v, want %+v", got, want)
+	}
+
+	// the slow subscription overflowed, it gets the buffered notification and then the error
+	var slowGot int
+	err = slow.run(context.Background(), func(n interface{}) error {
+		slowGot++
+		return nil
+	})
+	if status.Code(err) != codes.ResourceExhausted || slowGot != 1 {
+		t.Errorf("run() of overflowed subscription = %v, %d notifications, want ResourceExhausted, 1", err, slowGot)
+	}
+
+	// notifications after the overflow are dropped
+	s.OnNewBlock("hash3", 3)
+}
+
+func Test_GrpcServer_closeSubscriptions(t *testing.T) {
+	s := &GrpcServer{
+		blockSubscriptions:   make(map[*grpcSubscription]struct{}),
+		addressSubscriptions: make(map[string]map[*grpcSubscription]string),
+	}
+	b := &grpcSubscription{out: make(chan interface{}, 1)}
+	a := &grpcSubscription{out: make(chan interface{}, 1)}
+	s.blockSubscriptions[b] = struct{}{}
+	s.addressSubscriptions["desc"] = map[*grpcSubscription]string{a: "address"}
+	s.closeSubscriptions()
+	for _, sub := range []*grpcSubscription{a, b} {
+		err := sub.run(context.Background(), func(n interface{}) error { return nil })
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("run() after close = %v, want Unavailable", err)
+		}
+	}
+	if err := s.SubscribeBlocks(&grpcapi.SubscribeBlocksRequest{}, nil); status.Code(err) != codes.Unavailable {
+		t.Errorf("SubscribeBlocks() after close = %v, want Unavailable", err)
+	}
+}
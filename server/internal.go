@@ -8,27 +8,40 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const (
+	maxWebhookRequestSize    = 1 << 20
+	defaultWebhookDeliveries = 100
+	maxWebhookDeliveries     = 1000
+)
+
 // InternalServer is handle to internal http server
 type InternalServer struct {
 	https       *http.Server
 	certFiles   string
-	db          *db.RocksDB
+	backupDir   string
+	db          db.IndexStore
 	txCache     *db.TxCache
 	chain       bchain.BlockChain
 	chainParser bchain.BlockChainParser
 	is          *common.InternalState
 	api         *api.Worker
+	webhooks    *WebhookDispatcher
 }
 
 // NewInternalServer creates new internal http interface to blockbook and returns its handle
-func NewInternalServer(binding, certFiles string, db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, is *common.InternalState) (*InternalServer, error) {
+func NewInternalServer(binding, certFiles, backupDir string, db db.IndexStore, chain bchain.BlockChain, txCache *db.TxCache, is *common.InternalState) (*InternalServer, error) {
 	api, err := api.NewWorker(db, chain, txCache, is)
 	if err != nil {
 		return nil, err
@@ -43,6 +56,7 @@ func NewInternalServer(binding, certFiles string, db *db.RocksDB, chain bchain.B
 	s := &InternalServer{
 		https:       https,
 		certFiles:   certFiles,
+		backupDir:   backupDir,
 		db:          db,
 		txCache:     txCache,
 		chain:       chain,
@@ -53,11 +67,20 @@ func NewInternalServer(binding, certFiles string, db *db.RocksDB, chain bchain.B
 
 	serveMux.Handle(path+"favicon.ico", http.FileServer(http.Dir("./static/")))
 	serveMux.HandleFunc(path+"metrics", promhttp.Handler().ServeHTTP)
+	serveMux.HandleFunc(path+"backup", s.backup)
+	serveMux.HandleFunc(path+"dbtuning", s.dbTuning)
+	serveMux.HandleFunc(path+"webhooks", s.webhookList)
+	serveMux.HandleFunc(path+"webhooks/", s.webhook)
 	serveMux.HandleFunc(path, s.index)
 
 	return s, nil
 }
 
+// SetWebhookDispatcher enables the management of the webhooks of the dispatcher
+func (s *InternalServer) SetWebhookDispatcher(wd *WebhookDispatcher) {
+	s.webhooks = wd
+}
+
 // Run starts the server
 func (s *InternalServer) Run() error {
 	if s.certFiles == "" {
@@ -96,3 +119,182 @@ func (s *InternalServer) index(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(buf)
 }
+
+type resultBackup struct {
+	Path       string `json:"path"`
+	BestHeight uint32 `json:"bestHeight"`
+	Duration   string `json:"duration"`
+}
+
+// backup creates a backup of the db in a new subdirectory of the backup directory
+func (s *InternalServer) backup(w http.ResponseWriter, r *http.Request) {
+	if s.backupDir == "" {
+		http.Error(w, "Backups are disabled, specify the backupdir parameter", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed, use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	start := time.Now()
+	height, _, err := s.db.GetBestBlock()
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	path := filepath.Join(s.backupDir, fmt.Sprintf("%s-%d-%s", s.is.Coin, height, start.UTC().Format("20060102150405")))
+	if err = s.db.Backup(path); err != nil {
+		glog.Error("backup: ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf, err := json.MarshalIndent(resultBackup{
+		Path:       path,
+		BestHeight: height,
+		Duration:   time.Since(start).String(),
+	}, "", "    ")
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+// dbTuning returns the tuning profile of the db and the resulting tuning of all its columns
+func (s *InternalServer) dbTuning(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.db.(*db.RocksDB)
+	if !ok {
+		http.Error(w, "DB tuning is available only for RocksDB index", http.StatusNotFound)
+		return
+	}
+	ti, err := d.GetDBTuningInfo()
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	buf, err := json.MarshalIndent(ti, "", "    ")
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf)
+}
+
+func writeInternalJSON(w http.ResponseWriter, status int, data interface{}) {
+	buf, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		glog.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf)
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	if apiErr, ok := err.(*api.APIError); ok && apiErr.Public {
+		http.Error(w, apiErr.Error(), http.StatusBadRequest)
+		return
+	}
+	glog.Error("webhooks: ", err)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// webhookList returns the registered webhooks (GET) or registers a new webhook (POST),
+// the secret is returned only in the response of the registration
+func (s *InternalServer) webhookList(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		http.Error(w, "Webhooks are disabled, specify the webhooks parameter", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeInternalJSON(w, http.StatusOK, s.webhooks.GetWebhooks())
+	case http.MethodPost:
+		var wh db.Webhook
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookRequestSize)).Decode(&wh); err != nil {
+			http.Error(w, "Invalid webhook: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rwh, err := s.webhooks.AddWebhook(&wh)
+		if err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		writeInternalJSON(w, http.StatusCreated, rwh)
+	default:
+		http.Error(w, "Method not allowed, use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// webhook returns (GET) or removes (DELETE) the webhook webhooks/<id> or returns its delivery log webhooks/<id>/deliveries,
+// the delivery log can be filtered by the query parameters status, before (sequence number) and limit
+func (s *InternalServer) webhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		http.Error(w, "Webhooks are disabled, specify the webhooks parameter", http.StatusNotFound)
+		return
+	}
+	i := strings.LastIndex(r.URL.Path, "webhooks/")
+	id := r.URL.Path[i+len("webhooks/"):]
+	deliveries := strings.HasSuffix(id, "/deliveries")
+	if deliveries {
+		id = strings.TrimSuffix(id, "/deliveries")
+	}
+	if s.webhooks.GetWebhook(id) == nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	switch {
+	case deliveries && r.Method == http.MethodGet:
+		s.webhookDeliveries(w, r, id)
+	case !deliveries && r.Method == http.MethodGet:
+		writeInternalJSON(w, http.StatusOK, s.webhooks.GetWebhook(id))
+	case !deliveries && r.Method == http.MethodDelete:
+		if _, err := s.webhooks.DeleteWebhook(id); err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *InternalServer) webhookDeliveries(w http.ResponseWriter, r *http.Request, id string) {
+	var err error
+	var before uint64
+	limit := defaultWebhookDeliveries
+	status := r.URL.Query().Get("status")
+	if status != "" && status != db.WebhookDeliveryPending && status != db.WebhookDeliveryDelivered && status != db.WebhookDeliveryFailed {
+		http.Error(w, "Invalid status, expecting pending, delivered or failed", http.StatusBadRequest)
+		return
+	}
+	if b := r.URL.Query().Get("before"); b != "" {
+		if before, err = strconv.ParseUint(b, 10, 64); err != nil {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 || limit > maxWebhookDeliveries {
+			http.Error(w, fmt.Sprintf("Invalid limit, expecting 1-%d", maxWebhookDeliveries), http.StatusBadRequest)
+			return
+		}
+	}
+	wds, err := s.webhooks.GetWebhookDeliveries(id, status, before, limit)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if wds == nil {
+		wds = []*db.WebhookDelivery{}
+	}
+	writeInternalJSON(w, http.StatusOK, wds)
+}
@@ -0,0 +1,532 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain"
+	"blockbook/common"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The specification of the v2 API. The same specification is used to generate the OpenAPI document
+// served at api/v2/openapi.json and to validate the parameters of the api requests in jsonHandler.
+
+type apiParam struct {
+	name        string
+	in          string // path or query
+	typ         string // string, integer or boolean
+	minimum     int    // minimum of the integer parameter
+	enum        []string
+	required    bool
+	description string
+}
+
+type apiEndpoint struct {
+	// name is the first part of the url path following api/[v1/|v2/], joined by '-' with the part following
+	// the path parameter if there is one, it is used to find the endpoint of the request in jsonHandler
+	name    string
+	path    string
+	method  string
+	summary string
+	// description is the optional longer description of the endpoint
+	description string
+	params      []apiParam
+	// result is a value of the type returned by the endpoint
+	result interface{}
+	// export endpoints stream the results as csv or json lines
+	export bool
+	// chainType limits the endpoint to the chain type
+	chainType bchain.ChainType
+}
+
+// anyChainType marks the endpoints available for all chain types
+const anyChainType bchain.ChainType = -1
+
+func pageParam() apiParam {
+	return apiParam{name: "page", in: "query", typ: "integer", description: "page of the returned data, starting from 1"}
+}
+
+var apiV2Endpoints = []apiEndpoint{
+	{
+		name: "", path: "/api/v2/", method: http.MethodGet, chainType: anyChainType,
+		summary: "Status of blockbook and backend",
+		result:  api.SystemInfo{},
+	},
+	{
+		name: "block-index", path: "/api/v2/block-index/{height}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Hash of the block at height, the best block hash if height is not set",
+		params: []apiParam{
+			{name: "height", in: "path", typ: "integer", required: true},
+		},
+		result: resBlockIndex{},
+	},
+	{
+		name: "tx", path: "/api/v2/tx/{txid}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Transaction",
+		params: []apiParam{
+			{name: "txid", in: "path", typ: "string", required: true},
+			{name: "spending", in: "query", typ: "boolean", description: "find the transactions spending the outputs"},
+		},
+		result: api.Tx{},
+	},
+	{
+		name: "tx-specific", path: "/api/v2/tx-specific/{txid}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Transaction in the coin specific format of the backend",
+		params: []apiParam{
+			{name: "txid", in: "path", typ: "string", required: true},
+		},
+		result: json.RawMessage{},
+	},
+	{
+		name: "address", path: "/api/v2/address/{address}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Balances and transactions of the address",
+		params: []apiParam{
+			{name: "address", in: "path", typ: "string", required: true},
+			pageParam(),
+			{name: "from", in: "query", typ: "integer", description: "return only transactions from the block height"},
+			{name: "to", in: "query", typ: "integer", description: "return only transactions up to the block height"},
+			{name: "vout", in: "query", typ: "integer", description: "return only transactions with the address in the output with index vout"},
+			{name: "cursor", in: "query", typ: "string", description: "return transactions from the cursor instead of the page, tip for the first transactions, nextCursor of the response for the following ones"},
+		},
+		result: api.Address{},
+	},
+	{
+		name: "address-export", path: "/api/v2/address/{address}/export", method: http.MethodGet, chainType: bchain.ChainBitcoinType,
+		summary: "Export of the confirmed transactions of the address with the running balance, the oldest first",
+		description: "The export ends by the row `#complete,<txs>` in csv or by the record `{\"complete\":true,\"txs\":<txs>}` in json lines. " +
+			"If the export fails after it was started, it ends by the row `#error,<txs>,<error>` or by the record `{\"complete\":false,\"txs\":<txs>,\"error\":<error>}`. " +
+			"An export without the completion record is incomplete.",
+		params: []apiParam{
+			{name: "address", in: "path", typ: "string", required: true},
+			{name: "format", in: "query", typ: "string", enum: []string{"csv", "jsonl"}, description: "format of the export, csv by default"},
+			{name: "from", in: "query", typ: "integer", description: "export only transactions from the block height"},
+			{name: "to", in: "query", typ: "integer", description: "export only transactions up to the block height"},
+		},
+		result: api.AddressExportTx{},
+		export: true,
+	},
+	{
+		name: "utxo", path: "/api/v2/utxo/{address}", method: http.MethodGet, chainType: bchain.ChainBitcoinType,
+		summary: "Unspent outputs of the address",
+		params: []apiParam{
+			{name: "address", in: "path", typ: "string", required: true},
+			{name: "confirmed", in: "query", typ: "boolean", description: "return only confirmed outputs"},
+		},
+		result: []api.AddressUtxo{},
+	},
+	{
+		name: "block", path: "/api/v2/block/{block}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Block with its transactions",
+		params: []apiParam{
+			{name: "block", in: "path", typ: "string", required: true, description: "block height or hash"},
+			pageParam(),
+		},
+		result: api.Block{},
+	},
+	{
+		name: "sendtx", path: "/api/v2/sendtx/{hex}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Broadcast the transaction",
+		params: []apiParam{
+			{name: "hex", in: "path", typ: "string", required: true},
+		},
+		result: resultSendTransaction{},
+	},
+	{
+		name: "sendtx", path: "/api/v2/sendtx/", method: http.MethodPost, chainType: anyChainType,
+		summary: "Broadcast the transaction passed in the request body",
+		result:  resultSendTransaction{},
+	},
+	{
+		name: "estimatefee", path: "/api/v2/estimatefee/{blocks}", method: http.MethodGet, chainType: anyChainType,
+		summary: "Estimated fee per kilobyte for the confirmation within the number of blocks",
+		params: []apiParam{
+			{name: "blocks", in: "path", typ: "integer", minimum: 1, required: true},
+			{name: "conservative", in: "query", typ: "boolean"},
+		},
+		result: resultEstimateFeeAsString{},
+	},
+	{
+		name: "token-holders", path: "/api/v2/token/{contract}/holders", method: http.MethodGet, chainType: bchain.ChainEthereumType,
+		summary: "Holders of the ERC20 token sorted by balance",
+		params: []apiParam{
+			{name: "contract", in: "path", typ: "string", required: true},
+			pageParam(),
+		},
+		result: api.ContractHolders{},
+	},
+	{
+		name: "logs", path: "/api/v2/logs", method: http.MethodGet, chainType: bchain.ChainEthereumType,
+		summary: "Indexed receipt logs of the address",
+		params: []apiParam{
+			{name: "address", in: "query", typ: "string", required: true},
+			{name: "topic0", in: "query", typ: "string"},
+			{name: "from", in: "query", typ: "integer", description: "return only logs from the block height, the logs are indexed since the log index was enabled"},
+			{name: "to", in: "query", typ: "integer"},
+			pageParam(),
+		},
+		result: api.Logs{},
+	},
+}
+
+func findAPIEndpoint(name, method string) *apiEndpoint {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	for i := range apiV2Endpoints {
+		e := &apiV2Endpoints[i]
+		if e.name == name && e.method == method {
+			return e
+		}
+	}
+	return nil
+}
+
+// splitAPIPath splits the url path of the api request to the name of the endpoint and the path parameter,
+// the path parameter follows the first part of the path, the name of the endpoints in the form <name>/<param>/<action>
+// (for example address/<address>/export) is <name>-<action>
+func splitAPIPath(p string) (string, string) {
+	i := strings.Index(p, "api/")
+	if i < 0 {
+		return "", ""
+	}
+	p = p[i+4:]
+	if strings.HasPrefix(p, "v1/") || strings.HasPrefix(p, "v2/") {
+		p = p[3:]
+	}
+	parts := strings.SplitN(p, "/", 3)
+	switch {
+	case len(parts) == 1:
+		return parts[0], ""
+	case len(parts) == 3 && parts[2] != "":
+		return parts[0] + "-" + strings.TrimSuffix(parts[2], "/"), parts[1]
+	default:
+		return parts[0], parts[1]
+	}
+}
+
+// validateAPIRequest checks the parameters of the api request against the api specification,
+// the specification describes only the v2 API, the requests of the other versions are not validated
+func validateAPIRequest(r *http.Request, apiVersion int) error {
+	if apiVersion != apiV2 {
+		return nil
+	}
+	name, pathParam := splitAPIPath(r.URL.Path)
+	e := findAPIEndpoint(name, r.Method)
+	if e == nil {
+		return nil
+	}
+	return validateAPIParams(e, r, pathParam)
+}
+
+// validateAPIParams checks the parameters of the request against the specification of the endpoint
+func validateAPIParams(e *apiEndpoint, r *http.Request, pathParam string) error {
+	q := r.URL.Query()
+	for i := range e.params {
+		p := &e.params[i]
+		var v string
+		if p.in == "path" {
+			// the path parameters are sometimes optional in the implementation, validate only the present ones
+			v = pathParam
+		} else {
+			v = q.Get(p.name)
+			if v == "" && p.required {
+				return api.NewAPIError(fmt.Sprintf("Missing parameter '%s'", p.name), true)
+			}
+		}
+		if v == "" {
+			continue
+		}
+		switch p.typ {
+		case "integer":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return api.NewAPIError(fmt.Sprintf("Parameter '%s' is not a number", p.name), true)
+			}
+			if n < p.minimum {
+				return api.NewAPIError(fmt.Sprintf("Parameter '%s' must be greater than or equal to %d", p.name, p.minimum), true)
+			}
+		case "boolean":
+			if _, err := strconv.ParseBool(v); err != nil {
+				return api.NewAPIError(fmt.Sprintf("Parameter '%s' cannot be converted to boolean", p.name), true)
+			}
+		}
+		if len(p.enum) > 0 && !enumContains(p.enum, v) {
+			return api.NewAPIError(fmt.Sprintf("Parameter '%s' must be one of %s", p.name, strings.Join(p.enum, ", ")), true)
+		}
+	}
+	return nil
+}
+
+func enumContains(enum []string, v string) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPISchemas generates OpenAPI schemas of go types following the rules of encoding/json,
+// named struct types are stored as components and referenced
+type openAPISchemas struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+type openAPISchema map[string]interface{}
+
+var (
+	amountType     = reflect.TypeOf(api.Amount{})
+	bigIntType     = reflect.TypeOf(big.Int{})
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	numberType     = reflect.TypeOf(json.Number(""))
+)
+
+func newOpenAPISchemas() *openAPISchemas {
+	return &openAPISchemas{
+		components: make(map[string]interface{}),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+func (o *openAPISchemas) schema(t reflect.Type) openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case amountType:
+		return openAPISchema{"type": "string", "description": "amount in the base units of the coin"}
+	case bigIntType:
+		return openAPISchema{"type": "integer"}
+	case timeType:
+		return openAPISchema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return openAPISchema{}
+	case numberType:
+		return openAPISchema{"type": "number"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return openAPISchema{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return openAPISchema{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return openAPISchema{"type": "integer", "format": "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return openAPISchema{"type": "integer", "format": "int32", "minimum": 0}
+	case reflect.Uint, reflect.Uint64:
+		return openAPISchema{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return openAPISchema{"type": "number"}
+	case reflect.String:
+		return openAPISchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openAPISchema{"type": "string", "format": "byte"}
+		}
+		return openAPISchema{"type": "array", "items": o.schema(t.Elem())}
+	case reflect.Map:
+		return openAPISchema{"type": "object", "additionalProperties": o.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return o.structSchema(t)
+		}
+		name, ok := o.names[t]
+		if !ok {
+			name = o.componentName(t)
+			o.names[t] = name
+			// register the name before the fields are processed to handle recursive types
+			o.components[name] = nil
+			o.components[name] = o.structSchema(t)
+		}
+		return openAPISchema{"$ref": "#/components/schemas/" + name}
+	}
+	// interfaces and other types can contain any value
+	return openAPISchema{}
+}
+
+// componentName returns the name of the type, prefixed by the package name if the name is used by another type
+func (o *openAPISchemas) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, used := o.components[name]; used {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+func (o *openAPISchemas) structSchema(t reflect.Type) openAPISchema {
+	properties := make(map[string]interface{})
+	var required []string
+	o.addFields(t, properties, &required)
+	s := openAPISchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (o *openAPISchemas) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		omitempty := false
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name = tag[:j]
+			omitempty = strings.Contains(tag[j:], ",omitempty")
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// fields of embedded structs without json name are promoted
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			o.addFields(ft, properties, required)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		properties[name] = o.schema(f.Type)
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// openAPIDocument generates the OpenAPI document of the v2 API of the chain type
+func openAPIDocument(chainType bchain.ChainType, serverPath string) interface{} {
+	o := newOpenAPISchemas()
+	errorSchema := openAPISchema{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": openAPISchema{"type": "string"}},
+		"required":   []string{"error"},
+	}
+	o.components["Error"] = errorSchema
+	paths := make(map[string]map[string]interface{})
+	for i := range apiV2Endpoints {
+		e := &apiV2Endpoints[i]
+		if e.chainType != anyChainType && e.chainType != chainType {
+			continue
+		}
+		var params []interface{}
+		for _, p := range e.params {
+			s := openAPISchema{"type": p.typ}
+			if p.typ == "integer" {
+				s["minimum"] = p.minimum
+			}
+			if len(p.enum) > 0 {
+				s["enum"] = p.enum
+			}
+			param := map[string]interface{}{
+				"name":     p.name,
+				"in":       p.in,
+				"required": p.required,
+				"schema":   s,
+			}
+			if p.description != "" {
+				param["description"] = p.description
+			}
+			params = append(params, param)
+		}
+		errorResponse := map[string]interface{}{
+			"description": "error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": openAPISchema{"$ref": "#/components/schemas/Error"}},
+			},
+		}
+		content := map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": o.schema(reflect.TypeOf(e.result))},
+		}
+		if e.export {
+			content = map[string]interface{}{
+				"text/csv":             map[string]interface{}{"schema": openAPISchema{"type": "string"}},
+				"application/x-ndjson": map[string]interface{}{"schema": o.schema(reflect.TypeOf(e.result))},
+			}
+		}
+		op := map[string]interface{}{
+			"summary":     e.summary,
+			"operationId": strings.ToLower(e.method) + openAPIOperationName(e.path),
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "success",
+					"content":     content,
+				},
+				"400": errorResponse,
+				"500": errorResponse,
+			},
+		}
+		if e.description != "" {
+			op["description"] = e.description
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if e.method == http.MethodPost {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"text/plain": map[string]interface{}{"schema": openAPISchema{"type": "string"}},
+				},
+			}
+		}
+		pi, ok := paths[e.path]
+		if !ok {
+			pi = make(map[string]interface{})
+			paths[e.path] = pi
+		}
+		pi[strings.ToLower(e.method)] = op
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Blockbook API",
+			"version": common.GetVersionInfo().Version,
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": strings.TrimSuffix(serverPath, "/")}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": o.components},
+	}
+}
+
+// openAPIOperationName converts the path to the camel case name, e.g. /api/v2/block-index/{height} to BlockIndex
+func openAPIOperationName(p string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(strings.TrimPrefix(p, "/api/v2/"), "/") {
+		if part == "" || strings.HasPrefix(part, "{") {
+			continue
+		}
+		for _, w := range strings.Split(part, "-") {
+			if w != "" {
+				b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return "Index"
+	}
+	return b.String()
+}
+
+func (s *PublicServer) apiOpenAPI(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-openapi"}).Inc()
+	return s.openAPI, nil
+}
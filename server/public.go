@@ -5,11 +5,13 @@ import (
 	"blockbook/bchain"
 	"blockbook/common"
 	"blockbook/db"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"net/http"
 	"reflect"
@@ -17,6 +19,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -39,7 +42,7 @@ type PublicServer struct {
 	socketio         *SocketIoServer
 	websocket        *WebsocketServer
 	https            *http.Server
-	db               *db.RocksDB
+	db               db.IndexStore
 	txCache          *db.TxCache
 	chain            bchain.BlockChain
 	chainParser      bchain.BlockChainParser
@@ -50,11 +53,19 @@ type PublicServer struct {
 	is               *common.InternalState
 	templates        []*template.Template
 	debug            bool
+	// cache of the fee estimates of the Esplora API
+	esploraFeeEstimatesCache map[string]float64
+	esploraFeeEstimatesTime  time.Time
+	esploraFeeEstimatesLock  sync.Mutex
+	// OpenAPI document of the v2 API
+	openAPI     interface{}
+	rateLimiter *RateLimiter
+	graphQL     *gqlSchema
 }
 
 // NewPublicServer creates new public server http interface to blockbook and returns its handle
 // only basic functionality is mapped, to map all functions, call
-func NewPublicServer(binding string, certFiles string, db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, explorerURL string, metrics *common.Metrics, is *common.InternalState, debugMode bool) (*PublicServer, error) {
+func NewPublicServer(binding string, certFiles string, db db.IndexStore, chain bchain.BlockChain, txCache *db.TxCache, explorerURL string, metrics *common.Metrics, is *common.InternalState, debugMode bool) (*PublicServer, error) {
 
 	api, err := api.NewWorker(db, chain, txCache, is)
 	if err != nil {
@@ -108,6 +119,13 @@ func NewPublicServer(binding string, certFiles string, db *db.RocksDB, chain bch
 	return s, nil
 }
 
+// SetRateLimiter sets the limiter of the requests of the api, esplora, websocket and socket.io interfaces, it must be called before Run
+func (s *PublicServer) SetRateLimiter(l *RateLimiter) {
+	s.rateLimiter = l
+	s.websocket.rateLimiter = l
+	s.socketio.rateLimiter = l
+}
+
 // Run starts the server
 func (s *PublicServer) Run() error {
 	if s.certFiles == "" {
@@ -119,7 +137,8 @@ func (s *PublicServer) Run() error {
 }
 
 // ConnectFullPublicInterface enables complete public functionality
-func (s *PublicServer) ConnectFullPublicInterface() {
+// if esploraPath is set, the Esplora compatible REST API of BitcoinType coins is served under the path esploraPath
+func (s *PublicServer) ConnectFullPublicInterface(esploraPath string) {
 	serveMux := s.https.Handler.(*http.ServeMux)
 	_, path := splitBinding(s.binding)
 	// support for test pages
@@ -171,11 +190,22 @@ func (s *PublicServer) ConnectFullPublicInterface() {
 	serveMux.HandleFunc(path+"api/v2/block-index/", s.jsonHandler(s.apiBlockIndex, apiV2))
 	serveMux.HandleFunc(path+"api/v2/tx-specific/", s.jsonHandler(s.apiTxSpecific, apiV2))
 	serveMux.HandleFunc(path+"api/v2/tx/", s.jsonHandler(s.apiTx, apiV2))
-	serveMux.HandleFunc(path+"api/v2/address/", s.jsonHandler(s.apiAddress, apiV2))
+	serveMux.HandleFunc(path+"api/v2/address/", s.addressExportHandler(s.jsonHandler(s.apiAddress, apiV2)))
 	serveMux.HandleFunc(path+"api/v2/utxo/", s.jsonHandler(s.apiAddressUtxo, apiV2))
 	serveMux.HandleFunc(path+"api/v2/block/", s.jsonHandler(s.apiBlock, apiV2))
 	serveMux.HandleFunc(path+"api/v2/sendtx/", s.jsonHandler(s.apiSendTx, apiV2))
 	serveMux.HandleFunc(path+"api/v2/estimatefee/", s.jsonHandler(s.apiEstimateFee, apiV2))
+	serveMux.HandleFunc(path+"api/v2/token/", s.jsonHandler(s.apiTokenHolders, apiV2))
+	serveMux.HandleFunc(path+"api/v2/logs", s.jsonHandler(s.apiLogs, apiV2))
+	s.openAPI = openAPIDocument(s.chainParser.GetChainType(), path)
+	serveMux.HandleFunc(path+"api/v2/openapi.json", s.jsonHandler(s.apiOpenAPI, apiV2))
+	s.graphQL = newGraphQLSchema()
+	serveMux.HandleFunc(path+"api/graphql", s.apiGraphQL)
+	// Esplora compatible REST API
+	if esploraPath != "" && s.chainParser.GetChainType() == bchain.ChainBitcoinType {
+		ep := path + strings.Trim(esploraPath, "/") + "/"
+		serveMux.HandleFunc(ep, s.esploraHandler(ep))
+	}
 	// socket.io interface
 	serveMux.Handle(path+"socket.io/", s.socketio.GetHandler())
 	// websocket interface
@@ -259,10 +289,25 @@ func (s *PublicServer) jsonHandler(handler func(r *http.Request, apiVersion int)
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
 			if e, isError := data.(jsonError); isError {
 				w.WriteHeader(e.HTTPStatus)
+				json.NewEncoder(w).Encode(data)
+				return
 			}
-			json.NewEncoder(w).Encode(data)
+			var body bytes.Buffer
+			json.NewEncoder(&body).Encode(data)
+			if s.setCacheHeaders(w, r, data, body.Bytes()) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write(body.Bytes())
 		}()
-		data, err = handler(r, apiVersion)
+		if ok, wait := s.rateLimiter.allow("http", s.rateLimiter.getClient(r.RemoteAddr, r.Header, r.URL.Query())); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			data = jsonError{"Too many requests", http.StatusTooManyRequests}
+			return
+		}
+		if err = validateAPIRequest(r, apiVersion); err == nil {
+			data, err = handler(r, apiVersion)
+		}
 		if err != nil || data == nil {
 			if apiErr, ok := err.(*api.APIError); ok {
 				if apiErr.Public {
@@ -520,7 +565,12 @@ func (s *PublicServer) explorerAddress(w http.ResponseWriter, r *http.Request) (
 				}
 			}
 		}
-		address, err = s.api.GetAddress(r.URL.Path[i+1:], page, txsOnPage, api.TxHistoryLight, &api.AddressFilter{Vout: fn})
+		af := &api.AddressFilter{Vout: fn}
+		// pruned index can show only the history above the pruned height
+		if ph := s.is.GetPrunedHeight(); ph > 0 {
+			af.FromHeight = ph + 1
+		}
+		address, err = s.api.GetAddress(r.URL.Path[i+1:], page, txsOnPage, api.TxHistoryLight, af)
 		if err != nil {
 			return errorTpl, nil, err
 		}
@@ -698,10 +748,11 @@ func (s *PublicServer) apiIndex(r *http.Request, apiVersion int) (interface{}, e
 	return s.api.GetSystemInfo(false)
 }
 
+type resBlockIndex struct {
+	BlockHash string `json:"blockHash"`
+}
+
 func (s *PublicServer) apiBlockIndex(r *http.Request, apiVersion int) (interface{}, error) {
-	type resBlockIndex struct {
-		BlockHash string `json:"blockHash"`
-	}
 	var err error
 	var hash string
 	height := -1
@@ -766,7 +817,22 @@ func (s *PublicServer) apiAddress(r *http.Request, apiVersion int) (interface{},
 		if ec != nil {
 			page = 0
 		}
-		address, err = s.api.GetAddress(r.URL.Path[i+1:], page, txsInAPI, api.TxidHistory, &api.AddressFilter{Vout: api.AddressFilterVoutOff})
+		af := &api.AddressFilter{Vout: api.AddressFilterVoutOff}
+		if from, ec := strconv.Atoi(r.URL.Query().Get("from")); ec == nil && from > 0 {
+			af.FromHeight = uint32(from)
+		}
+		if to, ec := strconv.Atoi(r.URL.Query().Get("to")); ec == nil && to > 0 {
+			af.ToHeight = uint32(to)
+		}
+		if vout, ec := strconv.Atoi(r.URL.Query().Get("vout")); ec == nil && vout >= 0 {
+			af.Vout = vout
+		}
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			if af.Cursor, err = api.ParseTxCursor(c); err != nil {
+				return nil, err
+			}
+		}
+		address, err = s.api.GetAddress(r.URL.Path[i+1:], page, txsInAPI, api.TxidHistory, af)
 		if err == nil && apiVersion == apiV1 {
 			return s.api.AddressToV1(address), nil
 		}
@@ -812,6 +878,48 @@ func (s *PublicServer) apiBlock(r *http.Request, apiVersion int) (interface{}, e
 	return block, err
 }
 
+func (s *PublicServer) apiTokenHolders(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-token-holders"}).Inc()
+	// the path is in the form token/<contract>/holders
+	p := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(p) < 3 || p[len(p)-1] != "holders" || p[len(p)-3] != "token" {
+		return nil, api.NewAPIError("Invalid request, expecting token/<contract>/holders", true)
+	}
+	page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	return s.api.GetContractHolders(p[len(p)-2], page, txsInAPI)
+}
+
+func (s *PublicServer) apiLogs(r *http.Request, apiVersion int) (interface{}, error) {
+	s.metrics.ExplorerViews.With(common.Labels{"action": "api-logs"}).Inc()
+	q := r.URL.Query()
+	address := q.Get("address")
+	if address == "" {
+		return nil, api.NewAPIError("Missing parameter 'address'", true)
+	}
+	var from, to uint64
+	var err error
+	if f := q.Get("from"); f != "" {
+		from, err = strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return nil, api.NewAPIError("Parameter from is not a number", true)
+		}
+	}
+	if t := q.Get("to"); t != "" {
+		to, err = strconv.ParseUint(t, 10, 32)
+		if err != nil {
+			return nil, api.NewAPIError("Parameter to is not a number", true)
+		}
+	}
+	page, ec := strconv.Atoi(q.Get("page"))
+	if ec != nil {
+		page = 0
+	}
+	return s.api.GetLogs(address, q.Get("topic0"), uint32(from), uint32(to), page, txsInAPI)
+}
+
 type resultSendTransaction struct {
 	Result string `json:"result"`
 }
@@ -38,7 +38,7 @@ func setupRocksDB(t *testing.T, parser bchain.BlockChainParser) (*db.RocksDB, *c
 	if err != nil {
 		t.Fatal(err)
 	}
-	d, err := db.NewRocksDB(tmp, 100000, -1, parser, nil)
+	d, err := db.NewRocksDB(tmp, 100000, -1, parser, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -436,6 +436,64 @@ func httpTests_BitcoinType(t *testing.T, ts *httptest.Server) {
 				`{"page":1,"totalPages":1,"itemsOnPage":1000,"addrStr":"mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw","balance":"0","totalReceived":"1234567890123","totalSent":"1234567890123","unconfirmedBalance":"0","unconfirmedTxApperances":0,"txApperances":2,"txids":["7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25","effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75"]}`,
 			},
 		},
+		{
+			name:        "apiAddress cursor start",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw?cursor=tip"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"addrStr":"mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw","balance":"0","totalReceived":"1234567890123","totalSent":"1234567890123","unconfirmedBalance":"0","unconfirmedTxApperances":0,"txApperances":2,"txids":["7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25","effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75"]}`,
+			},
+		},
+		{
+			name:        "apiAddress cursor",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw?cursor=225494:1"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`"txApperances":2,"txids":["effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75"]}`,
+			},
+		},
+		{
+			name:        "apiAddress invalid cursor",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw?cursor=225494"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Invalid cursor"}`,
+			},
+		},
+		{
+			name:        "apiAddressExport csv",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw/export"),
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body: []string{
+				"txid,blockHash,blockHeight,blockTime,received,sent,fees,netValue,balance\n" +
+					"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75,0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997,225493,2018-08-21T13:27:01Z,1234567890123,0,0,1234567890123,1234567890123\n" +
+					"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25,00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6,225494,2018-08-21T13:45:23Z,0,1234567890123,346,-1234567890123,0\n" +
+					"#complete,2\n",
+			},
+		},
+		{
+			name:        "apiAddressExport jsonl from",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw/export?format=jsonl&from=225494"),
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson; charset=utf-8",
+			body: []string{
+				`{"txid":"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25","blockHash":"00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6","blockHeight":225494,"blockTime":1534859123,"received":"0","sent":"1234567890123","fees":"346","netValue":"-1234567890123","balance":"0"}` + "\n" +
+					`{"complete":true,"txs":1}` + "\n",
+			},
+		},
+		{
+			name:        "apiAddressExport invalid format",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw/export?format=xls"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Parameter 'format' must be one of csv, jsonl"}`,
+			},
+		},
 		{
 			name:        "apiAddressUtxo v1",
 			r:           newGetRequest(ts.URL + "/api/v1/utxo/mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL"),
@@ -490,6 +548,220 @@ func httpTests_BitcoinType(t *testing.T, ts *httptest.Server) {
 				`{"result":"0.00012299"}`,
 			},
 		},
+		{
+			name:        "apiEstimateFee invalid blocks",
+			r:           newGetRequest(ts.URL + "/api/v2/estimatefee/0"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Parameter 'blocks' must be greater than or equal to 1"}`,
+			},
+		},
+		{
+			name:        "apiAddress invalid page",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz?page=x"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Parameter 'page' is not a number"}`,
+			},
+		},
+		{
+			name:        "apiAddress v1 invalid page",
+			r:           newGetRequest(ts.URL + "/api/v1/address/mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw?page=x"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"page":1,"totalPages":1,"itemsOnPage":1000,"addrStr":"mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw"`,
+			},
+		},
+		{
+			name:        "apiAddress invalid vout",
+			r:           newGetRequest(ts.URL + "/api/v2/address/mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz?vout=-1"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Parameter 'vout' must be greater than or equal to 0"}`,
+			},
+		},
+		{
+			name:        "apiAddressUtxo invalid confirmed",
+			r:           newGetRequest(ts.URL + "/api/v2/utxo/mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz?confirmed=maybe"),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"error":"Parameter 'confirmed' cannot be converted to boolean"}`,
+			},
+		},
+		{
+			name:        "apiOpenAPI",
+			r:           newGetRequest(ts.URL + "/api/v2/openapi.json"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`"openapi":"3.0.3"`,
+				`"/api/v2/tx/{txid}":{"get":{`,
+				`"AddressUtxo":{"properties":{`,
+				`"description":"The export ends by the row`,
+				`"Error":{"properties":{"error":{"type":"string"}},"required":["error"],"type":"object"}`,
+			},
+		},
+		{
+			name:        "apiGraphQL schema",
+			r:           newGetRequest(ts.URL + "/api/graphql"),
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`address(address: String!, page: Int = 1, pageSize: Int = 25, from: Int, to: Int, cursor: String): Address`,
+				`spendingTx: Tx`,
+			},
+		},
+		{
+			name:        "apiGraphQL tx",
+			r:           newGetRequest(ts.URL + "/api/graphql?query=" + url.QueryEscape(`{tx(txid:"05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07"){txid fees vin{vout tx{txid blockheight}} block{height}}}`)),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"data":{"tx":{"txid":"05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07","fees":"876","vin":[{"vout":2,"tx":{"txid":"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75","blockheight":225493}}],"block":{"height":225494}}}}`,
+			},
+		},
+		{
+			name:        "apiGraphQL spendingTx",
+			r:           newGetRequest(ts.URL + "/api/graphql?query=" + url.QueryEscape(`{tx(txid:"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75"){vout{n spendingTx{txid}}}}`)),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"n":2,"spendingTx":{"txid":"05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07"}}`,
+			},
+		},
+		{
+			name:        "apiGraphQL address POST",
+			r:           newPostRequest(ts.URL+"/api/graphql", `{"query":"query A($a: String!) { address(address: $a) { addrStr balance txApperances transactions { txid blockheight } } }","variables":{"a":"mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw"}}`),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"data":{"address":{"addrStr":"mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw","balance":"0","txApperances":2,"transactions":[{"txid":"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25","blockheight":225494},{"txid":"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75","blockheight":225493}]}}}`,
+			},
+		},
+		{
+			name:        "apiGraphQL unknown field",
+			r:           newGetRequest(ts.URL + "/api/graphql?query=" + url.QueryEscape(`{tx(txid:"abc"){foo}}`)),
+			status:      http.StatusBadRequest,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"errors":[{"message":"Cannot query field \"foo\" on type \"Tx\""}]}`,
+			},
+		},
+		{
+			name:        "esploraTipHeight",
+			r:           newGetRequest(ts.URL + "/esplora/blocks/tip/height"),
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`225494`,
+			},
+		},
+		{
+			name:        "esploraBlockHeight",
+			r:           newGetRequest(ts.URL + "/esplora/block-height/225493"),
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997`,
+			},
+		},
+		{
+			name:        "esploraTx",
+			r:           newGetRequest(ts.URL + "/esplora/tx/05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"txid":"05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07","version":0,"locktime":0,"vin":[{"txid":"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75","vout":2,"prevout":{"scriptpubkey":"a914e921fc4912a315078f370d959f2c4f7b6d2a683c87","scriptpubkey_type":"p2sh","scriptpubkey_address":"2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1","value":9876},"scriptsig":"","is_coinbase":false,"sequence":0}],"vout":[{"scriptpubkey":"a914e921fc4912a315078f370d959f2c4f7b6d2a683c87","scriptpubkey_type":"p2sh","scriptpubkey_address":"2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1","value":9000}]`,
+				`"fee":876,"status":{"confirmed":true,"block_height":225494,"block_hash":"00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6","block_time":22549400002}}`,
+			},
+		},
+		{
+			name:        "esploraTxStatus",
+			r:           newGetRequest(ts.URL + "/esplora/tx/05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07/status"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"confirmed":true,"block_height":225494,"block_hash":"00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6","block_time":22549400002}`,
+			},
+		},
+		{
+			name:        "esploraTxStatus - not found",
+			r:           newGetRequest(ts.URL + "/esplora/tx/1232e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07/status"),
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`Tx not found, Not found`,
+			},
+		},
+		{
+			name:        "esploraTxOutspends",
+			r:           newGetRequest(ts.URL + "/esplora/tx/7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25/outspends"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`[{"spent":true,"txid":"3d90d15ed026dc45e19ffb52875ed18fa9e8012ad123d7f7212176e2b0ebdb71","vin":0,"status":{"confirmed":true,"block_height":225494,"block_hash":"00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6"`,
+				`{"spent":false}]`,
+			},
+		},
+		{
+			name:        "esploraAddressTxs",
+			r:           newGetRequest(ts.URL + "/esplora/address/mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL/txs/chain"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`[{"txid":"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25"`,
+			},
+		},
+		{
+			name:        "esploraAddressTxs - after last seen",
+			r:           newGetRequest(ts.URL + "/esplora/address/mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL/txs/chain/7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`[]`,
+			},
+		},
+		{
+			name:        "esploraFeeEstimates",
+			r:           newGetRequest(ts.URL + "/esplora/fee-estimates"),
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body: []string{
+				`{"1":0.1,"10":1,"1008":100.8,"11":1.1,`,
+			},
+		},
+		{
+			name:        "esploraBroadcast",
+			r:           newPostRequest(ts.URL+"/esplora/tx", "123456"),
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`9876`,
+			},
+		},
+		{
+			name:        "esploraBroadcast too large",
+			r:           newPostRequest(ts.URL+"/esplora/tx", strings.Repeat("0", esploraMaxBroadcastSize+1)),
+			status:      http.StatusRequestEntityTooLarge,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`Request is too large`,
+			},
+		},
+		{
+			name:        "esplora - not found",
+			r:           newGetRequest(ts.URL + "/esplora/blocks/tip/xyz"),
+			status:      http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+			body: []string{
+				`Not found`,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -624,7 +896,7 @@ func socketioTests_BitcoinType(t *testing.T, ts *httptest.Server) {
 func Test_PublicServer_BitcoinType(t *testing.T) {
 	s, dbpath := setupPublicHTTPServer(t)
 	defer closeAndDestroyPublicServer(t, s, dbpath)
-	s.ConnectFullPublicInterface()
+	s.ConnectFullPublicInterface("esplora")
 	// take the handler of the public server and pass it to the test server
 	ts := httptest.NewServer(s.https.Handler)
 	defer ts.Close()
@@ -632,3 +904,26 @@ func Test_PublicServer_BitcoinType(t *testing.T) {
 	httpTests_BitcoinType(t, ts)
 	socketioTests_BitcoinType(t, ts)
 }
+
+func Test_splitAPIPath(t *testing.T) {
+	tests := []struct {
+		path      string
+		name      string
+		pathParam string
+	}{
+		{path: "/api/v2/", name: "", pathParam: ""},
+		{path: "/api/v2/logs", name: "logs", pathParam: ""},
+		{path: "/api/tx/abcd", name: "tx", pathParam: "abcd"},
+		{path: "/api/v2/address/addr1/", name: "address", pathParam: "addr1"},
+		{path: "/api/v2/address/addr1/export", name: "address-export", pathParam: "addr1"},
+		{path: "/blockbook/api/v2/token/0x1234/holders/", name: "token-holders", pathParam: "0x1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			name, pathParam := splitAPIPath(tt.path)
+			if name != tt.name || pathParam != tt.pathParam {
+				t.Errorf("splitAPIPath() = %v, %v, want %v, %v", name, pathParam, tt.name, tt.pathParam)
+			}
+		})
+	}
+}
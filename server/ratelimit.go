@@ -0,0 +1,212 @@
+package server
+
+import (
+	"blockbook/common"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	defaultRateLimitTier = "default"
+	// interval of the removal of the refilled buckets
+	rateLimitCleanupInterval = time.Minute
+	// apiKeyHeader and apiKeyParam pass the API key of the client
+	apiKeyHeader = "X-Api-Key"
+	apiKeyParam  = "apikey"
+)
+
+// RateLimitTier is the limit of the requests of one client
+type RateLimitTier struct {
+	// Rate is the number of requests per second, 0 means no limit
+	Rate float64 `json:"rate"`
+	// Burst is the maximum number of requests in a burst, at least 1
+	Burst int `json:"burst"`
+}
+
+// RateLimitConfig is the configuration of the rate limits loaded from the config file
+type RateLimitConfig struct {
+	// Default is the limit applied per IP address to the clients without a valid API key
+	Default RateLimitTier `json:"default"`
+	// Tiers are the named limits applied per API key
+	Tiers map[string]RateLimitTier `json:"tiers"`
+	// APIKeys maps the API keys to the names of the tiers
+	APIKeys map[string]string `json:"apiKeys"`
+	// RealIPHeader is the header with the IP address of the client set by a reverse proxy, e.g. X-Real-Ip
+	RealIPHeader string `json:"realIpHeader"`
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time when the bucket is refilled
+	full time.Time
+}
+
+// RateLimiter limits the requests of the clients of the public interfaces using token buckets
+// nil RateLimiter does not limit any request
+type RateLimiter struct {
+	config      RateLimitConfig
+	metrics     *common.Metrics
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+	lock        sync.Mutex
+	now         func() time.Time
+}
+
+// rateLimitClient identifies the client of the rate limiter
+type rateLimitClient struct {
+	ip     string
+	apiKey string
+}
+
+// LoadRateLimitConfig reads the rate limit configuration from the json file
+func LoadRateLimitConfig(configFile string) (*RateLimitConfig, error) {
+	buf, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, errors.Annotatef(err, "read %v", configFile)
+	}
+	var config RateLimitConfig
+	if err = json.Unmarshal(buf, &config); err != nil {
+		return nil, errors.Annotatef(err, "parse %v", configFile)
+	}
+	return &config, nil
+}
+
+// NewRateLimiter creates the rate limiter with the given configuration
+func NewRateLimiter(config *RateLimitConfig, metrics *common.Metrics) (*RateLimiter, error) {
+	if err := checkRateLimitTier(defaultRateLimitTier, &config.Default); err != nil {
+		return nil, err
+	}
+	for name, t := range config.Tiers {
+		if name == defaultRateLimitTier {
+			return nil, errors.Errorf("Rate limit tier name %v is reserved", name)
+		}
+		if err := checkRateLimitTier(name, &t); err != nil {
+			return nil, err
+		}
+		config.Tiers[name] = t
+	}
+	for key, name := range config.APIKeys {
+		if key == "" {
+			return nil, errors.New("Empty API key")
+		}
+		if _, ok := config.Tiers[name]; !ok {
+			return nil, errors.Errorf("Unknown rate limit tier %v of API key", name)
+		}
+	}
+	return &RateLimiter{
+		config:  *config,
+		metrics: metrics,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}, nil
+}
+
+func checkRateLimitTier(name string, t *RateLimitTier) error {
+	if t.Rate < 0 || t.Burst < 0 {
+		return errors.Errorf("Invalid rate limit tier %v, negative rate or burst", name)
+	}
+	if t.Burst == 0 {
+		t.Burst = int(math.Ceil(t.Rate))
+		if t.Burst == 0 {
+			t.Burst = 1
+		}
+	}
+	return nil
+}
+
+// getClient identifies the client by the IP address and API key of the request
+func (l *RateLimiter) getClient(remoteAddr string, header http.Header, query url.Values) rateLimitClient {
+	if l == nil {
+		return rateLimitClient{}
+	}
+	var c rateLimitClient
+	if l.config.RealIPHeader != "" {
+		// the header can contain the list of proxies (X-Forwarded-For), the first entries can be set by the client,
+		// the last one is appended by the proxy in front of blockbook
+		if v := header[http.CanonicalHeaderKey(l.config.RealIPHeader)]; len(v) > 0 {
+			ips := strings.Split(v[len(v)-1], ",")
+			c.ip = strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+	if c.ip == "" {
+		c.ip = remoteAddr
+		if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+			c.ip = host
+		}
+	}
+	c.apiKey = header.Get(apiKeyHeader)
+	if c.apiKey == "" && query != nil {
+		c.apiKey = query.Get(apiKeyParam)
+	}
+	return c
+}
+
+// getTier returns the name and limit of the tier of the client and the key of its bucket
+func (l *RateLimiter) getTier(c rateLimitClient) (string, RateLimitTier, string) {
+	// unknown API keys are handled as requests without the key
+	if name, ok := l.config.APIKeys[c.apiKey]; ok && c.apiKey != "" {
+		return name, l.config.Tiers[name], "key:" + c.apiKey
+	}
+	return defaultRateLimitTier, l.config.Default, "ip:" + c.ip
+}
+
+// allow consumes a token of the client and returns false and the time to the next available token if the client exceeded its limit
+func (l *RateLimiter) allow(iface string, c rateLimitClient) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	tierName, tier, key := l.getTier(c)
+	ok, wait := true, time.Duration(0)
+	if tier.Rate > 0 {
+		ok, wait = l.take(key, &tier)
+	}
+	status := "allowed"
+	if !ok {
+		status = "limited"
+	}
+	l.metrics.RateLimitRequests.With(common.Labels{"interface": iface, "tier": tierName, "status": status}).Inc()
+	return ok, wait
+}
+
+func (l *RateLimiter) take(key string, tier *RateLimitTier) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	if now.Sub(l.lastCleanup) > rateLimitCleanupInterval {
+		l.cleanup(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(tier.Burst), last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(float64(tier.Burst), b.tokens+now.Sub(b.last).Seconds()*tier.Rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / tier.Rate * float64(time.Second))
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(tier.Burst) - b.tokens) / tier.Rate * float64(time.Second)))
+	return true, 0
+}
+
+// cleanup removes the refilled buckets, they are equal to new buckets
+func (l *RateLimiter) cleanup(now time.Time) {
+	for k, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastCleanup = now
+}
@@ -0,0 +1,178 @@
+// +build unittest
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestRateLimiter(t *testing.T) *RateLimiter {
+	l, err := NewRateLimiter(&RateLimitConfig{
+		Default: RateLimitTier{Rate: 1, Burst: 2},
+		Tiers: map[string]RateLimitTier{
+			"wallet":    {Rate: 10},
+			"unlimited": {},
+		},
+		APIKeys: map[string]string{
+			"key1": "wallet",
+			"key2": "unlimited",
+		},
+		RealIPHeader: "X-Real-Ip",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	l := newTestRateLimiter(t)
+	if got := l.config.Tiers["wallet"].Burst; got != 10 {
+		t.Errorf("default burst of tier = %v, want 10", got)
+	}
+	if got := l.config.Tiers["unlimited"].Burst; got != 1 {
+		t.Errorf("default burst of unlimited tier = %v, want 1", got)
+	}
+	invalid := []RateLimitConfig{
+		{Default: RateLimitTier{Rate: -1}},
+		{Tiers: map[string]RateLimitTier{"default": {Rate: 1}}},
+		{APIKeys: map[string]string{"key": "unknown"}},
+		{Tiers: map[string]RateLimitTier{"t": {Rate: 1}}, APIKeys: map[string]string{"": "t"}},
+	}
+	for i := range invalid {
+		if _, err := NewRateLimiter(&invalid[i], nil); err == nil {
+			t.Errorf("NewRateLimiter(%+v) expected error", invalid[i])
+		}
+	}
+}
+
+func TestRateLimiter_getClient(t *testing.T) {
+	l := newTestRateLimiter(t)
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		query      url.Values
+		want       rateLimitClient
+	}{
+		{
+			name:       "remote address",
+			remoteAddr: "192.168.1.1:12345",
+			header:     http.Header{},
+			want:       rateLimitClient{ip: "192.168.1.1"},
+		},
+		{
+			name:       "real ip header and api key header",
+			remoteAddr: "127.0.0.1:12345",
+			header:     http.Header{"X-Real-Ip": []string{"10.0.0.1"}, "X-Api-Key": []string{"key1"}},
+			query:      url.Values{"apikey": []string{"key2"}},
+			want:       rateLimitClient{ip: "10.0.0.1", apiKey: "key1"},
+		},
+		{
+			name:       "forged entries of real ip header",
+			remoteAddr: "127.0.0.1:12345",
+			header:     http.Header{"X-Real-Ip": []string{"1.1.1.1", "2.2.2.2, 10.0.0.1"}},
+			want:       rateLimitClient{ip: "10.0.0.1"},
+		},
+		{
+			name:       "api key param",
+			remoteAddr: "[::1]:12345",
+			header:     http.Header{},
+			query:      url.Values{"apikey": []string{"key2"}},
+			want:       rateLimitClient{ip: "::1", apiKey: "key2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.getClient(tt.remoteAddr, tt.header, tt.query); got != tt.want {
+				t.Errorf("getClient() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_getTier(t *testing.T) {
+	l := newTestRateLimiter(t)
+	tests := []struct {
+		client   rateLimitClient
+		wantName string
+		wantKey  string
+	}{
+		{rateLimitClient{ip: "1.1.1.1"}, "default", "ip:1.1.1.1"},
+		{rateLimitClient{ip: "1.1.1.1", apiKey: "key1"}, "wallet", "key:key1"},
+		{rateLimitClient{ip: "1.1.1.1", apiKey: "unknown"}, "default", "ip:1.1.1.1"},
+	}
+	for _, tt := range tests {
+		name, _, key := l.getTier(tt.client)
+		if name != tt.wantName || key != tt.wantKey {
+			t.Errorf("getTier(%+v) = %v %v, want %v %v", tt.client, name, key, tt.wantName, tt.wantKey)
+		}
+	}
+}
+
+func TestRateLimiter_take(t *testing.T) {
+	l := newTestRateLimiter(t)
+	now := time.Unix(1000000, 0)
+	l.now = func() time.Time { return now }
+	tier := l.config.Default
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.take("ip:a", &tier); !ok {
+			t.Fatalf("take() %d of burst not allowed", i)
+		}
+	}
+	ok, wait := l.take("ip:a", &tier)
+	if ok || wait != time.Second {
+		t.Errorf("take() over burst = %v %v, want false 1s", ok, wait)
+	}
+	if ok, _ := l.take("ip:b", &tier); !ok {
+		t.Error("take() of other client not allowed")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, wait := l.take("ip:a", &tier); ok || wait != 500*time.Millisecond {
+		t.Errorf("take() after 0.5s = %v %v, want false 0.5s", ok, wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.take("ip:a", &tier); !ok {
+		t.Error("take() after refill not allowed")
+	}
+	// the bucket of client b is refilled and removed, the bucket of client a is not refilled yet
+	now = now.Add(rateLimitCleanupInterval)
+	l.lastCleanup = now.Add(-rateLimitCleanupInterval - time.Second)
+	l.buckets["ip:a"].full = now.Add(time.Second)
+	l.take("ip:c", &tier)
+	if _, ok := l.buckets["ip:b"]; ok {
+		t.Error("cleanup() did not remove refilled bucket")
+	}
+	if _, ok := l.buckets["ip:a"]; !ok {
+		t.Error("cleanup() removed bucket which is not refilled")
+	}
+}
+
+func TestRateLimiter_nil(t *testing.T) {
+	var l *RateLimiter
+	if ok, _ := l.allow("http", l.getClient("1.1.1.1:1", http.Header{}, nil)); !ok {
+		t.Error("nil RateLimiter does not allow request")
+	}
+}
+
+func TestRateLimiter_esplora(t *testing.T) {
+	l := newTestRateLimiter(t)
+	now := time.Unix(1000000, 0)
+	l.now = func() time.Time { return now }
+	s := &PublicServer{rateLimiter: l}
+	h := s.esploraHandler("/esplora/")
+	for i, want := range []int{http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/esplora/unknown", nil))
+		if w.Code != want {
+			t.Errorf("request %d status = %v, want %v", i, w.Code, want)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("request %d Retry-After = %v, want 1", i, w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -22,17 +22,18 @@ import (
 // SocketIoServer is handle to SocketIoServer
 type SocketIoServer struct {
 	server      *gosocketio.Server
-	db          *db.RocksDB
+	db          db.IndexStore
 	txCache     *db.TxCache
 	chain       bchain.BlockChain
 	chainParser bchain.BlockChainParser
 	metrics     *common.Metrics
 	is          *common.InternalState
 	api         *api.Worker
+	rateLimiter *RateLimiter
 }
 
 // NewSocketIoServer creates new SocketIo interface to blockbook and returns its handle
-func NewSocketIoServer(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*SocketIoServer, error) {
+func NewSocketIoServer(db db.IndexStore, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*SocketIoServer, error) {
 	api, err := api.NewWorker(db, chain, txCache, is)
 	if err != nil {
 		return nil, err
@@ -168,6 +169,11 @@ func (s *SocketIoServer) onMessage(c *gosocketio.Channel, req map[string]json.Ra
 			rv = e
 		}
 	}()
+	if ok, _ := s.rateLimiter.allow("socketio", s.rateLimiter.getClient(c.Ip(), c.RequestHeader(), nil)); !ok {
+		e := resultError{}
+		e.Error.Message = "Too many requests"
+		return e
+	}
 	t := time.Now()
 	params := req["params"]
 	defer s.metrics.SocketIOReqDuration.With(common.Labels{"method": method}).Observe(float64(time.Since(t)) / 1e3) // in microseconds
@@ -0,0 +1,704 @@
+package server
+
+import (
+	"blockbook/api"
+	"blockbook/bchain"
+	"blockbook/common"
+	"blockbook/db"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// WebhookDispatcher sends the events of the addresses and of the blocks to the webhooks registered in the db.
+// An event is delivered as POST request with json payload signed by HMAC-SHA256 using the secret of the webhook,
+// failed deliveries are retried with exponential backoff. All deliveries are stored in the delivery log in the db,
+// pending deliveries are resumed after restart. The deliveries are sent by several workers, therefore they may arrive
+// out of order, the receiver should order them by the sequence number in the payload.
+// Reorgs are detected by comparing the hashes of the recently processed blocks with the index.
+type WebhookDispatcher struct {
+	db          *db.RocksDB
+	chain       bchain.BlockChain
+	chainParser bchain.BlockChainParser
+	metrics     *common.Metrics
+	api         *api.Worker
+	client      *http.Client
+	events      chan webhookEvent
+	deliveries  chan *db.WebhookDelivery
+	done        chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	// webhooks maps id to the registration, addrDescs maps address descriptor to the ids of the webhooks and the registered address
+	webhooks     map[string]*db.Webhook
+	addrDescs    map[string]map[string]string
+	webhooksLock sync.Mutex
+	// the fields below are accessed only by the event loop
+	pending      []*db.WebhookDelivery
+	seq          uint64
+	recentBlocks []*webhookBlock
+	lastPrune    time.Time
+}
+
+const (
+	webhookEventMempool      = "mempool"
+	webhookEventConfirmation = "confirmation"
+	webhookEventBlock        = "block"
+	webhookEventReorg        = "reorg"
+
+	webhookWorkers          = 4
+	webhookTimeout          = 10 * time.Second
+	webhookMaxAttempts      = 12
+	webhookRetryBaseDelay   = 10 * time.Second
+	webhookRetryMaxDelay    = time.Hour
+	webhookMaxAddresses     = 1000
+	webhookMaxConfirmations = 100
+	// number of processed blocks kept to detect reorgs, must not be lower than webhookMaxConfirmations
+	webhookRecentBlocks = 100
+	webhookPrunePeriod  = time.Hour
+	// finished deliveries older than webhookDeliveryLogRetention are removed from the delivery log
+	webhookDeliveryLogRetention = 7 * 24 * time.Hour
+)
+
+// webhookEvent is either a new block or a new mempool transaction of the address descriptor
+type webhookEvent struct {
+	hash     string
+	height   uint32
+	tx       *bchain.Tx
+	addrDesc bchain.AddressDescriptor
+}
+
+type webhookBlock struct {
+	height uint32
+	hash   string
+	// txids maps webhook id to the transactions of the webhook addresses notified as confirmed in the block
+	txids map[string][]string
+}
+
+type webhookPayload struct {
+	Webhook string      `json:"webhook"`
+	Seq     uint64      `json:"seq"`
+	Event   string      `json:"event"`
+	Time    int64       `json:"time"`
+	Data    interface{} `json:"data"`
+}
+
+type webhookTxData struct {
+	Address string  `json:"address"`
+	Tx      *api.Tx `json:"tx"`
+}
+
+type webhookConfirmationData struct {
+	Address       string `json:"address"`
+	Txid          string `json:"txid"`
+	Confirmations uint32 `json:"confirmations"`
+	BlockHeight   uint32 `json:"blockHeight"`
+	BlockHash     string `json:"blockHash"`
+}
+
+type webhookBlockData struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+type webhookReorgData struct {
+	// Disconnected are the blocks replaced by the reorg, Txids are the notified confirmed transactions in them
+	Disconnected []webhookBlockData `json:"disconnected"`
+	Txids        []string           `json:"txids,omitempty"`
+	Height       uint32             `json:"height"`
+	Hash         string             `json:"hash"`
+}
+
+// NewWebhookDispatcher creates the dispatcher of the webhooks registered in the db
+func NewWebhookDispatcher(d *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*WebhookDispatcher, error) {
+	api, err := api.NewWorker(d, chain, txCache, is)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebhookDispatcher{
+		db:          d,
+		chain:       chain,
+		chainParser: chain.GetChainParser(),
+		metrics:     metrics,
+		api:         api,
+		client:      &http.Client{Timeout: webhookTimeout},
+		events:      make(chan webhookEvent, outChannelSize),
+		deliveries:  make(chan *db.WebhookDelivery, outChannelSize),
+		done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		webhooks:    make(map[string]*db.Webhook),
+		addrDescs:   make(map[string]map[string]string),
+		seq:         uint64(time.Now().UnixNano()),
+		lastPrune:   time.Now(),
+	}
+	whs, err := d.GetWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, wh := range whs {
+		addrDescs, err := s.webhookAddrDescs(wh)
+		if err != nil {
+			glog.Error("webhooks: webhook ", wh.ID, " skipped, ", err)
+			continue
+		}
+		s.registerWebhook(wh, addrDescs)
+	}
+	err = d.GetPendingWebhookDeliveries(func(wd *db.WebhookDelivery) error {
+		s.pending = append(s.pending, wd)
+		if wd.Seq > s.seq {
+			s.seq = wd.Seq
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run starts the delivery workers and processes the events until shutdown
+func (s *WebhookDispatcher) Run() {
+	glog.Info("webhooks: starting with ", len(s.webhooks), " webhooks and ", len(s.pending), " pending deliveries")
+	s.wg.Add(webhookWorkers + 1)
+	defer s.wg.Done()
+	for i := 0; i < webhookWorkers; i++ {
+		go s.deliveryWorker()
+	}
+	for _, wd := range s.pending {
+		s.schedule(wd)
+	}
+	s.pending = nil
+	for {
+		select {
+		case <-s.done:
+			return
+		case e := <-s.events:
+			if e.tx != nil {
+				s.processTx(e.tx, e.addrDesc)
+			} else {
+				s.processBlock(e.hash, e.height)
+			}
+		}
+	}
+}
+
+// Shutdown stops the processing of the events and the deliveries, the interrupted deliveries are resumed after restart
+func (s *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	glog.Info("webhooks: shutdown")
+	close(s.done)
+	s.cancel()
+	c := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(c)
+	}()
+	select {
+	case <-c:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnNewBlock is a callback that passes the new block to the event loop
+func (s *WebhookDispatcher) OnNewBlock(hash string, height uint32) {
+	s.sendEvent(webhookEvent{hash: hash, height: height})
+}
+
+// OnNewTxAddr is a callback that passes the new mempool transaction to the event loop if the address has any webhook
+func (s *WebhookDispatcher) OnNewTxAddr(tx *bchain.Tx, addrDesc bchain.AddressDescriptor) {
+	s.webhooksLock.Lock()
+	n := len(s.addrDescs[string(addrDesc)])
+	s.webhooksLock.Unlock()
+	if n > 0 {
+		s.sendEvent(webhookEvent{tx: tx, addrDesc: addrDesc})
+	}
+}
+
+// sendEvent passes the event to the event loop without blocking the caller,
+// if the event loop does not keep up with the sync, the event is dropped
+func (s *WebhookDispatcher) sendEvent(e webhookEvent) {
+	select {
+	case s.events <- e:
+	case <-s.done:
+	default:
+		event := webhookEventBlock
+		if e.tx != nil {
+			event = webhookEventMempool
+		}
+		glog.Warning("webhooks: event queue full, ", event, " event dropped")
+		s.metrics.WebhookDeliveries.With(common.Labels{"event": event, "status": "dropped"}).Inc()
+	}
+}
+
+func (s *WebhookDispatcher) processTx(tx *bchain.Tx, addrDesc bchain.AddressDescriptor) {
+	var whs []*db.Webhook
+	var addresses []string
+	s.webhooksLock.Lock()
+	for id, a := range s.addrDescs[string(addrDesc)] {
+		whs = append(whs, s.webhooks[id])
+		addresses = append(addresses, a)
+	}
+	s.webhooksLock.Unlock()
+	if len(whs) == 0 {
+		return
+	}
+	atx, err := s.api.GetTransactionFromBchainTx(tx, 0, false, false)
+	if err != nil {
+		glog.Error("webhooks: GetTransactionFromBchainTx error ", err, " for ", tx.Txid)
+		return
+	}
+	for i, wh := range whs {
+		s.createDelivery(wh, webhookEventMempool, &webhookTxData{Address: addresses[i], Tx: atx})
+	}
+}
+
+func (s *WebhookDispatcher) processBlock(hash string, height uint32) {
+	if b := s.recentBlock(height); b != nil && b.hash == hash {
+		// the block was already processed
+		return
+	}
+	whs := s.getWebhooks()
+	if disconnected := s.detectReorg(height); len(disconnected) > 0 {
+		glog.Warning("webhooks: reorg, disconnected blocks ", disconnected[0].height, "-", disconnected[len(disconnected)-1].height)
+		for _, wh := range whs {
+			if !wh.Blocks && wh.Confirmations == 0 {
+				continue
+			}
+			data := &webhookReorgData{Height: height, Hash: hash}
+			for _, b := range disconnected {
+				data.Disconnected = append(data.Disconnected, webhookBlockData{Height: b.height, Hash: b.hash})
+				data.Txids = append(data.Txids, b.txids[wh.ID]...)
+			}
+			s.createDelivery(wh, webhookEventReorg, data)
+		}
+	}
+	if len(s.recentBlocks) >= webhookRecentBlocks {
+		s.recentBlocks = append(s.recentBlocks[:0], s.recentBlocks[len(s.recentBlocks)-webhookRecentBlocks+1:]...)
+	}
+	s.recentBlocks = append(s.recentBlocks, &webhookBlock{height: height, hash: hash, txids: make(map[string][]string)})
+	for _, wh := range whs {
+		if wh.Blocks {
+			s.createDelivery(wh, webhookEventBlock, &webhookBlockData{Height: height, Hash: hash})
+		}
+		if wh.Confirmations > 0 {
+			s.processConfirmations(wh, height)
+		}
+	}
+	if time.Since(s.lastPrune) > webhookPrunePeriod {
+		s.lastPrune = time.Now()
+		n, err := s.db.PruneWebhookDeliveries(s.lastPrune.Add(-webhookDeliveryLogRetention).Unix())
+		if err != nil {
+			glog.Error("webhooks: PruneWebhookDeliveries error ", err)
+		} else if n > 0 {
+			glog.Info("webhooks: pruned ", n, " deliveries from the delivery log")
+		}
+	}
+}
+
+// detectReorg removes the recent blocks that are not in the index anymore or are replaced by the block at height and returns them
+func (s *WebhookDispatcher) detectReorg(height uint32) []*webhookBlock {
+	for i, b := range s.recentBlocks {
+		if b.height < height {
+			hash, err := s.db.GetBlockHash(b.height)
+			if err != nil {
+				glog.Error("webhooks: GetBlockHash error ", err, " for ", b.height)
+				continue
+			}
+			if hash == b.hash {
+				continue
+			}
+		}
+		disconnected := append([]*webhookBlock(nil), s.recentBlocks[i:]...)
+		s.recentBlocks = s.recentBlocks[:i]
+		return disconnected
+	}
+	return nil
+}
+
+func (s *WebhookDispatcher) recentBlock(height uint32) *webhookBlock {
+	for _, b := range s.recentBlocks {
+		if b.height == height {
+			return b
+		}
+	}
+	return nil
+}
+
+// processConfirmations notifies the transactions of the webhook addresses which got a confirmation up to the webhook confirmations
+func (s *WebhookDispatcher) processConfirmations(wh *db.Webhook, height uint32) {
+	var lower uint32
+	if height >= uint32(wh.Confirmations) {
+		lower = height - uint32(wh.Confirmations) + 1
+	}
+	for _, a := range wh.Addresses {
+		addrDesc, err := s.chainParser.GetAddrDescFromAddress(a)
+		if err != nil {
+			glog.Error("webhooks: GetAddrDescFromAddress error ", err, " for ", a)
+			continue
+		}
+		seen := make(map[string]struct{})
+		err = s.db.GetAddrDescTransactionsReverse(addrDesc, lower, height, func(txid string, txHeight uint32, vout int32, isOutput bool) error {
+			if _, ok := seen[txid]; ok {
+				return nil
+			}
+			seen[txid] = struct{}{}
+			var blockHash string
+			if b := s.recentBlock(txHeight); b != nil {
+				blockHash = b.hash
+				b.addTxid(wh.ID, txid)
+			} else {
+				var err error
+				if blockHash, err = s.db.GetBlockHash(txHeight); err != nil {
+					return err
+				}
+			}
+			s.createDelivery(wh, webhookEventConfirmation, &webhookConfirmationData{
+				Address:       a,
+				Txid:          txid,
+				Confirmations: height - txHeight + 1,
+				BlockHeight:   txHeight,
+				BlockHash:     blockHash,
+			})
+			return nil
+		})
+		if err != nil {
+			glog.Error("webhooks: GetAddrDescTransactionsReverse error ", err, " for ", a)
+		}
+	}
+}
+
+func (b *webhookBlock) addTxid(webhook, txid string) {
+	for _, t := range b.txids[webhook] {
+		if t == txid {
+			return
+		}
+	}
+	b.txids[webhook] = append(b.txids[webhook], txid)
+}
+
+// createDelivery stores the event to the delivery log of the webhook and passes it to the delivery workers
+// the delivery is stored first, therefore it is never lost, even if it cannot be passed to the workers immediately
+func (s *WebhookDispatcher) createDelivery(wh *db.Webhook, event string, data interface{}) {
+	now := time.Now()
+	s.seq++
+	if seq := uint64(now.UnixNano()); seq > s.seq {
+		s.seq = seq
+	}
+	payload, err := json.Marshal(&webhookPayload{
+		Webhook: wh.ID,
+		Seq:     s.seq,
+		Event:   event,
+		Time:    now.Unix(),
+		Data:    data,
+	})
+	if err != nil {
+		glog.Error("webhooks: json error ", err, " of event ", event, " of webhook ", wh.ID)
+		return
+	}
+	wd := &db.WebhookDelivery{
+		Webhook:     wh.ID,
+		Seq:         s.seq,
+		Event:       event,
+		Payload:     payload,
+		Status:      db.WebhookDeliveryPending,
+		Created:     now.Unix(),
+		NextAttempt: now.Unix(),
+	}
+	if err = s.db.StoreWebhookDelivery(wd); err != nil {
+		glog.Error("webhooks: StoreWebhookDelivery error ", err, " of webhook ", wh.ID)
+		return
+	}
+	s.enqueue(wd)
+}
+
+// enqueue passes the delivery to the workers without blocking the caller, if all workers are busy,
+// the delivery stays pending in the delivery log and is enqueued again later
+func (s *WebhookDispatcher) enqueue(wd *db.WebhookDelivery) {
+	select {
+	case s.deliveries <- wd:
+	case <-s.done:
+	default:
+		glog.V(1).Info("webhooks: delivery queue full, delivery ", wd.Seq, " of webhook ", wd.Webhook, " postponed")
+		time.AfterFunc(webhookRetryBaseDelay, func() { s.enqueue(wd) })
+	}
+}
+
+// schedule passes the delivery to the workers at the time of its next attempt
+func (s *WebhookDispatcher) schedule(wd *db.WebhookDelivery) {
+	delay := time.Until(time.Unix(wd.NextAttempt, 0))
+	if delay <= 0 {
+		s.enqueue(wd)
+		return
+	}
+	time.AfterFunc(delay, func() { s.enqueue(wd) })
+}
+
+func webhookRetryDelay(attempts int) time.Duration {
+	d := webhookRetryBaseDelay
+	for i := 1; i < attempts && d < webhookRetryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > webhookRetryMaxDelay {
+		d = webhookRetryMaxDelay
+	}
+	return d
+}
+
+func (s *WebhookDispatcher) deliveryWorker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case wd := <-s.deliveries:
+			s.deliver(wd)
+		}
+	}
+}
+
+func (s *WebhookDispatcher) deliver(wd *db.WebhookDelivery) {
+	s.webhooksLock.Lock()
+	wh := s.webhooks[wd.Webhook]
+	s.webhooksLock.Unlock()
+	if wh == nil {
+		// the webhook was deleted together with its delivery log
+		return
+	}
+	now := time.Now()
+	responseStatus, err := s.post(wh, wd)
+	if s.ctx.Err() != nil {
+		// interrupted by shutdown, the delivery stays pending in the delivery log
+		return
+	}
+	wd.Attempts++
+	wd.LastAttempt = now.Unix()
+	wd.ResponseStatus = responseStatus
+	status := db.WebhookDeliveryDelivered
+	if err == nil {
+		wd.Status = db.WebhookDeliveryDelivered
+		wd.NextAttempt = 0
+		wd.Error = ""
+	} else {
+		wd.Error = err.Error()
+		if wd.Attempts >= webhookMaxAttempts {
+			glog.Warning("webhooks: delivery ", wd.Seq, " of webhook ", wd.Webhook, " failed after ", wd.Attempts, " attempts, ", err)
+			wd.Status = db.WebhookDeliveryFailed
+			wd.NextAttempt = 0
+			status = db.WebhookDeliveryFailed
+		} else {
+			glog.V(1).Info("webhooks: delivery ", wd.Seq, " of webhook ", wd.Webhook, " attempt ", wd.Attempts, " error ", err)
+			wd.NextAttempt = now.Add(webhookRetryDelay(wd.Attempts)).Unix()
+			status = "retry"
+		}
+	}
+	s.metrics.WebhookDeliveries.With(common.Labels{"event": wd.Event, "status": status}).Inc()
+	// store the delivery only if the webhook was not deleted in the meantime
+	var storeErr error
+	s.webhooksLock.Lock()
+	if s.webhooks[wd.Webhook] != nil {
+		storeErr = s.db.StoreWebhookDelivery(wd)
+	}
+	s.webhooksLock.Unlock()
+	if storeErr != nil {
+		glog.Error("webhooks: StoreWebhookDelivery error ", storeErr, " of webhook ", wd.Webhook)
+	}
+	if wd.Status == db.WebhookDeliveryPending {
+		s.schedule(wd)
+	}
+}
+
+// webhookSignature returns the value of the X-Blockbook-Signature header, the HMAC-SHA256 of the payload
+func webhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post sends the delivery to the webhook, any response other than 2xx is an error
+func (s *WebhookDispatcher) post(wh *db.Webhook, wd *db.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(wd.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Blockbook")
+	req.Header.Set("X-Blockbook-Event", wd.Event)
+	req.Header.Set("X-Blockbook-Delivery", strconv.FormatUint(wd.Seq, 10))
+	req.Header.Set("X-Blockbook-Signature", webhookSignature(wh.Secret, wd.Payload))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 65536))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.Errorf("Response status %v", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *WebhookDispatcher) webhookAddrDescs(wh *db.Webhook) ([]bchain.AddressDescriptor, error) {
+	addrDescs := make([]bchain.AddressDescriptor, len(wh.Addresses))
+	for i, a := range wh.Addresses {
+		addrDesc, err := s.chainParser.GetAddrDescFromAddress(a)
+		if err != nil {
+			return nil, api.NewAPIError(fmt.Sprintf("Invalid address %v, %v", a, err), true)
+		}
+		addrDescs[i] = addrDesc
+	}
+	return addrDescs, nil
+}
+
+// registerWebhook adds the webhook to the maps, must be called with webhooksLock held
+func (s *WebhookDispatcher) registerWebhook(wh *db.Webhook, addrDescs []bchain.AddressDescriptor) {
+	s.webhooks[wh.ID] = wh
+	for i, addrDesc := range addrDescs {
+		as := s.addrDescs[string(addrDesc)]
+		if as == nil {
+			as = make(map[string]string)
+			s.addrDescs[string(addrDesc)] = as
+		}
+		as[wh.ID] = wh.Addresses[i]
+	}
+}
+
+// AddWebhook validates and registers the webhook, the id, the creation time and the secret, if not set, are generated
+func (s *WebhookDispatcher) AddWebhook(wh *db.Webhook) (*db.Webhook, error) {
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, api.NewAPIError("Invalid url, expecting http or https url", true)
+	}
+	if len(wh.Addresses) == 0 && !wh.Blocks {
+		return nil, api.NewAPIError("Missing addresses or blocks", true)
+	}
+	if len(wh.Addresses) > webhookMaxAddresses {
+		return nil, api.NewAPIError(fmt.Sprintf("Too many addresses, maximum is %d", webhookMaxAddresses), true)
+	}
+	if wh.Confirmations < 0 || wh.Confirmations > webhookMaxConfirmations {
+		return nil, api.NewAPIError(fmt.Sprintf("Confirmations must be between 0 and %d", webhookMaxConfirmations), true)
+	}
+	addrDescs, err := s.webhookAddrDescs(wh)
+	if err != nil {
+		return nil, err
+	}
+	r := *wh
+	if r.ID, err = randomHex(16); err != nil {
+		return nil, err
+	}
+	if r.Secret == "" {
+		if r.Secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+	r.Created = time.Now().Unix()
+	s.webhooksLock.Lock()
+	defer s.webhooksLock.Unlock()
+	if err = s.db.StoreWebhook(&r); err != nil {
+		return nil, err
+	}
+	s.registerWebhook(&r, addrDescs)
+	glog.Info("webhooks: added webhook ", r.ID, " with ", len(r.Addresses), " addresses")
+	return &r, nil
+}
+
+// DeleteWebhook removes the webhook and its delivery log, returns false if the webhook is not registered
+func (s *WebhookDispatcher) DeleteWebhook(id string) (bool, error) {
+	s.webhooksLock.Lock()
+	defer s.webhooksLock.Unlock()
+	wh := s.webhooks[id]
+	if wh == nil {
+		return false, nil
+	}
+	if err := s.db.DeleteWebhook(id); err != nil {
+		return false, err
+	}
+	delete(s.webhooks, id)
+	for _, a := range wh.Addresses {
+		addrDesc, err := s.chainParser.GetAddrDescFromAddress(a)
+		if err != nil {
+			continue
+		}
+		as := s.addrDescs[string(addrDesc)]
+		delete(as, id)
+		if len(as) == 0 {
+			delete(s.addrDescs, string(addrDesc))
+		}
+	}
+	glog.Info("webhooks: deleted webhook ", id)
+	return true, nil
+}
+
+// getWebhooks returns all registered webhooks ordered by the time of creation
+func (s *WebhookDispatcher) getWebhooks() []*db.Webhook {
+	s.webhooksLock.Lock()
+	whs := make([]*db.Webhook, 0, len(s.webhooks))
+	for _, wh := range s.webhooks {
+		whs = append(whs, wh)
+	}
+	s.webhooksLock.Unlock()
+	sort.Slice(whs, func(i, j int) bool {
+		if whs[i].Created == whs[j].Created {
+			return whs[i].ID < whs[j].ID
+		}
+		return whs[i].Created < whs[j].Created
+	})
+	return whs
+}
+
+// GetWebhooks returns all registered webhooks without their secrets
+func (s *WebhookDispatcher) GetWebhooks() []*db.Webhook {
+	whs := s.getWebhooks()
+	r := make([]*db.Webhook, len(whs))
+	for i, wh := range whs {
+		c := *wh
+		c.Secret = ""
+		r[i] = &c
+	}
+	return r
+}
+
+// GetWebhook returns the webhook without its secret or nil if it is not registered
+func (s *WebhookDispatcher) GetWebhook(id string) *db.Webhook {
+	s.webhooksLock.Lock()
+	defer s.webhooksLock.Unlock()
+	wh := s.webhooks[id]
+	if wh == nil {
+		return nil
+	}
+	c := *wh
+	c.Secret = ""
+	return &c
+}
+
+// GetWebhookDeliveries returns the delivery log of the webhook, see db.GetWebhookDeliveries
+func (s *WebhookDispatcher) GetWebhookDeliveries(id, status string, before uint64, limit int) ([]*db.WebhookDelivery, error) {
+	return s.db.GetWebhookDeliveries(id, status, before, limit)
+}
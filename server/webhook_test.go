@@ -0,0 +1,378 @@
+// +build unittest
+
+package server
+
+import (
+	"blockbook/bchain/coins/btc"
+	"blockbook/common"
+	"blockbook/db"
+	"blockbook/tests/dbtestdata"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	webhookTestBlock1Hash = "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997"
+	webhookTestBlock2Hash = "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6"
+	webhookTestForkHash   = "00000000000000000000000000000000000000000000000000000000000fork2"
+)
+
+func setupWebhookDispatcher(t *testing.T) (*WebhookDispatcher, *common.InternalState, string) {
+	parser := btc.NewBitcoinParser(
+		btc.GetChainParams("test"),
+		&btc.Configuration{BlockAddressesToKeep: 1})
+	d, is, path := setupRocksDB(t, parser)
+	chain, err := dbtestdata.NewFakeBlockChain(parser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the metrics are not registered, they are registered by the test of the public server
+	metrics := &common.Metrics{
+		WebhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "webhook_deliveries_test"}, []string{"event", "status"}),
+	}
+	txCache, err := db.NewTxCache(d, chain, metrics, is, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewWebhookDispatcher(d, chain, txCache, metrics, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, is, path
+}
+
+func closeAndDestroyWebhookDispatcher(t *testing.T, s *WebhookDispatcher, dbpath string) {
+	if err := s.db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(dbpath)
+}
+
+type webhookTestPayload struct {
+	Webhook string          `json:"webhook"`
+	Seq     uint64          `json:"seq"`
+	Event   string          `json:"event"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// webhookTestReceiver checks the signature of the requests and passes the payloads to received
+func webhookTestReceiver(t *testing.T, secret string, status int, received chan *webhookTestPayload) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if secret != "" && r.Header.Get("X-Blockbook-Signature") != webhookSignature(secret, body) {
+			t.Errorf("invalid signature %v of payload %v", r.Header.Get("X-Blockbook-Signature"), string(body))
+		}
+		var p webhookTestPayload
+		if err = json.Unmarshal(body, &p); err != nil {
+			t.Error(err)
+		}
+		if r.Header.Get("X-Blockbook-Event") != p.Event {
+			t.Errorf("X-Blockbook-Event = %v, want %v", r.Header.Get("X-Blockbook-Event"), p.Event)
+		}
+		if received != nil {
+			received <- &p
+		}
+		w.WriteHeader(status)
+	}
+}
+
+func waitForWebhooks(t *testing.T, what string, fn func() bool) {
+	for i := 0; i < 100; i++ {
+		if fn() {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for ", what)
+}
+
+func Test_webhookSignature(t *testing.T) {
+	want := "sha256=21b7373c374f3e6011e9326e253361375dbf5379e3a5d800fbedce47bb99b361"
+	if got := webhookSignature("secret", []byte(`{"seq":1}`)); got != want {
+		t.Errorf("webhookSignature() = %v, want %v", got, want)
+	}
+}
+
+func Test_webhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 9, want: 2560 * time.Second},
+		{attempts: 10, want: time.Hour},
+		{attempts: webhookMaxAttempts, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%v) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func Test_WebhookDispatcher_fullQueues(t *testing.T) {
+	s, _, path := setupWebhookDispatcher(t)
+	defer closeAndDestroyWebhookDispatcher(t, s, path)
+	defer s.Shutdown(context.Background())
+	// the dispatcher is not running, the callbacks and the enqueue must not block when the queues are full
+	for i := 0; i <= cap(s.events); i++ {
+		s.OnNewBlock("0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997", 225493)
+	}
+	if len(s.events) != cap(s.events) {
+		t.Errorf("len(events) = %v, want %v", len(s.events), cap(s.events))
+	}
+	for i := 0; i <= cap(s.deliveries); i++ {
+		s.enqueue(&db.WebhookDelivery{Webhook: "test", Seq: uint64(i)})
+	}
+	if len(s.deliveries) != cap(s.deliveries) {
+		t.Errorf("len(deliveries) = %v, want %v", len(s.deliveries), cap(s.deliveries))
+	}
+}
+
+func Test_WebhookDispatcher(t *testing.T) {
+	s, _, path := setupWebhookDispatcher(t)
+	defer closeAndDestroyWebhookDispatcher(t, s, path)
+
+	received := make(chan *webhookTestPayload, 100)
+	ok := httptest.NewServer(webhookTestReceiver(t, "secret", http.StatusOK, received))
+	defer ok.Close()
+	failing := httptest.NewServer(webhookTestReceiver(t, "", http.StatusInternalServerError, nil))
+	defer failing.Close()
+
+	for _, wh := range []*db.Webhook{
+		{URL: "ftp://localhost/hook", Blocks: true},
+		{URL: ok.URL},
+		{URL: ok.URL, Addresses: []string{"!invalid"}},
+		{URL: ok.URL, Blocks: true, Confirmations: webhookMaxConfirmations + 1},
+	} {
+		if _, err := s.AddWebhook(wh); err == nil {
+			t.Errorf("AddWebhook(%+v) expected error", wh)
+		}
+	}
+	wh, err := s.AddWebhook(&db.Webhook{URL: ok.URL, Secret: "secret", Addresses: []string{dbtestdata.Addr5}, Blocks: true, Confirmations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wh.ID) != 32 || wh.Secret != "secret" || wh.Created == 0 {
+		t.Errorf("AddWebhook() = %+v", wh)
+	}
+	fwh, err := s.AddWebhook(&db.Webhook{URL: failing.URL, Blocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fwh.Secret) != 64 {
+		t.Errorf("AddWebhook() generated secret %v", fwh.Secret)
+	}
+	whs := s.GetWebhooks()
+	if len(whs) != 2 || whs[0].Secret != "" || whs[1].Secret != "" || (whs[0].ID != wh.ID && whs[1].ID != wh.ID) {
+		t.Errorf("GetWebhooks() = %+v", whs)
+	}
+
+	go s.Run()
+	defer s.Shutdown(context.Background())
+
+	tx, err := s.chain.GetTransaction(dbtestdata.TxidB2T3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrDesc, err := s.chainParser.GetAddrDescFromAddress(dbtestdata.Addr5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.OnNewBlock(webhookTestBlock1Hash, 225493)
+	s.OnNewBlock(webhookTestBlock2Hash, 225494)
+	s.OnNewTxAddr(tx, addrDesc)
+	// the block 225494 is replaced by another block
+	s.OnNewBlock(webhookTestForkHash, 225494)
+
+	want := []struct {
+		event string
+		data  string
+	}{
+		{webhookEventBlock, `{"height":225493,"hash":"` + webhookTestBlock1Hash + `"}`},
+		{webhookEventConfirmation, `{"address":"` + dbtestdata.Addr5 + `","txid":"` + dbtestdata.TxidB1T2 + `","confirmations":1,"blockHeight":225493,"blockHash":"` + webhookTestBlock1Hash + `"}`},
+		{webhookEventBlock, `{"height":225494,"hash":"` + webhookTestBlock2Hash + `"}`},
+		{webhookEventConfirmation, `{"address":"` + dbtestdata.Addr5 + `","txid":"` + dbtestdata.TxidB2T3 + `","confirmations":1,"blockHeight":225494,"blockHash":"` + webhookTestBlock2Hash + `"}`},
+		{webhookEventConfirmation, `{"address":"` + dbtestdata.Addr5 + `","txid":"` + dbtestdata.TxidB1T2 + `","confirmations":2,"blockHeight":225493,"blockHash":"` + webhookTestBlock1Hash + `"}`},
+		{webhookEventMempool, ``},
+		{webhookEventReorg, `{"disconnected":[{"height":225494,"hash":"` + webhookTestBlock2Hash + `"}],"txids":["` + dbtestdata.TxidB2T3 + `"],"height":225494,"hash":"` + webhookTestForkHash + `"}`},
+		{webhookEventBlock, `{"height":225494,"hash":"` + webhookTestForkHash + `"}`},
+		{webhookEventConfirmation, `{"address":"` + dbtestdata.Addr5 + `","txid":"` + dbtestdata.TxidB2T3 + `","confirmations":1,"blockHeight":225494,"blockHash":"` + webhookTestForkHash + `"}`},
+		{webhookEventConfirmation, `{"address":"` + dbtestdata.Addr5 + `","txid":"` + dbtestdata.TxidB1T2 + `","confirmations":2,"blockHeight":225493,"blockHash":"` + webhookTestBlock1Hash + `"}`},
+	}
+	var got []*webhookTestPayload
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case p := <-received:
+			got = append(got, p)
+		case <-timeout:
+			t.Fatalf("received %d payloads, want %d", len(got), len(want))
+		}
+	}
+	// the deliveries are sent in parallel, they are ordered by the sequence number
+	sort.Slice(got, func(i, j int) bool { return got[i].Seq < got[j].Seq })
+	for i, p := range got {
+		if p.Webhook != wh.ID || p.Event != want[i].event {
+			t.Errorf("payload %d = %v %v, want %v %v", i, p.Webhook, p.Event, wh.ID, want[i].event)
+			continue
+		}
+		if p.Event == webhookEventMempool {
+			var d struct {
+				Address string
+				Tx      struct {
+					Txid string
+				}
+			}
+			if err := json.Unmarshal(p.Data, &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Address != dbtestdata.Addr5 || d.Tx.Txid != dbtestdata.TxidB2T3 {
+				t.Errorf("payload %d data = %+v", i, d)
+			}
+		} else if string(p.Data) != want[i].data {
+			t.Errorf("payload %d data = %v, want %v", i, string(p.Data), want[i].data)
+		}
+	}
+
+	waitForWebhooks(t, "delivery log", func() bool {
+		wds, err := s.GetWebhookDeliveries(wh.ID, db.WebhookDeliveryDelivered, 0, 100)
+		return err == nil && len(wds) == len(want)
+	})
+	// the failing webhook receives block and reorg events, the deliveries are scheduled for retry
+	waitForWebhooks(t, "retry of failing deliveries", func() bool {
+		wds, err := s.GetWebhookDeliveries(fwh.ID, db.WebhookDeliveryPending, 0, 100)
+		if err != nil || len(wds) != 4 {
+			return false
+		}
+		for _, wd := range wds {
+			if wd.Attempts != 1 || wd.ResponseStatus != http.StatusInternalServerError || wd.NextAttempt < wd.LastAttempt+10 || !strings.HasPrefix(wd.Error, "Response status 500") {
+				return false
+			}
+		}
+		return true
+	})
+
+	found, err := s.DeleteWebhook(wh.ID)
+	if err != nil || !found {
+		t.Fatalf("DeleteWebhook() = %v, %v, want true", found, err)
+	}
+	if s.GetWebhook(wh.ID) != nil {
+		t.Error("GetWebhook() of deleted webhook is not nil")
+	}
+	if wds, err := s.GetWebhookDeliveries(wh.ID, "", 0, 100); err != nil || len(wds) != 0 {
+		t.Errorf("GetWebhookDeliveries() of deleted webhook = %v, %v", len(wds), err)
+	}
+	if found, err = s.DeleteWebhook(wh.ID); err != nil || found {
+		t.Errorf("DeleteWebhook() of deleted webhook = %v, %v, want false", found, err)
+	}
+
+	// a new dispatcher loads the remaining webhook and resumes its pending deliveries
+	s2, err := NewWebhookDispatcher(s.db, s.chain, nil, s.metrics, &common.InternalState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s2.webhooks) != 1 || s2.webhooks[fwh.ID] == nil || len(s2.pending) != 4 || s2.seq < s.seq {
+		t.Errorf("NewWebhookDispatcher() loaded %d webhooks and %d pending deliveries, want 1 and 4", len(s2.webhooks), len(s2.pending))
+	}
+}
+
+func Test_InternalServer_webhooks(t *testing.T) {
+	s, is, path := setupWebhookDispatcher(t)
+	defer closeAndDestroyWebhookDispatcher(t, s, path)
+	// s.Run is never called, binding can be to any port
+	ins, err := NewInternalServer("localhost:12345", "", "", s.db, s.chain, nil, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(ins.https.Handler)
+	defer ts.Close()
+
+	do := func(method, u, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+u, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	if status, _ := do("GET", "/webhooks", ""); status != http.StatusNotFound {
+		t.Errorf("GET /webhooks of disabled webhooks = %v, want %v", status, http.StatusNotFound)
+	}
+	ins.SetWebhookDispatcher(s)
+
+	if status, body := do("POST", "/webhooks", `{"url":"http://localhost/hook","addresses":["!invalid"]}`); status != http.StatusBadRequest || !strings.HasPrefix(body, "Invalid address !invalid") {
+		t.Errorf("POST /webhooks invalid address = %v %v", status, body)
+	}
+	status, body := do("POST", "/webhooks", `{"url":"http://localhost/hook","secret":"abc","addresses":["`+dbtestdata.Addr5+`"],"confirmations":6}`)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /webhooks = %v %v", status, body)
+	}
+	var wh db.Webhook
+	if err := json.Unmarshal([]byte(body), &wh); err != nil {
+		t.Fatal(err)
+	}
+	want := db.Webhook{ID: wh.ID, URL: "http://localhost/hook", Secret: "abc", Addresses: []string{dbtestdata.Addr5}, Confirmations: 6, Created: wh.Created}
+	if !reflect.DeepEqual(wh, want) {
+		t.Errorf("POST /webhooks = %+v, want %+v", wh, want)
+	}
+
+	want.Secret = ""
+	tests := []struct {
+		method string
+		url    string
+		status int
+		want   interface{}
+	}{
+		{method: "GET", url: "/webhooks", status: http.StatusOK, want: []db.Webhook{want}},
+		{method: "GET", url: "/webhooks/" + wh.ID, status: http.StatusOK, want: want},
+		{method: "GET", url: "/webhooks/" + wh.ID + "/deliveries", status: http.StatusOK, want: []db.WebhookDelivery{}},
+		{method: "GET", url: "/webhooks/" + wh.ID + "/deliveries?status=unknown", status: http.StatusBadRequest},
+		{method: "GET", url: "/webhooks/" + wh.ID + "/deliveries?limit=0", status: http.StatusBadRequest},
+		{method: "PUT", url: "/webhooks", status: http.StatusMethodNotAllowed},
+		{method: "DELETE", url: "/webhooks/" + wh.ID, status: http.StatusNoContent},
+		{method: "GET", url: "/webhooks/" + wh.ID, status: http.StatusNotFound},
+		{method: "GET", url: "/webhooks", status: http.StatusOK, want: []db.Webhook{}},
+	}
+	for _, tt := range tests {
+		status, body := do(tt.method, tt.url, "")
+		if status != tt.status {
+			t.Errorf("%v %v = %v %v, want %v", tt.method, tt.url, status, body, tt.status)
+			continue
+		}
+		if tt.want != nil {
+			b, err := json.MarshalIndent(tt.want, "", "    ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != string(b) {
+				t.Errorf("%v %v = %v, want %v", tt.method, tt.url, body, string(b))
+			}
+		}
+	}
+}
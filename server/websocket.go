@@ -47,6 +47,7 @@ type websocketChannel struct {
 	out           chan *websocketRes
 	ip            string
 	requestHeader http.Header
+	client        rateLimitClient
 	alive         bool
 	aliveLock     sync.Mutex
 }
@@ -55,7 +56,7 @@ type websocketChannel struct {
 type WebsocketServer struct {
 	socket                    *websocket.Conn
 	upgrader                  *websocket.Upgrader
-	db                        *db.RocksDB
+	db                        db.IndexStore
 	txCache                   *db.TxCache
 	chain                     bchain.BlockChain
 	chainParser               bchain.BlockChainParser
@@ -67,10 +68,11 @@ type WebsocketServer struct {
 	newBlockSubscriptionsLock sync.Mutex
 	addressSubscriptions      map[string]map[*websocketChannel]string
 	addressSubscriptionsLock  sync.Mutex
+	rateLimiter               *RateLimiter
 }
 
 // NewWebsocketServer creates new websocket interface to blockbook and returns its handle
-func NewWebsocketServer(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*WebsocketServer, error) {
+func NewWebsocketServer(db db.IndexStore, chain bchain.BlockChain, txCache *db.TxCache, metrics *common.Metrics, is *common.InternalState) (*WebsocketServer, error) {
 	api, err := api.NewWorker(db, chain, txCache, is)
 	if err != nil {
 		return nil, err
@@ -121,6 +123,7 @@ func (s *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		out:           make(chan *websocketRes, outChannelSize),
 		ip:            r.RemoteAddr,
 		requestHeader: r.Header,
+		client:        s.rateLimiter.getClient(r.RemoteAddr, r.Header, r.URL.Query()),
 		alive:         true,
 	}
 	go s.inputLoop(c)
@@ -277,6 +280,12 @@ func (s *WebsocketServer) onRequest(c *websocketChannel, req *websocketReq) {
 			}
 		}
 	}()
+	if ok, _ := s.rateLimiter.allow("websocket", c.client); !ok {
+		e := resultError{}
+		e.Error.Message = "Too many requests"
+		data = e
+		return
+	}
 	t := time.Now()
 	defer s.metrics.WebsocketReqDuration.With(common.Labels{"method": req.Method}).Observe(float64(time.Since(t)) / 1e3) // in microseconds
 	f, ok := requestHandlers[req.Method]
@@ -305,6 +314,7 @@ type accountInfoReq struct {
 	FromHeight     int    `json:"from"`
 	ToHeight       int    `json:"to"`
 	ContractFilter string `json:"contractFilter"`
+	Cursor         string `json:"cursor"`
 }
 
 func unmarshalGetAccountInfoRequest(params []byte) (*accountInfoReq, error) {
@@ -329,12 +339,18 @@ func (s *WebsocketServer) getAccountInfo(req *accountInfoReq) (res *api.Address,
 		opt = api.Basic
 	}
 
-	return s.api.GetAddress(req.Descriptor, req.Page, req.PageSize, opt, &api.AddressFilter{
+	filter := &api.AddressFilter{
 		FromHeight: uint32(req.FromHeight),
 		ToHeight:   uint32(req.ToHeight),
 		Contract:   req.ContractFilter,
 		Vout:       api.AddressFilterVoutOff,
-	})
+	}
+	if req.Cursor != "" {
+		if filter.Cursor, err = api.ParseTxCursor(req.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	return s.api.GetAddress(req.Descriptor, req.Page, req.PageSize, opt, filter)
 }
 
 func (s *WebsocketServer) getInfo() (interface{}, error) {
@@ -370,10 +386,21 @@ func (s *WebsocketServer) estimateFee(c *websocketChannel, params []byte) (inter
 		Blocks   []int                  `json:"blocks"`
 		Specific map[string]interface{} `json:"specific"`
 	}
+	type eip1559Fee struct {
+		MaxFeePerGas         string `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	}
+	type eip1559Fees struct {
+		BaseFeePerGas string      `json:"baseFeePerGas"`
+		Low           *eip1559Fee `json:"low"`
+		Medium        *eip1559Fee `json:"medium"`
+		High          *eip1559Fee `json:"high"`
+	}
 	type estimateFeeRes struct {
-		FeePerTx   string `json:"feePerTx,omitempty"`
-		FeePerUnit string `json:"feePerUnit,omitempty"`
-		FeeLimit   string `json:"feeLimit,omitempty"`
+		FeePerTx   string       `json:"feePerTx,omitempty"`
+		FeePerUnit string       `json:"feePerUnit,omitempty"`
+		FeeLimit   string       `json:"feeLimit,omitempty"`
+		Eip1559    *eip1559Fees `json:"eip1559,omitempty"`
 	}
 	var r estimateFeeReq
 	err := json.Unmarshal(params, &r)
@@ -387,6 +414,25 @@ func (s *WebsocketServer) estimateFee(c *websocketChannel, params []byte) (inter
 			return nil, err
 		}
 		sg := strconv.FormatUint(gas, 10)
+		// EIP-1559 fees are not available before the London hard fork or on chains without EIP-1559
+		var ef *eip1559Fees
+		fees, err := s.chain.EthereumTypeGetEip1559Fees()
+		if err == nil {
+			toFee := func(f *bchain.Eip1559Fee) *eip1559Fee {
+				return &eip1559Fee{
+					MaxFeePerGas:         f.MaxFeePerGas.String(),
+					MaxPriorityFeePerGas: f.MaxPriorityFeePerGas.String(),
+				}
+			}
+			ef = &eip1559Fees{
+				BaseFeePerGas: fees.BaseFeePerGas.String(),
+				Low:           toFee(fees.Low),
+				Medium:        toFee(fees.Medium),
+				High:          toFee(fees.High),
+			}
+		} else {
+			glog.V(1).Info("EthereumTypeGetEip1559Fees: ", err)
+		}
 		for i, b := range r.Blocks {
 			fee, err := s.chain.EstimateSmartFee(b, true)
 			if err != nil {
@@ -396,6 +442,7 @@ func (s *WebsocketServer) estimateFee(c *websocketChannel, params []byte) (inter
 			res[i].FeeLimit = sg
 			fee.Mul(&fee, new(big.Int).SetUint64(gas))
 			res[i].FeePerTx = fee.String()
+			res[i].Eip1559 = ef
 		}
 	} else {
 		conservative := true